@@ -0,0 +1,255 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/age_crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ageSuffix is the file name extension of the age files.
+const ageSuffix = ".age"
+
+var (
+	ErrFormatRequired = errors.New("the format of the files must be given, only age is supported (--age)")
+)
+
+// ExportAge encrypts the file at the specified path of the repository as an age file for the recipients,
+// written to the local file dst, or to w if dst is "-". A directory is exported if recursive is true,
+// to the local directory dst with an age file per file. The recipients are age recipients ("age1..."),
+// public keys, or names or emails of contacts of the address book.
+// It returns the repository paths of the exported files.
+func (a *App) ExportAge(encryptedPrivateKey string, src string, recipients []string, dst string, recursive bool, w io.Writer) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	publicKeys := make([]core.PublicKey, 0, len(recipients))
+	for _, recipient := range recipients {
+		publicKey, err := a.resolveAgeRecipient(recipient)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	if len(publicKeys) == 0 {
+		return core.NewAppResultWithError(age_crypto.ErrNoRecipient)
+	}
+	src = repoPath(src)
+	info, err := a.fileSystem.Stat(src)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	exported := make([]string, 0)
+	if !info.IsDir() {
+		if dst == "-" {
+			err = a.exportAgeFile(src, w, publicKeys)
+		} else {
+			err = a.exportAgeLocalFile(src, dst, publicKeys)
+		}
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(append(exported, src))
+	}
+	if !recursive {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsDir, src))
+	}
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	err = a.walkFiles(src, true, func(p string, info os.FileInfo) error {
+		local := filepath.Join(dst, filepath.FromSlash(strings.TrimPrefix(p, src)))
+		if info.IsDir() {
+			return os.MkdirAll(local, os.ModePerm)
+		}
+		if err := a.exportAgeLocalFile(p, local+ageSuffix, publicKeys); err != nil {
+			return err
+		}
+		exported = append(exported, p)
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(exported)
+}
+
+// ImportAge decrypts the local age file src with the identity into the specified path dst of the repository.
+// A directory is imported if recursive is true: its age files are imported without the ".age" extension,
+// and the other files are skipped. The identity is an age identity ("AGE-SECRET-KEY-1..."),
+// or the local path of an age identity file. If it is empty, the private key of the user is used.
+// It returns the repository paths of the imported files.
+func (a *App) ImportAge(encryptedPrivateKey string, src string, dst string, identity string, recursive bool) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	identities, err := parseAgeIdentities(encryptedPrivateKey, identity)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	dst = repoPath(dst)
+	info, err := os.Stat(src)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	imported := make([]string, 0)
+	if !info.IsDir() {
+		// Importing into an existing directory keeps the name, without the extension
+		if info, err := a.fileSystem.Stat(dst); err == nil && info.IsDir() {
+			dst = path.Join(dst, strings.TrimSuffix(filepath.Base(src), ageSuffix))
+		}
+		if err := a.importAgeFile(src, dst, identities); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(append(imported, dst))
+	}
+	if !recursive {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsDir, src))
+	}
+	err = filepath.Walk(src, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, local)
+		if err != nil {
+			return err
+		}
+		p := path.Join(dst, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return a.makeDir(p)
+		}
+		if !strings.HasSuffix(p, ageSuffix) {
+			return nil
+		}
+		p = strings.TrimSuffix(p, ageSuffix)
+		if err := a.importAgeFile(local, p, identities); err != nil {
+			return err
+		}
+		imported = append(imported, p)
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(imported)
+}
+
+// ConvertAgeKey converts an age recipient to a public key and an age identity to a private key,
+// or a public key to an age recipient, or a private key to an age identity if private is true.
+func (a *App) ConvertAgeKey(key string, private bool) core.AppResult {
+	switch {
+	case strings.HasPrefix(key, "age1"):
+		publicKey, err := age_crypto.ParseRecipient(key)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(publicKey.String())
+	case strings.HasPrefix(key, "AGE-SECRET-KEY-1"):
+		privateKey, err := age_crypto.ParseIdentity(key)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(privateKey.Unsafe().String())
+	case private:
+		privateKey, err := core.NewPrivateKeyFromEncoded(key)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		identity, err := age_crypto.EncodeIdentity(privateKey)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(identity)
+	default:
+		publicKey, err := core.NewPublicKeyFromEncoded(key)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		recipient, err := age_crypto.EncodeRecipient(publicKey)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(recipient)
+	}
+}
+
+// resolveAgeRecipient returns the public key of the recipient given by age recipient, public key or contact.
+func (a *App) resolveAgeRecipient(recipient string) (core.PublicKey, error) {
+	if strings.HasPrefix(recipient, "age1") {
+		return age_crypto.ParseRecipient(recipient)
+	}
+	publicKey, err := a.contactService.Resolve(recipient)
+	if err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	return core.NewPublicKeyFromEncoded(publicKey)
+}
+
+// parseAgeIdentities returns the private keys of the age identity or identity file,
+// or the private key of the user if identity is empty.
+func parseAgeIdentities(encryptedPrivateKey string, identity string) ([]core.PrivateKey, error) {
+	if identity == "" {
+		privateKey, err := core.NewPrivateKeyFromEncoded(encryptedPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return []core.PrivateKey{privateKey}, nil
+	}
+	if strings.HasPrefix(identity, "AGE-SECRET-KEY-1") {
+		privateKey, err := age_crypto.ParseIdentity(identity)
+		if err != nil {
+			return nil, err
+		}
+		return []core.PrivateKey{privateKey}, nil
+	}
+	file, err := os.Open(identity)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return age_crypto.ParseIdentities(file)
+}
+
+// exportAgeFile encrypts the file at the specified path of the repository as an age file for the recipients, written to w.
+func (a *App) exportAgeFile(p string, w io.Writer, recipients []core.PublicKey) error {
+	ageWriter, err := age_crypto.NewWriter(w, recipients...)
+	if err != nil {
+		return err
+	}
+	if err := a.readFile(p, ageWriter); err != nil {
+		return err
+	}
+	return ageWriter.Close()
+}
+
+// exportAgeLocalFile encrypts the file at the specified path of the repository as the local age file dst.
+func (a *App) exportAgeLocalFile(p string, dst string, recipients []core.PublicKey) error {
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := a.exportAgeFile(p, file, recipients); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// importAgeFile decrypts the local age file src with the identities into the file at the specified path of the repository.
+func (a *App) importAgeFile(src string, p string, identities []core.PrivateKey) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := age_crypto.NewReader(file, identities...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	return a.writeFile(p, r)
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+)
+
+// Algorithm returns the AEAD algorithm the new files of the repository are encrypted with, and the supported algorithms.
+// If alg is not empty, the algorithm of the repository is set first. The files already encrypted keep their algorithm,
+// which is stored in their header, and are read with it.
+func (a *App) Algorithm(encryptedPrivateKey string, alg string) core.AppResult {
+	if alg != "" && !file_crypto.IsSupportedAlg(alg) {
+		return core.NewAppResultWithError(file_crypto.ErrUnsupportedAlg)
+	}
+	if initRes := a.initWithPrivateKey(encryptedPrivateKey); !initRes.Ok {
+		return initRes
+	}
+	if alg != "" {
+		if err := a.configService.SetAlgorithm(alg); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	current := a.configService.GetAlgorithm()
+	if current == "" {
+		current = file_crypto.DefaultAlg
+	}
+	return core.NewAppResultWithValue(core.AlgorithmReport{
+		Algorithm: current,
+		Supported: file_crypto.SupportedAlgs(),
+	})
+}
@@ -3,13 +3,21 @@ package app
 import (
 	"ctb-cli/config"
 	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
 	"ctb-cli/fuse"
-	"ctb-cli/objectstorage/cloud"
-	"ctb-cli/repositories"
+	"ctb-cli/services"
+	"ctb-cli/services/audit_service"
+	"ctb-cli/services/capsule_service"
 	"ctb-cli/services/config_service"
+	"ctb-cli/services/contact_service"
+	"ctb-cli/services/expiry_service"
 	"ctb-cli/services/filesystem_service"
-	"ctb-cli/services/key_service"
-	"ctb-cli/services/object_service"
+	"ctb-cli/services/fsck_service"
+	"ctb-cli/services/group_service"
+	"ctb-cli/services/invite_service"
+	"ctb-cli/services/member_service"
+	"ctb-cli/services/offboard_service"
+	"ctb-cli/services/password_service"
 	"ctb-cli/services/share_service"
 	"errors"
 	"os"
@@ -18,10 +26,24 @@ import (
 
 // App represents the main application struct.
 type App struct {
-	keyStore      core.KeyService
-	fileSystem    *filesystem_service.FileSystem
-	shareService  *share_service.Service
-	configService *config_service.ConfigService
+	keyStore        core.KeyService
+	fileSystem      *filesystem_service.FileSystem
+	shareService    *share_service.Service
+	configService   *config_service.ConfigService
+	fsckService     *fsck_service.Service
+	auditService    *audit_service.Service
+	memberService   *member_service.Service
+	contactService  *contact_service.Service
+	groupService    *group_service.Service
+	offboardService *offboard_service.Service
+	expiryService   *expiry_service.Service
+	inviteService   *invite_service.Service
+	passwordService *password_service.Service
+	capsuleService  *capsule_service.Service
+
+	// password is the passphrase of a password recipient unlocking the commands reading the repository
+	// instead of a private key, empty if the private key is used
+	password string
 
 	// fuse is the fuse service used by the application
 	fuse *fuse.CtbFs
@@ -37,6 +59,7 @@ var (
 	ErrRootFolderNotEmpty        = errors.New("root folder is not empty")
 	ErrCreatingRepositoryConfig  = errors.New("error creating repository config")
 	ErrInitRepositoryFolders     = errors.New("error initializing repository folders")
+	ErrHybridKeyRequired         = errors.New("a hybrid private key is required to init a repository requiring hybrid keys")
 )
 
 // New returns a new App
@@ -47,26 +70,30 @@ func New(cfg config.Config) App {
 }
 
 func (a *App) initServices() core.AppResult {
-	cloudClient := cloud.NewClient("http://localhost:1323", 10*1024*1024)
-	//cloudClient := objectstorage.NewDummyClient()
-
 	// Get the root paths
 	root, _ := a.cfg.GetRepoCtbRoot()
 	cachePath, _ := a.cfg.GetCacheRoot()
-
-	// Create the repositories
-	keyRepository := repositories.NewKeyRepositoryFile(root)
-	objectCacheRepository := repositories.NewObjectCacheRepository(cachePath)
-	objectRepository := repositories.NewObjectRepository(root)
-	linkRepository := repositories.NewLinkRepository(root)
-	vaultRepository := repositories.NewVaultRepositoryFile(root)
+	statePath, err := a.cfg.GetStateRoot()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
 
 	// Create the services
-	a.keyStore = key_service.NewKeyStore(keyRepository, vaultRepository)
-	objectService := object_service.NewService(&objectCacheRepository, &objectRepository, cloudClient)
-	a.shareService = share_service.NewService(a.keyStore, linkRepository, vaultRepository, &objectService)
-	a.configService = config_service.New(root)
-	a.fileSystem = filesystem_service.NewFileSystem(a.keyStore, objectService, linkRepository, vaultRepository, *a.configService)
+	s := services.New(root, cachePath, statePath)
+	a.keyStore = s.KeyStore
+	a.configService = s.Config
+	a.fileSystem = s.FileSystem
+	a.shareService = s.Share
+	a.fsckService = s.Fsck
+	a.auditService = s.Audit
+	a.memberService = s.Member
+	a.contactService = s.Contact
+	a.groupService = s.Group
+	a.offboardService = s.Offboard
+	a.expiryService = s.Expiry
+	a.inviteService = s.Invite
+	a.passwordService = s.Password
+	a.capsuleService = s.Capsule
 
 	return core.NewAppResult()
 }
@@ -86,6 +113,21 @@ func (a *App) SetPrivateKey(encodedPrivateKey string) core.AppResult {
 	return core.NewAppResult()
 }
 
+// SetPassword sets the passphrase of a password recipient, used instead of the private key
+// by the commands reading the repository: ls, cat, get and mount, which is then read-only.
+func (a *App) SetPassword(passphrase string) {
+	a.password = passphrase
+}
+
+// unlockWithPassword sets the private key of the password recipient of the passphrase.
+// Password recipients are not members of the repository, so the membership is not checked.
+func (a *App) unlockWithPassword() core.AppResult {
+	if _, err := a.passwordService.Unlock(a.password); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
 // SetAndCheckPrivateKey sets the private key and checks its validity.
 // It takes an encodedPrivateKey as input and returns an AppResult indicating the success or failure of the operation.
 func (a *App) SetAndCheckPrivateKey(encodedPrivateKey string) core.AppResult {
@@ -95,18 +137,40 @@ func (a *App) SetAndCheckPrivateKey(encodedPrivateKey string) core.AppResult {
 		return setResult
 	}
 	// Check the private key
-	res := a.keyStore.IsUserJoined()
-	if !res {
+	if !a.isUserJoined() {
 		return core.NewAppResultWithError(ErrPrivateKeyCheckFailed)
 	}
 	return core.NewAppResult()
 }
 
+// isUserJoined returns true if the user of the private key is a member of the repository.
+func (a *App) isUserJoined() bool {
+	userId, err := a.keyStore.GetUserId()
+	if err != nil {
+		return false
+	}
+	return a.memberService.IsMember(userId)
+}
+
 // InitRepo initializes the repository by creating the necessary folders, setting the private key,
 // and joining the user. It also creates a vault in the root path.
 // The encryptedPrivateKey parameter is the encrypted private key used for authentication.
+// The user is registered as the first owner of the repository under the given display name.
+// The new files are encrypted with the given AEAD algorithm, or with the default algorithm if alg is empty.
+// If encryptNames is true, the names of the files and directories are stored encrypted.
+// If requireHybrid is true, the data keys can only be sealed with both X25519 and ML-KEM-768,
+// so the user must have a hybrid key and the groups, password recipients, invitations and capsules are not available.
 // It returns an AppResult indicating the success or failure of the initialization.
-func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
+func (a *App) InitRepo(encryptedPrivateKey string, name string, alg string, encryptNames bool, requireHybrid bool) core.AppResult {
+	if alg != "" && !file_crypto.IsSupportedAlg(alg) {
+		return core.NewAppResultWithError(file_crypto.ErrUnsupportedAlg)
+	}
+	if requireHybrid {
+		if privateKey, err := core.NewPrivateKeyFromEncoded(encryptedPrivateKey); err != nil || !privateKey.IsHybrid() {
+			return core.NewAppResultWithError(ErrHybridKeyRequired)
+		}
+	}
+
 	// Get the root and temp paths
 	root, _ := a.cfg.GetRepoCtbRoot()
 
@@ -139,6 +203,11 @@ func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
 	if err != nil {
 		return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
 	}
+	if alg != "" {
+		if err := a.configService.SetAlgorithm(alg); err != nil {
+			return core.NewAppResultWithError(ErrCreatingRepositoryConfig)
+		}
+	}
 
 	// Set the private key
 	setResult := a.SetPrivateKey(encryptedPrivateKey)
@@ -146,6 +215,12 @@ func (a *App) InitRepo(encryptedPrivateKey string) core.AppResult {
 		return setResult
 	}
 
+	// Register the user as the first owner, who signs the policy the keys of the root vault are sealed with
+	policy := core.RepoPolicy{RequireHybrid: requireHybrid, EncryptNames: encryptNames}
+	if err := a.memberService.InitRegistry(name, policy); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+
 	// Create a vault in the root path
 	if err := a.fileSystem.CreateVaultInPath("/"); err != nil {
 		return core.NewAppResultWithError(err)
@@ -0,0 +1,32 @@
+package app
+
+import "ctb-cli/core"
+
+// ListAudit returns the entries of the audit log of the repository.
+func (a *App) ListAudit() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	entries, err := a.auditService.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(entries)
+}
+
+// VerifyAudit checks the hash chain, the signatures and the head of the audit log
+// and returns the report of the problems found.
+func (a *App) VerifyAudit() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	report, err := a.auditService.Verify()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
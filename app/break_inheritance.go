@@ -0,0 +1,44 @@
+package app
+
+import "ctb-cli/core"
+
+// BreakInheritance makes the directory at the specified path reachable only by the given recipients,
+// each a public key, the name or email of a contact of the address book, or a group name prefixed by "@".
+// The users reaching the directory through its parent directories lose access, and so do the other recipients of the directory.
+// The user of the private key keeps access. If dryRun is true, the changes are only reported.
+func (a *App) BreakInheritance(encryptedPrivateKey string, path string, recipients []string, dryRun bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	publicKeys := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		publicKey, err := a.resolveRecipient(recipient)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	report, err := a.shareService.BreakInheritance(path, publicKeys, dryRun)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	for _, list := range []core.KeyAccessList{report.Recipients, report.LostAccess} {
+		if err := a.contactService.NameAccessList(list); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		if err := a.groupService.NameAccessList(list); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		if err := a.passwordService.NameAccessList(list); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	return core.NewAppResultWithValue(report)
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/capsule"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrUnsafeCapsulePath = errors.New("the capsule holds a file outside of the destination directory")
+)
+
+// ExportCapsule writes a capsule holding the file or directory tree at the specified path of the repository,
+// sealed to the recipient, to the local file output, or to w if output is "-".
+// The recipient is a public key or the name or email of a contact of the address book.
+func (a *App) ExportCapsule(encryptedPrivateKey string, path string, recipient string, output string, w io.Writer) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	publicKey, err := a.contactService.Resolve(recipient)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if output == "-" {
+		report, err := a.capsuleService.Export(path, publicKey, w)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(report)
+	}
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathExists, output))
+	}
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	report, err := a.capsuleService.Export(path, publicKey, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
+
+// OpenCapsule decrypts the files of the capsule read from the local file input, or from r if input is "-",
+// into the local directory dst. The capsule must be sealed to the user of the private key,
+// who does not need to be a member of the repository. Existing files are not overwritten.
+func (a *App) OpenCapsule(encryptedPrivateKey string, input string, r io.Reader, dst string) core.AppResult {
+	privateKey, err := core.NewPrivateKeyFromEncoded(encryptedPrivateKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		defer file.Close()
+		r = file
+	}
+	reader, err := capsule.Open(r, privateKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	report := core.CapsuleReport{Path: dst, Recipient: reader.Manifest().Recipient, Files: make([]string, 0)}
+	for {
+		file, content, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		local, err := capsuleLocalPath(dst, file.Path)
+		if err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		if err := writeLocalFile(local, content); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		report.Files = append(report.Files, file.Path)
+	}
+	return core.NewAppResultWithValue(report)
+}
+
+// capsuleLocalPath returns the local path of a file of a capsule opened in the directory dst.
+// The paths of a capsule are not authenticated, so the paths that are not local, leaving the directory, are rejected.
+func capsuleLocalPath(dst string, p string) (string, error) {
+	rel := filepath.FromSlash(p)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafeCapsulePath, p)
+	}
+	return filepath.Join(dst, rel), nil
+}
+
+// writeLocalFile writes the content to a new local file, creating the missing parent directories.
+func writeLocalFile(local string, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(local), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s", ErrPathExists, local)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(local)
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bytes"
+	"ctb-cli/config"
+	"ctb-cli/core"
+	"ctb-cli/crypto/capsule"
+	"ctb-cli/crypto/file_crypto"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newCapsule returns a capsule holding the files, sealed to a new user, and the encoded private key of the user.
+func newCapsule(t *testing.T, files map[string]string) (*bytes.Buffer, string) {
+	var privateKey core.PrivateKey
+	for {
+		key, err := core.NewPrivateKeyFromRand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key.Unsafe().String()) == 44 {
+			privateKey = key
+			break
+		}
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]capsule.Entry, 0, len(files))
+	for p, content := range files {
+		key := core.NewKeyInfo(p+"-key", core.NewKeyFromRand())
+		object := bytes.NewBuffer(nil)
+		writer, err := file_crypto.NewWriter(object, &key, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, capsule.Entry{
+			Path:       p,
+			Key:        &key,
+			Size:       int64(len(content)),
+			ObjectSize: int64(object.Len()),
+			Open: func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(object.Bytes())), nil
+			},
+		})
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := capsule.Write(buf, publicKey, entries); err != nil {
+		t.Fatal(err)
+	}
+	return buf, privateKey.Unsafe().String()
+}
+
+func TestOpenCapsuleInWorkingDirectory(t *testing.T) {
+	files := map[string]string{"a.txt": "a", "docs/b.txt": "b"}
+	buf, encodedKey := newCapsule(t, files)
+	t.Chdir(t.TempDir())
+
+	a := New(config.Config{})
+	if res := a.OpenCapsule(encodedKey, "-", buf, "."); !res.Ok {
+		t.Fatal(res.Err)
+	}
+	for p, content := range files {
+		got, err := os.ReadFile(filepath.FromSlash(p))
+		if err != nil || string(got) != content {
+			t.Errorf("got %q, %v for %s, want %q", got, err, p, content)
+		}
+	}
+}
+
+func TestOpenCapsuleRejectsUnsafePaths(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "dst")
+	for _, p := range []string{"../escape.txt", "/abs.txt", "docs/../../escape.txt"} {
+		buf, encodedKey := newCapsule(t, map[string]string{p: "x"})
+		a := New(config.Config{})
+		if res := a.OpenCapsule(encodedKey, "-", buf, dst); res.Ok || !errors.Is(res.Err, ErrUnsafeCapsulePath) {
+			t.Errorf("got %v for %s, want ErrUnsafeCapsulePath", res.Err, p)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/services/config_service"
+	"fmt"
+)
+
+// CompressionInherit is the compression given to a directory to inherit the compression of its parent directory.
+const CompressionInherit = "inherit"
+
+// Compression returns the compression the new files of the directory at the specified path are compressed with
+// before encryption. If compression is not empty, the compression of the directory is set first: "zstd", "none",
+// or "inherit" to use the compression of the parent directory. The files already encrypted keep their compression,
+// which is stored in their header, and the files whose content is already compressed are not compressed again.
+func (a *App) Compression(encryptedPrivateKey string, p string, compression string) core.AppResult {
+	if compression != "" && compression != CompressionInherit && compression != config_service.CompressionNone &&
+		!file_crypto.IsSupportedCompression(compression) {
+		return core.NewAppResultWithError(file_crypto.ErrUnsupportedCompression)
+	}
+	if initRes := a.initWithPrivateKey(encryptedPrivateKey); !initRes.Ok {
+		return initRes
+	}
+	p = repoPath(p)
+	info, err := a.fileSystem.Stat(p)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if !info.IsDir() {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsNotDir, p))
+	}
+	if compression != "" {
+		if compression == CompressionInherit {
+			compression = ""
+		}
+		if err := a.configService.SetDirCompression(p, compression); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	current := a.configService.GetCompression(p)
+	if current == "" {
+		current = config_service.CompressionNone
+	}
+	return core.NewAppResultWithValue(core.CompressionReport{
+		Path:        p,
+		Compression: current,
+		Inherited:   a.configService.GetDirCompression(p) == "",
+	})
+}
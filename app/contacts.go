@@ -0,0 +1,56 @@
+package app
+
+import "ctb-cli/core"
+
+// AddContact adds a contact with the given name and optional email for the public key to the address book.
+// If the public key is already in the address book, its contact is replaced only if replace is true.
+// The private key is needed to sign the contact.
+func (a *App) AddContact(encryptedPrivateKey string, name string, email string, publicKey string, replace bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	contact, err := a.contactService.Add(name, email, publicKey, replace)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(contact)
+}
+
+// RemoveContact removes the contact with the given name, email or public key from the address book.
+func (a *App) RemoveContact(encryptedPrivateKey string, alias string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.contactService.Remove(alias); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// ListContacts returns the contacts of the address book.
+func (a *App) ListContacts() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	contacts, err := a.contactService.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(contacts)
+}
@@ -0,0 +1,24 @@
+package app
+
+import "ctb-cli/core"
+
+// Expire removes the expired shares of the repository and replaces the keys of the vaults their recipients could reach.
+// The shares reaching vaults the user of the private key has no access to are kept and reported as skipped.
+// If dryRun is true, the changes are only reported.
+func (a *App) Expire(encryptedPrivateKey string, dryRun bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	report, err := a.expiryService.Expire(dryRun)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
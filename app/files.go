@@ -0,0 +1,438 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrPathIsDir       = errors.New("path is a directory")
+	ErrPathIsNotDir    = errors.New("path is not a directory")
+	ErrPathExists      = errors.New("path already exists")
+	ErrDirNotEmpty     = errors.New("directory is not empty")
+	ErrRemovingRootDir = errors.New("cannot remove the root directory")
+)
+
+// fileBufferSize is the size of the buffer used to copy files to and from the repository.
+const fileBufferSize = 64 * 1024
+
+// ListFiles lists the files and directories at the specified path of the repository.
+// If the path is a file, the list only contains the file.
+// If recursive is true, the sub directories are listed as well.
+func (a *App) ListFiles(encryptedPrivateKey string, p string, recursive bool) core.AppResult {
+	if res := a.initForReading(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	p = repoPath(p)
+	info, err := a.fileSystem.Stat(p)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if !info.IsDir() {
+		return core.NewAppResultWithValue(core.FileList{newFileEntry(p, info)})
+	}
+	list := make(core.FileList, 0)
+	err = a.walkFiles(p, recursive, func(p string, info os.FileInfo) error {
+		list = append(list, newFileEntry(p, info))
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(list)
+}
+
+// ReadFile decrypts the file at the specified path of the repository and writes its content to w.
+func (a *App) ReadFile(encryptedPrivateKey string, p string, w io.Writer) core.AppResult {
+	if res := a.initForReading(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	if err := a.readFile(repoPath(p), w); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// WriteFile encrypts the content read from r into the file at the specified path of the repository.
+// The file is created if it does not exist, and replaced if it does.
+func (a *App) WriteFile(encryptedPrivateKey string, p string, r io.Reader) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	if err := a.writeFile(repoPath(p), r); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// GetFiles decrypts the file or directory tree at the specified path of the repository to the local path dst.
+// Directories are only copied if recursive is true.
+// It returns the repository paths of the copied files.
+func (a *App) GetFiles(encryptedPrivateKey string, src string, dst string, recursive bool) core.AppResult {
+	if res := a.initForReading(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	src = repoPath(src)
+	info, err := a.fileSystem.Stat(src)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	copied := make([]string, 0)
+	if !info.IsDir() {
+		if err := a.getFile(src, dst); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(append(copied, src))
+	}
+	if !recursive {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsDir, src))
+	}
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	err = a.walkFiles(src, true, func(p string, info os.FileInfo) error {
+		local := filepath.Join(dst, filepath.FromSlash(strings.TrimPrefix(p, src)))
+		if info.IsDir() {
+			return os.MkdirAll(local, os.ModePerm)
+		}
+		if err := a.getFile(p, local); err != nil {
+			return err
+		}
+		copied = append(copied, p)
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(copied)
+}
+
+// PutFiles encrypts the local file or directory tree at src into the specified path dst of the repository.
+// Directories are only copied if recursive is true.
+// It returns the repository paths of the copied files.
+func (a *App) PutFiles(encryptedPrivateKey string, src string, dst string, recursive bool) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	dst = repoPath(dst)
+	info, err := os.Stat(src)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	copied := make([]string, 0)
+	if !info.IsDir() {
+		if err := a.putFile(src, dst); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResultWithValue(append(copied, dst))
+	}
+	if !recursive {
+		return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsDir, src))
+	}
+	err = filepath.Walk(src, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, local)
+		if err != nil {
+			return err
+		}
+		p := path.Join(dst, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return a.makeDir(p)
+		}
+		if err := a.putFile(local, p); err != nil {
+			return err
+		}
+		copied = append(copied, p)
+		return nil
+	})
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(copied)
+}
+
+// MoveFile moves the file or directory at oldPath of the repository to newPath.
+func (a *App) MoveFile(encryptedPrivateKey string, oldPath string, newPath string) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	oldPath, newPath = repoPath(oldPath), repoPath(newPath)
+	if _, err := a.fileSystem.Stat(oldPath); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	// Moving into an existing directory keeps the name, like mv
+	if info, err := a.fileSystem.Stat(newPath); err == nil {
+		if !info.IsDir() {
+			return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathExists, newPath))
+		}
+		newPath = path.Join(newPath, path.Base(oldPath))
+	}
+	if err := a.fileSystem.Rename(oldPath, newPath); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// RemoveFile removes the file or directory at the specified path of the repository.
+// Directories are only removed if they are empty, unless recursive is true.
+func (a *App) RemoveFile(encryptedPrivateKey string, p string, recursive bool) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	p = repoPath(p)
+	if p == "/" {
+		return core.NewAppResultWithError(ErrRemovingRootDir)
+	}
+	if err := a.removeFile(p, recursive); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// MakeDir creates a directory at the specified path of the repository.
+// If parents is true, the missing parent directories are created as well
+// and it is not an error if the directory already exists.
+func (a *App) MakeDir(encryptedPrivateKey string, p string, parents bool) core.AppResult {
+	if res := a.initWithPrivateKey(encryptedPrivateKey); !res.Ok {
+		return res
+	}
+	p = repoPath(p)
+	if !parents {
+		if _, err := a.fileSystem.Stat(p); err == nil {
+			return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathExists, p))
+		}
+		if info, err := a.fileSystem.Stat(path.Dir(p)); err != nil {
+			return core.NewAppResultWithError(err)
+		} else if !info.IsDir() {
+			return core.NewAppResultWithError(fmt.Errorf("%w: %s", ErrPathIsNotDir, path.Dir(p)))
+		}
+		if err := a.fileSystem.CreateDir(p); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+		return core.NewAppResult()
+	}
+	if err := a.makeDir(p); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// initWithPrivateKey initializes the app services and sets the private key of the user.
+func (a *App) initWithPrivateKey(encryptedPrivateKey string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	return a.SetAndCheckPrivateKey(encryptedPrivateKey)
+}
+
+// initForReading initializes the services and sets the private key, or the key of the password recipient
+// if a passphrase is set, for the commands only reading the repository.
+func (a *App) initForReading(encryptedPrivateKey string) core.AppResult {
+	if a.password == "" {
+		return a.initWithPrivateKey(encryptedPrivateKey)
+	}
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	return a.unlockWithPassword()
+}
+
+// walkFiles calls fn for every file and directory in the directory at the specified path of the repository.
+// If recursive is true, it walks the sub directories as well, calling fn for a directory before its content.
+func (a *App) walkFiles(dir string, recursive bool, fn func(p string, info os.FileInfo) error) error {
+	subFiles, err := a.fileSystem.GetSubFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range subFiles {
+		p := path.Join(dir, info.Name())
+		if err := fn(p, info); err != nil {
+			return err
+		}
+		if recursive && info.IsDir() {
+			if err := a.walkFiles(p, true, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readFile decrypts the file at the specified path of the repository and writes its content to w.
+// The decrypted file is removed from the cache afterward.
+func (a *App) readFile(p string, w io.Writer) (err error) {
+	info, err := a.fileSystem.Stat(p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrPathIsDir, p)
+	}
+	// Remove the decrypted file from the cache
+	defer func() {
+		err = errors.Join(err, a.fileSystem.Commit(p))
+	}()
+	buff := make([]byte, fileBufferSize)
+	var ofst int64
+	for ofst < info.Size() {
+		n, err := a.fileSystem.Read(p, buff, ofst)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := w.Write(buff[:n]); err != nil {
+			return err
+		}
+		ofst += int64(n)
+	}
+	return nil
+}
+
+// writeFile encrypts the content read from r into the file at the specified path of the repository.
+// The file is created if it does not exist, and truncated if it does.
+// If writing fails, the file is restored to its previous content, or removed if it did not exist.
+func (a *App) writeFile(p string, r io.Reader) error {
+	info, err := a.fileSystem.Stat(p)
+	if err == nil && info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrPathIsDir, p)
+	}
+	var previous *core.Link
+	if err == nil {
+		link, lerr := a.fileSystem.GetLink(p)
+		if lerr != nil {
+			return lerr
+		}
+		previous = &link
+		err = a.fileSystem.Resize(p, 0)
+	} else {
+		err = a.fileSystem.CreateFile(p)
+	}
+	if err == nil {
+		err = a.copyToFile(p, r)
+	}
+	if err == nil {
+		// Encrypt the file
+		err = a.fileSystem.Commit(p)
+	}
+	if err != nil {
+		return errors.Join(err, a.fileSystem.Discard(p, previous))
+	}
+	return nil
+}
+
+// copyToFile writes the content read from r to the file at the specified path of the repository, open for writing.
+func (a *App) copyToFile(p string, r io.Reader) error {
+	buff := make([]byte, fileBufferSize)
+	var ofst int64
+	for {
+		n, rerr := r.Read(buff)
+		if n > 0 {
+			if _, err := a.fileSystem.Write(p, buff[:n], ofst); err != nil {
+				return err
+			}
+			ofst += int64(n)
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+}
+
+// getFile decrypts the file at the specified path of the repository to the local file dst.
+func (a *App) getFile(p string, dst string) error {
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := a.readFile(p, file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// putFile encrypts the local file src to the file at the specified path of the repository.
+func (a *App) putFile(src string, p string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return a.writeFile(p, file)
+}
+
+// makeDir creates the directory at the specified path of the repository and its missing parents.
+func (a *App) makeDir(p string) error {
+	info, err := a.fileSystem.Stat(p)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s", ErrPathIsNotDir, p)
+		}
+		return nil
+	}
+	if err := a.makeDir(path.Dir(p)); err != nil {
+		return err
+	}
+	return a.fileSystem.CreateDir(p)
+}
+
+// removeFile removes the file or directory at the specified path of the repository.
+func (a *App) removeFile(p string, recursive bool) error {
+	info, err := a.fileSystem.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return a.fileSystem.RemovePath(p)
+	}
+	subFiles, err := a.fileSystem.GetSubFiles(p)
+	if err != nil {
+		return err
+	}
+	if len(subFiles) > 0 && !recursive {
+		return fmt.Errorf("%w: %s", ErrDirNotEmpty, p)
+	}
+	for _, sub := range subFiles {
+		if err := a.removeFile(path.Join(p, sub.Name()), true); err != nil {
+			return err
+		}
+	}
+	return a.fileSystem.RemoveDir(p)
+}
+
+// repoPath returns the clean, slash separated, absolute form of a path of the repository.
+func repoPath(p string) string {
+	return path.Clean("/" + filepath.ToSlash(p))
+}
+
+// newFileEntry creates a listing entry for the file or directory at the specified path of the repository.
+func newFileEntry(p string, info os.FileInfo) core.FileEntry {
+	mode := info.Mode()
+	if info.IsDir() {
+		mode |= os.ModeDir
+	}
+	return core.FileEntry{
+		Path:  p,
+		IsDir: info.IsDir(),
+		Size:  info.Size(),
+		Mode:  mode.String(),
+	}
+}
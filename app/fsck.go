@@ -0,0 +1,26 @@
+package app
+
+import "ctb-cli/core"
+
+// Fsck checks the consistency of the repository and returns the report of the problems found.
+// If full is true, the objects the user has access to are decrypted completely,
+// which requires the private key of the user.
+func (a *App) Fsck(encryptedPrivateKey string, full bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key if given, it is only needed to decrypt the objects
+	if encryptedPrivateKey != "" {
+		keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+		if !keySetRes.Ok {
+			return keySetRes
+		}
+	}
+	report, err := a.fsckService.Check(full && encryptedPrivateKey != "")
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
@@ -6,12 +6,17 @@ import (
 )
 
 // GenerateUserKey generates a user private key and returns it as a string.
+// A hybrid key also holds an ML-KEM-768 key pair, the data keys shared with the user are then sealed with both X25519 and ML-KEM-768.
 // It returns an AppResult containing the generated key on success,
 // or an AppErrorResult containing the error on failure.
-func (a *App) GenerateUserKey() core.AppResult {
-	keyStore := key_service.NewKeyStore(nil, nil)
+func (a *App) GenerateUserKey(hybrid bool) core.AppResult {
+	keyStore := key_service.NewKeyStore(nil, nil, nil)
 	// generate the key
-	key, err := keyStore.GenerateUserKey()
+	generate := keyStore.GenerateUserKey
+	if hybrid {
+		generate = keyStore.GenerateHybridUserKey
+	}
+	key, err := generate()
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
@@ -22,10 +27,12 @@ func (a *App) GenerateUserKey() core.AppResult {
 	return core.NewAppResultWithValue(GenerateUserKeyResult{
 		PrivateKey: key.Unsafe().String(),
 		PublicKey:  publicKey.String(),
+		Hybrid:     key.IsHybrid(),
 	})
 }
 
 type GenerateUserKeyResult struct {
 	PrivateKey string `json:"private_key"`
 	PublicKey  string `json:"public_key"`
+	Hybrid     bool   `json:"hybrid,omitempty"`
 }
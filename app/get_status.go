@@ -37,7 +37,7 @@ func (a *App) GetStatus(encryptedPrivateKey string) core.AppResult {
 	}
 
 	// check if the user has joined
-	isJoined := a.keyStore.IsUserJoined()
+	isJoined := a.isUserJoined()
 	publicKey := core.PublicKey{}
 
 	if isJoined {
@@ -0,0 +1,84 @@
+package app
+
+import "ctb-cli/core"
+
+// CreateGroup creates a group with the given name, with the user of the private key as its only member.
+// Keys shared with the group can be used by all its members.
+func (a *App) CreateGroup(encryptedPrivateKey string, name string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	group, err := a.groupService.Create(name)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(group)
+}
+
+// AddGroupMember adds the user given by public key or contact to the group.
+// The user of the private key must be a member of the group.
+func (a *App) AddGroupMember(encryptedPrivateKey string, name string, user string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	publicKey, err := a.contactService.Resolve(user)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.groupService.AddMember(name, publicKey); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// RemoveGroupMember removes the user given by public key or contact from the group and rotates the key pair of the group.
+// The user of the private key must be a member of the group. It returns the new public key of the group.
+func (a *App) RemoveGroupMember(encryptedPrivateKey string, name string, user string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	publicKey, err := a.contactService.Resolve(user)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	groupId, err := a.groupService.RemoveMember(name, publicKey)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(groupId)
+}
+
+// ListGroups returns the groups of the repository with their members.
+func (a *App) ListGroups() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	groups, err := a.groupService.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(groups)
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"ctb-cli/core"
+	"time"
+)
+
+// defaultInviteExpiry is the time an invitation can be redeemed when no expiry is given.
+const defaultInviteExpiry = 7 * 24 * time.Hour
+
+// Invite creates a one-time invitation to the file or directory at the specified path, for a user who has no key yet.
+// If passphrase is empty, the invitation is protected by a random code, returned with the invitation.
+// If expires is not empty, the invitation expires at that time, as for Share, otherwise it expires after 7 days.
+func (a *App) Invite(encryptedPrivateKey string, path string, passphrase string, expires string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	expiresAt := time.Now().Add(defaultInviteExpiry)
+	if expires != "" {
+		var err error
+		if expiresAt, err = parseExpiry(expires); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	code, err := a.inviteService.Create(path, passphrase, expiresAt)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(code)
+}
+
+// Redeem redeems an invitation with the private key of the user, given by its code, or by its id and passphrase.
+// The file or directory of the invitation is shared with the user, who joins the repository as a guest with the given name
+// if not already a member.
+func (a *App) Redeem(encryptedPrivateKey string, code string, passphrase string, name string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key, the user is not a member yet
+	keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	invite, err := a.inviteService.Redeem(code, passphrase, name)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(invite)
+}
@@ -4,17 +4,71 @@ import (
 	"ctb-cli/core"
 )
 
-// @Todo: Remove!
-
-// Join joins the user in the repository by creating the user key folder.
+// Join requests to join the repository as the user of the private key, under the given display name.
+// The user becomes a member when an existing member approves the request.
 // Returns an AppResult indicating the success or failure of the operation.
-func (a *App) Join(encryptedPrivateKey string) core.AppResult {
+func (a *App) Join(encryptedPrivateKey string, name string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.memberService.RequestJoin(name); err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	return core.NewAppResult()
 }
 
-// JoinByUserId joins the user identified by the given userId.
-// It initializes the app services, then joins the user using the key store.
+// Approve approves the join request of the user with the given public key, granting the user access to the directory
+// at path with the given role, the root of the repository if path is empty.
+// The user of the private key must be a member of the repository.
 // Returns an AppResult indicating the success or failure of the operation.
-func (a *App) JoinByUserId(userId string) core.AppResult {
+func (a *App) Approve(encryptedPrivateKey string, publicKey string, role core.MemberRole, path string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	if err := a.memberService.Approve(publicKey, role, path); err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	return core.NewAppResult()
 }
+
+// ListMembers returns the members of the repository.
+func (a *App) ListMembers() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	members, err := a.memberService.ListMembers()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(members)
+}
+
+// ListJoinRequests returns the pending join requests of the repository.
+func (a *App) ListJoinRequests() core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	requests, err := a.memberService.ListJoinRequests()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(requests)
+}
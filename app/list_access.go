@@ -12,5 +12,46 @@ func (a *App) ListAccess(path string) core.AppResult {
 	if err != nil {
 		return core.NewAppResultWithError(err)
 	}
+	if err := a.contactService.NameAccessList(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.groupService.NameAccessList(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.passwordService.NameAccessList(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(res)
+}
+
+// ListAccessTree lists the access to the file or directory at the specified path and to everything below it.
+// If user is not empty, only the paths the user has access to are listed.
+// The user is a public key, the name or email of a contact of the address book, or a group name prefixed by "@".
+func (a *App) ListAccessTree(path string, user string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	userId := ""
+	if user != "" {
+		var err error
+		if userId, err = a.resolveRecipient(user); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	res, err := a.shareService.GetAccessTree(path, userId)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.contactService.NameAccessReport(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.groupService.NameAccessReport(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.passwordService.NameAccessReport(res); err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	return core.NewAppResultWithValue(res)
 }
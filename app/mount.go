@@ -2,30 +2,151 @@ package app
 
 import (
 	"ctb-cli/core"
+	"ctb-cli/daemon"
 	"ctb-cli/fuse"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Mount mounts the file system and returns the result.
+// While the file system is mounted, the mount is controlled through its control socket.
+// On SIGINT or SIGTERM the file system is unmounted gracefully: files open for writing are committed
+// or journaled before unmounting. After unmounting, the plaintext cache of the mount is wiped.
 // It returns an AppResult containing the result of the operation.
 func (a *App) Mount() core.AppResult {
+	server, err := daemon.Listen(mountHandler{app: a, startedAt: time.Now()})
+	if err != nil {
+		// The file system is still usable without the control socket
+		log.Error("Error starting control socket: ", err)
+	} else {
+		defer server.Close()
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sigc; ok {
+			log.Info("Signal received, unmounting")
+			if err := a.fuse.Unmount(); err != nil {
+				log.Error("Error unmounting: ", err)
+			}
+		}
+	}()
 	a.fuse.Mount()
+	signal.Stop(sigc)
+	close(sigc)
+	if err := a.fileSystem.WipeCache(); err != nil {
+		log.Error("Error wiping cache: ", err)
+	}
 	return core.NewAppResult()
 }
 
 // PrepareMount creates the fuse file system and returns the result.
-func (a *App) PrepareMount(encryptedPrivateKey string, mount string) core.AppResult {
-	// init the app
-	initRes := a.initServices()
-	if !initRes.Ok {
-		return initRes
-	}
-	// set the private key
-	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+// Before mounting in read-write mode, the journaled files are committed and the expired shares are removed.
+// If readOnly is true, the file system is mounted in read-only mode and the repository is never modified.
+// If a passphrase is set, the file system is mounted with the keys of the password recipient, always in read-only mode.
+func (a *App) PrepareMount(encryptedPrivateKey string, mount string, readOnly bool) core.AppResult {
+	// set the private key, or the key of the password recipient
+	keySetRes := a.initForReading(encryptedPrivateKey)
 	if !keySetRes.Ok {
 		return keySetRes
 	}
+	if a.password != "" {
+		readOnly = true
+	}
+	// commit the files journaled by a previous mount that did not shut down cleanly
+	if !readOnly {
+		replayed, err := a.fileSystem.ReplayJournal()
+		for _, path := range replayed {
+			log.Info("Committed journaled file: ", path)
+		}
+		if err != nil {
+			log.Error("Error replaying journal: ", err)
+		}
+		// remove the expired shares
+		report, err := a.expiryService.Expire(false)
+		for _, share := range report.Shares {
+			if share.Skipped != "" {
+				log.Warn("Expired share of ", share.Path, " with ", share.Recipient, " kept: ", share.Skipped)
+			} else {
+				log.Info("Removed expired share of ", share.Path, " with ", share.Recipient)
+			}
+		}
+		if err != nil {
+			log.Error("Error removing expired shares: ", err)
+		}
+	}
 	// create the fuse
-	a.fuse = fuse.New(a.fileSystem)
+	a.fuse = fuse.New(a.fileSystem, readOnly)
 	res := a.fuse.FindMountPoint(mount)
 	return core.NewAppResultWithValue(res)
 }
+
+// CheckMount checks the private key, or the password, of a mount started in the background.
+// The repository is not modified, the background process prepares the mount with PrepareMount.
+func (a *App) CheckMount(encryptedPrivateKey string) core.AppResult {
+	return a.initForReading(encryptedPrivateKey)
+}
+
+// MountInBackground starts a background process that mounts the file system, using the given command line arguments
+// and writing stdin to its standard input.
+// It returns when the background process has mounted the file system, with the status of the new mount.
+func (a *App) MountInBackground(args []string, stdin io.Reader) core.AppResult {
+	res, err := daemon.Spawn(args, stdin)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(*res.Status)
+}
+
+// ListMounts returns the status of every running mount of the current user.
+func (a *App) ListMounts() core.AppResult {
+	list, err := daemon.List()
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(list)
+}
+
+// GetMountStatus returns the status of the running mount serving the given mount point,
+// including the files that are pending commit and the recent errors.
+func (a *App) GetMountStatus(mountPoint string) core.AppResult {
+	status, err := daemon.Status(mountPoint)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(status)
+}
+
+// Unmount gracefully unmounts the running mount serving the given mount point.
+// The mount drains its write cache before unmounting.
+func (a *App) Unmount(mountPoint string) core.AppResult {
+	if err := daemon.Unmount(mountPoint); err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
+
+// mountHandler serves the control socket requests of the mount of the application.
+type mountHandler struct {
+	app       *App
+	startedAt time.Time
+}
+
+// Status returns the status of the mount.
+func (h mountHandler) Status() core.MountStatus {
+	status := h.app.fuse.Status()
+	status.RepoPath, _ = h.app.cfg.GetRepoCtbRoot()
+	status.Pid = os.Getpid()
+	status.StartedAt = h.startedAt
+	return status
+}
+
+// Unmount drains the write cache and unmounts the file system.
+func (h mountHandler) Unmount() error {
+	return h.app.fuse.Unmount()
+}
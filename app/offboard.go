@@ -0,0 +1,28 @@
+package app
+
+import "ctb-cli/core"
+
+// Offboard offboards the user given by public key or contact: removes the keys shared with the user and their groups,
+// replaces the keys of the vaults the user could reach and revokes their membership.
+// The user of the private key must be an owner of the repository. If dryRun is true, the changes are only reported.
+func (a *App) Offboard(encryptedPrivateKey string, user string, dryRun bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	userId, err := a.contactService.Resolve(user)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	report, err := a.offboardService.Offboard(userId, dryRun)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
@@ -12,7 +12,7 @@ func (a *App) GetPubkey(privateKeyString string) core.AppResult {
 		return core.NewAppResultWithError(err)
 	}
 	// Create a new key store without key and vault repositories.
-	keyStore := key_service.NewKeyStore(nil, nil)
+	keyStore := key_service.NewKeyStore(nil, nil, nil)
 	publicKey, err := keyStore.GetPublicKeyByPrivateKey(privateKey)
 	if err != nil {
 		return core.NewAppResultWithError(err)
@@ -0,0 +1,40 @@
+package app
+
+import "ctb-cli/core"
+
+// Repair fixes the problems of the repository found by the consistency check and returns the report of the fixes.
+// The objects are only decrypted to fix wrong sizes and find unreadable objects if the private key of the user is given.
+// If dryRun is true, the fixes are only reported.
+func (a *App) Repair(encryptedPrivateKey string, dryRun bool) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key if given, it is only needed to decrypt the objects
+	if encryptedPrivateKey != "" {
+		keySetRes := a.SetPrivateKey(encryptedPrivateKey)
+		if !keySetRes.Ok {
+			return keySetRes
+		}
+	}
+	report, err := a.fsckService.Repair(encryptedPrivateKey != "", dryRun)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(report)
+}
+
+// RestoreRepair undoes the repair with the specified backup id.
+func (a *App) RestoreRepair(backupId string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	err := a.fsckService.Restore(backupId)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResult()
+}
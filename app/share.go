@@ -1,10 +1,23 @@
 package app
 
-import "ctb-cli/core"
+import (
+	"ctb-cli/core"
+	"errors"
+	"strings"
+	"time"
+)
 
-// Share shares a file or directory located at the specified path with the given public key.
+var (
+	ErrInvalidExpiry = errors.New("the expiry must be a date (YYYY-MM-DD) or a time in RFC 3339 format")
+	ErrExpiryInPast  = errors.New("the expiry is in the past")
+)
+
+// Share shares a file or directory located at the specified path with the given recipient.
+// The recipient is a public key, the name or email of a contact of the address book, or a group name prefixed by "@".
+// If expires is not empty, the share expires at that time: a date, the share expiring at the end of the day,
+// or a time in RFC 3339 format.
 // Returns an AppResult indicating the success or failure of the operation.
-func (a *App) Share(path string, publicKey string, encryptedPrivateKey string) core.AppResult {
+func (a *App) Share(path string, recipient string, encryptedPrivateKey string, expires string) core.AppResult {
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
@@ -15,24 +28,100 @@ func (a *App) Share(path string, publicKey string, encryptedPrivateKey string) c
 	if !keySetRes.Ok {
 		return keySetRes
 	}
-	if err := a.shareService.ShareByPublicKey(path, publicKey); err != nil {
+	var expiresAt time.Time
+	if expires != "" {
+		var err error
+		if expiresAt, err = parseExpiry(expires); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	publicKey, err := a.resolveRecipient(recipient)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	if err := a.shareService.ShareByPublicKey(path, publicKey, expiresAt); err != nil {
 		return core.NewAppResultWithError(err)
 	}
 	return core.NewAppResult()
 }
 
-// Unshare removes the sharing of a file or directory with a specific public key.
+// SharePassword shares a file or directory located at the specified path with the password recipient of the passphrase,
+// for a user who does not manage a key pair. The recipient is created with the given label if no recipient matches
+// the passphrase yet. The user reads the shared files by giving the passphrase to the ls, cat, get and mount commands.
+// If expires is not empty, the share expires at that time, as for Share.
+func (a *App) SharePassword(path string, passphrase string, label string, encryptedPrivateKey string, expires string) core.AppResult {
+	// init the app
+	initRes := a.initServices()
+	if !initRes.Ok {
+		return initRes
+	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	var expiresAt time.Time
+	if expires != "" {
+		var err error
+		if expiresAt, err = parseExpiry(expires); err != nil {
+			return core.NewAppResultWithError(err)
+		}
+	}
+	recipient, err := a.passwordService.Share(path, passphrase, label, expiresAt)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
+	return core.NewAppResultWithValue(recipient)
+}
+
+// Unshare removes the sharing of a file or directory with a specific recipient,
+// given by public key, by the name or email of a contact of the address book, or by group name prefixed by "@".
 // It initializes the app services and calls the UnshareByPublicKey method of the shareService.
+// The private key is needed to sign the entry of the audit log.
 // If an error occurs during the unsharing process, it returns an AppResult with the error.
 // Otherwise, it returns a successful AppResult.
-func (a *App) Unshare(path string, publicKey string) core.AppResult {
+func (a *App) Unshare(path string, recipient string, encryptedPrivateKey string) core.AppResult {
 	// init the app
 	initRes := a.initServices()
 	if !initRes.Ok {
 		return initRes
 	}
+	// set the private key
+	keySetRes := a.SetAndCheckPrivateKey(encryptedPrivateKey)
+	if !keySetRes.Ok {
+		return keySetRes
+	}
+	publicKey, err := a.resolveRecipient(recipient)
+	if err != nil {
+		return core.NewAppResultWithError(err)
+	}
 	if err := a.shareService.Unshare(path, publicKey); err != nil {
 		return core.NewAppResultWithError(err)
 	}
 	return core.NewAppResult()
 }
+
+// resolveRecipient returns the public key of the recipient given by public key, contact or group name.
+func (a *App) resolveRecipient(recipient string) (string, error) {
+	if strings.HasPrefix(recipient, core.GroupPrefix) {
+		return a.groupService.Resolve(recipient)
+	}
+	return a.contactService.Resolve(recipient)
+}
+
+// parseExpiry parses the expiry of a share: a date, the share expiring at the end of the day in local time,
+// or a time in RFC 3339 format.
+func parseExpiry(expires string) (time.Time, error) {
+	expiresAt, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		date, err := time.ParseInLocation(time.DateOnly, expires, time.Local)
+		if err != nil {
+			return time.Time{}, ErrInvalidExpiry
+		}
+		expiresAt = date.AddDate(0, 0, 1)
+	}
+	if !expiresAt.After(time.Now()) {
+		return time.Time{}, ErrExpiryInPast
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,341 @@
+package bridgeguard
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+// File is an open file or directory of a repository.
+// Changes written to a file are encrypted and committed to the repository when its last handle is closed.
+type File struct {
+	repo *Repo
+	name string // name the file was opened with
+	path string // path of the file in the repository
+	flag int    // flags the file was opened with
+	dir  bool   // the file is a directory
+	ofst int64  // offset of the next read or write
+
+	// dirEntries holds the entries of a directory not returned by ReadDir yet, nil if they are not read yet
+	dirEntries []fs.DirEntry
+	closed     bool
+}
+
+// Make sure File implements the io/fs file interfaces
+var (
+	_ fs.File            = (*File)(nil)
+	_ fs.ReadDirFile     = (*File)(nil)
+	_ io.ReadWriteSeeker = (*File)(nil)
+	_ io.ReaderAt        = (*File)(nil)
+	_ io.WriterAt        = (*File)(nil)
+)
+
+// newFile creates a new handle for the file at the repository path p.
+func newFile(repo *Repo, name string, p string, flag int, dir bool) *File {
+	return &File{
+		repo: repo,
+		name: name,
+		path: p,
+		flag: flag,
+		dir:  dir,
+	}
+}
+
+// Name returns the name of the file as passed to Open, Create or OpenFile.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Stat returns the file info of the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.closed {
+		return nil, f.pathError("stat", fs.ErrClosed)
+	}
+	info, err := f.repo.fileSystem.Stat(f.path)
+	if err != nil {
+		return nil, f.pathError("stat", err)
+	}
+	return newFileInfo(f.name, info), nil
+}
+
+// Read reads up to len(b) bytes from the file at the current offset.
+// It returns io.EOF at the end of the file.
+func (f *File) Read(b []byte) (int, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	n, err := f.readAt("read", b, f.ofst)
+	f.ofst += int64(n)
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from the file starting at offset off.
+// It returns io.EOF if the end of the file is reached before b is filled.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if off < 0 {
+		return 0, f.pathError("readat", fs.ErrInvalid)
+	}
+	n, err := f.readAt("readat", b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Write writes len(b) bytes to the file at the current offset,
+// or at the end of the file if it was opened with os.O_APPEND.
+func (f *File) Write(b []byte) (int, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		info, err := f.repo.fileSystem.Stat(f.path)
+		if err != nil {
+			return 0, f.pathError("write", err)
+		}
+		f.ofst = info.Size()
+	}
+	n, err := f.writeAt("write", b, f.ofst)
+	f.ofst += int64(n)
+	return n, err
+}
+
+// WriteAt writes len(b) bytes to the file starting at offset off.
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		return 0, f.pathError("writeat", fs.ErrInvalid)
+	}
+	if off < 0 {
+		return 0, f.pathError("writeat", fs.ErrInvalid)
+	}
+	return f.writeAt("writeat", b, off)
+}
+
+// Seek sets the offset of the next read or write, interpreted according to whence
+// as in io.Seeker, and returns the new offset.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.closed {
+		return 0, f.pathError("seek", fs.ErrClosed)
+	}
+	if f.dir {
+		return 0, f.pathError("seek", syscall.EISDIR)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.ofst
+	case io.SeekEnd:
+		info, err := f.repo.fileSystem.Stat(f.path)
+		if err != nil {
+			return 0, f.pathError("seek", err)
+		}
+		offset += info.Size()
+	default:
+		return 0, f.pathError("seek", fs.ErrInvalid)
+	}
+	if offset < 0 {
+		return 0, f.pathError("seek", fs.ErrInvalid)
+	}
+	f.ofst = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file.
+func (f *File) Truncate(size int64) error {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if err := f.checkWrite(); err != nil {
+		return f.pathError("truncate", err)
+	}
+	if size < 0 {
+		return f.pathError("truncate", fs.ErrInvalid)
+	}
+	if err := f.repo.fileSystem.Resize(f.path, size); err != nil {
+		return f.pathError("truncate", err)
+	}
+	return nil
+}
+
+// Sync commits the changes written to the file so far to the repository.
+func (f *File) Sync() error {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.closed {
+		return f.pathError("sync", fs.ErrClosed)
+	}
+	if err := f.repo.fileSystem.Sync(f.path); err != nil {
+		return f.pathError("sync", err)
+	}
+	return nil
+}
+
+// ReadDir reads the entries of the directory, sorted by file name.
+// If n > 0, it returns at most n entries and io.EOF when there are no more entries.
+// If n <= 0, it returns all the remaining entries.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.closed {
+		return nil, f.pathError("readdir", fs.ErrClosed)
+	}
+	if !f.dir {
+		return nil, f.pathError("readdir", syscall.ENOTDIR)
+	}
+	if f.dirEntries == nil {
+		entries, err := f.repo.readDir(f.name, f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.dirEntries = entries
+	}
+	if n <= 0 {
+		entries := f.dirEntries
+		f.dirEntries = []fs.DirEntry{}
+		return entries, nil
+	}
+	if len(f.dirEntries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(f.dirEntries))
+	entries := f.dirEntries[:n]
+	f.dirEntries = f.dirEntries[n:]
+	return entries, nil
+}
+
+// Close closes the file. If it is the last open handle of the file,
+// the changes written to the file are encrypted and committed to the repository.
+func (f *File) Close() error {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+	if f.closed {
+		return f.pathError("close", fs.ErrClosed)
+	}
+	f.closed = true
+	if err := f.repo.closeFile(f); err != nil {
+		return f.pathError("close", err)
+	}
+	return nil
+}
+
+// readAt reads from the file at offset off, stopping at the end of the file.
+func (f *File) readAt(op string, b []byte, off int64) (int, error) {
+	if err := f.checkRead(); err != nil {
+		return 0, f.pathError(op, err)
+	}
+	info, err := f.repo.fileSystem.Stat(f.path)
+	if err != nil {
+		return 0, f.pathError(op, err)
+	}
+	if off >= info.Size() {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > info.Size()-off {
+		b = b[:info.Size()-off]
+	}
+	n, err := f.repo.fileSystem.Read(f.path, b, off)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	if err != nil && err != io.EOF {
+		return n, f.pathError(op, err)
+	}
+	return n, err
+}
+
+// writeAt writes to the file at offset off.
+func (f *File) writeAt(op string, b []byte, off int64) (int, error) {
+	if err := f.checkWrite(); err != nil {
+		return 0, f.pathError(op, err)
+	}
+	n, err := f.repo.fileSystem.Write(f.path, b, off)
+	if err != nil {
+		return n, f.pathError(op, err)
+	}
+	return n, nil
+}
+
+// checkRead returns an error if the file cannot be read.
+func (f *File) checkRead() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	if f.dir {
+		return syscall.EISDIR
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return syscall.EBADF
+	}
+	return nil
+}
+
+// checkWrite returns an error if the file cannot be written.
+func (f *File) checkWrite() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	if f.dir {
+		return syscall.EISDIR
+	}
+	if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return syscall.EBADF
+	}
+	return nil
+}
+
+// pathError wraps the error in a *fs.PathError for the file.
+func (f *File) pathError(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+// fileInfo is the file info of a file or directory of a repository, named after its io/fs name.
+type fileInfo struct {
+	info fs.FileInfo
+	name string
+}
+
+var _ fs.FileInfo = fileInfo{}
+
+// newFileInfo creates the file info of the file or directory with the given io/fs name.
+func newFileInfo(name string, info fs.FileInfo) fileInfo {
+	return fileInfo{
+		info: info,
+		name: path.Base(name),
+	}
+}
+
+func (i fileInfo) Name() string {
+	return i.name
+}
+
+func (i fileInfo) Size() int64 {
+	return i.info.Size()
+}
+
+// Mode returns the access of the user to the file, with fs.ModeDir set for directories.
+func (i fileInfo) Mode() fs.FileMode {
+	if i.info.IsDir() {
+		return i.info.Mode() | fs.ModeDir
+	}
+	return i.info.Mode()
+}
+
+func (i fileInfo) ModTime() time.Time {
+	return i.info.ModTime()
+}
+
+func (i fileInfo) IsDir() bool {
+	return i.info.IsDir()
+}
+
+func (i fileInfo) Sys() any {
+	return nil
+}
@@ -0,0 +1,414 @@
+// Package bridgeguard gives Go programs in-process access to encrypted repositories, without FUSE or the CLI.
+//
+// A Repo implements io/fs.FS, fs.ReadDirFS and fs.StatFS, so it can be used with fs.WalkDir, fs.ReadFile,
+// http.FS and the like, and adds Create, OpenFile, Remove, Rename and Mkdir to modify the repository.
+// Names follow the io/fs conventions: they are slash separated, unrooted, and "." is the root of the repository.
+package bridgeguard
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/services"
+	"ctb-cli/services/config_service"
+	"ctb-cli/services/filesystem_service"
+	"ctb-cli/services/member_service"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	ErrNotJoined    = errors.New("the user has not joined the repository")
+	ErrRepoNotEmpty = errors.New("the repository folder is not empty")
+)
+
+// Repo is a handle to an encrypted repository, opened with the private key of a user.
+// It is safe for concurrent use.
+type Repo struct {
+	mu sync.Mutex
+
+	keyStore      core.KeyService
+	fileSystem    *filesystem_service.FileSystem
+	configService *config_service.ConfigService
+	memberService *member_service.Service
+
+	// files holds the open files, so the changes to a file are committed when its last handle is closed
+	files map[*File]struct{}
+}
+
+// Make sure Repo implements the io/fs interfaces
+var (
+	_ fs.FS        = (*Repo)(nil)
+	_ fs.ReadDirFS = (*Repo)(nil)
+	_ fs.StatFS    = (*Repo)(nil)
+)
+
+// Open opens the repository at repoPath with the encoded private key of a user who joined the repository.
+// The plaintext of the files being read or written is cached in cachePath, where the public key of the first owner
+// of the repository is pinned the first time it is opened, so the members are verified against it afterwards.
+func Open(repoPath string, cachePath string, encodedPrivateKey string) (*Repo, error) {
+	r := newRepo(repoPath, cachePath)
+	if err := r.setPrivateKey(encodedPrivateKey); err != nil {
+		return nil, err
+	}
+	userId, err := r.keyStore.GetUserId()
+	if err != nil {
+		return nil, err
+	}
+	if !r.memberService.IsMember(userId) {
+		return nil, ErrNotJoined
+	}
+	return r, nil
+}
+
+// Init initializes a new repository in the empty folder repoPath, owned by the user of the encoded private key,
+// and opens it. The user is pinned as the first owner of the repository in cachePath.
+func Init(repoPath string, cachePath string, encodedPrivateKey string) (*Repo, error) {
+	return initRepo(repoPath, cachePath, encodedPrivateKey, false)
+}
+
+// InitWithEncryptedNames initializes a new repository as Init does, storing the names of its files and directories
+// encrypted with the keys of the vaults of their parent directories.
+func InitWithEncryptedNames(repoPath string, cachePath string, encodedPrivateKey string) (*Repo, error) {
+	return initRepo(repoPath, cachePath, encodedPrivateKey, true)
+}
+
+// initRepo initializes a new repository in the empty folder repoPath and opens it.
+func initRepo(repoPath string, cachePath string, encodedPrivateKey string, encryptNames bool) (*Repo, error) {
+	if err := os.MkdirAll(repoPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+	rootFiles, err := os.ReadDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(rootFiles) > 0 {
+		return nil, ErrRepoNotEmpty
+	}
+	// Create the system folders
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		if err := os.MkdirAll(filepath.Join(repoPath, ".meta", folder), os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+	r := newRepo(repoPath, cachePath)
+	if err := r.configService.InitConfig(""); err != nil {
+		return nil, err
+	}
+	if err := r.setPrivateKey(encodedPrivateKey); err != nil {
+		return nil, err
+	}
+	// Register the user as the first owner before creating the root vault, whose keys follow the policy of the repository
+	if err := r.memberService.InitRegistry("", core.RepoPolicy{EncryptNames: encryptNames}); err != nil {
+		return nil, err
+	}
+	// Create a vault in the root path
+	if err := r.fileSystem.CreateVaultInPath("/"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newRepo creates the services of the repository at repoPath.
+func newRepo(repoPath string, cachePath string) *Repo {
+	s := services.New(repoPath, cachePath, cachePath)
+	return &Repo{
+		keyStore:      s.KeyStore,
+		fileSystem:    s.FileSystem,
+		configService: s.Config,
+		memberService: s.Member,
+		files:         make(map[*File]struct{}),
+	}
+}
+
+// setPrivateKey decodes the private key and sets it in the key store.
+func (r *Repo) setPrivateKey(encodedPrivateKey string) error {
+	privateKey, err := core.NewPrivateKeyFromEncoded(encodedPrivateKey)
+	if err != nil {
+		return err
+	}
+	r.keyStore.SetPrivateKey(privateKey)
+	return nil
+}
+
+// Close closes the open files of the repository, committing their changes,
+// and wipes the plaintext cached by the repository.
+func (r *Repo) Close() error {
+	r.mu.Lock()
+	files := make([]*File, 0, len(r.files))
+	for file := range r.files {
+		files = append(files, file)
+	}
+	r.mu.Unlock()
+	var errs []error
+	for _, file := range files {
+		if err := file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs = append(errs, r.fileSystem.WipeCache())
+	return errors.Join(errs...)
+}
+
+// Open opens the named file or directory for reading.
+func (r *Repo) Open(name string) (fs.File, error) {
+	file, err := r.openFile("open", name, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Create creates or truncates the named file, opening it for reading and writing.
+func (r *Repo) Create(name string) (*File, error) {
+	return r.openFile("open", name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+// OpenFile opens the named file with the flags of os.OpenFile (os.O_RDONLY, os.O_CREATE and so on).
+// The permission bits are ignored, the access to the files is given by the keys shared with the user.
+func (r *Repo) OpenFile(name string, flag int, perm fs.FileMode) (*File, error) {
+	return r.openFile("open", name, flag)
+}
+
+// Stat returns the file info of the named file or directory.
+func (r *Repo) Stat(name string) (fs.FileInfo, error) {
+	p, err := repoPath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := r.fileSystem.Stat(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return newFileInfo(name, info), nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by file name.
+func (r *Repo) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := repoPath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readDir(name, p)
+}
+
+// Mkdir creates the named directory. The parent directory must exist.
+// The permission bits are ignored.
+func (r *Repo) Mkdir(name string, perm fs.FileMode) error {
+	p, err := repoPath("mkdir", name)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.fileSystem.Stat(p); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := r.checkParent(path.Dir(p)); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if err := r.fileSystem.CreateDir(p); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (r *Repo) Remove(name string) error {
+	p, err := repoPath("remove", name)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := r.fileSystem.Stat(p)
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if !info.IsDir() {
+		err = r.fileSystem.RemovePath(p)
+	} else if subFiles, serr := r.fileSystem.GetSubFiles(p); serr != nil {
+		err = serr
+	} else if len(subFiles) > 0 {
+		err = syscall.ENOTEMPTY
+	} else {
+		err = r.fileSystem.RemoveDir(p)
+	}
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Rename renames the file or directory oldName to newName.
+// Unlike os.Rename, it fails if newName already exists.
+func (r *Repo) Rename(oldName string, newName string) error {
+	oldPath, err := repoPath("rename", oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := repoPath("rename", newName)
+	if err != nil {
+		return err
+	}
+	linkError := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: err}
+	}
+	if oldPath == "/" {
+		return linkError(fs.ErrPermission)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.fileSystem.Stat(oldPath); err != nil {
+		return linkError(err)
+	}
+	if _, err := r.fileSystem.Stat(newPath); err == nil {
+		return linkError(fs.ErrExist)
+	}
+	if err := r.checkParent(path.Dir(newPath)); err != nil {
+		return linkError(err)
+	}
+	if err := r.fileSystem.Rename(oldPath, newPath); err != nil {
+		return linkError(err)
+	}
+	// Keep the open files pointing to their new path
+	for file := range r.files {
+		if file.path == oldPath {
+			file.path = newPath
+		} else if rel, ok := strings.CutPrefix(file.path, oldPath+"/"); ok {
+			file.path = path.Join(newPath, rel)
+		}
+	}
+	return nil
+}
+
+// openFile opens the named file with the given flags.
+func (r *Repo) openFile(op string, name string, flag int) (*File, error) {
+	p, err := repoPath(op, name)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := r.open(p, name, flag)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	r.files[file] = struct{}{}
+	return file, nil
+}
+
+// open opens the file at the repository path p, creating or truncating it as requested in flag.
+func (r *Repo) open(p string, name string, flag int) (*File, error) {
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	info, err := r.fileSystem.Stat(p)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		if err := r.checkParent(path.Dir(p)); err != nil {
+			return nil, err
+		}
+		if r.fileSystem.GetUserFileAccess(path.Dir(p), true)&0222 == 0 {
+			return nil, fs.ErrPermission
+		}
+		if err := r.fileSystem.CreateFile(p); err != nil {
+			return nil, err
+		}
+		return newFile(r, name, p, flag, false), nil
+	}
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, fs.ErrExist
+	}
+	if info.IsDir() {
+		if write || flag&os.O_TRUNC != 0 {
+			return nil, syscall.EISDIR
+		}
+		return newFile(r, name, p, flag, true), nil
+	}
+	if write && info.Mode()&0222 == 0 || !write && info.Mode()&0444 == 0 {
+		return nil, fs.ErrPermission
+	}
+	if write && flag&os.O_TRUNC != 0 {
+		if err := r.fileSystem.Resize(p, 0); err != nil {
+			return nil, err
+		}
+	}
+	return newFile(r, name, p, flag, false), nil
+}
+
+// readDir returns the entries of the directory at the repository path p sorted by file name.
+func (r *Repo) readDir(name string, p string) ([]fs.DirEntry, error) {
+	subFiles, err := r.fileSystem.GetSubFiles(p)
+	if err != nil {
+		if !r.isDir(p) {
+			err = syscall.ENOTDIR
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, 0, len(subFiles))
+	for _, info := range subFiles {
+		entries = append(entries, fs.FileInfoToDirEntry(newFileInfo(info.Name(), info)))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// checkParent returns an error if the parent directory at the repository path p does not exist or is not a directory.
+func (r *Repo) checkParent(p string) error {
+	info, err := r.fileSystem.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return syscall.ENOTDIR
+	}
+	return nil
+}
+
+// isDir returns true if the repository path p is an existing directory.
+func (r *Repo) isDir(p string) bool {
+	info, err := r.fileSystem.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// closeFile removes the file from the open files and commits its changes if it is the last open handle of its path.
+func (r *Repo) closeFile(file *File) error {
+	delete(r.files, file)
+	if file.dir {
+		return nil
+	}
+	for other := range r.files {
+		if other.path == file.path {
+			return nil
+		}
+	}
+	return r.fileSystem.Commit(file.path)
+}
+
+// repoPath validates the io/fs name and returns the corresponding path of the repository.
+func repoPath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return "/", nil
+	}
+	return "/" + name, nil
+}
@@ -0,0 +1,376 @@
+package bridgeguard_test
+
+import (
+	"bytes"
+	"ctb-cli/bridgeguard"
+	"ctb-cli/core"
+	"ctb-cli/services/offboard_service"
+	"ctb-cli/test/testrepo"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// newTestRepo initializes a repository and opens it as its first owner.
+func newTestRepo(t *testing.T) (*bridgeguard.Repo, testrepo.Repo) {
+	r := testrepo.New(t)
+	return r.OpenFS(t), r
+}
+
+func writeFile(t *testing.T, repo *bridgeguard.Repo, name string, data []byte) {
+	file, err := repo.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFS(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	if err := repo.Mkdir("docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Mkdir("docs/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, repo, "hello.txt", []byte("hello world"))
+	writeFile(t, repo, "docs/a.txt", bytes.Repeat([]byte("a"), 300))
+	writeFile(t, repo, "docs/sub/empty.txt", nil)
+
+	if err := fstest.TestFS(repo, "hello.txt", "docs/a.txt", "docs/sub/empty.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	repo, r := newTestRepo(t)
+	data := []byte("persisted content")
+	writeFile(t, repo, "file.txt", data)
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := bridgeguard.Open(r.Path, r.CachePath, r.OwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, err := fs.ReadFile(reopened, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+}
+
+func TestReadWriteSeek(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	writeFile(t, repo, "file.txt", []byte("0123456789"))
+
+	file, err := repo.OpenFile("file.txt", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(-2, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	n, err := file.Read(buf)
+	if err != nil || string(buf[:n]) != "89" {
+		t.Fatalf("got %q, %v, want \"89\"", buf[:n], err)
+	}
+	if _, err := file.Read(buf); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	appended, err := repo.OpenFile("file.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := appended.Write([]byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if err := appended.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fs.ReadFile(repo, "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "0123ab6789!" {
+		t.Fatalf("got %q, want %q", got, "0123ab6789!")
+	}
+}
+
+func TestRenameRemove(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	if err := repo.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, repo, "dir/file.txt", []byte("content"))
+
+	if err := repo.Rename("dir/file.txt", "moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Stat("dir/file.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want fs.ErrNotExist", err)
+	}
+	got, err := fs.ReadFile(repo, "moved.txt")
+	if err != nil || string(got) != "content" {
+		t.Fatalf("got %q, %v, want \"content\"", got, err)
+	}
+
+	if err := repo.Remove("moved.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Remove("dir"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := repo.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("got %d entries, want 0", len(entries))
+	}
+}
+
+func TestPathErrors(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	writeFile(t, repo, "file.txt", []byte("content"))
+
+	var pathErr *fs.PathError
+	if _, err := repo.Open("missing.txt"); !errors.As(err, &pathErr) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, want *fs.PathError with fs.ErrNotExist", err)
+	}
+	if _, err := repo.Open("/file.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("got %v, want fs.ErrInvalid", err)
+	}
+	if _, err := repo.OpenFile("file.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("got %v, want fs.ErrExist", err)
+	}
+	if err := repo.Mkdir("file.txt", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("got %v, want fs.ErrExist", err)
+	}
+	file, err := repo.Open("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("got %v, want fs.ErrClosed", err)
+	}
+}
+
+func TestEncryptedNames(t *testing.T) {
+	r := testrepo.NewWithEncryptedNames(t)
+	repoPath := r.Path
+	repo := r.OpenFS(t)
+
+	if err := repo.Mkdir("Customer ACME", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Mkdir("Customer ACME/Project Falcon", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, repo, "Customer ACME/contract.pdf", []byte("contract"))
+	writeFile(t, repo, "Customer ACME/Project Falcon/plan.txt", []byte("plan"))
+	if err := repo.Rename("Customer ACME/Project Falcon", "Project Falcon"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(repo, "Customer ACME/contract.pdf", "Project Falcon/plan.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Customer ACME", "Project Falcon", "contract.pdf", "plan.txt"}
+	checkNoPlaintextNames(t, repoPath, names)
+
+	// Replacing the key of a vault replaces its name key and renames its entries
+	owner := r.Owner(t)
+	before := storedNames(t, filepath.Join(repoPath, onDiskPath(t, owner, "Customer ACME")))
+	if _, err := owner.KeyStore.RotateVaultKey("/Customer ACME"); err != nil {
+		t.Fatal(err)
+	}
+	after := storedNames(t, filepath.Join(repoPath, onDiskPath(t, owner, "Customer ACME")))
+	if len(after) != len(before) {
+		t.Fatalf("got entries %v after the rotation, want %d entries", after, len(before))
+	}
+	for i := range before {
+		if before[i] == after[i] {
+			t.Errorf("%s is not renamed by the rotation", before[i])
+		}
+	}
+	checkNoPlaintextNames(t, repoPath, names)
+
+	// The audit log is listed with the paths in plaintext
+	entries, err := owner.Audit.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[core.AuditAction][]string)
+	for _, e := range entries {
+		paths[e.Action] = append(paths[e.Action], e.Path)
+		if e.Action == core.AuditMoveVault {
+			paths[e.Action] = append(paths[e.Action], e.Target)
+		}
+	}
+	if got := paths[core.AuditRotateKey]; len(got) != 1 || got[0] != "/Customer ACME" {
+		t.Errorf("got rotated paths %v, want [/Customer ACME]", got)
+	}
+	// The old path of the moved directory was encrypted with the replaced name key, it is listed as stored
+	if got := paths[core.AuditMoveVault]; len(got) != 2 || got[0] == "/Customer ACME/Project Falcon" || got[1] != "/Project Falcon" {
+		t.Errorf("got moved paths %v, want the stored old path and /Project Falcon", got)
+	}
+
+	reopened, err := bridgeguard.Open(r.Path, r.CachePath, r.OwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if err := fstest.TestFS(reopened, "Customer ACME/contract.pdf", "Project Falcon/plan.txt"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.ReadFile(reopened, "Project Falcon/plan.txt")
+	if err != nil || string(got) != "plan" {
+		t.Fatalf("got %q, %v, want \"plan\"", got, err)
+	}
+}
+
+func TestEncryptedNamesWithoutAccess(t *testing.T) {
+	r := testrepo.NewWithEncryptedNames(t, "a", "b")
+	r.WriteFile(t, "b/file", []byte("content"))
+	owner := r.Owner(t)
+	// An owner and a member with access to a directory only, who cannot decrypt the names of the root
+	partial := r.Join(t, owner, core.MemberRoleOwner, "/a")
+	member := r.Join(t, owner, core.MemberRoleMember, "/b")
+
+	// The entries of the root are not checked, and the keys and objects they use are not reported as orphaned
+	report, err := partial.Fsck.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unresolved := 0
+	for _, f := range report.Findings {
+		switch f.Code {
+		case core.FsckUnresolvedName:
+			unresolved++
+		case core.FsckOrphanedKeyShare, core.FsckOrphanedVaultKey, core.FsckOrphanedObject:
+			t.Errorf("got finding %s %s %s, the ids used by the unresolved entries are unknown", f.Code, f.Path, f.Target)
+		}
+	}
+	if unresolved != 2 {
+		t.Errorf("got %d unresolved entries, want 2:\n%s", unresolved, report)
+	}
+
+	// The offboarding reports the directories it cannot walk, and does not change anything
+	plan, err := partial.Offboard.Offboard(member.Id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Unresolved) != 2 {
+		t.Errorf("got unresolved directories %v, want 2", plan.Unresolved)
+	}
+	if _, err := partial.Offboard.Offboard(member.Id, false); !errors.Is(err, offboard_service.ErrUnresolvedPaths) {
+		t.Fatalf("got %v, want ErrUnresolvedPaths", err)
+	}
+	if !owner.Member.IsMember(member.Id) {
+		t.Error("the member is revoked by an incomplete offboarding")
+	}
+
+	// The expiry sweep reports the directories whose shares it cannot check
+	expiries, err := partial.Expiry.Expire(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiries.Unresolved) != 2 {
+		t.Errorf("got unresolved directories %v, want 2", expiries.Unresolved)
+	}
+
+	// The first owner has access to all the names
+	if plan, err := owner.Offboard.Offboard(member.Id, true); err != nil || len(plan.Unresolved) != 0 {
+		t.Errorf("got unresolved directories %v, %v, want none", plan.Unresolved, err)
+	}
+}
+
+// checkNoPlaintextNames checks that no file of the repository is named with, or holds, one of the names in plaintext,
+// including the records stored outside the directories, such as the audit log.
+func checkNoPlaintextNames(t *testing.T, repoPath string, names []string) {
+	t.Helper()
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if d.Name() == name {
+				t.Errorf("%s is stored in plaintext at %s", name, p)
+			}
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if bytes.Contains(content, []byte(name)) {
+				t.Errorf("%s is stored in plaintext in %s", name, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedNames returns the names stored on disk of the entries of the directory, without the system folders.
+func storedNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name() != ".meta" {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// onDiskPath returns the path stored on disk of the path, relative to the root of the repository.
+func onDiskPath(t *testing.T, user testrepo.User, path string) string {
+	t.Helper()
+	resolved, err := user.Names.ResolvePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
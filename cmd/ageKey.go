@@ -0,0 +1,27 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// ageKeyCmd represents the age-key command
+var ageKeyCmd = &cobra.Command{
+	Use:   "age-key <key>",
+	Short: "Convert keys from and to the age format",
+	Long: `Convert an age recipient (age1...) to a public key, or an age identity (AGE-SECRET-KEY-1...) to a private key.
+	A public key is converted to an age recipient, and a private key to an age identity with --private.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		private, _ := cmd.Flags().GetBool("private")
+		res := ctbApp.ConvertAgeKey(args[0], private)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(ageKeyCmd)
+	ageKeyCmd.Flags().Bool("private", false, "Convert a private key to an age identity.")
+}
@@ -0,0 +1,31 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// algorithmCmd represents the algorithm command
+var algorithmCmd = &cobra.Command{
+	Use:   "algorithm [name]",
+	Short: "Show or set the encryption algorithm of the repository",
+	Long: `Show the AEAD algorithm the new files of the repository are encrypted with, and the supported algorithms.
+	With a name, set the algorithm of the repository: AEAD_ChaCha20_Poly1305 (default), AEAD_AES_256_GCM, faster on processors with AES instructions,
+	or AEAD_XChaCha20_Poly1305. The files already encrypted keep their algorithm, which is stored in their header.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		alg := ""
+		if len(args) == 1 {
+			alg = args[0]
+		}
+		res := ctbApp.Algorithm(encryptedPrivateKey, alg)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(algorithmCmd)
+	SetRequiredKeyFlag(algorithmCmd)
+}
@@ -0,0 +1,34 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"ctb-cli/core"
+
+	"github.com/spf13/cobra"
+)
+
+// approveCmd represents the approve command
+var approveCmd = &cobra.Command{
+	Use:   "approve <public key>",
+	Short: "Approve a join request",
+	Long: `Approve the join request of the user with the given public key. The root of the repository is shared with the user,
+	who is added to the member registry with the given role. Only owners can approve owners.
+	Use the path flag to share a directory only instead of the root of the repository.
+	Use members requests command to list the pending join requests.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, _ := cmd.Flags().GetString("role")
+		path, _ := cmd.Flags().GetString("path")
+		res := ctbApp.Approve(encryptedPrivateKey, args[0], core.MemberRole(role), path)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(approveCmd)
+	SetRequiredKeyFlag(approveCmd)
+	approveCmd.Flags().String("role", string(core.MemberRoleMember), "role of the user: member or owner")
+	approveCmd.Flags().String("path", "/", "directory shared with the user")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"ctb-cli/core"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log",
+	Long: `Inspect the audit log of the repository. Every share, unshare, vault creation and vault move is recorded
+	in the log, chained to the previous entry and signed by the user who made the change.`,
+}
+
+// auditShowCmd represents the audit show command
+var auditShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "List the entries of the audit log",
+	Long:  `List the entries of the audit log in order, with the user who made each change.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListAudit()
+		MarshalOutput(res)
+	},
+}
+
+// auditVerifyCmd represents the audit verify command
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the audit log",
+	Long: `Verify the hash chain and the signatures of the audit log, and detect entries modified, removed or truncated.
+	The command exits with status 1 if the log is not intact.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.VerifyAudit()
+		MarshalOutput(res)
+		if report, ok := res.Result.(core.AuditReport); !res.Ok || ok && !report.Valid {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditShowCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+}
@@ -0,0 +1,32 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// breakInheritanceCmd represents the break-inheritance command
+var breakInheritanceCmd = &cobra.Command{
+	Use:   "break-inheritance <path> [recipient...]",
+	Short: "Restrict a directory to chosen recipients",
+	Long: `Restrict the directory with the specified path to the given recipients, so that the users who reach it
+	through its parent directories lose access. Each recipient is a public key, the name or email of a contact
+	of the address book, or a group name prefixed by "@". You always keep access to the directory.
+	The key of the directory is replaced by a key shared only with the recipients, and the keys of its sub directories
+	are replaced, so that the files written afterwards are not readable by the users losing access.
+	The files shared directly with other users below the directory stay shared. Run with the dry-run flag first to review the changes.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		res := ctbApp.BreakInheritance(encryptedPrivateKey, args[0], args[1:], dryRun)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(breakInheritanceCmd)
+	SetRequiredKeyFlag(breakInheritanceCmd)
+	breakInheritanceCmd.Flags().Bool("dry-run", false, "Only report the changes, without applying them.")
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// catCmd represents the cat command
+var catCmd = &cobra.Command{
+	Use:   "cat <path>",
+	Short: "Print a file of the repository",
+	Long: `Decrypt the file at the given path of the repository and write its content to the standard output,
+	without mounting the repository.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ReadFile(encryptedPrivateKey, args[0], os.Stdout)
+		if !res.Ok {
+			MarshalOutput(res)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(catCmd)
+	SetKeyOrPasswordFlags(catCmd)
+}
@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// compressionCmd represents the compression command
+var compressionCmd = &cobra.Command{
+	Use:   "compression path [zstd|none|inherit]",
+	Short: "Show or set the compression of the files of a directory",
+	Long: `Show the compression the new files of the directory are compressed with before encryption.
+	With a compression, set the compression of the directory, which applies to its sub directories that do not set theirs:
+	zstd, none, or inherit to use the compression of the parent directory. The repository does not compress files by default.
+	The files whose content is already compressed, such as archives, images and videos, are not compressed again,
+	and the files already encrypted keep their compression, which is stored in their header.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		compression := ""
+		if len(args) == 2 {
+			compression = args[1]
+		}
+		res := ctbApp.Compression(encryptedPrivateKey, args[0], compression)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(compressionCmd)
+	SetRequiredKeyFlag(compressionCmd)
+}
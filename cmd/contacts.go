@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// contactsCmd represents the contacts command
+var contactsCmd = &cobra.Command{
+	Use:   "contacts",
+	Short: "List the address book of the repository",
+	Long: `List the contacts of the address book with the fingerprint of their public key.
+	Contacts can be used instead of public keys in the share, unshare and list-access commands.
+	Compare the fingerprint with the owner of the key before sharing with a new contact.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListContacts()
+		MarshalOutput(res)
+	},
+}
+
+// contactsAddCmd represents the contacts add command
+var contactsAddCmd = &cobra.Command{
+	Use:   "add <name> <public key>",
+	Short: "Add a contact to the address book",
+	Long: `Add a contact with the given name and public key to the address book. The contact is signed with your private key.
+	The name and the email must not be used by another contact. Use the replace flag to change the contact of a public key.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		email, _ := cmd.Flags().GetString("email")
+		replace, _ := cmd.Flags().GetBool("replace")
+		res := ctbApp.AddContact(encryptedPrivateKey, args[0], email, args[1], replace)
+		MarshalOutput(res)
+	},
+}
+
+// contactsRemoveCmd represents the contacts remove command
+var contactsRemoveCmd = &cobra.Command{
+	Use:   "remove <name|email|public key>",
+	Short: "Remove a contact from the address book",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RemoveContact(encryptedPrivateKey, args[0])
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(contactsCmd)
+	contactsCmd.AddCommand(contactsAddCmd)
+	contactsCmd.AddCommand(contactsRemoveCmd)
+	SetRequiredKeyFlag(contactsAddCmd)
+	SetRequiredKeyFlag(contactsRemoveCmd)
+	contactsAddCmd.Flags().StringP("email", "e", "", "email of the contact")
+	contactsAddCmd.Flags().Bool("replace", false, "replace the contact of the public key if it is already in the address book")
+}
@@ -0,0 +1,32 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// expireCmd represents the expire command
+var expireCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Remove the expired shares",
+	Long: `Remove the shares whose expiry has passed (see the expires flag of the share command).
+	The key of every directory the recipient of an expired share could reach is replaced,
+	so that the files written afterwards are not readable by the recipient.
+	The shares reaching directories you have no access to are kept, as their keys cannot be replaced, and are reported as skipped.
+	The expired shares are also removed when the repository is mounted in read-write mode.
+	Run with the dry-run flag first to review the changes.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		res := ctbApp.Expire(encryptedPrivateKey, dryRun)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(expireCmd)
+	SetRequiredKeyFlag(expireCmd)
+	expireCmd.Flags().Bool("dry-run", false, "Only report the changes, without applying them.")
+}
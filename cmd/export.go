@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"ctb-cli/app"
+	"ctb-cli/core"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export --age <path> <local path>",
+	Short: "Export files of the repository as age files",
+	Long: `Encrypt the file with the given path of the repository as a standard age file for the recipients given by the to flag,
+	which can be decrypted with the age tools. The recipients are age recipients (age1...), public keys, or names or emails of contacts.
+	Use "-" as the local path to write the age file to the standard output.
+	Directories are only exported with --recursive, to a local directory with an age file per file, named with the .age extension.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if age, _ := cmd.Flags().GetBool("age"); !age {
+			MarshalOutput(core.NewAppResultWithError(app.ErrFormatRequired))
+			return
+		}
+		recipients, _ := cmd.Flags().GetStringArray("to")
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.ExportAge(encryptedPrivateKey, args[0], recipients, args[1], recursive, os.Stdout)
+		if args[1] != "-" || !res.Ok {
+			MarshalOutput(res)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+	SetRequiredKeyFlag(exportCmd)
+	exportCmd.Flags().Bool("age", false, "Export the files in the age format.")
+	exportCmd.Flags().StringArray("to", nil, "Recipient age recipient, public key, name or email of a contact. Can be repeated.")
+	exportCmd.Flags().BoolP("recursive", "r", false, "Export directories recursively.")
+	if err := exportCmd.MarkFlagRequired("to"); err != nil {
+		panic(err)
+	}
+}
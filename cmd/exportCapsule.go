@@ -0,0 +1,42 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCapsuleCmd represents the export-capsule command
+var exportCapsuleCmd = &cobra.Command{
+	Use:   "export-capsule <path>",
+	Short: "Export files to a capsule for a user without access",
+	Long: `Export the file or directory with the specified path to a capsule, a single self-contained file holding the
+	encrypted files with their keys sealed to the recipient given by the to flag: a public key or the name or email of a contact.
+	The capsule can be delivered by any means, by email or on a USB drive, to the recipient, who opens it with the open-capsule
+	command without access to the repository. Use "-" as the output to write the capsule to the standard output.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		output, _ := cmd.Flags().GetString("output")
+		res := ctbApp.ExportCapsule(encryptedPrivateKey, args[0], to, output, os.Stdout)
+		if output != "-" || !res.Ok {
+			MarshalOutput(res)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCapsuleCmd)
+	SetRequiredKeyFlag(exportCapsuleCmd)
+	exportCapsuleCmd.Flags().String("to", "", "Recipient public key, name or email of a contact.")
+	exportCapsuleCmd.Flags().StringP("output", "o", "", "Local file to write the capsule to, or - for the standard output.")
+	if err := exportCapsuleCmd.MarkFlagRequired("to"); err != nil {
+		panic(err)
+	}
+	if err := exportCapsuleCmd.MarkFlagRequired("output"); err != nil {
+		panic(err)
+	}
+}
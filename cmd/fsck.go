@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"ctb-cli/core"
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the consistency of the repository",
+	Long: `Walk the repository and report missing or damaged vaults, links, objects and key shares.
+	With --full, the files you have access to are decrypted completely, which requires your private key.
+	The command exits with status 1 if any error is found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		full, _ := cmd.Flags().GetBool("full")
+		if full && encryptedPrivateKey == "" {
+			return errors.New("--full requires your private key (--key)")
+		}
+		res := ctbApp.Fsck(encryptedPrivateKey, full)
+		MarshalOutput(res)
+		if report, ok := res.Result.(core.FsckReport); !res.Ok || ok && report.Errors > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(fsckCmd)
+	fsckCmd.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key. Required for --full.")
+	fsckCmd.Flags().Bool("full", false, "Decrypt the files you have access to completely.")
+}
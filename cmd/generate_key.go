@@ -13,13 +13,17 @@ var generateKeyCmd = &cobra.Command{
 	Short: "Generate a new private key for user",
 	Long: `Generate a new private key for user and return it as a string. The key is used to encrypt and decrypt data keys and vaults.
 	This funtion doesnt affect the state of the repository and only returns the key. The key is not stored in the repository.
-	You can use join command to join the repository and store the corresponding public key in the repository.`,
+	You can use join command to join the repository and store the corresponding public key in the repository.
+	With --hybrid, the key also holds an ML-KEM-768 key pair, and the data keys shared with the user are sealed with both X25519 and ML-KEM-768,
+	so that recorded repository metadata stays protected against future quantum computers. Classic and hybrid members can share the same repository.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		res := ctbApp.GenerateUserKey()
+		hybrid, _ := cmd.Flags().GetBool("hybrid")
+		res := ctbApp.GenerateUserKey(hybrid)
 		MarshalOutput(res)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(generateKeyCmd)
+	generateKeyCmd.Flags().Bool("hybrid", false, "Generate a hybrid X25519 and ML-KEM-768 key")
 }
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// getCmd represents the get command
+var getCmd = &cobra.Command{
+	Use:   "get <path> <local path>",
+	Short: "Copy files of the repository to the local file system",
+	Long: `Decrypt the file or directory at the given path of the repository to the local path, without mounting it.
+	Use "-" as the local path to write the file to the standard output.
+	Directories are only copied with --recursive.
+	Use the password flag instead of the key flag to get the files shared with a password recipient.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[1] == "-" {
+			res := ctbApp.ReadFile(encryptedPrivateKey, args[0], os.Stdout)
+			if !res.Ok {
+				MarshalOutput(res)
+			}
+			return
+		}
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.GetFiles(encryptedPrivateKey, args[0], args[1], recursive)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(getCmd)
+	SetKeyOrPasswordFlags(getCmd)
+	getCmd.Flags().BoolP("recursive", "r", false, "Copy directories recursively.")
+}
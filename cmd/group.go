@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// groupCmd represents the group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "List the groups of the repository",
+	Long: `List the groups of the repository with their members.
+	Share a file or directory with a group by giving the group name prefixed by "@" as recipient, e.g. share docs/ @team.
+	Adding or removing a member of a group only changes the group, not the files shared with it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListGroups()
+		MarshalOutput(res)
+	},
+}
+
+// groupCreateCmd represents the group create command
+var groupCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a group",
+	Long:  `Create a group with the given name. You are the first member of the group.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.CreateGroup(encryptedPrivateKey, args[0])
+		MarshalOutput(res)
+	},
+}
+
+// groupAddCmd represents the group add command
+var groupAddCmd = &cobra.Command{
+	Use:   "add <name> <user>",
+	Short: "Add a member to a group",
+	Long: `Add the user, given by public key or by the name or email of a contact, to the group.
+	You must be a member of the group.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.AddGroupMember(encryptedPrivateKey, args[0], args[1])
+		MarshalOutput(res)
+	},
+}
+
+// groupRemoveCmd represents the group remove command
+var groupRemoveCmd = &cobra.Command{
+	Use:   "remove <name> <user>",
+	Short: "Remove a member from a group",
+	Long: `Remove the user, given by public key or by the name or email of a contact, from the group.
+	You must be a member of the group. The key pair of the group is replaced and the data keys shared
+	with the group are shared with the new key pair, so you must have access to them.
+	The user may have kept the data keys, rotate them to revoke the access to the files changed later.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RemoveGroupMember(encryptedPrivateKey, args[0], args[1])
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupCreateCmd)
+	groupCmd.AddCommand(groupAddCmd)
+	groupCmd.AddCommand(groupRemoveCmd)
+	SetRequiredKeyFlag(groupCreateCmd)
+	SetRequiredKeyFlag(groupAddCmd)
+	SetRequiredKeyFlag(groupRemoveCmd)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"ctb-cli/app"
+	"ctb-cli/core"
+
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import --age <local path> <path>",
+	Short: "Import age files into the repository",
+	Long: `Decrypt the local age file with the identity and encrypt it into the given path of the repository.
+	The identity is an age identity (AGE-SECRET-KEY-1...) or the path of an age identity file; your own key is used by default,
+	as the keys of the repository are age X25519 keys (see the age-key command).
+	Directories are only imported with --recursive: their .age files are imported without the extension, the other files are skipped.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if age, _ := cmd.Flags().GetBool("age"); !age {
+			MarshalOutput(core.NewAppResultWithError(app.ErrFormatRequired))
+			return
+		}
+		identity, _ := cmd.Flags().GetString("identity")
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.ImportAge(encryptedPrivateKey, args[0], args[1], identity, recursive)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	SetRequiredKeyFlag(importCmd)
+	importCmd.Flags().Bool("age", false, "Import files in the age format.")
+	importCmd.Flags().StringP("identity", "i", "", "Age identity, or path of an age identity file. Defaults to your key.")
+	importCmd.Flags().BoolP("recursive", "r", false, "Import directories recursively.")
+}
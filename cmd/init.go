@@ -14,7 +14,11 @@ var initCmd = &cobra.Command{
 	Long: `Init in folder. This command should be run in the root of the folder you want to use as a repository. It creates the necessary files to use the repository.
 	The user who runs this command is automatically joined in the repository as the owner.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		res := ctbApp.InitRepo(encryptedPrivateKey)
+		name, _ := cmd.Flags().GetString("name")
+		alg, _ := cmd.Flags().GetString("alg")
+		encryptNames, _ := cmd.Flags().GetBool("encrypt-names")
+		requireHybrid, _ := cmd.Flags().GetBool("require-hybrid")
+		res := ctbApp.InitRepo(encryptedPrivateKey, name, alg, encryptNames, requireHybrid)
 		MarshalOutput(res)
 	},
 }
@@ -22,4 +26,8 @@ var initCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(initCmd)
 	SetRequiredKeyFlag(initCmd)
+	initCmd.Flags().StringP("name", "n", "", "display name of the user in the member registry")
+	initCmd.Flags().String("alg", "", "AEAD algorithm of the files: AEAD_ChaCha20_Poly1305 (default), AEAD_AES_256_GCM or AEAD_XChaCha20_Poly1305")
+	initCmd.Flags().Bool("encrypt-names", false, "store the names of the files and directories encrypted, they cannot be changed afterwards")
+	initCmd.Flags().Bool("require-hybrid", false, "seal the data keys with both X25519 and ML-KEM-768 only, which disables groups, password recipients, invitations and capsules")
 }
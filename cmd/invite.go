@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// inviteCmd represents the invite command
+var inviteCmd = &cobra.Command{
+	Use:   "invite <path>",
+	Short: "Invite a user who has no key yet",
+	Long: `Create a one-time invitation to the file or directory with the specified path, for a user who has no key yet.
+	The invitation is protected by a random code, printed by this command, or by the given passphrase.
+	Give the code, or the id of the invitation and the passphrase, to the invitee, who redeems it with the redeem command
+	after generating a key with the generate-key command. The invitee then joins the repository as a guest.
+	The invitation expires after 7 days unless the expires flag is given. You must be a member of the repository, and not a guest.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		passphrase, _ := cmd.Flags().GetString("passphrase")
+		expires, _ := cmd.Flags().GetString("expires")
+		res := ctbApp.Invite(encryptedPrivateKey, args[0], passphrase, expires)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(inviteCmd)
+	SetRequiredKeyFlag(inviteCmd)
+	inviteCmd.Flags().String("passphrase", "", "Protect the invitation with this passphrase instead of a random code.")
+	inviteCmd.Flags().String("expires", "", "Expiry of the invitation, a date (YYYY-MM-DD) or a time in RFC 3339 format.")
+}
@@ -10,12 +10,13 @@ import (
 // joinCmd represents the join command
 var joinCmd = &cobra.Command{
 	Use:   "join",
-	Short: "Join user to the repository",
-	Long: `Join user to the repository. This command join the current user to the repository by storing the corresponding public key in the repository. 
+	Short: "Request to join the repository",
+	Long: `Request to join the repository. This command writes a join request of the current user, signed with the private key, in the repository.
+	The user becomes a member when an existing member approves the request with the approve command.
 	Use generate-key command to generate the private key.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// join the user
-		res := ctbApp.Join(encryptedPrivateKey)
+		name, _ := cmd.Flags().GetString("name")
+		res := ctbApp.Join(encryptedPrivateKey, name)
 		MarshalOutput(res)
 	},
 }
@@ -23,4 +24,5 @@ var joinCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(joinCmd)
 	SetRequiredKeyFlag(joinCmd)
+	joinCmd.Flags().StringP("name", "n", "", "display name of the user in the member registry")
 }
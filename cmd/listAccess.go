@@ -12,9 +12,19 @@ var listAccessCmd = &cobra.Command{
 	Use:   "list-access",
 	Short: "List access to a file or directory",
 	Long: `This command lists the access to a file or directory located at the specified path.
-	The access list includes the public keys of users who have access to the file or directory.`,
+	The access list includes the public keys of users who have access to the file or directory,
+	with their name if they are in the address book.
+	Use the recursive flag to list the access to every file and directory below the path,
+	and the user flag to list only the paths the given user has access to.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		user, _ := cmd.Flags().GetString("user")
+		if recursive || user != "" {
+			MarshalOutput(ctbApp.ListAccessTree(path, user))
+			return
+		}
 		res := ctbApp.ListAccess(path)
 		MarshalOutput(res)
 	},
@@ -22,14 +32,6 @@ var listAccessCmd = &cobra.Command{
 
 func init() {
 	RootCmd.AddCommand(listAccessCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// listAccessCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// listAccessCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	listAccessCmd.Flags().BoolP("recursive", "R", false, "List the access to every file and directory below the path.")
+	listAccessCmd.Flags().StringP("user", "u", "", "Public key, or name or email of a contact. Only the paths the user has access to are listed, recursively.")
 }
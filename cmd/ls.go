@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// lsCmd represents the ls command
+var lsCmd = &cobra.Command{
+	Use:   "ls [path]",
+	Short: "List files in the repository",
+	Long: `List the files and directories at the given path of the repository, without mounting it.
+	The path defaults to the root of the repository.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "/"
+		if len(args) > 0 {
+			path = args[0]
+		}
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.ListFiles(encryptedPrivateKey, path, recursive)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(lsCmd)
+	SetKeyOrPasswordFlags(lsCmd)
+	lsCmd.Flags().BoolP("recursive", "R", false, "List sub directories recursively.")
+}
@@ -0,0 +1,35 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// membersCmd represents the members command
+var membersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "List the members of the repository",
+	Long: `List the members of the member registry with their role, who approved them and whether their signature chain is verified.
+	Use members requests command to list the pending join requests.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListMembers()
+		MarshalOutput(res)
+	},
+}
+
+// membersRequestsCmd represents the members requests command
+var membersRequestsCmd = &cobra.Command{
+	Use:   "requests",
+	Short: "List the pending join requests",
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListJoinRequests()
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(membersCmd)
+	membersCmd.AddCommand(membersRequestsCmd)
+}
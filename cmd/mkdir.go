@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// mkdirCmd represents the mkdir command
+var mkdirCmd = &cobra.Command{
+	Use:   "mkdir <path>",
+	Short: "Create a directory in the repository",
+	Long:  `Create a directory at the given path of the repository, without mounting it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		parents, _ := cmd.Flags().GetBool("parents")
+		res := ctbApp.MakeDir(encryptedPrivateKey, args[0], parents)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mkdirCmd)
+	SetRequiredKeyFlag(mkdirCmd)
+	mkdirCmd.Flags().Bool("parents", false, "Create the missing parent directories, no error if the directory exists.")
+}
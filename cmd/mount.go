@@ -6,6 +6,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -14,18 +15,78 @@ import (
 var mountCmd = &cobra.Command{
 	Use:   "mount",
 	Short: "Mount",
-	Long:  `Mount the file system. This command mounts the file system and blocks the terminal.`,
+	Long: `Mount the file system. This command mounts the file system and blocks the terminal.
+	Use the read-only flag to mount the file system without the ability to modify the repository.
+	Use the daemon flag to mount the file system in the background. Running mounts can be listed with the mounts command,
+	inspected with the mount status command, and unmounted with the unmount command.
+	Use the password flag instead of the key flag to mount the files shared with a password recipient, always in read-only mode.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		mount, _ := cmd.Flags().GetString("mount")
-		res := ctbApp.PrepareMount(encryptedPrivateKey, mount)
+		readOnly, _ := cmd.Flags().GetBool("read-only")
+		// The background process prepares the mount itself, so the journal and the expired shares are processed once
+		if daemon, _ := cmd.Flags().GetBool("daemon"); daemon {
+			res := ctbApp.CheckMount(encryptedPrivateKey)
+			if res.Ok {
+				args, secret := backgroundMountArgs(os.Args[1:])
+				res = ctbApp.MountInBackground(args, strings.NewReader(secret))
+			}
+			MarshalOutput(res)
+			return
+		}
+		res := ctbApp.PrepareMount(encryptedPrivateKey, mount, readOnly)
 		MarshalOutput(res)
+		if !res.Ok {
+			return
+		}
 		fmt.Fprint(os.Stdout, "/**********************************\n")
 		ctbApp.Mount()
 	},
 }
 
+// mountStatusCmd represents the mount status command
+var mountStatusCmd = &cobra.Command{
+	Use:   "status [mount point]",
+	Short: "Get the status of running mounts",
+	Long: `Get the status of the running mount serving the given mount point, including the files pending commit and the recent errors.
+	If no mount point is given, the status of every running mount is returned.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			MarshalOutput(ctbApp.ListMounts())
+			return
+		}
+		MarshalOutput(ctbApp.GetMountStatus(args[0]))
+	},
+}
+
+// backgroundMountArgs returns the command line arguments of the background mount process
+// and the secret to write to its standard input.
+// They are the arguments of the current process without the daemon flag, where the private key
+// or the password is replaced by stdinSecret so that it is read from the standard input.
+func backgroundMountArgs(args []string) ([]string, string) {
+	res := make([]string, 0, len(args)+2)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--daemon" || strings.HasPrefix(arg, "--daemon="):
+		case arg == "-k" || arg == "--key" || arg == "--password":
+			i++ // skip the value
+		case strings.HasPrefix(arg, "-k") || strings.HasPrefix(arg, "--key=") || strings.HasPrefix(arg, "--password="):
+		default:
+			res = append(res, arg)
+		}
+	}
+	if password != "" {
+		return append(res, "--password", stdinSecret), password
+	}
+	return append(res, "--key", stdinSecret), encryptedPrivateKey
+}
+
 func init() {
 	RootCmd.AddCommand(mountCmd)
-	SetRequiredKeyFlag(mountCmd)
+	mountCmd.AddCommand(mountStatusCmd)
+	SetKeyOrPasswordFlags(mountCmd)
 	mountCmd.PersistentFlags().StringP("mount", "m", "", "Mount point.")
+	mountCmd.Flags().Bool("read-only", false, "Mount the file system in read-only mode.")
+	mountCmd.Flags().Bool("daemon", false, "Mount the file system in the background.")
 }
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// mountsCmd represents the mounts command
+var mountsCmd = &cobra.Command{
+	Use:   "mounts",
+	Short: "List running mounts",
+	Long:  `List the running mounts of the current user, with their repository, pending commits and recent errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.ListMounts()
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mountsCmd)
+}
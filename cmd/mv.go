@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Use:   "mv <path> <new path>",
+	Short: "Move a file or directory in the repository",
+	Long: `Move or rename the file or directory at the given path of the repository, without mounting it.
+	If the new path is an existing directory, the file or directory is moved into it.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.MoveFile(encryptedPrivateKey, args[0], args[1])
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(mvCmd)
+	SetRequiredKeyFlag(mvCmd)
+}
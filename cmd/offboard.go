@@ -0,0 +1,31 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// offboardCmd represents the offboard command
+var offboardCmd = &cobra.Command{
+	Use:   "offboard <user>",
+	Short: "Remove a user from the repository",
+	Long: `Remove the user, given by public key or by the name or email of a contact, from the repository.
+	Every key shared with the user is removed, the user is removed from their groups whose keys are replaced,
+	the key of every vault the user could reach is replaced so that the files written afterwards are not readable by the user,
+	and the membership of the user is revoked. The files written before stay readable with the keys the user may have kept.
+	You must be an owner of the repository. Run with the dry-run flag first to review the changes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		res := ctbApp.Offboard(encryptedPrivateKey, args[0], dryRun)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(offboardCmd)
+	SetRequiredKeyFlag(offboardCmd)
+	offboardCmd.Flags().Bool("dry-run", false, "Only report the changes, without applying them.")
+}
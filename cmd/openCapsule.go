@@ -0,0 +1,33 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// openCapsuleCmd represents the open-capsule command
+var openCapsuleCmd = &cobra.Command{
+	Use:   "open-capsule <capsule> [local path]",
+	Short: "Decrypt the files of a capsule",
+	Long: `Decrypt the files of a capsule created by the export-capsule command into the local directory,
+	the current directory by default. The capsule must be sealed to your key, no repository is needed.
+	Use "-" as the capsule to read it from the standard input. Existing files are not overwritten.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dst := "."
+		if len(args) > 1 {
+			dst = args[1]
+		}
+		res := ctbApp.OpenCapsule(encryptedPrivateKey, args[0], os.Stdin, dst)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(openCapsuleCmd)
+	SetRequiredKeyFlag(openCapsuleCmd)
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"bytes"
 	"ctb-cli/core"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -18,6 +20,7 @@ const (
 	outputEnumText outputEnum = "text"
 	outputEnumYaml outputEnum = "yaml"
 	outputEnumXml  outputEnum = "xml"
+	outputEnumCsv  outputEnum = "csv"
 )
 
 // String is used both by fmt.Print and by Cobra in help text
@@ -28,11 +31,11 @@ func (e *outputEnum) String() string {
 // Set is used by Cobra to parse the CLI flags
 func (e *outputEnum) Set(v string) error {
 	switch v {
-	case "json", "text", "yaml", "xml":
+	case "json", "text", "yaml", "xml", "csv":
 		*e = outputEnum(v)
 		return nil
 	default:
-		return errors.New(`must be one of "text", "josn", "yaml", or "xml"`)
+		return errors.New(`must be one of "text", "josn", "yaml", "xml" or "csv"`)
 	}
 }
 
@@ -74,6 +77,38 @@ func MarshalOutput(result core.AppResult) {
 		if result.Result != nil {
 			res = fmt.Appendf(res, "%v", result.Result)
 		}
+	case outputEnumCsv:
+		if !result.Ok {
+			res = fmt.Appendf(res, "Error\n%v", result.Err)
+			break
+		}
+		if result.Result == nil {
+			break
+		}
+		records, ok := csvRecords(result.Result)
+		if !ok {
+			res = fmt.Appendf(res, "Error\n%v", errCsvNotSupported)
+			break
+		}
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(records); err != nil {
+			panic(err)
+		}
+		res = buf.Bytes()
 	}
 	fmt.Println(string(res))
 }
+
+var errCsvNotSupported = errors.New("the csv output is not supported by this command")
+
+// csvRecords returns the CSV records of the result, or false if the result cannot be written as CSV.
+func csvRecords(result interface{}) ([][]string, bool) {
+	switch r := result.(type) {
+	case interface{ CSVRecords() [][]string }:
+		return r.CSVRecords(), true
+	case core.KeyAccessList:
+		return core.KeyAccessListCSVRecords(r), true
+	}
+	return nil, false
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// putCmd represents the put command
+var putCmd = &cobra.Command{
+	Use:   "put <local path> <path>",
+	Short: "Copy local files into the repository",
+	Long: `Encrypt the local file or directory into the given path of the repository, without mounting it.
+	Use "-" as the local path to read the file from the standard input.
+	Directories are only copied with --recursive.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] == "-" {
+			res := ctbApp.WriteFile(encryptedPrivateKey, args[1], os.Stdin)
+			MarshalOutput(res)
+			return
+		}
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.PutFiles(encryptedPrivateKey, args[0], args[1], recursive)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(putCmd)
+	SetRequiredKeyFlag(putCmd)
+	putCmd.Flags().BoolP("recursive", "r", false, "Copy directories recursively.")
+}
@@ -0,0 +1,32 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// redeemCmd represents the redeem command
+var redeemCmd = &cobra.Command{
+	Use:   "redeem <code>",
+	Short: "Redeem an invitation",
+	Long: `Redeem an invitation created with the invite command, given by its code,
+	or by its id with the passphrase flag if the invitation is protected by a passphrase.
+	The file or directory of the invitation is shared with you, and you join the repository as a guest if you are not a member yet.
+	Use generate-key command to generate the private key.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		passphrase, _ := cmd.Flags().GetString("passphrase")
+		name, _ := cmd.Flags().GetString("name")
+		res := ctbApp.Redeem(encryptedPrivateKey, args[0], passphrase, name)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(redeemCmd)
+	SetRequiredKeyFlag(redeemCmd)
+	redeemCmd.Flags().String("passphrase", "", "Passphrase of the invitation, given with the id of the invitation.")
+	redeemCmd.Flags().StringP("name", "n", "", "display name of the user in the member registry")
+}
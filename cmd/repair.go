@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// repairCmd represents the repair command
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Repair the repository",
+	Long: `Fix the problems found by fsck that can be fixed without losing data: missing system folders,
+	configuration files and vault links are recreated, unused key shares and vault keys are removed.
+	With your private key, wrong file sizes are corrected and files whose object cannot be decrypted are quarantined.
+	Every file changed or removed is saved to a backup first, the repair can be undone with the repair restore command.
+	Use the dry-run flag to list the fixes without applying them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		res := ctbApp.Repair(encryptedPrivateKey, dryRun)
+		MarshalOutput(res)
+	},
+}
+
+// repairRestoreCmd represents the repair restore command
+var repairRestoreCmd = &cobra.Command{
+	Use:   "restore [backup id]",
+	Short: "Undo a repair",
+	Long:  `Undo the repair with the given backup id, restoring the files it changed or removed.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.RestoreRepair(args[0])
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(repairCmd)
+	repairCmd.AddCommand(repairRestoreCmd)
+	repairCmd.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key. Required to correct sizes and quarantine unreadable files.")
+	repairCmd.Flags().Bool("dry-run", false, "List the fixes without applying them.")
+}
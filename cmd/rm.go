@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// rmCmd represents the rm command
+var rmCmd = &cobra.Command{
+	Use:   "rm <path>",
+	Short: "Remove a file or directory from the repository",
+	Long: `Remove the file or directory at the given path of the repository, without mounting it.
+	Directories are only removed if they are empty, unless --recursive is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		res := ctbApp.RemoveFile(encryptedPrivateKey, args[0], recursive)
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rmCmd)
+	SetRequiredKeyFlag(rmCmd)
+	rmCmd.Flags().BoolP("recursive", "r", false, "Remove directories and their content recursively.")
+}
@@ -20,6 +20,7 @@ import (
 var cfgFile string
 var repoPath string
 var encryptedPrivateKey string
+var password string
 var output outputEnum = outputEnumText
 
 var ctbApp app.App
@@ -52,7 +53,7 @@ func init() {
 
 	RootCmd.PersistentFlags().StringVarP(&repoPath, "path", "p", "", "path to the repository")
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $USERPROFILE/.ctb/config.yaml)")
-	RootCmd.PersistentFlags().VarP(&output, "output", "o", `Output format. allowed: "json", "text", "yaml", and "xml"`)
+	RootCmd.PersistentFlags().VarP(&output, "output", "o", `Output format. allowed: "json", "text", "yaml", "xml", and "csv"`)
 	RootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
@@ -4,29 +4,32 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"ctb-cli/services/key_service"
-
 	"github.com/spf13/cobra"
 )
 
 // shareCmd represents the share command
 var shareCmd = &cobra.Command{
-	Use:   "share",
+	Use:   "share <path> [recipient]",
 	Short: "Share files with other users",
-	Long: `This command shares file or directory with the specified path with the given public key.
-	The files are shared with the user who has the corresponding private key.`,
-	Args: cobra.MinimumNArgs(1),
+	Long: `This command shares file or directory with the specified path with the given recipient.
+	The recipient is a public key, the name or email of a contact of the address book (see the contacts command),
+	or a group name prefixed by "@" (see the group command).
+	The files are shared with the user who has the corresponding private key.
+	With the expires flag, the share expires at the end of the given date, or at the given RFC 3339 time:
+	it does not give access anymore and is removed by the expire command, which also replaces the keys the user could reach.
+	With the password flag, the files are shared with the password recipient of the passphrase instead of a recipient,
+	for a user who does not manage a key pair: the user reads them by giving the passphrase to the ls, cat, get and mount commands.
+	The recipient is created with the label flag the first time the passphrase is used.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
-		recipient, _ := cmd.Flags().GetString("recipient")
-		if join, _ := cmd.Flags().GetBool("join"); join {
-			joinRes := ctbApp.JoinByUserId(recipient)
-			if !joinRes.Ok && joinRes.Err != key_service.ErrUserAlreadyJoined {
-				MarshalOutput(joinRes)
-				return
-			}
+		expires, _ := cmd.Flags().GetString("expires")
+		if passphrase, _ := cmd.Flags().GetString("password"); passphrase != "" {
+			label, _ := cmd.Flags().GetString("label")
+			res := ctbApp.SharePassword(path, passphrase, label, encryptedPrivateKey, expires)
+			MarshalOutput(res)
+			return
 		}
-		res := ctbApp.Share(path, recipient, encryptedPrivateKey)
+		res := ctbApp.Share(path, getRecipient(cmd, args), encryptedPrivateKey, expires)
 		MarshalOutput(res)
 	},
 }
@@ -34,10 +37,17 @@ var shareCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(shareCmd)
 	SetRequiredKeyFlag(shareCmd)
-	shareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key. Required.")
-	shareCmd.Flags().BoolP("join", "j", false, "Join the user if not already joined.")
-	err := shareCmd.MarkPersistentFlagRequired("recipient")
-	if err != nil {
-		panic(err)
+	SetRecipientFlag(shareCmd)
+	shareCmd.Flags().String("expires", "", "Expiry of the share, a date (YYYY-MM-DD) or a time in RFC 3339 format.")
+	shareCmd.Flags().String("password", "", "Share with the password recipient of this passphrase instead of a recipient.")
+	shareCmd.Flags().String("label", "", "Label of the password recipient created for the passphrase.")
+	shareCmd.MarkFlagsMutuallyExclusive("password", "recipient")
+	// Without recipient when sharing with a password recipient
+	recipientArgs := shareCmd.Args
+	shareCmd.Args = func(cmd *cobra.Command, args []string) error {
+		if passphrase, _ := cmd.Flags().GetString("password"); passphrase != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return recipientArgs(cmd, args)
 	}
 }
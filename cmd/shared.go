@@ -1,6 +1,11 @@
 package cmd
 
 import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
@@ -12,3 +17,72 @@ func SetRequiredKeyFlag(c *cobra.Command) {
 		panic(err)
 	}
 }
+
+// SetRequiredLocalKeyFlag sets the required 'key' flag for a command, without requiring it for its subcommands.
+func SetRequiredLocalKeyFlag(c *cobra.Command) {
+	c.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key. Required.")
+	err := c.MarkFlagRequired("key")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SetKeyOrPasswordFlags sets the 'key' and 'password' flags for a command reading the repository,
+// which is run either with the private key or with the passphrase of a password recipient.
+func SetKeyOrPasswordFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&encryptedPrivateKey, "key", "k", "", "Your private key, or - to read it from the standard input. Required unless the password is given.")
+	c.Flags().StringVar(&password, "password", "", "Passphrase of a password recipient, or - to read it from the standard input, used instead of the private key.")
+	c.MarkFlagsOneRequired("key", "password")
+	c.MarkFlagsMutuallyExclusive("key", "password")
+	c.PreRunE = func(cmd *cobra.Command, args []string) error {
+		var err error
+		if encryptedPrivateKey, err = readStdinSecret(encryptedPrivateKey); err != nil {
+			return err
+		}
+		if password, err = readStdinSecret(password); err != nil {
+			return err
+		}
+		ctbApp.SetPassword(password)
+		return nil
+	}
+}
+
+// stdinSecret is the value of the 'key' or 'password' flag telling to read it from the standard input,
+// keeping it out of the command line visible to the other users of the system.
+const stdinSecret = "-"
+
+// readStdinSecret returns the value of a secret flag, read from the standard input if the flag is stdinSecret.
+func readStdinSecret(value string) (string, error) {
+	if value != stdinSecret {
+		return value, nil
+	}
+	secret, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// SetRecipientFlag sets the 'recipient' flag for a command taking a path and a recipient.
+// The recipient can be given by the flag or as the second argument.
+func SetRecipientFlag(c *cobra.Command) {
+	c.PersistentFlags().StringP("recipient", "r", "", "recipient public key, name or email of a contact, or @group.")
+	c.Args = func(cmd *cobra.Command, args []string) error {
+		if err := cobra.RangeArgs(1, 2)(cmd, args); err != nil {
+			return err
+		}
+		if recipient, _ := cmd.Flags().GetString("recipient"); (recipient == "") == (len(args) == 1) {
+			return errors.New("give the recipient either as the second argument or with the recipient flag")
+		}
+		return nil
+	}
+}
+
+// getRecipient returns the recipient given by the 'recipient' flag or as the second argument.
+func getRecipient(cmd *cobra.Command, args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	recipient, _ := cmd.Flags().GetString("recipient")
+	return recipient
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// unmountCmd represents the unmount command
+var unmountCmd = &cobra.Command{
+	Use:   "unmount <mount point>",
+	Short: "Unmount a running mount",
+	Long: `Unmount the running mount serving the given mount point.
+	The files open for writing are committed before the file system is unmounted.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		res := ctbApp.Unmount(args[0])
+		MarshalOutput(res)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(unmountCmd)
+}
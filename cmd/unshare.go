@@ -9,22 +9,21 @@ import (
 
 // unshareCmd represents the unshare command
 var unshareCmd = &cobra.Command{
-	Use:   "unshare",
+	Use:   "unshare <path> [recipient]",
 	Short: "Unshare files with other users",
-	Long:  `This command unshares file or directory with the specified path with the given public key.`,
+	Long: `This command unshares file or directory with the specified path with the given recipient.
+	The recipient is a public key, the name or email of a contact of the address book (see the contacts command),
+	or a group name prefixed by "@" (see the group command).
+	Your private key is needed to sign the record of the change in the audit log.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		path := args[0]
-		recipient, _ := cmd.Flags().GetString("recipient")
-		res := ctbApp.Unshare(path, recipient)
+		res := ctbApp.Unshare(path, getRecipient(cmd, args), encryptedPrivateKey)
 		MarshalOutput(res)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(unshareCmd)
-	unshareCmd.PersistentFlags().StringP("recipient", "r", "", "recipient public key. Required.")
-	err := unshareCmd.MarkPersistentFlagRequired("recipient")
-	if err != nil {
-		panic(err)
-	}
+	SetRequiredKeyFlag(unshareCmd)
+	SetRecipientFlag(unshareCmd)
 }
@@ -43,3 +43,17 @@ func (c *Config) GetCacheRoot() (string, error) {
 	}
 	return path, nil
 }
+
+// GetStateRoot returns the root path of the state kept on the device of the user, outside the repositories,
+// such as the pinned first owners of the repositories.
+func (c *Config) GetStateRoot() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(homeDir, ".cognitechbridge")
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return "", err
+	}
+	return path, nil
+}
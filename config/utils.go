@@ -9,7 +9,7 @@ func GetStringConfigOrPrintErr(path string, err string) (string, error) {
 	res := viper.GetString(path)
 	if res == "" {
 		fmt.Println(err)
-		return "", fmt.Errorf(err)
+		return "", fmt.Errorf("%s", err)
 	}
 	return res, nil
 }
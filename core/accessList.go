@@ -1,8 +1,110 @@
 package core
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type KeyAccess struct {
 	PublicKey string
 	Inherited bool
+	Name      string     // name of the user in the address book, empty if unknown
+	ExpiresAt *time.Time `json:",omitempty" yaml:",omitempty" xml:",omitempty"` // time the access expires, nil if it does not expire
+}
+
+// String returns the user, the kind of access and its expiry, if any.
+func (a KeyAccess) String() string {
+	return a.displayName() + "\t" + accessKind(a.Inherited) + expirySuffix(a.ExpiresAt)
 }
 
 type KeyAccessList = []KeyAccess
+
+// PathAccess is the access list of a file or directory of a repository.
+type PathAccess struct {
+	Path   string        `json:"path" yaml:"path" xml:"path"`
+	IsDir  bool          `json:"isDir" yaml:"isDir" xml:"isDir"`
+	Access KeyAccessList `json:"access" yaml:"access" xml:"access"`
+}
+
+// AccessReport is the access list of every file and directory of a directory tree, in walk order.
+type AccessReport []PathAccess
+
+// String returns the report with one line per path and user.
+func (r AccessReport) String() string {
+	var sb strings.Builder
+	for _, p := range r {
+		path := p.Path
+		if p.IsDir {
+			path = strings.TrimSuffix(path, "/") + "/"
+		}
+		if len(p.Access) == 0 {
+			fmt.Fprintf(&sb, "%s\t-\n", path)
+		}
+		for _, a := range p.Access {
+			fmt.Fprintf(&sb, "%s\t%s\n", path, a)
+		}
+	}
+	return sb.String()
+}
+
+// CSVRecords returns the report as CSV records with a header, one record per path and user.
+// Paths nobody has access to are listed with an empty user.
+func (r AccessReport) CSVRecords() [][]string {
+	records := [][]string{{"path", "type", "user", "name", "access", "expires"}}
+	for _, p := range r {
+		kind := "file"
+		if p.IsDir {
+			kind = "dir"
+		}
+		if len(p.Access) == 0 {
+			records = append(records, []string{p.Path, kind, "", "", "", ""})
+		}
+		for _, a := range p.Access {
+			records = append(records, []string{p.Path, kind, a.PublicKey, a.Name, accessKind(a.Inherited), expiryDate(a.ExpiresAt)})
+		}
+	}
+	return records
+}
+
+// KeyAccessListCSVRecords returns the access list as CSV records with a header, one record per user.
+func KeyAccessListCSVRecords(list KeyAccessList) [][]string {
+	records := [][]string{{"user", "name", "inherited", "expires"}}
+	for _, a := range list {
+		records = append(records, []string{a.PublicKey, a.Name, strconv.FormatBool(a.Inherited), expiryDate(a.ExpiresAt)})
+	}
+	return records
+}
+
+// displayName returns the name of the user followed by the public key, or the public key if the name is unknown.
+func (a KeyAccess) displayName() string {
+	if a.Name == "" {
+		return a.PublicKey
+	}
+	return a.Name + " (" + a.PublicKey + ")"
+}
+
+// accessKind returns "inherited" for an access inherited from a parent vault and "direct" otherwise.
+func accessKind(inherited bool) string {
+	if inherited {
+		return "inherited"
+	}
+	return "direct"
+}
+
+// expiryDate returns the expiry as a date, or an empty string if there is none.
+func expiryDate(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+	return expiresAt.Format(time.DateOnly)
+}
+
+// expirySuffix returns the expiry to append to a line of text, or an empty string if there is none.
+func expirySuffix(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+	return "\texpires " + expiryDate(expiresAt)
+}
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AlgorithmReport is the AEAD algorithm the new files of the repository are encrypted with, and the supported algorithms.
+type AlgorithmReport struct {
+	Algorithm string   `json:"algorithm" yaml:"algorithm" xml:"algorithm"`
+	Supported []string `json:"supported" yaml:"supported" xml:"supported"`
+}
+
+// String returns the algorithm of the repository followed by the supported algorithms.
+func (r AlgorithmReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n", r.Algorithm)
+	fmt.Fprintf(&sb, "supported: %s\n", strings.Join(r.Supported, ", "))
+	return sb.String()
+}
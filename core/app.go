@@ -3,6 +3,9 @@ package core
 import (
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
 )
 
 // AppResult represents the result of an application operation.
@@ -77,3 +80,35 @@ func NewInvalidRepositoyStatus(isEmpty bool) RepositoryStatus {
 		RepoId:    "",
 	}
 }
+
+// MountStatus represents the status of a running mount.
+type MountStatus struct {
+	MountPoint     string    `json:"mount_point" yaml:"mount_point" xml:"mount_point"`
+	RepoPath       string    `json:"repo_path" yaml:"repo_path" xml:"repo_path"`
+	Pid            int       `json:"pid" yaml:"pid" xml:"pid"`
+	Mounted        bool      `json:"mounted" yaml:"mounted" xml:"mounted"`
+	ReadOnly       bool      `json:"read_only" yaml:"read_only" xml:"read_only"`
+	StartedAt      time.Time `json:"started_at" yaml:"started_at" xml:"started_at"`
+	PendingCommits []string  `json:"pending_commits" yaml:"pending_commits" xml:"pending_commits"`
+	Errors         []string  `json:"errors" yaml:"errors" xml:"errors"`
+}
+
+// FileEntry represents a file or directory of a repository listing.
+type FileEntry struct {
+	Path  string `json:"path" yaml:"path" xml:"path"`
+	IsDir bool   `json:"is_dir" yaml:"is_dir" xml:"is_dir"`
+	Size  int64  `json:"size" yaml:"size" xml:"size"`
+	Mode  string `json:"mode" yaml:"mode" xml:"mode"`
+}
+
+// FileList is a list of files and directories of a repository listing.
+type FileList []FileEntry
+
+// String returns the list in a format similar to `ls -l`, one entry per line.
+func (l FileList) String() string {
+	var sb strings.Builder
+	for _, entry := range l {
+		fmt.Fprintf(&sb, "%s %12d %s\n", entry.Mode, entry.Size, entry.Path)
+	}
+	return sb.String()
+}
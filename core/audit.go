@@ -0,0 +1,145 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditAction is the kind of change recorded in the audit log.
+type AuditAction string
+
+const (
+	AuditShare         AuditAction = "share"          // a key was shared with a recipient
+	AuditUnshare       AuditAction = "unshare"        // a key share of a recipient was removed
+	AuditCreateVault   AuditAction = "create-vault"   // a vault was created for a directory
+	AuditMoveVault     AuditAction = "move-vault"     // the vault of a directory was moved
+	AuditAddMember     AuditAction = "add-member"     // a user was approved as a member of the repository
+	AuditInvite        AuditAction = "invite"         // an invitation to a file or directory was created
+	AuditRedeemInvite  AuditAction = "redeem-invite"  // an invitation was redeemed by a user
+	AuditExportCapsule AuditAction = "export-capsule" // files were exported to a capsule sealed to a recipient
+
+	AuditCreateGroup       AuditAction = "create-group"        // a group was created
+	AuditAddGroupMember    AuditAction = "add-group-member"    // a user was added to a group
+	AuditRemoveGroupMember AuditAction = "remove-group-member" // a user was removed from a group
+	AuditRotateGroupKey    AuditAction = "rotate-group-key"    // the key pair of a group was replaced
+
+	AuditRotateKey        AuditAction = "rotate-key"        // the key of a vault was replaced
+	AuditRevokeMember     AuditAction = "revoke-member"     // a member was revoked from the repository
+	AuditBreakInheritance AuditAction = "break-inheritance" // the key of a vault was replaced by a key not stored in the parent vault
+	AuditExpire           AuditAction = "expire"            // an expired key share of a recipient was removed
+	AuditSetExpiry        AuditAction = "set-expiry"        // the expiry of a key share of a recipient was set or removed
+)
+
+// AuditLogger records the changes of the sharing and membership of the repository.
+type AuditLogger interface {
+	// Log appends an entry to the audit log. target is the recipient for shares and added members (of groups),
+	// the vault id for created vaults, the new path for moved vaults and the new key id for rotated keys.
+	Log(action AuditAction, path string, target string, keyId string) error
+}
+
+// AuditEntry is an entry of the audit log.
+// Every entry holds the hash of the previous one and is signed by the user who made the change.
+type AuditEntry struct {
+	Seq       uint64      `json:"seq" yaml:"seq" xml:"seq"`
+	Time      time.Time   `json:"time" yaml:"time" xml:"time"`
+	Actor     string      `json:"actor" yaml:"actor" xml:"actor"` // public key of the user who made the change
+	Action    AuditAction `json:"action" yaml:"action" xml:"action"`
+	Path      string      `json:"path" yaml:"path" xml:"path"`
+	Target    string      `json:"target,omitempty" yaml:"target,omitempty" xml:"target,omitempty"`
+	KeyId     string      `json:"keyId,omitempty" yaml:"keyId,omitempty" xml:"keyId,omitempty"`
+	ExpiresAt *time.Time  `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty" xml:"expiresAt,omitempty"` // expiry of the key share of a set-expiry entry, nil if it is removed
+	Prev      string      `json:"prev" yaml:"prev" xml:"prev"`                                              // hash of the previous entry, empty for the first one
+	Hash      string      `json:"hash" yaml:"hash" xml:"hash"`
+	Signature string      `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// ComputeHash returns the hex encoded SHA-256 hash of the entry, without its hash and signature.
+func (e AuditEntry) ComputeHash() string {
+	e.Hash = ""
+	e.Signature = ""
+	js, _ := json.Marshal(e)
+	sum := sha256.Sum256(js)
+	return hex.EncodeToString(sum[:])
+}
+
+// SignedMessage returns the message signed by the actor of the entry.
+func (e AuditEntry) SignedMessage() []byte {
+	return []byte("audit-entry:" + e.Hash)
+}
+
+// AuditHead references the last entry of the audit log.
+// It is signed by the user who appended the entry, so that removed entries at the end of the log are detected.
+type AuditHead struct {
+	Seq       uint64 `json:"seq"`
+	Hash      string `json:"hash"`
+	Actor     string `json:"actor"`
+	Signature string `json:"signature"`
+}
+
+// SignedMessage returns the message signed by the actor of the head.
+func (h AuditHead) SignedMessage() []byte {
+	return []byte(fmt.Sprintf("audit-head:%d:%s", h.Seq, h.Hash))
+}
+
+// AuditLog is the list of the entries of the audit log.
+type AuditLog []AuditEntry
+
+// String returns the log with one entry per line.
+func (l AuditLog) String() string {
+	var sb strings.Builder
+	for _, e := range l {
+		fmt.Fprintf(&sb, "%5d %s %-12s %s %s", e.Seq, e.Time.Format(time.RFC3339), e.Action, e.Actor, e.Path)
+		if e.Target != "" {
+			fmt.Fprintf(&sb, " -> %s", e.Target)
+		}
+		if e.ExpiresAt != nil {
+			fmt.Fprintf(&sb, " until %s", e.ExpiresAt.Format(time.RFC3339))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// AuditProblem is a problem found by the verification of the audit log.
+type AuditProblem struct {
+	Seq     uint64 `json:"seq" yaml:"seq" xml:"seq"` // sequence number of the entry, 0 for the head
+	Message string `json:"message" yaml:"message" xml:"message"`
+}
+
+// AuditReport is the result of the verification of the audit log.
+type AuditReport struct {
+	Entries  int            `json:"entries" yaml:"entries" xml:"entries"`
+	Valid    bool           `json:"valid" yaml:"valid" xml:"valid"`
+	Problems []AuditProblem `json:"problems" yaml:"problems" xml:"problems"`
+}
+
+// Add adds a problem to the report and marks the log as invalid.
+func (r *AuditReport) Add(seq uint64, format string, a ...any) {
+	r.Valid = false
+	r.Problems = append(r.Problems, AuditProblem{
+		Seq:     seq,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// String returns the report with one problem per line followed by a summary.
+func (r AuditReport) String() string {
+	var sb strings.Builder
+	for _, p := range r.Problems {
+		if p.Seq == 0 {
+			fmt.Fprintf(&sb, "head: %s\n", p.Message)
+		} else {
+			fmt.Fprintf(&sb, "entry %d: %s\n", p.Seq, p.Message)
+		}
+	}
+	if r.Valid {
+		fmt.Fprintf(&sb, "%d entries verified, the audit log is intact\n", r.Entries)
+	} else {
+		fmt.Fprintf(&sb, "%d entries checked, %d problems found\n", r.Entries, len(r.Problems))
+	}
+	return sb.String()
+}
@@ -0,0 +1,23 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CapsuleReport is the result of exporting files to a capsule, or of opening a capsule.
+type CapsuleReport struct {
+	Path      string   `json:"path" yaml:"path" xml:"path"`                // path of the exported file or directory, or of the directory the capsule is opened in
+	Recipient string   `json:"recipient" yaml:"recipient" xml:"recipient"` // public key the capsule is sealed to
+	Files     []string `json:"files" yaml:"files" xml:"files"`             // files of the capsule, relative to the path
+}
+
+// String returns the report with one line per file, followed by a summary.
+func (r CapsuleReport) String() string {
+	var sb strings.Builder
+	for _, file := range r.Files {
+		fmt.Fprintf(&sb, "%s\n", file)
+	}
+	fmt.Fprintf(&sb, "%d files in the capsule of %s for %s\n", len(r.Files), r.Path, r.Recipient)
+	return sb.String()
+}
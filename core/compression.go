@@ -0,0 +1,20 @@
+package core
+
+import (
+	"fmt"
+)
+
+// CompressionReport is the compression the new files of a directory are compressed with before encryption.
+type CompressionReport struct {
+	Path        string `json:"path" yaml:"path" xml:"path"`
+	Compression string `json:"compression" yaml:"compression" xml:"compression"` // "none" if the files are not compressed
+	Inherited   bool   `json:"inherited" yaml:"inherited" xml:"inherited"`       // true if the compression is set by a parent directory
+}
+
+// String returns the path of the directory followed by its compression.
+func (r CompressionReport) String() string {
+	if r.Inherited {
+		return fmt.Sprintf("%s: %s (inherited)\n", r.Path, r.Compression)
+	}
+	return fmt.Sprintf("%s: %s\n", r.Path, r.Compression)
+}
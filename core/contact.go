@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Contact is an entry of the address book of the repository, mapping a name and an email to a public key.
+// It is signed by the member who added it.
+type Contact struct {
+	PublicKey string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"`
+	Name      string    `json:"name" yaml:"name" xml:"name"`
+	Email     string    `json:"email,omitempty" yaml:"email,omitempty" xml:"email,omitempty"`
+	AddedBy   string    `json:"addedBy" yaml:"addedBy" xml:"addedBy"` // public key of the member who added the contact
+	AddedAt   time.Time `json:"addedAt" yaml:"addedAt" xml:"addedAt"`
+	Signature string    `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// SignedMessage returns the message signed by the member who added the contact.
+func (c Contact) SignedMessage() []byte {
+	c.Signature = ""
+	js, _ := json.Marshal(c)
+	return append([]byte("contact:"), js...)
+}
+
+// Aliases returns the names the contact can be referred to by.
+func (c Contact) Aliases() []string {
+	if c.Email == "" {
+		return []string{c.Name}
+	}
+	return []string{c.Name, c.Email}
+}
+
+// Matches returns true if the alias is the name or the email of the contact, ignoring case.
+func (c Contact) Matches(alias string) bool {
+	for _, a := range c.Aliases() {
+		if strings.EqualFold(a, strings.TrimSpace(alias)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContactEntry is a contact of the address book with the fingerprint of its public key
+// and the result of the verification of its signature.
+type ContactEntry struct {
+	Contact     `yaml:",inline"`
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint" xml:"fingerprint"`
+	Verified    bool   `json:"verified" yaml:"verified" xml:"verified"`
+}
+
+// ContactList is the list of the contacts of the address book.
+type ContactList []ContactEntry
+
+// String returns the list with one contact per line.
+func (l ContactList) String() string {
+	var sb strings.Builder
+	for _, c := range l {
+		name := c.Name
+		if c.Email != "" {
+			name += " <" + c.Email + ">"
+		}
+		status := ""
+		if !c.Verified {
+			status = " (unverified)"
+		}
+		fmt.Fprintf(&sb, "%s  %s  [%s]%s\n", c.PublicKey, name, c.Fingerprint, status)
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DataKeyExpiry is the time a data key shared with a recipient expires, signed by the member who set it.
+type DataKeyExpiry struct {
+	KeyId     string    `json:"keyId" yaml:"keyId" xml:"keyId"`
+	Recipient string    `json:"recipient" yaml:"recipient" xml:"recipient"`
+	Path      string    `json:"path" yaml:"path" xml:"path"` // path the data key is shared at
+	ExpiresAt time.Time `json:"expiresAt" yaml:"expiresAt" xml:"expiresAt"`
+	SignedBy  string    `json:"signedBy" yaml:"signedBy" xml:"signedBy"`
+	Signature string    `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// SignedMessage returns the message signed by the member who set the expiry.
+// The path is not signed, so the expiry stays valid when the directory is moved.
+func (e DataKeyExpiry) SignedMessage() []byte {
+	e.Path = ""
+	e.Signature = ""
+	js, _ := json.Marshal(e)
+	return append([]byte("data-key-expiry:"), js...)
+}
+
+// Expired returns true if the data key is expired at the given time.
+func (e DataKeyExpiry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.After(now)
+}
+
+// ExpiryLog records the expiries of the data key shares in the audit log, where their removal is detected.
+type ExpiryLog interface {
+	// LogExpiry appends the expiry to the audit log, a zero time removes the expiry.
+	LogExpiry(expiry DataKeyExpiry) error
+	// ListLoggedExpiries returns the last expiry logged for each data key shared with a recipient, without path.
+	ListLoggedExpiries() ([]DataKeyExpiry, error)
+}
+
+// ExpiredShare is an expired data key share removed by the expiry sweep, or to be removed for a dry run.
+type ExpiredShare struct {
+	KeyId         string    `json:"keyId" yaml:"keyId" xml:"keyId"`
+	Recipient     string    `json:"recipient" yaml:"recipient" xml:"recipient"`
+	Path          string    `json:"path" yaml:"path" xml:"path"`
+	ExpiresAt     time.Time `json:"expiresAt" yaml:"expiresAt" xml:"expiresAt"`
+	RotatedVaults []string  `json:"rotatedVaults" yaml:"rotatedVaults" xml:"rotatedVaults"`             // vaults whose key is replaced because the recipient could reach them
+	Skipped       string    `json:"skipped,omitempty" yaml:"skipped,omitempty" xml:"skipped,omitempty"` // reason the share is kept, empty if it is removed
+}
+
+// ExpiryReport is the result of the expiry sweep, or the plan of it for a dry run.
+type ExpiryReport struct {
+	DryRun     bool           `json:"dryRun" yaml:"dryRun" xml:"dryRun"`
+	Shares     []ExpiredShare `json:"shares" yaml:"shares" xml:"shares"`
+	Unresolved []string       `json:"unresolved,omitempty" yaml:"unresolved,omitempty" xml:"unresolved,omitempty"` // directories not walked, their names cannot be decrypted
+}
+
+// String returns the report with one line per expired share and replaced vault key, followed by a summary.
+func (r ExpiryReport) String() string {
+	var sb strings.Builder
+	removed := 0
+	for _, s := range r.Shares {
+		if s.Skipped != "" {
+			fmt.Fprintf(&sb, "%-16s %s: key %s shared with %s expired on %s, %s\n", "skip", s.Path, s.KeyId, s.Recipient, s.ExpiresAt.Format(time.DateOnly), s.Skipped)
+			continue
+		}
+		removed++
+		fmt.Fprintf(&sb, "%-16s %s: key %s shared with %s expired on %s\n", "remove-key-share", s.Path, s.KeyId, s.Recipient, s.ExpiresAt.Format(time.DateOnly))
+		for _, path := range s.RotatedVaults {
+			fmt.Fprintf(&sb, "%-16s %s\n", "rotate-vault-key", path)
+		}
+	}
+	for _, path := range r.Unresolved {
+		fmt.Fprintf(&sb, "%-16s %s: the name cannot be decrypted, the shares below are not checked\n", "unresolved", path)
+	}
+	if r.DryRun {
+		fmt.Fprintf(&sb, "%d expired shares to remove (dry run)\n", removed)
+	} else {
+		fmt.Fprintf(&sb, "%d expired shares removed\n", removed)
+	}
+	return sb.String()
+}
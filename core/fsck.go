@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FsckSeverity is the severity of a finding of the repository consistency check.
+type FsckSeverity string
+
+const (
+	FsckSeverityError   FsckSeverity = "error"   // the repository is damaged and data may be unreadable
+	FsckSeverityWarning FsckSeverity = "warning" // the repository holds leftovers that do not affect the data
+	FsckSeverityInfo    FsckSeverity = "info"    // something could not be checked
+)
+
+// Codes of the findings of the repository consistency check.
+const (
+	FsckMissingSystemFolder  = "missing-system-folder"
+	FsckMissingConfig        = "missing-config"
+	FsckMissingVaultLink     = "missing-vault-link"
+	FsckMissingVault         = "missing-vault"
+	FsckUnresolvableVaultKey = "unresolvable-vault-key"
+	FsckInvalidLink          = "invalid-link"
+	FsckMissingObject        = "missing-object"
+	FsckCorruptHeader        = "corrupt-header"
+	FsckUnknownKey           = "unknown-key"
+	FsckCorruptObject        = "corrupt-object"
+	FsckSizeMismatch         = "size-mismatch"
+	FsckOrphanedKeyShare     = "orphaned-key-share"
+	FsckOrphanedVaultKey     = "orphaned-vault-key"
+	FsckOrphanedObject       = "orphaned-object"
+	FsckInvalidRecipient     = "invalid-recipient"
+	FsckNoAccess             = "no-access"
+	FsckUnreadableKeyShares  = "unreadable-key-shares"
+	FsckUnreadableObjects    = "unreadable-objects"
+	FsckUnresolvedName       = "unresolved-name"
+)
+
+// FsckFinding is a single problem found by the repository consistency check.
+type FsckFinding struct {
+	Severity FsckSeverity `json:"severity" yaml:"severity" xml:"severity"`
+	Code     string       `json:"code" yaml:"code" xml:"code"`
+	Path     string       `json:"path" yaml:"path" xml:"path"`
+	Target   string       `json:"target,omitempty" yaml:"target,omitempty" xml:"target,omitempty"` // id of the key or object concerned, recipient/key id for key shares
+	Message  string       `json:"message" yaml:"message" xml:"message"`
+}
+
+// FsckReport is the result of the repository consistency check.
+type FsckReport struct {
+	Dirs     int           `json:"dirs" yaml:"dirs" xml:"dirs"`
+	Files    int           `json:"files" yaml:"files" xml:"files"`
+	Errors   int           `json:"errors" yaml:"errors" xml:"errors"`
+	Warnings int           `json:"warnings" yaml:"warnings" xml:"warnings"`
+	Findings []FsckFinding `json:"findings" yaml:"findings" xml:"findings"`
+}
+
+// Add adds a finding to the report and updates the counters.
+// target is the id of the key or object concerned, or recipient/key id for key shares.
+func (r *FsckReport) Add(severity FsckSeverity, code string, path string, target string, format string, a ...any) {
+	switch severity {
+	case FsckSeverityError:
+		r.Errors++
+	case FsckSeverityWarning:
+		r.Warnings++
+	}
+	r.Findings = append(r.Findings, FsckFinding{
+		Severity: severity,
+		Code:     code,
+		Path:     path,
+		Target:   target,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// String returns the report with one finding per line followed by a summary.
+func (r FsckReport) String() string {
+	var sb strings.Builder
+	for _, f := range r.Findings {
+		fmt.Fprintf(&sb, "%-7s %-22s %s: %s\n", f.Severity, f.Code, f.Path, f.Message)
+	}
+	fmt.Fprintf(&sb, "%d directories, %d files checked: %d errors, %d warnings\n", r.Dirs, r.Files, r.Errors, r.Warnings)
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GroupPrefix marks a recipient as the name of a group, as in "@team".
+const GroupPrefix = "@"
+
+// Group is a named group of users sharing a group key pair.
+// Keys are shared with the public key of the group, and the private key of the group is sealed to each member,
+// so that adding or removing a member only changes the group.
+type Group struct {
+	Name      string    `json:"name" yaml:"name" xml:"name"`
+	PublicKey string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"`
+	CreatedBy string    `json:"createdBy" yaml:"createdBy" xml:"createdBy"` // public key of the member who created the group
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt" xml:"createdAt"`
+	Signature string    `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// SignedMessage returns the message signed by the member who created the group.
+func (g Group) SignedMessage() []byte {
+	g.Signature = ""
+	js, _ := json.Marshal(g)
+	return append([]byte("group:"), js...)
+}
+
+// GroupEntry is a group with its members and the result of the verification of its signature.
+type GroupEntry struct {
+	Group    `yaml:",inline"`
+	Members  []string `json:"members" yaml:"members" xml:"members>member"` // public keys of the members
+	Verified bool     `json:"verified" yaml:"verified" xml:"verified"`
+}
+
+// GroupList is the list of the groups of the repository.
+type GroupList []GroupEntry
+
+// String returns the list with one line per group followed by one line per member.
+func (l GroupList) String() string {
+	var sb strings.Builder
+	for _, g := range l {
+		status := ""
+		if !g.Verified {
+			status = " (unverified)"
+		}
+		fmt.Fprintf(&sb, "%s%s %s%s\n", GroupPrefix, g.Name, g.PublicKey, status)
+		for _, m := range g.Members {
+			fmt.Fprintf(&sb, "  %s\n", m)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InheritanceReport is the result of breaking the inheritance of a directory, or the plan of it for a dry run.
+type InheritanceReport struct {
+	Path          string        `json:"path" yaml:"path" xml:"path"`
+	DryRun        bool          `json:"dryRun" yaml:"dryRun" xml:"dryRun"`
+	KeyId         string        `json:"keyId,omitempty" yaml:"keyId,omitempty" xml:"keyId,omitempty"` // id of the new key of the vault, empty for a dry run
+	Recipients    KeyAccessList `json:"recipients" yaml:"recipients" xml:"recipients"`                // users and groups the new key is shared with
+	LostAccess    KeyAccessList `json:"lostAccess" yaml:"lostAccess" xml:"lostAccess"`                // users who lose access to the directory
+	RotatedVaults []string      `json:"rotatedVaults" yaml:"rotatedVaults" xml:"rotatedVaults"`       // sub directories whose vault key is replaced
+}
+
+// String returns the report with one line per recipient, user losing access and replaced vault key, followed by a summary.
+func (r InheritanceReport) String() string {
+	var sb strings.Builder
+	for _, a := range r.Recipients {
+		fmt.Fprintf(&sb, "%-16s %s\n", "keep-access", a.displayName())
+	}
+	for _, a := range r.LostAccess {
+		fmt.Fprintf(&sb, "%-16s %s (%s)\n", "lose-access", a.displayName(), accessKind(a.Inherited))
+	}
+	for _, path := range r.RotatedVaults {
+		fmt.Fprintf(&sb, "%-16s %s\n", "rotate-vault-key", path)
+	}
+	if r.DryRun {
+		fmt.Fprintf(&sb, "%d users would lose access to %s (dry run)\n", len(r.LostAccess), r.Path)
+	} else {
+		fmt.Fprintf(&sb, "inheritance of %s is broken, %d users lost access\n", r.Path, len(r.LostAccess))
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Invite is a one-time invitation to access a file or directory, for a user who has no key yet.
+// The key of the file or directory is sealed to a key pair derived from a secret, a random code or a passphrase,
+// which the member who created the invitation gives to the invitee. The invitation is signed by that member.
+// Once redeemed, the sealed key is removed and the invitation records the public key of the invitee.
+type Invite struct {
+	Id         string    `json:"id" yaml:"id" xml:"id"`
+	Path       string    `json:"path" yaml:"path" xml:"path"`
+	KeyId      string    `json:"keyId" yaml:"keyId" xml:"keyId"`
+	SharePath  string    `json:"sharePath" yaml:"sharePath" xml:"sharePath"` // path the key is shared at with the invitee
+	PublicKey  string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"` // public key derived from the secret
+	Salt       string    `json:"salt" yaml:"salt" xml:"salt"`
+	SealedKey  string    `json:"sealedKey,omitempty" yaml:"sealedKey,omitempty" xml:"sealedKey,omitempty"`
+	CreatedBy  string    `json:"createdBy" yaml:"createdBy" xml:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt" yaml:"createdAt" xml:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt" yaml:"expiresAt" xml:"expiresAt"`
+	Signature  string    `json:"signature" yaml:"signature" xml:"signature"`
+	RedeemedBy string    `json:"redeemedBy,omitempty" yaml:"redeemedBy,omitempty" xml:"redeemedBy,omitempty"`
+	RedeemedAt time.Time `json:"redeemedAt,omitempty" yaml:"redeemedAt,omitempty" xml:"redeemedAt,omitempty"`
+}
+
+// SignedMessage returns the message signed by the member who created the invitation.
+// The sealed key and the redemption are not signed, as they change when the invitation is redeemed.
+func (i Invite) SignedMessage() []byte {
+	i.Signature = ""
+	i.SealedKey = ""
+	i.RedeemedBy = ""
+	i.RedeemedAt = time.Time{}
+	js, _ := json.Marshal(i)
+	return append([]byte("invite:"), js...)
+}
+
+// InviteCode is a created invitation with the code to give to the invitee.
+// The code is empty if the invitation is protected by a passphrase, the invitee then needs the id and the passphrase.
+type InviteCode struct {
+	Id        string    `json:"id" yaml:"id" xml:"id"`
+	Code      string    `json:"code,omitempty" yaml:"code,omitempty" xml:"code,omitempty"`
+	Path      string    `json:"path" yaml:"path" xml:"path"`
+	ExpiresAt time.Time `json:"expiresAt" yaml:"expiresAt" xml:"expiresAt"`
+}
+
+// String returns the invitation and its code.
+func (c InviteCode) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "invitation %s to %s, expires %s\n", c.Id, c.Path, c.ExpiresAt.Format(time.RFC3339))
+	if c.Code != "" {
+		fmt.Fprintf(&sb, "code: %s\n", c.Code)
+	}
+	return sb.String()
+}
+
+// String returns the invitation and its redemption.
+func (i Invite) String() string {
+	if i.RedeemedBy == "" {
+		return fmt.Sprintf("invitation %s to %s, expires %s\n", i.Id, i.Path, i.ExpiresAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("invitation %s to %s redeemed by %s\n", i.Id, i.Path, i.RedeemedBy)
+}
@@ -3,7 +3,10 @@ package core
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"strings"
 
 	"github.com/btcsuite/btcutil/base58"
 	"golang.org/x/crypto/curve25519"
@@ -13,6 +16,12 @@ var (
 	ErrInvalidPublicKey = errors.New("invalid public key")
 )
 
+const (
+	PrivateKeySize       = 32                              // PrivateKeySize is the size of an X25519 private key.
+	HybridSeedSize       = 64                              // HybridSeedSize is the size of the ML-KEM-768 seed of a hybrid private key.
+	HybridPrivateKeySize = PrivateKeySize + HybridSeedSize // HybridPrivateKeySize is the size of a hybrid private key, the X25519 key followed by the ML-KEM-768 seed.
+)
+
 type PublicKey struct {
 	value []byte
 }
@@ -63,7 +72,20 @@ func (key PublicKey) Equals(other PublicKey) bool {
 	return bytes.Equal(key.value, other.value)
 }
 
+// Fingerprint returns a short digest of the PublicKey, to compare keys out of band.
+// It is the first 10 bytes of the SHA-256 hash of the key, in groups of 4 hex digits.
+func (key PublicKey) Fingerprint() string {
+	sum := sha256.Sum256(key.value)
+	digits := hex.EncodeToString(sum[:10])
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
 // PrivateKey represents a private key used for cryptographic operations.
+// A hybrid private key also holds the seed of an ML-KEM-768 key pair after the X25519 key.
 type PrivateKey struct {
 	value []byte
 }
@@ -73,18 +95,20 @@ func EmptyPrivateKey() PrivateKey {
 }
 
 // NewPrivateKeyFromEncoded creates a PrivateKey from an encoded base58 string.
+// Hybrid private keys are about 131 characters long.
 func NewPrivateKeyFromEncoded(encoded string) (PrivateKey, error) {
-	if len(encoded) != 44 {
+	value := base58.Decode(encoded)
+	if len(encoded) != 44 && len(value) != HybridPrivateKeySize {
 		return EmptyPrivateKey(), ErrInvalidPublicKey
 	}
 	return PrivateKey{
-		value: base58.Decode(encoded),
+		value: value,
 	}, nil
 }
 
 // NewPrivateKeyFromRand creates a PrivateKey from a random byte slice.
 func NewPrivateKeyFromRand() (PrivateKey, error) {
-	key := make([]byte, 32)
+	key := make([]byte, PrivateKeySize)
 	_, err := rand.Read(key)
 	if err != nil {
 		return EmptyPrivateKey(), err
@@ -101,11 +125,27 @@ func NewPrivateKeyFromBytes(bytes []byte) PrivateKey {
 	}
 }
 
-// Bytes returns the byte slice representation of the PrivateKey.
+// Bytes returns the byte slice representation of the X25519 private key.
 func (key PrivateKey) Bytes() []byte {
+	if len(key.value) > PrivateKeySize {
+		return key.value[:PrivateKeySize]
+	}
 	return key.value
 }
 
+// IsHybrid returns true if the PrivateKey also holds an ML-KEM-768 key.
+func (key PrivateKey) IsHybrid() bool {
+	return len(key.value) == HybridPrivateKeySize
+}
+
+// HybridSeed returns the seed of the ML-KEM-768 key of a hybrid PrivateKey, nil for other keys.
+func (key PrivateKey) HybridSeed() []byte {
+	if !key.IsHybrid() {
+		return nil
+	}
+	return key.value[PrivateKeySize:]
+}
+
 // Unsafe returns an UnsafePrivateKey for unsafe operations.
 func (key PrivateKey) Unsafe() UnsafePrivateKey {
 	return UnsafePrivateKey{key}
@@ -136,9 +176,9 @@ type UnsafePrivateKey struct {
 	PrivateKey
 }
 
-// Encode returns the base58 encoded string representation of the PrivateKey (Unsafe).
+// Encode returns the base58 encoded string representation of the PrivateKey (Unsafe), with the seed of hybrid keys.
 func (key UnsafePrivateKey) Encode() string {
-	return base58.Encode(key.Bytes())
+	return base58.Encode(key.value)
 }
 
 // String returns the base58 encoded string representation of the PrivateKey (Unsafe).
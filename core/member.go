@@ -0,0 +1,143 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MemberRole is the role of a member of the repository.
+type MemberRole string
+
+const (
+	MemberRoleOwner  MemberRole = "owner"  // can approve members and owners
+	MemberRoleMember MemberRole = "member" // can approve members
+	MemberRoleGuest  MemberRole = "guest"  // joined by redeeming an invitation, can only access what is shared with them
+)
+
+// MemberChecker tells whether a user is a verified member of the repository.
+type MemberChecker interface {
+	IsMember(userId string) bool
+}
+
+// MemberLister lists the members of the repository with the result of the verification of their signature chain.
+type MemberLister interface {
+	ListMembers() (MemberList, error)
+}
+
+// TrustRoot stores the public key of the first owner of the repository, which the signature chains of the members lead to.
+// The key is pinned on the device of the user, outside the repository, when the user initializes the member registry
+// or reads it for the first time. It is empty for repositories created before the registry.
+type TrustRoot interface {
+	GetFirstOwner() string
+	SetFirstOwner(publicKey string) error
+}
+
+// KemKeyResolver returns the ML-KEM-768 public keys of the verified members with a hybrid key, by public key.
+type KemKeyResolver interface {
+	ListKemPublicKeys() map[string][]byte
+}
+
+// Member is an entry of the member registry of the repository.
+// It is signed by the member who added it, the first owner signs their own entry with the policy of the repository.
+// The data keys shared with a member with a hybrid key are sealed with both X25519 and ML-KEM-768.
+// A guest who redeemed an invitation is added by the member who created the invitation,
+// and the entry is signed with the key pair of the invitation.
+type Member struct {
+	PublicKey string      `json:"publicKey" yaml:"publicKey" xml:"publicKey"`
+	Name      string      `json:"name" yaml:"name" xml:"name"`
+	Role      MemberRole  `json:"role" yaml:"role" xml:"role"`
+	AddedBy   string      `json:"addedBy" yaml:"addedBy" xml:"addedBy"` // public key of the member who added the member
+	AddedAt   time.Time   `json:"addedAt" yaml:"addedAt" xml:"addedAt"`
+	Invite    string      `json:"invite,omitempty" yaml:"invite,omitempty" xml:"invite,omitempty"` // id of the invitation redeemed by a guest
+	KemKey    string      `json:"kemKey,omitempty" yaml:"kemKey,omitempty" xml:"kemKey,omitempty"` // ML-KEM-768 public key of a member with a hybrid key, raw base64
+	Policy    *RepoPolicy `json:"policy,omitempty" yaml:"policy,omitempty" xml:"policy,omitempty"` // policy of the repository, in the entry of the first owner only
+	Signature string      `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// RepoPolicy is the policy of the repository, set when the repository is initialized and signed by the first owner,
+// so it cannot be weakened without invalidating the registry.
+type RepoPolicy struct {
+	RequireHybrid bool `json:"requireHybrid,omitempty" yaml:"requireHybrid,omitempty" xml:"requireHybrid,omitempty"` // data keys sealed with both X25519 and ML-KEM-768
+	EncryptNames  bool `json:"encryptNames,omitempty" yaml:"encryptNames,omitempty" xml:"encryptNames,omitempty"`    // names of the files and directories encrypted
+}
+
+// SignedMessage returns the message signed by the member who added the member.
+func (m Member) SignedMessage() []byte {
+	m.Signature = ""
+	js, _ := json.Marshal(m)
+	return append([]byte("member:"), js...)
+}
+
+// MemberEntry is a member of the registry with the result of the verification of its signature chain.
+type MemberEntry struct {
+	Member   `yaml:",inline"`
+	Verified bool `json:"verified" yaml:"verified" xml:"verified"`
+	Revoked  bool `json:"revoked" yaml:"revoked" xml:"revoked"`
+}
+
+// MemberList is the list of the members of the repository.
+type MemberList []MemberEntry
+
+// String returns the list with one member per line.
+func (l MemberList) String() string {
+	var sb strings.Builder
+	for _, m := range l {
+		status := ""
+		if m.KemKey != "" {
+			status = " (hybrid)"
+		}
+		if m.Revoked {
+			status = " (revoked)"
+		} else if !m.Verified {
+			status = " (unverified)"
+		}
+		fmt.Fprintf(&sb, "%s %-6s %s%s\n", m.PublicKey, m.Role, m.Name, status)
+	}
+	return sb.String()
+}
+
+// Revocation revokes the membership of a member of the repository. It is signed by the owner who revoked the member.
+// The members approved by the revoked member stay members.
+type Revocation struct {
+	PublicKey string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"`
+	RevokedBy string    `json:"revokedBy" yaml:"revokedBy" xml:"revokedBy"` // public key of the owner who revoked the member
+	RevokedAt time.Time `json:"revokedAt" yaml:"revokedAt" xml:"revokedAt"`
+	Signature string    `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// SignedMessage returns the message signed by the owner who revoked the member.
+func (r Revocation) SignedMessage() []byte {
+	r.Signature = ""
+	js, _ := json.Marshal(r)
+	return append([]byte("revocation:"), js...)
+}
+
+// JoinRequest is a pending request of a user to join the repository, signed by the user.
+type JoinRequest struct {
+	PublicKey   string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"`
+	Name        string    `json:"name" yaml:"name" xml:"name"`
+	RequestedAt time.Time `json:"requestedAt" yaml:"requestedAt" xml:"requestedAt"`
+	KemKey      string    `json:"kemKey,omitempty" yaml:"kemKey,omitempty" xml:"kemKey,omitempty"` // ML-KEM-768 public key of a user with a hybrid key, raw base64
+	Signature   string    `json:"signature" yaml:"signature" xml:"signature"`
+}
+
+// SignedMessage returns the message signed by the user who requested to join.
+func (r JoinRequest) SignedMessage() []byte {
+	r.Signature = ""
+	js, _ := json.Marshal(r)
+	return append([]byte("join-request:"), js...)
+}
+
+// JoinRequestList is the list of the pending join requests of the repository.
+type JoinRequestList []JoinRequest
+
+// String returns the list with one request per line.
+func (l JoinRequestList) String() string {
+	var sb strings.Builder
+	for _, r := range l {
+		fmt.Fprintf(&sb, "%s %s %s\n", r.PublicKey, r.RequestedAt.Format(time.RFC3339), r.Name)
+	}
+	return sb.String()
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OffboardActionKind is the kind of change made to offboard a user.
+type OffboardActionKind string
+
+const (
+	OffboardRemoveKeyShare  OffboardActionKind = "remove-key-share"  // a data key shared with the user is removed
+	OffboardRemoveFromGroup OffboardActionKind = "remove-from-group" // the user is removed from a group whose key pair is replaced
+	OffboardRotateVaultKey  OffboardActionKind = "rotate-vault-key"  // the key of a vault the user could reach is replaced
+	OffboardRemoveContact   OffboardActionKind = "remove-contact"    // the contact of the user is removed from the address book
+	OffboardRevokeMember    OffboardActionKind = "revoke-member"     // the membership of the user is revoked
+)
+
+// OffboardAction is a single change made to offboard a user.
+type OffboardAction struct {
+	Kind        OffboardActionKind `json:"kind" yaml:"kind" xml:"kind"`
+	Path        string             `json:"path" yaml:"path" xml:"path"`
+	Target      string             `json:"target,omitempty" yaml:"target,omitempty" xml:"target,omitempty"` // key id for key shares, group id for groups
+	Description string             `json:"description" yaml:"description" xml:"description"`
+}
+
+// OffboardReport is the result of offboarding a user, or the plan of it for a dry run.
+type OffboardReport struct {
+	User       string           `json:"user" yaml:"user" xml:"user"`
+	DryRun     bool             `json:"dryRun" yaml:"dryRun" xml:"dryRun"`
+	Actions    []OffboardAction `json:"actions" yaml:"actions" xml:"actions"`
+	Unresolved []string         `json:"unresolved,omitempty" yaml:"unresolved,omitempty" xml:"unresolved,omitempty"` // directories not walked, their names cannot be decrypted
+}
+
+// Add adds an action to the report.
+func (r *OffboardReport) Add(kind OffboardActionKind, path string, target string, format string, a ...any) {
+	r.Actions = append(r.Actions, OffboardAction{
+		Kind:        kind,
+		Path:        path,
+		Target:      target,
+		Description: fmt.Sprintf(format, a...),
+	})
+}
+
+// String returns the report with one action per line followed by a summary.
+func (r OffboardReport) String() string {
+	var sb strings.Builder
+	for _, a := range r.Actions {
+		fmt.Fprintf(&sb, "%-18s %s: %s\n", a.Kind, a.Path, a.Description)
+	}
+	for _, path := range r.Unresolved {
+		fmt.Fprintf(&sb, "%-18s %s: the name cannot be decrypted, the keys shared with the user and the vaults below are unknown\n", "unresolved", path)
+	}
+	if r.DryRun {
+		fmt.Fprintf(&sb, "%d changes planned to offboard %s (dry run)\n", len(r.Actions), r.User)
+	} else {
+		fmt.Fprintf(&sb, "%d changes applied, %s is offboarded\n", len(r.Actions), r.User)
+	}
+	return sb.String()
+}
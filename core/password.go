@@ -0,0 +1,25 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// PasswordRecipient is a recipient whose key pair is derived from a passphrase and a salt,
+// for users who do not manage a key pair. Keys are shared with its public key like with any other recipient.
+type PasswordRecipient struct {
+	PublicKey string    `json:"publicKey" yaml:"publicKey" xml:"publicKey"` // public key derived from the passphrase
+	Label     string    `json:"label,omitempty" yaml:"label,omitempty" xml:"label,omitempty"`
+	Salt      string    `json:"salt" yaml:"salt" xml:"salt"`
+	LogN      int       `json:"logN,omitempty" yaml:"logN,omitempty" xml:"logN,omitempty"` // base 2 logarithm of the scrypt cost, 0 for the interactive cost
+	CreatedBy string    `json:"createdBy" yaml:"createdBy" xml:"createdBy"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt" xml:"createdAt"`
+}
+
+// String returns the public key of the recipient, followed by its label.
+func (r PasswordRecipient) String() string {
+	if r.Label == "" {
+		return fmt.Sprintf("password recipient %s\n", r.PublicKey)
+	}
+	return fmt.Sprintf("password recipient %s (%s)\n", r.PublicKey, r.Label)
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepairAction is a single fix of the repository repair.
+type RepairAction struct {
+	Code        string   `json:"code" yaml:"code" xml:"code"` // code of the finding fixed
+	Path        string   `json:"path" yaml:"path" xml:"path"`
+	Target      string   `json:"target,omitempty" yaml:"target,omitempty" xml:"target,omitempty"`
+	Description string   `json:"description" yaml:"description" xml:"description"`
+	Backup      []string `json:"backup,omitempty" yaml:"backup,omitempty" xml:"backup,omitempty"`    // files saved before the fix, relative to the repository root
+	Created     []string `json:"created,omitempty" yaml:"created,omitempty" xml:"created,omitempty"` // files and folders created by the fix, relative to the repository root
+}
+
+// RepairReport is the result of the repository repair.
+type RepairReport struct {
+	BackupId  string         `json:"backupId,omitempty" yaml:"backupId,omitempty" xml:"backupId,omitempty"` // id of the backup to restore to undo the repair
+	DryRun    bool           `json:"dryRun" yaml:"dryRun" xml:"dryRun"`
+	Actions   []RepairAction `json:"actions" yaml:"actions" xml:"actions"`
+	Remaining FsckReport     `json:"remaining" yaml:"remaining" xml:"remaining"` // findings of the check after the repair
+}
+
+// String returns the report with one action per line followed by a summary.
+func (r RepairReport) String() string {
+	var sb strings.Builder
+	for _, a := range r.Actions {
+		fmt.Fprintf(&sb, "%-22s %s: %s\n", a.Code, a.Path, a.Description)
+	}
+	switch {
+	case r.DryRun:
+		fmt.Fprintf(&sb, "%d fixes planned (dry run)\n", len(r.Actions))
+	case r.BackupId != "":
+		fmt.Fprintf(&sb, "%d fixes applied, undo with: repair restore %s\n", len(r.Actions), r.BackupId)
+	default:
+		fmt.Fprintf(&sb, "%d fixes applied\n", len(r.Actions))
+	}
+	fmt.Fprintf(&sb, "%d errors, %d warnings remaining\n", r.Remaining.Errors, r.Remaining.Warnings)
+	return sb.String()
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"io/fs"
+	"time"
 )
 
 type ObjectService interface {
@@ -14,17 +15,56 @@ type ObjectService interface {
 	RemoveFromCache(id string) error
 }
 
+// EncryptionPolicy returns the AEAD algorithm the new objects of the repository are encrypted with,
+// empty for the default algorithm.
+type EncryptionPolicy interface {
+	GetAlgorithm() string
+}
+
+// CompressionPolicy returns the compression the new objects of the files of the directory are compressed with,
+// empty if they are not compressed.
+type CompressionPolicy interface {
+	GetCompression(dir string) string
+}
+
+// HybridPolicy returns true if the repository requires the data keys to be sealed with both X25519 and ML-KEM-768.
+type HybridPolicy interface {
+	RequireHybrid() bool
+}
+
+// NamePolicy returns true if the names of the files and directories of the repository are encrypted.
+type NamePolicy interface {
+	EncryptNames() bool
+}
+
+// PathEncoder converts the paths of the files and directories to the paths stored on disk and back,
+// so that the records kept outside the directories do not hold the names of a repository encrypting them.
+type PathEncoder interface {
+	// EncodePath returns the rooted path stored on disk for the path.
+	EncodePath(path string) (string, error)
+	// DecodePath returns the rooted path of the path stored on disk, or an error if a name cannot be decrypted.
+	DecodePath(stored string) (string, error)
+}
+
+// NameRotator renames the entries of a directory when the name key of its vault is replaced.
+type NameRotator interface {
+	RenameEntries(dir string, oldKey Key, newKey Key) error
+}
+
 type FileSystemService interface {
 	GetSubFiles(path string) (res []fs.FileInfo, err error)
 	CreateFile(path string) (err error)
 	CreateDir(path string) (err error)
 	RemoveDir(path string) (err error)
 	Write(path string, buff []byte, ofst int64) (n int, err error)
+	Append(path string, buff []byte) (n int, err error)
 	Read(path string, buff []byte, ofst int64) (n int, err error)
 	Rename(oldPath string, newPath string) (err error)
 	RemovePath(path string) (err error)
 	Resize(path string, size int64) (err error)
 	Commit(path string) error
+	Sync(path string) error
+	Journal(path string) error
 	OpenInWrite(path string) error
 	GetUserFileAccess(path string, isDir bool) fs.FileMode
 	GetDiskUsage() (totalBytes, freeBytes uint64, err error)
@@ -34,8 +74,11 @@ type KeyService interface {
 	SetPrivateKey(privateKey PrivateKey)
 	Get(keyID string, startVaultId string, startVaultPath string) (*KeyInfo, error)
 	Insert(key *KeyInfo, path string) error
+	InsertAtStoredPath(key *KeyInfo, storedPath string) error
 	Share(keyId string, startVaultId string, startVaultPath string, recipient PublicKey, recipientUserId string) error
 	GetPublicKey() (PublicKey, error)
+	GetKemPublicKey() ([]byte, error)
+	CheckX25519Recipient() error
 	GetPublicKeyByPrivateKey(PrivateKey PrivateKey) (PublicKey, error)
 	CreateVault(parentId string, path string) (*Vault, error)
 	GenerateKeyInVault(vaultId string, vaultPath string) (*KeyInfo, error)
@@ -43,8 +86,17 @@ type KeyService interface {
 	MoveVault(vaultId string, oldVaultPath string, newVaultPath string, oldParentVaultId string, oldParentVaultPath, newParentVaultId string, newParentVaultPath string) error
 	MoveKey(keyId string, oldVaultId string, oldVaultPath string, newVaultId string, newVaultPath string) error
 	GenerateUserKey() (*PrivateKey, error)
-	IsUserJoined() bool
+	GetUserId() (string, error)
 	GetHasAccessToKey(keyId string, startVaultId string, startVaultPath string, userId string) (bool, bool)
 	GetKeyAccessList(keyId string, startVaultId string, startVaultPath string) (KeyAccessList, error)
 	Unshare(keyId string, recipientUserId string, path string) error
+	Sign(message []byte) ([]byte, error)
+	CreateGroupKey() (PublicKey, error)
+	ShareGroupKey(groupId string, recipient PublicKey, recipientUserId string) error
+	UnshareGroupKey(groupId string, userId string) error
+	RotateVaultKey(vaultPath string) (string, error)
+	RotateGroupKey(groupId string, members []string) (PublicKey, error)
+	BreakInheritance(vaultPath string, recipients []string) (string, error)
+	SetShareExpiry(keyId string, recipientUserId string, path string, expiresAt time.Time) error
+	GetShareExpiry(keyId string, recipientUserId string, path string) *time.Time
 }
@@ -12,6 +12,9 @@ var (
 type Vault struct {
 	Id    string `json:"id"`
 	KeyId string `json:"keyId"`
+	// NameKeyId is the id of the key in the vault the names of the sub files and directories are encrypted with,
+	// empty if the names are not encrypted
+	NameKeyId string `json:"nameKeyId,omitempty"`
 }
 
 func (v *Vault) Marshal() ([]byte, error) {
@@ -0,0 +1,347 @@
+// Package age_crypto reads and writes files in the age format (https://age-encryption.org/v1) for X25519 recipients,
+// so that files can be exchanged with the age tools. The keys of the repository are X25519 keys,
+// which are converted from and to the age recipient and identity strings.
+package age_crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"ctb-cli/crypto/stream"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/btcsuite/btcutil/bech32"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	intro          = "age-encryption.org/v1\n"
+	stanzaPrefix   = "->"
+	footerPrefix   = "---"
+	x25519Label    = "age-encryption.org/v1/X25519"
+	x25519Type     = "X25519"
+	recipientHRP   = "age"
+	identityHRP    = "AGE-SECRET-KEY-"
+	fileKeySize    = 16
+	nonceSize      = 16
+	columnsPerLine = 64
+)
+
+var (
+	ErrInvalidRecipient = errors.New("invalid age recipient")
+	ErrInvalidIdentity  = errors.New("invalid age identity")
+	ErrNoRecipient      = errors.New("no recipient to encrypt the file to")
+	ErrInvalidHeader    = errors.New("invalid age header")
+	ErrNoIdentityMatch  = errors.New("no identity matches a recipient of the file")
+	ErrHeaderMac        = errors.New("the age header is not authentic")
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+// EncodeRecipient returns the age recipient string, starting with "age1", of the public key.
+func EncodeRecipient(publicKey core.PublicKey) (string, error) {
+	return encodeBech32(recipientHRP, publicKey.Bytes())
+}
+
+// ParseRecipient returns the public key of the age X25519 recipient string.
+func ParseRecipient(recipient string) (core.PublicKey, error) {
+	hrp, value, err := decodeBech32(recipient)
+	if err != nil || hrp != recipientHRP || len(value) != curve25519.PointSize || recipient != strings.ToLower(recipient) {
+		return core.EmptyPublicKey(), ErrInvalidRecipient
+	}
+	return core.NewPublicKeyFromBytes(value), nil
+}
+
+// EncodeIdentity returns the age identity string, starting with "AGE-SECRET-KEY-1", of the private key.
+func EncodeIdentity(privateKey core.PrivateKey) (string, error) {
+	s, err := encodeBech32(strings.ToLower(identityHRP), privateKey.Bytes())
+	return strings.ToUpper(s), err
+}
+
+// ParseIdentity returns the private key of the age X25519 identity string.
+func ParseIdentity(identity string) (core.PrivateKey, error) {
+	hrp, value, err := decodeBech32(identity)
+	if err != nil || hrp != strings.ToLower(identityHRP) || len(value) != curve25519.ScalarSize || identity != strings.ToUpper(identity) {
+		return core.EmptyPrivateKey(), ErrInvalidIdentity
+	}
+	return core.NewPrivateKeyFromBytes(value), nil
+}
+
+// ParseIdentities returns the private keys of the age identity file content:
+// one identity per line, empty lines and lines starting with "#" are ignored.
+func ParseIdentities(r io.Reader) ([]core.PrivateKey, error) {
+	res := make([]core.PrivateKey, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, identity)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, ErrInvalidIdentity
+	}
+	return res, nil
+}
+
+// NewWriter returns a writer encrypting the data written to it as an age file for the recipients, written to dst.
+// The writer must be closed to write the last chunk of the file.
+func NewWriter(dst io.Writer, recipients ...core.PublicKey) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipient
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	// Header
+	header := bytes.NewBufferString(intro)
+	for _, recipient := range recipients {
+		share, body, err := wrapFileKey(fileKey, recipient)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(header, "%s %s %s\n", stanzaPrefix, x25519Type, b64.EncodeToString(share))
+		writeWrapped(header, b64.EncodeToString(body))
+	}
+	header.WriteString(footerPrefix)
+	mac, err := headerMac(fileKey, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(header, " %s\n", b64.EncodeToString(mac))
+	// Payload
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header.Write(nonce)
+	if _, err := dst.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	payloadKey, err := hkdfKey(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewWriter(payloadKey, dst)
+}
+
+// NewReader returns a reader decrypting the age file read from src with one of the identities.
+// The content is authenticated while it is read.
+func NewReader(src io.Reader, identities ...core.PrivateKey) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	stanzas, headerNoMac, mac, err := parseHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := unwrapFileKey(stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := headerMac(fileKey, headerNoMac)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, ErrHeaderMac
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	payloadKey, err := hkdfKey(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewReader(payloadKey, br)
+}
+
+// stanza is a recipient stanza of the header of an age file.
+type stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// parseHeader parses the header of an age file. It returns the stanzas, the header up to the MAC, and the MAC.
+func parseHeader(br *bufio.Reader) ([]stanza, []byte, []byte, error) {
+	var header bytes.Buffer
+	line, err := br.ReadString('\n')
+	if err != nil || line != intro {
+		return nil, nil, nil, ErrInvalidHeader
+	}
+	header.WriteString(line)
+	stanzas := make([]stanza, 0)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, nil, nil, ErrInvalidHeader
+		}
+		if strings.HasPrefix(line, footerPrefix+" ") {
+			header.WriteString(footerPrefix)
+			mac, err := b64.DecodeString(strings.TrimSuffix(strings.TrimPrefix(line, footerPrefix+" "), "\n"))
+			if err != nil || len(mac) != sha256.Size {
+				return nil, nil, nil, ErrInvalidHeader
+			}
+			return stanzas, header.Bytes(), mac, nil
+		}
+		header.WriteString(line)
+		args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+		if args[0] != stanzaPrefix || len(args) < 2 {
+			return nil, nil, nil, ErrInvalidHeader
+		}
+		s := stanza{Type: args[1], Args: args[2:]}
+		// The body is wrapped at 64 columns and ends with a shorter line, possibly empty
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return nil, nil, nil, ErrInvalidHeader
+			}
+			header.WriteString(line)
+			chunk := strings.TrimSuffix(line, "\n")
+			if len(chunk) > columnsPerLine {
+				return nil, nil, nil, ErrInvalidHeader
+			}
+			b, err := b64.DecodeString(chunk)
+			if err != nil {
+				return nil, nil, nil, ErrInvalidHeader
+			}
+			s.Body = append(s.Body, b...)
+			if len(chunk) < columnsPerLine {
+				break
+			}
+		}
+		stanzas = append(stanzas, s)
+	}
+}
+
+// wrapFileKey wraps the file key for the X25519 recipient. It returns the ephemeral share and the wrapped key.
+func wrapFileKey(fileKey []byte, recipient core.PublicKey) ([]byte, []byte, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return nil, nil, err
+	}
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedSecret, err := curve25519.X25519(ephemeral, recipient.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapKey, err := hkdfKey(sharedSecret, append(append([]byte{}, share...), recipient.Bytes()...), x25519Label)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return share, aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil), nil
+}
+
+// unwrapFileKey unwraps the file key from the first X25519 stanza of one of the identities.
+func unwrapFileKey(stanzas []stanza, identities []core.PrivateKey) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != x25519Type {
+			continue
+		}
+		if len(s.Args) != 1 || len(s.Body) != fileKeySize+chacha20poly1305.Overhead {
+			return nil, ErrInvalidHeader
+		}
+		share, err := b64.DecodeString(s.Args[0])
+		if err != nil || len(share) != curve25519.PointSize {
+			return nil, ErrInvalidHeader
+		}
+		for _, identity := range identities {
+			sharedSecret, err := curve25519.X25519(identity.Bytes(), share)
+			if err != nil {
+				return nil, ErrInvalidHeader
+			}
+			publicKey, err := identity.ToPublicKey()
+			if err != nil {
+				return nil, err
+			}
+			wrapKey, err := hkdfKey(sharedSecret, append(append([]byte{}, share...), publicKey.Bytes()...), x25519Label)
+			if err != nil {
+				return nil, err
+			}
+			aead, err := chacha20poly1305.New(wrapKey)
+			if err != nil {
+				return nil, err
+			}
+			if fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), s.Body, nil); err == nil {
+				return fileKey, nil
+			}
+		}
+	}
+	return nil, ErrNoIdentityMatch
+}
+
+// headerMac returns the MAC of the header, from the intro to the footer prefix, with a key derived from the file key.
+func headerMac(fileKey []byte, header []byte) ([]byte, error) {
+	key, err := hkdfKey(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(header)
+	return h.Sum(nil), nil
+}
+
+// hkdfKey derives a 32 bytes key from the secret, salt and info using HKDF and SHA-256.
+func hkdfKey(secret []byte, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeWrapped writes the base64 string wrapped at 64 columns, ending with a shorter line, possibly empty.
+func writeWrapped(w *bytes.Buffer, s string) {
+	for len(s) >= columnsPerLine {
+		w.WriteString(s[:columnsPerLine] + "\n")
+		s = s[columnsPerLine:]
+	}
+	w.WriteString(s + "\n")
+}
+
+// encodeBech32 encodes the value with the human-readable part in bech32.
+func encodeBech32(hrp string, value []byte) (string, error) {
+	data, err := bech32.ConvertBits(value, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(hrp, data)
+}
+
+// decodeBech32 decodes the bech32 string. The human-readable part is returned in lowercase.
+func decodeBech32(s string) (string, []byte, error) {
+	hrp, data, err := bech32.Decode(s)
+	if err != nil {
+		return "", nil, err
+	}
+	value, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, value, nil
+}
@@ -0,0 +1,155 @@
+package age_crypto_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"ctb-cli/core"
+	"ctb-cli/crypto/age_crypto"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test vector of the age test kit: the identity of the scalar 0x42 repeated 32 times and its recipient
+const (
+	testIdentity  = "AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX"
+	testRecipient = "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"
+)
+
+func TestKeyConversion(t *testing.T) {
+	privateKey, err := age_crypto.ParseIdentity(testIdentity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(privateKey.Bytes(), bytes.Repeat([]byte{0x42}, 32)) {
+		t.Errorf("Parsed identity does not match the test vector")
+	}
+	identity, err := age_crypto.EncodeIdentity(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity != testIdentity {
+		t.Errorf("Expected identity %s, got %s", testIdentity, identity)
+	}
+
+	publicKey, _ := privateKey.ToPublicKey()
+	recipient, err := age_crypto.EncodeRecipient(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient != testRecipient {
+		t.Errorf("Expected recipient %s, got %s", testRecipient, recipient)
+	}
+	parsed, err := age_crypto.ParseRecipient(testRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equals(publicKey) {
+		t.Errorf("Parsed recipient does not match the public key")
+	}
+
+	// Identities are not recipients, and mixed case is rejected
+	if _, err := age_crypto.ParseRecipient(testIdentity); err != age_crypto.ErrInvalidRecipient {
+		t.Errorf("Expected ErrInvalidRecipient, got %v", err)
+	}
+	if _, err := age_crypto.ParseIdentity(strings.ToLower(testIdentity)); err != age_crypto.ErrInvalidIdentity {
+		t.Errorf("Expected ErrInvalidIdentity, got %v", err)
+	}
+}
+
+func TestParseIdentities(t *testing.T) {
+	file := "# created: 2024-01-01T00:00:00Z\n# public key: " + testRecipient + "\n" + testIdentity + "\n\n"
+	identities, err := age_crypto.ParseIdentities(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 {
+		t.Fatalf("Expected 1 identity, got %d", len(identities))
+	}
+	if _, err := age_crypto.ParseIdentities(strings.NewReader("# nothing\n")); err != age_crypto.ErrInvalidIdentity {
+		t.Errorf("Expected ErrInvalidIdentity, got %v", err)
+	}
+}
+
+func encrypt(t *testing.T, data []byte, recipients ...core.PublicKey) []byte {
+	buf := bytes.NewBuffer(nil)
+	w, err := age_crypto.NewWriter(buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newKey(t *testing.T) (core.PrivateKey, core.PublicKey) {
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := privateKey.ToPublicKey()
+	return privateKey, publicKey
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, alicePub := newKey(t)
+	bob, bobPub := newKey(t)
+	for _, length := range []int{0, 1, 64 * 1024, 200*1024 + 3} {
+		data := make([]byte, length)
+		_, _ = rand.Read(data)
+		file := encrypt(t, data, alicePub, bobPub)
+		if !bytes.HasPrefix(file, []byte("age-encryption.org/v1\n-> X25519 ")) {
+			t.Fatalf("The file does not start with the age header")
+		}
+		for _, identity := range []core.PrivateKey{alice, bob} {
+			r, err := age_crypto.NewReader(bytes.NewReader(file), identity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Errorf("Decrypted data of length %d does not match", length)
+			}
+		}
+	}
+}
+
+func TestWrongIdentity(t *testing.T) {
+	_, publicKey := newKey(t)
+	other, _ := newKey(t)
+	file := encrypt(t, []byte("hello"), publicKey)
+	if _, err := age_crypto.NewReader(bytes.NewReader(file), other); !errors.Is(err, age_crypto.ErrNoIdentityMatch) {
+		t.Errorf("Expected ErrNoIdentityMatch, got %v", err)
+	}
+}
+
+func TestTamperedFile(t *testing.T) {
+	privateKey, publicKey := newKey(t)
+	file := encrypt(t, []byte("hello world"), publicKey)
+
+	// Adding a stanza to the header breaks the MAC
+	footer := bytes.Index(file, []byte("\n--- ")) + 1
+	tampered := append(append(append([]byte{}, file[:footer]...), "-> other\n\n"...), file[footer:]...)
+	if _, err := age_crypto.NewReader(bytes.NewReader(tampered), privateKey); !errors.Is(err, age_crypto.ErrHeaderMac) {
+		t.Errorf("Expected ErrHeaderMac, got %v", err)
+	}
+
+	// Changing the payload fails authentication
+	tampered = append([]byte{}, file...)
+	tampered[len(tampered)-1] ^= 1
+	r, err := age_crypto.NewReader(bytes.NewReader(tampered), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Errorf("Expected an error reading a tampered payload")
+	}
+}
@@ -0,0 +1,191 @@
+package capsule
+
+import (
+	"bufio"
+	"ctb-cli/core"
+	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/crypto/key_crypto"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A capsule is a single self-contained file holding encrypted objects of a repository, delivered out of band.
+// It starts with the magic string and the manifest, a JSON document prefixed by its length as a 4 bytes big endian integer,
+// followed by the encrypted objects of the files of the manifest, in order and of the size given in the manifest.
+// The key of every object is sealed to the recipient of the capsule, so only the recipient can decrypt the files.
+const magic = "ctb-capsule/v1\n"
+
+// maxManifestSize is the maximum size of the manifest, to reject corrupted capsules early.
+const maxManifestSize = 64 * 1024 * 1024
+
+var (
+	ErrInvalidCapsule   = errors.New("invalid capsule")
+	ErrNotRecipient     = errors.New("the capsule is not sealed to this key")
+	ErrKeyMismatch      = errors.New("the key of the object does not match the manifest")
+	ErrObjectSize       = errors.New("the object size does not match the manifest")
+	ErrNoFileInCapsule  = errors.New("no file to put in the capsule")
+	ErrEmptyCapsulePath = errors.New("the path of a file of the capsule is empty")
+)
+
+// Manifest describes the files of a capsule.
+type Manifest struct {
+	Recipient string `json:"recipient"` // public key the keys of the objects are sealed to
+	Files     []File `json:"files"`
+}
+
+// File is a file of a capsule.
+type File struct {
+	Path       string `json:"path"`       // path of the file, relative to the exported path
+	KeyId      string `json:"keyId"`      // id of the key of the object
+	SealedKey  string `json:"sealedKey"`  // key of the object sealed to the recipient
+	Size       int64  `json:"size"`       // size of the decrypted file
+	ObjectSize int64  `json:"objectSize"` // size of the encrypted object
+}
+
+// Entry is a file to put in a capsule: its encrypted object, as stored in the repository, and its key.
+type Entry struct {
+	Path       string
+	Key        *core.KeyInfo
+	Size       int64
+	ObjectSize int64
+	Open       func() (io.ReadCloser, error) // opens the encrypted object
+}
+
+// Write writes a capsule holding the entries, with their keys sealed to the recipient, to dst.
+func Write(dst io.Writer, recipient core.PublicKey, entries []Entry) error {
+	if len(entries) == 0 {
+		return ErrNoFileInCapsule
+	}
+	manifest := Manifest{Recipient: recipient.String(), Files: make([]File, 0, len(entries))}
+	for _, entry := range entries {
+		if entry.Path == "" {
+			return ErrEmptyCapsulePath
+		}
+		sealedKey, err := key_crypto.SealDataKey(entry.Key.Key, recipient)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, File{
+			Path:       entry.Path,
+			KeyId:      entry.Key.Id,
+			SealedKey:  sealedKey,
+			Size:       entry.Size,
+			ObjectSize: entry.ObjectSize,
+		})
+	}
+	js, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 0, len(magic)+4+len(js))
+	header = append(header, magic...)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(js)))
+	header = append(header, js...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := writeObject(dst, entry); err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeObject copies the encrypted object of the entry to dst, checking its size.
+func writeObject(dst io.Writer, entry Entry) error {
+	object, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	n, err := io.Copy(dst, io.LimitReader(object, entry.ObjectSize+1))
+	if err != nil {
+		return err
+	}
+	if n != entry.ObjectSize {
+		return ErrObjectSize
+	}
+	return nil
+}
+
+// Reader reads the files of a capsule.
+type Reader struct {
+	manifest   Manifest
+	privateKey core.PrivateKey
+	src        *bufio.Reader
+	next       int
+	current    io.Reader // remaining encrypted object of the last file returned by Next
+}
+
+// Open reads the manifest of the capsule from src, to be opened with the private key of its recipient.
+func Open(src io.Reader, privateKey core.PrivateKey) (*Reader, error) {
+	r := &Reader{privateKey: privateKey, src: bufio.NewReader(src)}
+	header := make([]byte, len(magic)+4)
+	if _, err := io.ReadFull(r.src, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, ErrInvalidCapsule
+	}
+	size := binary.BigEndian.Uint32(header[len(magic):])
+	if size > maxManifestSize {
+		return nil, ErrInvalidCapsule
+	}
+	js := make([]byte, size)
+	if _, err := io.ReadFull(r.src, js); err != nil {
+		return nil, ErrInvalidCapsule
+	}
+	if err := json.Unmarshal(js, &r.manifest); err != nil {
+		return nil, ErrInvalidCapsule
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if publicKey.String() != r.manifest.Recipient {
+		return nil, ErrNotRecipient
+	}
+	return r, nil
+}
+
+// Manifest returns the manifest of the capsule.
+func (r *Reader) Manifest() Manifest {
+	return r.manifest
+}
+
+// Next returns the next file of the capsule and a reader of its decrypted content.
+// The content is authenticated while it is read. It returns io.EOF when there are no more files.
+func (r *Reader) Next() (File, io.Reader, error) {
+	// Skip what is left of the previous object
+	if r.current != nil {
+		if _, err := io.Copy(io.Discard, r.current); err != nil {
+			return File{}, nil, err
+		}
+		r.current = nil
+	}
+	if r.next >= len(r.manifest.Files) {
+		return File{}, nil, io.EOF
+	}
+	file := r.manifest.Files[r.next]
+	r.next++
+	key, err := key_crypto.OpenDataKey(file.SealedKey, r.privateKey)
+	if err != nil {
+		return File{}, nil, ErrNotRecipient
+	}
+	object := &io.LimitedReader{R: r.src, N: file.ObjectSize}
+	r.current = object
+	header, enc, err := file_crypto.Parse(object)
+	if err != nil {
+		return File{}, nil, fmt.Errorf("%s: %w", file.Path, ErrInvalidCapsule)
+	}
+	if header.KeyId != file.KeyId {
+		return File{}, nil, fmt.Errorf("%s: %w", file.Path, ErrKeyMismatch)
+	}
+	keyInfo := core.NewKeyInfo(file.KeyId, *key)
+	content, err := enc.Decrypt(&keyInfo)
+	if err != nil {
+		return File{}, nil, fmt.Errorf("%s: %w", file.Path, err)
+	}
+	return file, content, nil
+}
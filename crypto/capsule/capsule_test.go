@@ -0,0 +1,149 @@
+package capsule_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"ctb-cli/core"
+	"ctb-cli/crypto/capsule"
+	"ctb-cli/crypto/file_crypto"
+	"errors"
+	"io"
+	"testing"
+)
+
+// encryptObject encrypts the data as an object of the repository.
+func encryptObject(t *testing.T, data []byte, key *core.KeyInfo) []byte {
+	buf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewWriter(buf, key, "fileId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newEntry returns a capsule entry of the data, encrypted with a new key.
+func newEntry(t *testing.T, path string, data []byte) capsule.Entry {
+	key := core.NewKeyInfo(path+"-key", core.NewKeyFromRand())
+	object := encryptObject(t, data, &key)
+	return capsule.Entry{
+		Path:       path,
+		Key:        &key,
+		Size:       int64(len(data)),
+		ObjectSize: int64(len(object)),
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(object)), nil
+		},
+	}
+}
+
+func newRecipient(t *testing.T) (core.PrivateKey, core.PublicKey) {
+	privateKey, err := core.NewPrivateKeyFromRand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, publicKey
+}
+
+func TestWriteAndOpen(t *testing.T) {
+	privateKey, publicKey := newRecipient(t)
+	large := make([]byte, 200*1024)
+	_, _ = rand.Read(large)
+	files := map[string][]byte{"a.txt": []byte("hello world"), "docs/large.bin": large, "empty.txt": nil}
+	entries := []capsule.Entry{
+		newEntry(t, "a.txt", files["a.txt"]),
+		newEntry(t, "docs/large.bin", files["docs/large.bin"]),
+		newEntry(t, "empty.txt", files["empty.txt"]),
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := capsule.Write(buf, publicKey, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := capsule.Open(bytes.NewReader(buf.Bytes()), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reader.Manifest().Files) != len(entries) {
+		t.Fatalf("Expected %d files, got %d", len(entries), len(reader.Manifest().Files))
+	}
+	for i := 0; ; i++ {
+		file, content, err := reader.Next()
+		if err == io.EOF {
+			if i != len(entries) {
+				t.Errorf("Expected %d files, read %d", len(entries), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Skip the content of the large file, Next must skip the rest of the object
+		if file.Path == "docs/large.bin" {
+			continue
+		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, files[file.Path]) {
+			t.Errorf("Content of %s does not match", file.Path)
+		}
+	}
+}
+
+func TestOpenWithOtherKey(t *testing.T) {
+	_, publicKey := newRecipient(t)
+	otherKey, _ := newRecipient(t)
+	buf := bytes.NewBuffer(nil)
+	if err := capsule.Write(buf, publicKey, []capsule.Entry{newEntry(t, "a.txt", []byte("hello"))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := capsule.Open(bytes.NewReader(buf.Bytes()), otherKey); !errors.Is(err, capsule.ErrNotRecipient) {
+		t.Errorf("Expected ErrNotRecipient, got %v", err)
+	}
+}
+
+func TestTamperedCapsule(t *testing.T) {
+	privateKey, publicKey := newRecipient(t)
+	buf := bytes.NewBuffer(nil)
+	if err := capsule.Write(buf, publicKey, []capsule.Entry{newEntry(t, "a.txt", []byte("hello world"))}); err != nil {
+		t.Fatal(err)
+	}
+	// Flip the last byte, in the encrypted content
+	data := buf.Bytes()
+	data[len(data)-1] ^= 1
+	reader, err := capsule.Open(bytes.NewReader(data), privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, content, err := reader.Next()
+	if err == nil {
+		_, err = io.ReadAll(content)
+	}
+	if err == nil {
+		t.Errorf("Expected an error reading a tampered capsule")
+	}
+
+	// Not a capsule
+	if _, err := capsule.Open(bytes.NewReader([]byte("hello")), privateKey); !errors.Is(err, capsule.ErrInvalidCapsule) {
+		t.Errorf("Expected ErrInvalidCapsule, got %v", err)
+	}
+}
+
+func TestWriteWithoutFiles(t *testing.T) {
+	_, publicKey := newRecipient(t)
+	if err := capsule.Write(io.Discard, publicKey, nil); !errors.Is(err, capsule.ErrNoFileInCapsule) {
+		t.Errorf("Expected ErrNoFileInCapsule, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package file_crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Names of the AEAD algorithms stored in the Alg field of the header of encrypted files.
+const (
+	AlgChaCha20Poly1305  = "AEAD_ChaCha20_Poly1305"
+	AlgAES256GCM         = "AEAD_AES_256_GCM"
+	AlgXChaCha20Poly1305 = "AEAD_XChaCha20_Poly1305"
+)
+
+// DefaultAlg is the algorithm used to encrypt files when none is selected.
+const DefaultAlg = AlgChaCha20Poly1305
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported encryption algorithm")
+	ErrAlgRegistered  = errors.New("the encryption algorithm is already registered")
+)
+
+// AEADFactory creates an AEAD cipher from a 32-byte key.
+// The cipher must have a 16-byte overhead, the chunk counter of the stream is stored in the last bytes of its nonce.
+type AEADFactory func(key []byte) (cipher.AEAD, error)
+
+var (
+	algMutex   sync.RWMutex
+	algorithms = map[string]AEADFactory{
+		AlgChaCha20Poly1305:  chacha20poly1305.New,
+		AlgAES256GCM:         newAES256GCM,
+		AlgXChaCha20Poly1305: chacha20poly1305.NewX,
+	}
+)
+
+// RegisterAlg registers an AEAD algorithm under the name stored in the header of the files it encrypts.
+func RegisterAlg(name string, factory AEADFactory) error {
+	algMutex.Lock()
+	defer algMutex.Unlock()
+	if _, ok := algorithms[name]; ok {
+		return ErrAlgRegistered
+	}
+	algorithms[name] = factory
+	return nil
+}
+
+// IsSupportedAlg returns true if the algorithm is registered.
+func IsSupportedAlg(name string) bool {
+	algMutex.RLock()
+	defer algMutex.RUnlock()
+	_, ok := algorithms[name]
+	return ok
+}
+
+// SupportedAlgs returns the names of the registered algorithms, sorted.
+func SupportedAlgs() []string {
+	algMutex.RLock()
+	defer algMutex.RUnlock()
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newAEAD creates the AEAD cipher of the algorithm with the key, or returns ErrUnsupportedAlg if it is not registered.
+func newAEAD(name string, key []byte) (cipher.AEAD, error) {
+	algMutex.RLock()
+	factory, ok := algorithms[name]
+	algMutex.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedAlg
+	}
+	return factory(key)
+}
+
+// newAES256GCM creates an AES-256-GCM cipher, the key must be 32 bytes long.
+func newAES256GCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, aes.KeySizeError(len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,230 @@
+package file_crypto
+
+import (
+	"bytes"
+	"ctb-cli/crypto/stream"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Names of the compressions stored in the Compression field of the header of encrypted files.
+const (
+	CompressionNone = ""
+	CompressionZstd = "zstd"
+)
+
+var (
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+	ErrInvalidCompressedChunk = errors.New("invalid compressed chunk")
+)
+
+// A compressed file is a sequence of chunks, each holding up to stream.ChunkSize bytes of the file compressed on its own,
+// so the content is never expanded by more than the chunk headers. The chunk header is the method of the chunk,
+// followed by the size of its payload on 3 bytes, big endian. The chunks are written to the STREAM writer,
+// so their headers are encrypted and authenticated with the content.
+const (
+	chunkHeaderSize = 4
+	chunkRaw        = 0 // the payload is the content of the chunk
+	chunkZstd       = 1 // the payload is the content of the chunk compressed with zstd
+)
+
+// compressedExtensions are the extensions of the files whose content is already compressed.
+var compressedExtensions = map[string]struct{}{
+	".7z": {}, ".avif": {}, ".br": {}, ".bz2": {}, ".flac": {}, ".gif": {}, ".gz": {}, ".heic": {}, ".jpeg": {}, ".jpg": {},
+	".m4a": {}, ".mkv": {}, ".mov": {}, ".mp3": {}, ".mp4": {}, ".ogg": {}, ".png": {}, ".rar": {}, ".tgz": {}, ".webm": {},
+	".webp": {}, ".xz": {}, ".zip": {}, ".zst": {},
+}
+
+// compressedMagics are the leading bytes of the compressed formats, detected in the first chunk of a file.
+// They also cover the formats stored in zip containers, such as the Office Open XML and OpenDocument files.
+var compressedMagics = [][]byte{
+	{'P', 'K', 0x03, 0x04},             // zip
+	{0x1f, 0x8b},                       // gzip
+	{0x28, 0xb5, 0x2f, 0xfd},           // zstd
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},   // xz
+	{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, // 7z
+	{'R', 'a', 'r', '!'},               // rar
+	{'B', 'Z', 'h'},                    // bzip2
+	{0x89, 'P', 'N', 'G'},              // png
+	{0xff, 0xd8, 0xff},                 // jpeg
+	{'G', 'I', 'F', '8'},               // gif
+}
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(stream.ChunkSize))
+	})
+)
+
+// IsSupportedCompression returns true if the files can be compressed with the compression.
+func IsSupportedCompression(compression string) bool {
+	return compression == CompressionNone || compression == CompressionZstd
+}
+
+// IsCompressedName returns true if the extension of the file name is the one of an already compressed content type,
+// which is not worth compressing again.
+func IsCompressedName(name string) bool {
+	_, ok := compressedExtensions[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// isCompressedContent returns true if the content starts with the magic bytes of a compressed format.
+func isCompressedContent(content []byte) bool {
+	for _, magic := range compressedMagics {
+		if bytes.HasPrefix(content, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter splits the content written to it into chunks, compresses them and writes them to dst.
+type compressWriter struct {
+	dst     io.WriteCloser
+	buf     []byte
+	out     []byte
+	sniffed bool // the first chunk has been checked for a compressed format
+	raw     bool // the content is already compressed, the chunks are stored raw
+}
+
+// newCompressWriter returns a writer compressing the content written to it with zstd, chunk by chunk, to dst.
+// Closing it flushes the last chunk and closes dst.
+func newCompressWriter(dst io.WriteCloser) (*compressWriter, error) {
+	if _, err := zstdEncoder(); err != nil {
+		return nil, err
+	}
+	return &compressWriter{
+		dst: dst,
+		buf: make([]byte, 0, stream.ChunkSize),
+	}, nil
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flushChunk(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// Close flushes the last chunk and closes the destination writer.
+func (w *compressWriter) Close() error {
+	if len(w.buf) > 0 {
+		if err := w.flushChunk(); err != nil {
+			return err
+		}
+	}
+	return w.dst.Close()
+}
+
+// flushChunk compresses the buffered content and writes it as a chunk.
+// The chunk is stored raw if the content is already compressed or does not shrink.
+func (w *compressWriter) flushChunk() error {
+	if !w.sniffed {
+		w.sniffed = true
+		w.raw = isCompressedContent(w.buf)
+	}
+	method, payload := byte(chunkRaw), w.buf
+	if !w.raw {
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return err
+		}
+		w.out = encoder.EncodeAll(w.buf, w.out[:0])
+		if len(w.out) < len(w.buf) {
+			method, payload = chunkZstd, w.out
+		}
+	}
+	size := len(payload)
+	header := []byte{method, byte(size >> 16), byte(size >> 8), byte(size)}
+	if _, err := w.dst.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.dst.Write(payload); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// decompressReader reads the chunks written by a compressWriter from src and decompresses them.
+type decompressReader struct {
+	src    io.Reader
+	unread []byte
+	buf    []byte
+	out    []byte
+}
+
+// newDecompressReader returns a reader decompressing the chunks read from src.
+func newDecompressReader(src io.Reader) (*decompressReader, error) {
+	if _, err := zstdDecoder(); err != nil {
+		return nil, err
+	}
+	return &decompressReader{src: src}, nil
+}
+
+func (r *decompressReader) Read(p []byte) (int, error) {
+	if len(r.unread) == 0 {
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.unread)
+	r.unread = r.unread[n:]
+	return n, nil
+}
+
+// readChunk reads and decompresses the next chunk into r.unread.
+// It returns io.EOF if there is no chunk left.
+func (r *decompressReader) readChunk() error {
+	var header [chunkHeaderSize]byte
+	if _, err := io.ReadFull(r.src, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return ErrInvalidCompressedChunk
+		}
+		return err
+	}
+	size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+	if size == 0 || size > stream.ChunkSize {
+		return ErrInvalidCompressedChunk
+	}
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	if _, err := io.ReadFull(r.src, r.buf); err != nil {
+		return ErrInvalidCompressedChunk
+	}
+	switch header[0] {
+	case chunkRaw:
+		r.unread = r.buf
+	case chunkZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return err
+		}
+		r.out, err = decoder.DecodeAll(r.buf, r.out[:0])
+		if err != nil || len(r.out) == 0 || len(r.out) > stream.ChunkSize {
+			return ErrInvalidCompressedChunk
+		}
+		r.unread = r.out
+	default:
+		return ErrInvalidCompressedChunk
+	}
+	return nil
+}
@@ -13,6 +13,8 @@ type Header struct {
 	Alg     string `json:"alg"`
 	FileID  string `json:"file_id"`
 	KeyId   string `json:"key_id"`
+	// Compression is the compression of the content before encryption, empty if the content is not compressed
+	Compression string `json:"compression,omitempty"`
 }
 
 // Marshal header
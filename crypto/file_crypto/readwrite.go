@@ -13,27 +13,60 @@ type writer struct {
 	notFirst     bool           // Indicates whether it is not the first write operation.
 	dst          io.Writer      // The destination writer to write the encrypted data to.
 	streamWriter *stream.Writer // The stream writer used for encryption.
+	content      io.WriteCloser // The writer of the content, the stream writer or the compressor writing to it.
 }
 
 var (
 	fileVersion = []byte{1} //Current encryption file version
 )
 
-// NewWriter creates a new writer object that encrypts data and writes it to the specified destination writer.
+// NewWriter creates a new writer object that encrypts data with the default algorithm and writes it to the specified destination writer.
 // It takes the destination writer, key information, and file ID as parameters.
 // The function returns a pointer to the writer object and an error if any occurred during the creation process.
 func NewWriter(dst io.Writer, keyInfo *core.KeyInfo, fileId string) (*writer, error) {
-	// Create a new stream writer with the key and the destination writer.
-	streamWriter, err := stream.NewWriter(keyInfo.Key.Bytes(), dst)
+	return NewWriterWithAlg(dst, keyInfo, fileId, DefaultAlg)
+}
+
+// NewWriterWithAlg creates a new writer object that encrypts data with the given AEAD algorithm, stored in the header,
+// and writes it to the specified destination writer. It returns ErrUnsupportedAlg if the algorithm is not registered.
+func NewWriterWithAlg(dst io.Writer, keyInfo *core.KeyInfo, fileId string, alg string) (*writer, error) {
+	return NewCompressedWriter(dst, keyInfo, fileId, alg, CompressionNone)
+}
+
+// NewCompressedWriter creates a new writer object that compresses data chunk by chunk with the given compression,
+// then encrypts it with the given AEAD algorithm, both stored in the header, and writes it to the specified destination writer.
+// The chunks of content that are already compressed are stored as they are.
+// It returns ErrUnsupportedCompression if the compression is not supported.
+func NewCompressedWriter(dst io.Writer, keyInfo *core.KeyInfo, fileId string, alg string, compression string) (*writer, error) {
+	if !IsSupportedCompression(compression) {
+		return nil, ErrUnsupportedCompression
+	}
+	aead, err := newAEAD(alg, keyInfo.Key.Bytes())
 	if err != nil {
 		return nil, err
 	}
+	// Create a new stream writer with the cipher and the destination writer.
+	streamWriter, err := stream.NewWriterWithAEAD(aead, dst)
+	if err != nil {
+		return nil, err
+	}
+	// Compress the content before encrypting it, if required
+	var content io.WriteCloser = streamWriter
+	if compression == CompressionZstd {
+		content, err = newCompressWriter(streamWriter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	header := newHeader(fileId, keyInfo.Id, alg)
+	header.Compression = compression
 	// Create a new writer object with the destination writer, header, and stream writer.
 	return &writer{
 		dst:          dst,
-		header:       newHeader(fileId, keyInfo.Id),
+		header:       header,
 		notFirst:     false,
 		streamWriter: streamWriter,
+		content:      content,
 	}, nil
 }
 
@@ -48,7 +81,7 @@ func (e *writer) Write(buf []byte) (int, error) {
 		}
 		e.notFirst = true
 	}
-	return e.streamWriter.Write(buf)
+	return e.content.Write(buf)
 }
 
 // writeFileVersionAndHeader writes the file version and header to the destination writer.
@@ -81,16 +114,15 @@ func (e *writer) Close() error {
 		}
 		e.notFirst = true
 	}
-	// Close the stream writer to flush the remaining data and finalize the encryption.
-	return e.streamWriter.Close()
+	// Close the content writer to flush the remaining data and finalize the encryption.
+	return e.content.Close()
 }
 
-// newHeader creates a new Header struct with the specified fileId and keyId.
-// It sets the default algorithm by calling the getAlgorithmName function.
-func newHeader(fileId string, keyId string) Header {
+// newHeader creates a new Header struct with the specified fileId, keyId and algorithm.
+func newHeader(fileId string, keyId string, alg string) Header {
 	return Header{
 		Version: "V1",
-		Alg:     "AEAD_ChaCha20_Poly1305", // Set default algorithm
+		Alg:     alg,
 		FileID:  fileId,
 		KeyId:   keyId,
 	}
@@ -98,7 +130,9 @@ func newHeader(fileId string, keyId string) Header {
 
 // EncryptedStream represents an encrypted stream of data.
 type EncryptedStream struct {
-	source io.Reader
+	source      io.Reader
+	alg         string // algorithm of the header
+	compression string // compression of the header
 }
 
 // Parse reads the encrypted data from the provided source and returns the parsed header,
@@ -108,14 +142,31 @@ func Parse(source io.Reader) (*Header, *EncryptedStream, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return header, &EncryptedStream{source: source}, nil
+	return header, &EncryptedStream{source: source, alg: header.Alg, compression: header.Compression}, nil
 }
 
-// Decrypt decrypts the encrypted stream using the provided key.
+// Decrypt decrypts the encrypted stream using the provided key, with the algorithm of the header,
+// and decompresses it if the header has a compression.
 // It returns an io.Reader that can be used to read the decrypted data.
+// If the algorithm is not registered, ErrUnsupportedAlg is returned, and if the compression is not supported,
+// ErrUnsupportedCompression is returned.
 // If an error occurs during decryption, it is returned along with nil reader.
 func (e EncryptedStream) Decrypt(key *core.KeyInfo) (io.Reader, error) {
-	return stream.NewReader(key.Key.Bytes(), e.source)
+	if !IsSupportedCompression(e.compression) {
+		return nil, ErrUnsupportedCompression
+	}
+	aead, err := newAEAD(e.alg, key.Key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	reader, err := stream.NewReaderWithAEAD(aead, e.source)
+	if err != nil {
+		return nil, err
+	}
+	if e.compression == CompressionZstd {
+		return newDecompressReader(reader)
+	}
+	return reader, nil
 }
 
 // readFileVersionAndHeader reads the file version and header from the given source.
@@ -93,3 +93,162 @@ func TestRoundTrip(t *testing.T) {
 	testRoundTrip(t, 1024)
 	testRoundTrip(t, 1024*1024)
 }
+
+// TestRoundTripAlgs tests the round trip with every supported algorithm, stored in the header
+func TestRoundTripAlgs(t *testing.T) {
+	for _, alg := range file_crypto.SupportedAlgs() {
+		t.Run(alg, func(t *testing.T) {
+			keyInfo := core.KeyInfo{Id: "ID", Key: core.NewKeyFromRand()}
+			originalData := make([]byte, 200*1024)
+			_, _ = rand.Read(originalData)
+
+			memBuf := bytes.NewBuffer(nil)
+			writer, err := file_crypto.NewWriterWithAlg(memBuf, &keyInfo, "fileId", alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := writer.Write(originalData); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			header, encStream, err := file_crypto.Parse(memBuf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Alg != alg {
+				t.Errorf("Expected Alg to be '%s', got '%s'", alg, header.Alg)
+			}
+			decryptedData, err := encStream.Decrypt(&keyInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readData, err := io.ReadAll(decryptedData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(originalData, readData) {
+				t.Errorf("Original and read data do not match")
+			}
+		})
+	}
+}
+
+// TestUnsupportedAlg tests that unknown algorithms are rejected on write and read
+func TestUnsupportedAlg(t *testing.T) {
+	keyInfo := core.KeyInfo{Id: "ID", Key: core.NewKeyFromRand()}
+	if _, err := file_crypto.NewWriterWithAlg(bytes.NewBuffer(nil), &keyInfo, "fileId", "AEAD_Unknown"); err != file_crypto.ErrUnsupportedAlg {
+		t.Errorf("Expected ErrUnsupportedAlg, got %v", err)
+	}
+
+	header := file_crypto.Header{Version: "V1", Alg: "AEAD_Unknown", FileID: "fileId", KeyId: "ID"}
+	headerBytes, err := header.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := append([]byte{1}, headerBytes...)
+	_, encStream, err := file_crypto.Parse(bytes.NewReader(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encStream.Decrypt(&keyInfo); err != file_crypto.ErrUnsupportedAlg {
+		t.Errorf("Expected ErrUnsupportedAlg, got %v", err)
+	}
+}
+
+// encryptCompressed encrypts the data with the compression and returns the encrypted file
+func encryptCompressed(t *testing.T, keyInfo *core.KeyInfo, data []byte, compression string) []byte {
+	memBuf := bytes.NewBuffer(nil)
+	writer, err := file_crypto.NewCompressedWriter(memBuf, keyInfo, "fileId", file_crypto.DefaultAlg, compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return memBuf.Bytes()
+}
+
+// TestRoundTripCompressed tests the round trip of compressible, random and already compressed data with zstd
+func TestRoundTripCompressed(t *testing.T) {
+	keyInfo := core.KeyInfo{Id: "ID", Key: core.NewKeyFromRand()}
+	random := make([]byte, 150*1024)
+	_, _ = rand.Read(random)
+	zipped := append([]byte("PK\x03\x04"), bytes.Repeat([]byte("compressible"), 10*1024)...)
+	cases := map[string][]byte{
+		"empty":        nil,
+		"small":        []byte("hello world"),
+		"compressible": bytes.Repeat([]byte("date,level,message\n2024-01-01,INFO,started\n"), 20*1024),
+		"random":       random,
+		"zip":          zipped,
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			encrypted := encryptCompressed(t, &keyInfo, data, file_crypto.CompressionZstd)
+			header, encStream, err := file_crypto.Parse(bytes.NewReader(encrypted))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Compression != file_crypto.CompressionZstd {
+				t.Errorf("Expected Compression to be 'zstd', got '%s'", header.Compression)
+			}
+			decryptedData, err := encStream.Decrypt(&keyInfo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readData, err := io.ReadAll(decryptedData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, readData) {
+				t.Errorf("Original and read data do not match")
+			}
+			// Compressible data shrinks, the other data is stored as it is with the chunk headers
+			plain := encryptCompressed(t, &keyInfo, data, file_crypto.CompressionNone)
+			switch name {
+			case "compressible":
+				if len(encrypted)*5 > len(plain) {
+					t.Errorf("Expected the compressed file to be 5 times smaller, got %d bytes for %d", len(encrypted), len(plain))
+				}
+			case "random", "zip":
+				if len(encrypted) > len(plain)+3*4+len(`,"compression":"zstd"`) {
+					t.Errorf("Expected the chunks to be stored raw, got %d bytes for %d", len(encrypted), len(plain))
+				}
+			}
+		})
+	}
+}
+
+// TestUnsupportedCompression tests that unknown compressions are rejected on write and read
+func TestUnsupportedCompression(t *testing.T) {
+	keyInfo := core.KeyInfo{Id: "ID", Key: core.NewKeyFromRand()}
+	if _, err := file_crypto.NewCompressedWriter(bytes.NewBuffer(nil), &keyInfo, "fileId", file_crypto.DefaultAlg, "lz4"); err != file_crypto.ErrUnsupportedCompression {
+		t.Errorf("Expected ErrUnsupportedCompression, got %v", err)
+	}
+
+	header := file_crypto.Header{Version: "V1", Alg: file_crypto.DefaultAlg, FileID: "fileId", KeyId: "ID", Compression: "lz4"}
+	headerBytes, err := header.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, encStream, err := file_crypto.Parse(bytes.NewReader(append([]byte{1}, headerBytes...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encStream.Decrypt(&keyInfo); err != file_crypto.ErrUnsupportedCompression {
+		t.Errorf("Expected ErrUnsupportedCompression, got %v", err)
+	}
+}
+
+func TestIsCompressedName(t *testing.T) {
+	for name, want := range map[string]bool{"photo.JPG": true, "archive.tar.gz": true, "report.csv": false, "app.log": false, "README": false} {
+		if got := file_crypto.IsCompressedName(name); got != want {
+			t.Errorf("IsCompressedName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
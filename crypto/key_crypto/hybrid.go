@@ -0,0 +1,174 @@
+package key_crypto
+
+import (
+	"crypto/cipher"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	HybridV1Tag  = "X25519MLKEM768"                        // HybridV1Tag is the first line of the data keys sealed with the hybrid X25519 and ML-KEM-768 scheme.
+	HybridV1Info = "cognitechbridge.com/v1/X25519MLKEM768" // HybridV1Info is the info string used for deriving the wrap key from the hybrid shared secrets.
+)
+
+var (
+	ErrNotHybridKey           = errors.New("the private key is not a hybrid key")
+	ErrInvalidKemPublicKey    = errors.New("invalid ML-KEM-768 public key")
+	ErrHybridKeyRequired      = errors.New("the data key is sealed with a hybrid key, a hybrid private key is required")
+	ErrGeneratingHybridKey    = errors.New("error generating hybrid key")
+	ErrDecapsulatingHybridKey = errors.New("error decapsulating hybrid key")
+)
+
+// NewHybridPrivateKey generates a hybrid private key, an X25519 private key followed by the seed of an ML-KEM-768 key pair.
+// The public key of a hybrid key is the X25519 public key, so the user id does not change with the hybrid part.
+func NewHybridPrivateKey() (core.PrivateKey, error) {
+	value := make([]byte, core.HybridPrivateKeySize)
+	if _, err := io.ReadFull(rand.Reader, value); err != nil {
+		return core.EmptyPrivateKey(), ErrGeneratingHybridKey
+	}
+	return core.NewPrivateKeyFromBytes(value), nil
+}
+
+// KemPublicKey returns the ML-KEM-768 encapsulation key of the hybrid private key.
+func KemPublicKey(privateKey core.PrivateKey) ([]byte, error) {
+	decapsulationKey, err := kemDecapsulationKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return decapsulationKey.EncapsulationKey().Bytes(), nil
+}
+
+// CheckKemPublicKey returns ErrInvalidKemPublicKey if the key is not a valid ML-KEM-768 public key.
+func CheckKemPublicKey(kemPublicKey []byte) error {
+	if _, err := mlkem.NewEncapsulationKey768(kemPublicKey); err != nil {
+		return ErrInvalidKemPublicKey
+	}
+	return nil
+}
+
+// kemDecapsulationKey returns the ML-KEM-768 decapsulation key derived from the seed of the hybrid private key.
+func kemDecapsulationKey(privateKey core.PrivateKey) (*mlkem.DecapsulationKey768, error) {
+	seed := privateKey.HybridSeed()
+	if seed == nil {
+		return nil, ErrNotHybridKey
+	}
+	decapsulationKey, err := mlkem.NewDecapsulationKey768(seed)
+	if err != nil {
+		return nil, ErrNotHybridKey
+	}
+	return decapsulationKey, nil
+}
+
+// SealHybridDataKey encrypts a data key to a hybrid recipient, so that recovering it requires breaking both X25519 and ML-KEM-768.
+// It derives an X25519 shared secret from a random ephemeral secret as SealDataKey does, and encapsulates an ML-KEM-768 shared secret
+// to the KEM public key of the recipient. The wrap key is derived from both shared secrets using HKDF and SHA-256,
+// with the ephemeral share, the KEM ciphertext and the public key as salt.
+// The result is serialized as "X25519MLKEM768 \n ephemeralShare \n kemCiphertext \n cipheredDataKey", and opened by OpenDataKey.
+func SealHybridDataKey(key core.Key, publicKey core.PublicKey, kemPublicKey []byte) (string, error) {
+	encapsulationKey, err := mlkem.NewEncapsulationKey768(kemPublicKey)
+	if err != nil {
+		return "", ErrInvalidKemPublicKey
+	}
+	// Generate a random 32-byte ephemeral secret and derive the X25519 shared secret
+	ephemeralSecret := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, ephemeralSecret); err != nil {
+		return "", ErrGeneratingRandomEphemeralSecret
+	}
+	ephemeralShare, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting data key: %v", err)
+	}
+	sharedSecret, err := curve25519.X25519(ephemeralSecret, publicKey.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("error encrypting data key: %v", err)
+	}
+	// Encapsulate the ML-KEM-768 shared secret
+	kemSecret, kemCiphertext := encapsulationKey.Encapsulate()
+	ephemeralShareString := base64.RawStdEncoding.EncodeToString(ephemeralShare)
+	kemCiphertextString := base64.RawStdEncoding.EncodeToString(kemCiphertext)
+	aead, err := hybridCipher(sharedSecret, kemSecret, ephemeralShareString, kemCiphertextString, publicKey)
+	if err != nil {
+		return "", err
+	}
+	// Encrypt the data key with an all-zero nonce, the wrap key is used once
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	ciphered := aead.Seal(nil, nonce, key.Bytes(), nil)
+	return strings.Join([]string{
+		HybridV1Tag,
+		ephemeralShareString,
+		kemCiphertextString,
+		base64.RawStdEncoding.EncodeToString(ciphered),
+	}, "\n"), nil
+}
+
+// openHybridDataKey decrypts a data key sealed by SealHybridDataKey, the parts are the serialized key without the tag.
+func openHybridDataKey(parts []string, privateKey core.PrivateKey) (*core.Key, error) {
+	if len(parts) != 3 {
+		return nil, ErrInvalidSerializedKey
+	}
+	ephemeralShare, err1 := base64.RawStdEncoding.DecodeString(parts[0])
+	kemCiphertext, err2 := base64.RawStdEncoding.DecodeString(parts[1])
+	ciphered, err3 := base64.RawStdEncoding.DecodeString(parts[2])
+	if errors.Join(err1, err2, err3) != nil {
+		return nil, ErrInvalidSerializedKey
+	}
+	decapsulationKey, err := kemDecapsulationKey(privateKey)
+	if err != nil {
+		return nil, ErrHybridKeyRequired
+	}
+	kemSecret, err := decapsulationKey.Decapsulate(kemCiphertext)
+	if err != nil {
+		return nil, ErrDecapsulatingHybridKey
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	sharedSecret, err := curve25519.X25519(privateKey.Bytes(), ephemeralShare)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	aead, err := hybridCipher(sharedSecret, kemSecret, parts[0], parts[1], publicKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	deciphered, err := aead.Open(nil, nonce, ciphered, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	key, err := core.KeyFromBytes(deciphered)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %v", err)
+	}
+	return &key, nil
+}
+
+// hybridCipher derives the wrap key from the X25519 and ML-KEM-768 shared secrets using HKDF and SHA-256,
+// and returns the AEAD cipher created from it.
+func hybridCipher(sharedSecret []byte, kemSecret []byte, ephemeralShareString string, kemCiphertextString string, publicKey core.PublicKey) (cipher.AEAD, error) {
+	secret := make([]byte, 0, len(sharedSecret)+len(kemSecret))
+	secret = append(append(secret, sharedSecret...), kemSecret...)
+	salt := ephemeralShareString + kemCiphertextString + publicKey.Encode()
+	hk := hkdf.New(sha256.New, secret, []byte(salt), []byte(HybridV1Info))
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hk, wrapKey); err != nil {
+		return nil, ErrErrorDerivingWrapKey
+	}
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, ErrFaliledToCreateCipher
+	}
+	return aead, nil
+}
@@ -13,6 +13,7 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
 )
 
 const (
@@ -29,8 +30,23 @@ var (
 	ErrFaliledToCreateCipher           = errors.New("failed to create cipher")
 	ErrErrorDerivingWrapKey            = errors.New("error deriving wrap key")
 	ErrCannotDeriveKeyFromEmptyKey     = errors.New("cannot derive key from empty key")
+	ErrEmptySecret                     = errors.New("the secret is empty")
+	ErrWrongPassphrase                 = errors.New("the passphrase does not match the recipient")
+	ErrInvalidWorkFactor               = errors.New("invalid scrypt work factor")
 )
 
+// Parameters of scrypt used to derive private keys from secrets, as recommended for interactive logins.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// PasswordLogN is the base 2 logarithm of the scrypt cost of the new password recipients.
+// Their keys stay at rest in the repository for as long as the share lasts, so the cost is the one recommended
+// for file encryption rather than for interactive logins, using 1 GiB of memory.
+const PasswordLogN = 20
+
 // deriveKey derives a key from the root key, salt, and info using HKDF and SHA-256.
 // It returns the derived key and any error encountered during the derivation process.
 func deriveKey(rootKey core.Key, salt []byte, info string) (core.Key, error) {
@@ -48,6 +64,89 @@ func deriveKey(rootKey core.Key, salt []byte, info string) (core.Key, error) {
 	return core.KeyFromBytes(derivedKey)
 }
 
+// DerivePrivateKey derives an X25519 private key from a secret, such as an invitation code or a passphrase, and a salt
+// using scrypt, so that guessing the secret is expensive. The same secret and salt always give the same private key.
+func DerivePrivateKey(secret string, salt []byte) (core.PrivateKey, error) {
+	return derivePrivateKey(secret, salt, scryptN)
+}
+
+// derivePrivateKey derives an X25519 private key from a secret and a salt using scrypt with the cost n.
+func derivePrivateKey(secret string, salt []byte, n int) (core.PrivateKey, error) {
+	if secret == "" {
+		return core.EmptyPrivateKey(), ErrEmptySecret
+	}
+	derived, err := scrypt.Key([]byte(secret), salt, n, scryptR, scryptP, curve25519.ScalarSize)
+	if err != nil {
+		return core.EmptyPrivateKey(), ErrGeneratingDerivedKey
+	}
+	return core.NewPrivateKeyFromBytes(derived), nil
+}
+
+// PasswordRecipient is a recipient whose key pair is derived from a passphrase and a random salt,
+// for users who do not manage a key pair. Data keys are sealed to its public key like for any other recipient,
+// and opened with the private key derived again from the passphrase and the salt.
+type PasswordRecipient struct {
+	PublicKey core.PublicKey
+	Salt      []byte
+	LogN      int // base 2 logarithm of the scrypt cost, 0 for the recipients created with the interactive cost
+}
+
+// NewPasswordRecipient creates a password recipient for the passphrase with a new random salt,
+// deriving its key pair with the scrypt cost PasswordLogN.
+func NewPasswordRecipient(passphrase string) (PasswordRecipient, error) {
+	return newPasswordRecipient(passphrase, PasswordLogN)
+}
+
+// newPasswordRecipient creates a password recipient for the passphrase with a new random salt and the scrypt cost 2^logN.
+func newPasswordRecipient(passphrase string, logN int) (PasswordRecipient, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return PasswordRecipient{}, ErrGeneratingRandomSalt
+	}
+	recipient := PasswordRecipient{Salt: salt, LogN: logN}
+	privateKey, err := recipient.derive(passphrase)
+	if err != nil {
+		return PasswordRecipient{}, err
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil {
+		return PasswordRecipient{}, err
+	}
+	recipient.PublicKey = publicKey
+	return recipient, nil
+}
+
+// SealDataKey seals the data key to the password recipient.
+func (r PasswordRecipient) SealDataKey(dataKey core.Key) (string, error) {
+	return SealDataKey(dataKey, r.PublicKey)
+}
+
+// Unlock derives the private key of the password recipient from the passphrase.
+// It returns ErrWrongPassphrase if the passphrase is not the one of the recipient.
+func (r PasswordRecipient) Unlock(passphrase string) (core.PrivateKey, error) {
+	privateKey, err := r.derive(passphrase)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	publicKey, err := privateKey.ToPublicKey()
+	if err != nil || !publicKey.Equals(r.PublicKey) {
+		return core.EmptyPrivateKey(), ErrWrongPassphrase
+	}
+	return privateKey, nil
+}
+
+// derive derives the private key of the password recipient from the passphrase with the scrypt cost of the recipient.
+// The cost is bounded, so that a recipient stored in the repository cannot be used to exhaust the memory.
+func (r PasswordRecipient) derive(passphrase string) (core.PrivateKey, error) {
+	if r.LogN == 0 {
+		return DerivePrivateKey(passphrase, r.Salt)
+	}
+	if r.LogN < 15 || r.LogN > PasswordLogN {
+		return core.EmptyPrivateKey(), ErrInvalidWorkFactor
+	}
+	return derivePrivateKey(passphrase, r.Salt, 1<<r.LogN)
+}
+
 // SealVaultDataKey encrypts the given data key using a vault key and returns the encrypted result.
 // It generates a random 32-byte salt, derives a key from the vault key, salt, and info using HKDF and SHA-256,
 // creates a new AEAD cipher using the derived key, encrypts the data key using the AEAD cipher,
@@ -191,6 +290,7 @@ func SealDataKey(key core.Key, publicKey core.PublicKey) (string, error) {
 }
 
 // OpenDataKey decrypts a serialized key using the provided private key.
+// Keys sealed by SealHybridDataKey are detected by their tag and require a hybrid private key.
 // It splits the serialized key into the ephemeral share and ciphered data key,
 // decodes them from base64, and derives the shared secret and wrap key.
 // Finally, it decrypts the data key using the wrap key and returns it as a core.Key.
@@ -205,6 +305,10 @@ func SealDataKey(key core.Key, publicKey core.PublicKey) (string, error) {
 func OpenDataKey(serialized string, privateKey core.PrivateKey) (*core.Key, error) {
 	// Split the serialized key into the ephemeral share and ciphered data key by the newline separator
 	parts := strings.Split(serialized, "\n")
+	// Keys sealed with the hybrid scheme start with its tag
+	if parts[0] == HybridV1Tag {
+		return openHybridDataKey(parts[1:], privateKey)
+	}
 	if len(parts) != 2 {
 		return nil, ErrInvalidSerializedKey
 	}
@@ -56,3 +56,206 @@ func TestSealAndOpenDataKey(t *testing.T) {
 		t.Errorf("Opened key does not match original data key")
 	}
 }
+
+func TestDerivePrivateKey(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+
+	// The same secret and salt give the same private key
+	key1, err := key_crypto.DerivePrivateKey("correct horse battery staple", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := key_crypto.DerivePrivateKey("correct horse battery staple", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub1, _ := key1.ToPublicKey()
+	pub2, _ := key2.ToPublicKey()
+	if !pub1.Equals(pub2) {
+		t.Errorf("Derived keys do not match")
+	}
+
+	// Another secret or salt give another private key
+	key3, _ := key_crypto.DerivePrivateKey("correct horse battery stapler", salt)
+	key4, _ := key_crypto.DerivePrivateKey("correct horse battery staple", []byte("fedcba9876543210"))
+	pub3, _ := key3.ToPublicKey()
+	pub4, _ := key4.ToPublicKey()
+	if pub1.Equals(pub3) || pub1.Equals(pub4) {
+		t.Errorf("Derived keys should differ")
+	}
+
+	// The derived key opens the data keys sealed to its public key
+	dataKey := core.NewKeyFromRand()
+	sealedKey, err := key_crypto.SealDataKey(dataKey, pub1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openedKey, err := key_crypto.OpenDataKey(sealedKey, key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+
+	// An empty secret is rejected
+	if _, err := key_crypto.DerivePrivateKey("", salt); err != key_crypto.ErrEmptySecret {
+		t.Errorf("Expected ErrEmptySecret, got %v", err)
+	}
+}
+
+func TestPasswordRecipient(t *testing.T) {
+	recipient, err := key_crypto.NewPasswordRecipient("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal a data key to the recipient
+	dataKey := core.NewKeyFromRand()
+	sealedKey, err := recipient.SealDataKey(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The passphrase unlocks the private key opening the data key
+	privateKey, err := recipient.Unlock("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openedKey, err := key_crypto.OpenDataKey(sealedKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+
+	// Another passphrase is rejected
+	if _, err := recipient.Unlock("correct horse battery stapler"); err != key_crypto.ErrWrongPassphrase {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+
+	// The same passphrase gives another recipient with another salt
+	other, err := key_crypto.NewPasswordRecipient("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.PublicKey.Equals(recipient.PublicKey) {
+		t.Errorf("Password recipients with different salts should differ")
+	}
+
+	// The key pair is derived with the cost for keys at rest
+	if recipient.LogN != key_crypto.PasswordLogN {
+		t.Errorf("Expected a scrypt cost of 2^%d, got 2^%d", key_crypto.PasswordLogN, recipient.LogN)
+	}
+}
+
+func TestPasswordRecipientWorkFactor(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	privateKey, err := key_crypto.DerivePrivateKey("correct horse battery staple", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := privateKey.ToPublicKey()
+
+	// The recipients created with the interactive cost are still unlocked
+	legacy := key_crypto.PasswordRecipient{PublicKey: publicKey, Salt: salt}
+	if _, err := legacy.Unlock("correct horse battery staple"); err != nil {
+		t.Errorf("Expected the recipient to be unlocked, got %v", err)
+	}
+
+	// A cost outside of the bounds is rejected before deriving the key
+	for _, logN := range []int{1, key_crypto.PasswordLogN + 1} {
+		recipient := key_crypto.PasswordRecipient{PublicKey: publicKey, Salt: salt, LogN: logN}
+		if _, err := recipient.Unlock("correct horse battery staple"); err != key_crypto.ErrInvalidWorkFactor {
+			t.Errorf("Expected ErrInvalidWorkFactor for 2^%d, got %v", logN, err)
+		}
+	}
+}
+
+func TestSealAndOpenHybridDataKey(t *testing.T) {
+	dataKey := core.NewKeyFromRand()
+	privateKey, err := key_crypto.NewHybridPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := privateKey.ToPublicKey()
+	kemPublicKey, err := key_crypto.KemPublicKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal the data key with the hybrid scheme and open it, the format is detected
+	sealedKey, err := key_crypto.SealHybridDataKey(dataKey, publicKey, kemPublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openedKey, err := key_crypto.OpenDataKey(sealedKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+
+	// The hybrid private key still opens the keys sealed with X25519 only
+	classicKey, err := key_crypto.SealDataKey(dataKey, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openedKey, err = key_crypto.OpenDataKey(classicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !openedKey.Equals(dataKey) {
+		t.Errorf("Opened key does not match original data key")
+	}
+
+	// The X25519 part of the key alone does not open the hybrid sealed key
+	classicPrivateKey := core.NewPrivateKeyFromBytes(privateKey.Bytes())
+	if _, err := key_crypto.OpenDataKey(sealedKey, classicPrivateKey); err != key_crypto.ErrHybridKeyRequired {
+		t.Errorf("Expected ErrHybridKeyRequired, got %v", err)
+	}
+
+	// The encoded hybrid key is decoded with its seed
+	decoded, err := core.NewPrivateKeyFromEncoded(privateKey.Unsafe().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.IsHybrid() {
+		t.Errorf("Decoded key should be hybrid")
+	}
+	if _, err := key_crypto.OpenDataKey(sealedKey, decoded); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenHybridDataKeyWithAnotherKey(t *testing.T) {
+	dataKey := core.NewKeyFromRand()
+	key1, err := key_crypto.NewHybridPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := key_crypto.NewHybridPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub1, _ := key1.ToPublicKey()
+	kem1, err := key_crypto.KemPublicKey(key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedKey, err := key_crypto.SealHybridDataKey(dataKey, pub1, kem1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key_crypto.OpenDataKey(sealedKey, key2); err == nil {
+		t.Errorf("Opening with another key should fail")
+	}
+
+	// A classic key has no KEM public key
+	classic, _ := core.NewPrivateKeyFromRand()
+	if _, err := key_crypto.KemPublicKey(classic); err != key_crypto.ErrNotHybridKey {
+		t.Errorf("Expected ErrNotHybridKey, got %v", err)
+	}
+}
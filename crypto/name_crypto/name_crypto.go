@@ -0,0 +1,100 @@
+// Package name_crypto encrypts the names of the files and directories of a repository deterministically,
+// so that the encrypted name of a file can be computed from its plain name, without listing the directory.
+//
+// The names are encrypted with a synthetic IV (SIV) construction: the IV is the HMAC-SHA256 of the name,
+// truncated to 16 bytes, and the name is encrypted with AES-256-CTR using that IV. The IV also authenticates
+// the name when it is decrypted. The same name encrypted with the same key always gives the same encrypted name,
+// which only reveals whether two names of the same directory are equal.
+// The encrypted names are encoded with lower case base32 without padding, so that they are valid file names
+// on case insensitive file systems.
+package name_crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ctb-cli/core"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	NameSIVV1Info = "cognitechbridge.com/v1/NameSIV" // NameSIVV1Info is the info string used for deriving the name keys from the key of the directory.
+	MaxNameLength = 255                              // MaxNameLength is the maximum length of an encrypted name, the limit of most file systems.
+	ivSize        = 16
+)
+
+var (
+	ErrEmptyName          = errors.New("the name is empty")
+	ErrEmptyKey           = errors.New("the name key is empty")
+	ErrNameTooLong        = errors.New("the name is too long to be encrypted")
+	ErrInvalidName        = errors.New("invalid encrypted name")
+	ErrNameAuthentication = errors.New("the encrypted name cannot be authenticated")
+)
+
+var nameEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// EncryptName encrypts the name with the key deterministically and returns the encoded encrypted name.
+func EncryptName(key core.Key, name string) (string, error) {
+	if name == "" {
+		return "", ErrEmptyName
+	}
+	macKey, block, err := deriveKeys(key)
+	if err != nil {
+		return "", err
+	}
+	// The synthetic IV is the truncated MAC of the name
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(name))
+	iv := mac.Sum(nil)[:ivSize]
+	out := make([]byte, ivSize+len(name))
+	copy(out, iv)
+	cipher.NewCTR(block, iv).XORKeyStream(out[ivSize:], []byte(name))
+	encoded := nameEncoding.EncodeToString(out)
+	if len(encoded) > MaxNameLength {
+		return "", ErrNameTooLong
+	}
+	return encoded, nil
+}
+
+// DecryptName decrypts the encoded encrypted name with the key and checks that it was encrypted with the key.
+func DecryptName(key core.Key, encrypted string) (string, error) {
+	raw, err := nameEncoding.DecodeString(strings.ToLower(encrypted))
+	if err != nil || len(raw) <= ivSize {
+		return "", ErrInvalidName
+	}
+	macKey, block, err := deriveKeys(key)
+	if err != nil {
+		return "", err
+	}
+	iv := raw[:ivSize]
+	name := make([]byte, len(raw)-ivSize)
+	cipher.NewCTR(block, iv).XORKeyStream(name, raw[ivSize:])
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(name)
+	if !hmac.Equal(mac.Sum(nil)[:ivSize], iv) {
+		return "", ErrNameAuthentication
+	}
+	return string(name), nil
+}
+
+// deriveKeys derives the MAC key and the AES-256 cipher of the names from the key using HKDF and SHA-256.
+func deriveKeys(key core.Key) ([]byte, cipher.Block, error) {
+	if key.IsEmpty() {
+		return nil, nil, ErrEmptyKey
+	}
+	derived := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key.Bytes(), nil, []byte(NameSIVV1Info)), derived); err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return derived[:32], block, nil
+}
@@ -0,0 +1,66 @@
+package name_crypto_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/name_crypto"
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecryptName(t *testing.T) {
+	key := core.NewKeyFromRand()
+	for _, name := range []string{"a", "report.pdf", "Customer ACME - 2024", "名前.txt", strings.Repeat("x", 140)} {
+		encrypted, err := name_crypto.EncryptName(key, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encrypted == name || strings.ToLower(encrypted) != encrypted || strings.HasPrefix(encrypted, ".") {
+			t.Errorf("Encrypted name %q is not a lower case opaque name", encrypted)
+		}
+		// The encryption is deterministic
+		again, err := name_crypto.EncryptName(key, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != encrypted {
+			t.Errorf("Encrypting %q twice gives %q and %q", name, encrypted, again)
+		}
+		decrypted, err := name_crypto.DecryptName(key, encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != name {
+			t.Errorf("Decrypted name %q does not match %q", decrypted, name)
+		}
+		// Case insensitive file systems may change the case of the name
+		if decrypted, err := name_crypto.DecryptName(key, strings.ToUpper(encrypted)); err != nil || decrypted != name {
+			t.Errorf("Decrypting the upper case name failed: %v", err)
+		}
+	}
+}
+
+func TestDecryptNameWithAnotherKey(t *testing.T) {
+	encrypted, err := name_crypto.EncryptName(core.NewKeyFromRand(), "secret project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := name_crypto.DecryptName(core.NewKeyFromRand(), encrypted); err != name_crypto.ErrNameAuthentication {
+		t.Errorf("Expected ErrNameAuthentication, got %v", err)
+	}
+	if _, err := name_crypto.DecryptName(core.NewKeyFromRand(), ".meta"); err != name_crypto.ErrInvalidName {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+}
+
+func TestEncryptNameErrors(t *testing.T) {
+	key := core.NewKeyFromRand()
+	if _, err := name_crypto.EncryptName(key, ""); err != name_crypto.ErrEmptyName {
+		t.Errorf("Expected ErrEmptyName, got %v", err)
+	}
+	if _, err := name_crypto.EncryptName(key, strings.Repeat("x", 200)); err != name_crypto.ErrNameTooLong {
+		t.Errorf("Expected ErrNameTooLong, got %v", err)
+	}
+	if _, err := name_crypto.EncryptName(core.EmptyKey(), "name"); err != name_crypto.ErrEmptyKey {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+}
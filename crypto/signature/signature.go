@@ -0,0 +1,118 @@
+// Package signature signs and verifies messages with the X25519 keys of the users,
+// using the XEdDSA signature scheme.
+//
+// XEdDSA converts the X25519 private key to an Ed25519 signing key, and the X25519 public key
+// to the matching Ed25519 public key, so that users can sign without a second key pair.
+// The signatures are verified as Ed25519 signatures.
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"ctb-cli/core"
+	"encoding/base64"
+	"errors"
+
+	"filippo.io/edwards25519"
+	"filippo.io/edwards25519/field"
+)
+
+const (
+	SignatureSize = ed25519.SignatureSize // SignatureSize is the size of a signature in bytes.
+)
+
+var (
+	ErrInvalidPrivateKey    = errors.New("invalid private key")
+	ErrGeneratingRandomness = errors.New("error generating random nonce")
+)
+
+// Sign signs the message with the X25519 private key and returns the signature.
+func Sign(privateKey core.PrivateKey, message []byte) ([]byte, error) {
+	// The X25519 private scalar is the clamped private key
+	k, err := edwards25519.NewScalar().SetBytesWithClamping(privateKey.Bytes())
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	// The Ed25519 public key is the point kB with its sign bit cleared,
+	// the scalar is negated if the sign bit of kB is set
+	publicKey := new(edwards25519.Point).ScalarBaseMult(k).Bytes()
+	a := edwards25519.NewScalar().Set(k)
+	if publicKey[31]&0x80 != 0 {
+		a.Negate(k)
+		publicKey[31] &= 0x7f
+	}
+	// Derive the nonce from the scalar, the message and 64 random bytes
+	z := make([]byte, 64)
+	if _, err := rand.Read(z); err != nil {
+		return nil, ErrGeneratingRandomness
+	}
+	h := sha512.New()
+	h.Write([]byte{0xfe})
+	for i := 0; i < 31; i++ {
+		h.Write([]byte{0xff})
+	}
+	h.Write(a.Bytes())
+	h.Write(message)
+	h.Write(z)
+	r, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+	// Compute the Ed25519 challenge and the signature scalar s = r + ha
+	h.Reset()
+	h.Write(R)
+	h.Write(publicKey)
+	h.Write(message)
+	c, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	s := edwards25519.NewScalar().MultiplyAdd(c, a, r)
+	return append(R, s.Bytes()...), nil
+}
+
+// Verify reports whether the signature of the message is valid for the X25519 public key.
+func Verify(publicKey core.PublicKey, message []byte, signature []byte) bool {
+	edPublicKey, ok := edwardsPublicKey(publicKey)
+	if !ok || len(signature) != SignatureSize {
+		return false
+	}
+	return ed25519.Verify(edPublicKey, message, signature)
+}
+
+// Encode returns the encoding of the signature stored in the repository.
+func Encode(signature []byte) string {
+	return base64.RawStdEncoding.EncodeToString(signature)
+}
+
+// VerifyEncoded reports whether the encoded signature of the message is valid for the encoded public key.
+func VerifyEncoded(encodedPublicKey string, message []byte, encodedSignature string) bool {
+	publicKey, err := core.NewPublicKeyFromEncoded(encodedPublicKey)
+	if err != nil {
+		return false
+	}
+	signature, err := base64.RawStdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return false
+	}
+	return Verify(publicKey, message, signature)
+}
+
+// edwardsPublicKey converts the X25519 public key u to the Ed25519 public key y = (u - 1) / (u + 1)
+// with its sign bit cleared. It returns false if the public key is not a canonical encoding.
+func edwardsPublicKey(publicKey core.PublicKey) (ed25519.PublicKey, bool) {
+	if len(publicKey.Bytes()) != 32 {
+		return nil, false
+	}
+	u, err := new(field.Element).SetBytes(publicKey.Bytes())
+	if err != nil || string(u.Bytes()) != string(publicKey.Bytes()) {
+		return nil, false
+	}
+	one := new(field.Element).One()
+	num := new(field.Element).Subtract(u, one)
+	den := new(field.Element).Add(u, one)
+	y := new(field.Element).Multiply(num, new(field.Element).Invert(den))
+	return y.Bytes(), true
+}
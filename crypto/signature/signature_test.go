@@ -0,0 +1,94 @@
+package signature_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	// Sign with many keys, so that both signs of the Ed25519 public key are covered
+	for i := 0; i < 32; i++ {
+		privateKey, err := core.NewPrivateKeyFromRand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := privateKey.ToPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := []byte("message to sign")
+		sig, err := signature.Sign(privateKey, message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sig) != signature.SignatureSize {
+			t.Fatalf("signature size is %d, want %d", len(sig), signature.SignatureSize)
+		}
+		if !signature.Verify(publicKey, message, sig) {
+			t.Fatal("valid signature does not verify")
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	privateKey, _ := core.NewPrivateKeyFromRand()
+	publicKey, _ := privateKey.ToPublicKey()
+	otherKey, _ := core.NewPrivateKeyFromRand()
+	otherPublicKey, _ := otherKey.ToPublicKey()
+	message := []byte("message to sign")
+	sig, err := signature.Sign(privateKey, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signature.Verify(publicKey, []byte("other message"), sig) {
+		t.Error("signature verifies for another message")
+	}
+	if signature.Verify(otherPublicKey, message, sig) {
+		t.Error("signature verifies for another public key")
+	}
+	tampered := append([]byte{}, sig...)
+	tampered[10] ^= 1
+	if signature.Verify(publicKey, message, tampered) {
+		t.Error("tampered signature verifies")
+	}
+	if signature.Verify(publicKey, message, sig[:len(sig)-1]) {
+		t.Error("truncated signature verifies")
+	}
+}
+
+func TestSignaturesAreRandomized(t *testing.T) {
+	privateKey, _ := core.NewPrivateKeyFromRand()
+	message := []byte("message to sign")
+	sig1, _ := signature.Sign(privateKey, message)
+	sig2, _ := signature.Sign(privateKey, message)
+	if string(sig1) == string(sig2) {
+		t.Error("two signatures of the same message are equal")
+	}
+}
+
+func TestVerifyEncoded(t *testing.T) {
+	privateKey, _ := core.NewPrivateKeyFromRand()
+	publicKey, _ := privateKey.ToPublicKey()
+	// Public keys are encoded in 44 characters, skip the keys whose encoding is shorter
+	for len(publicKey.String()) != 44 {
+		privateKey, _ = core.NewPrivateKeyFromRand()
+		publicKey, _ = privateKey.ToPublicKey()
+	}
+	message := []byte("message to sign")
+	sig, err := signature.Sign(privateKey, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := signature.Encode(sig)
+	if !signature.VerifyEncoded(publicKey.String(), message, encoded) {
+		t.Error("valid encoded signature does not verify")
+	}
+	if signature.VerifyEncoded("invalid", message, encoded) {
+		t.Error("signature verifies for an invalid public key")
+	}
+	if signature.VerifyEncoded(publicKey.String(), message, "!"+encoded) {
+		t.Error("invalid encoding verifies")
+	}
+}
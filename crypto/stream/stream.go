@@ -24,7 +24,7 @@ type Reader struct {
 	buf    [encChunkSize]byte
 
 	err   error
-	nonce [chacha20poly1305.NonceSize]byte
+	nonce []byte
 }
 
 const (
@@ -32,14 +32,26 @@ const (
 	lastChunkFlag = 0x01
 )
 
+var ErrUnsupportedAEAD = errors.New("stream: the AEAD overhead must be 16 bytes")
+
 func NewReader(key []byte, src io.Reader) (*Reader, error) {
 	aead, err := chacha20poly1305.New(key)
 	if err != nil {
 		return nil, err
 	}
+	return NewReaderWithAEAD(aead, src)
+}
+
+// NewReaderWithAEAD returns a Reader decrypting src with aead, which must have a 16-byte overhead.
+// The chunk counter is stored in the last bytes of the nonce, whatever its size.
+func NewReaderWithAEAD(aead cipher.AEAD, src io.Reader) (*Reader, error) {
+	if aead.Overhead() != chacha20poly1305.Overhead {
+		return nil, ErrUnsupportedAEAD
+	}
 	return &Reader{
-		a:   aead,
-		src: src,
+		a:     aead,
+		src:   src,
+		nonce: make([]byte, aead.NonceSize()),
 	}, nil
 }
 
@@ -99,34 +111,34 @@ func (r *Reader) readChunk() (last bool, err error) {
 	case err == io.ErrUnexpectedEOF:
 		// The last chunk can be short, but not empty unless it's the first and
 		// only chunk.
-		if !nonceIsZero(&r.nonce) && n == r.a.Overhead() {
+		if !nonceIsZero(r.nonce) && n == r.a.Overhead() {
 			return false, errors.New("last chunk is empty, try age v1.0.0, and please consider reporting this")
 		}
 		in = in[:n]
 		last = true
-		setLastChunkFlag(&r.nonce)
+		setLastChunkFlag(r.nonce)
 	case err != nil:
 		return false, err
 	}
 
 	outBuf := make([]byte, 0, ChunkSize)
-	out, err := r.a.Open(outBuf, r.nonce[:], in, nil)
+	out, err := r.a.Open(outBuf, r.nonce, in, nil)
 	if err != nil && !last {
 		// Check if this was a full-length final chunk.
 		last = true
-		setLastChunkFlag(&r.nonce)
-		out, err = r.a.Open(outBuf, r.nonce[:], in, nil)
+		setLastChunkFlag(r.nonce)
+		out, err = r.a.Open(outBuf, r.nonce, in, nil)
 	}
 	if err != nil {
 		return false, errors.New("failed to decrypt and authenticate payload chunk")
 	}
 
-	incNonce(&r.nonce)
+	incNonce(r.nonce)
 	r.unread = r.buf[:copy(r.buf[:], out)]
 	return last, nil
 }
 
-func incNonce(nonce *[chacha20poly1305.NonceSize]byte) {
+func incNonce(nonce []byte) {
 	for i := len(nonce) - 2; i >= 0; i-- {
 		nonce[i]++
 		if nonce[i] != 0 {
@@ -138,12 +150,17 @@ func incNonce(nonce *[chacha20poly1305.NonceSize]byte) {
 	}
 }
 
-func setLastChunkFlag(nonce *[chacha20poly1305.NonceSize]byte) {
+func setLastChunkFlag(nonce []byte) {
 	nonce[len(nonce)-1] = lastChunkFlag
 }
 
-func nonceIsZero(nonce *[chacha20poly1305.NonceSize]byte) bool {
-	return *nonce == [chacha20poly1305.NonceSize]byte{}
+func nonceIsZero(nonce []byte) bool {
+	for _, b := range nonce {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 type Writer struct {
@@ -151,7 +168,7 @@ type Writer struct {
 	dst       io.Writer
 	unwritten []byte // backed by buf
 	buf       [encChunkSize]byte
-	nonce     [chacha20poly1305.NonceSize]byte
+	nonce     []byte
 	err       error
 }
 
@@ -160,9 +177,19 @@ func NewWriter(key []byte, dst io.Writer) (*Writer, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewWriterWithAEAD(aead, dst)
+}
+
+// NewWriterWithAEAD returns a Writer encrypting to dst with aead, which must have a 16-byte overhead.
+// The chunk counter is stored in the last bytes of the nonce, whatever its size.
+func NewWriterWithAEAD(aead cipher.AEAD, dst io.Writer) (*Writer, error) {
+	if aead.Overhead() != chacha20poly1305.Overhead {
+		return nil, ErrUnsupportedAEAD
+	}
 	w := &Writer{
-		a:   aead,
-		dst: dst,
+		a:     aead,
+		dst:   dst,
+		nonce: make([]byte, aead.NonceSize()),
 	}
 	w.unwritten = w.buf[:0]
 	return w, nil
@@ -220,11 +247,11 @@ func (w *Writer) flushChunk(last bool) error {
 	}
 
 	if last {
-		setLastChunkFlag(&w.nonce)
+		setLastChunkFlag(w.nonce)
 	}
-	buf := w.a.Seal(w.buf[:0], w.nonce[:], w.unwritten, nil)
+	buf := w.a.Seal(w.buf[:0], w.nonce, w.unwritten, nil)
 	_, err := w.dst.Write(buf)
 	w.unwritten = w.buf[:0]
-	incNonce(&w.nonce)
+	incNonce(w.nonce)
 	return err
 }
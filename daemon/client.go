@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dialTimeout is the maximum time to wait for a control socket to accept a connection.
+const dialTimeout = 2 * time.Second
+
+// Send sends the request to the control socket at the given path and returns the response.
+// A response with Ok set to false is returned as an error.
+func Send(path string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, err
+	}
+	var res Response
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return Response{}, err
+	}
+	if !res.Ok {
+		return res, errors.New(res.Err)
+	}
+	return res, nil
+}
+
+// List returns the status of every running mount of the current user.
+// Sockets that do not answer are considered stale and removed.
+func List() ([]core.MountStatus, error) {
+	sockets, err := listSockets()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]core.MountStatus, 0)
+	for _, path := range sockets {
+		res, err := Send(path, Request{Command: CommandStatus})
+		if err != nil {
+			_ = os.Remove(path)
+			continue
+		}
+		list = append(list, *res.Status)
+	}
+	return list, nil
+}
+
+// Status returns the status of the running mount serving the given mount point.
+func Status(mountPoint string) (core.MountStatus, error) {
+	path, err := find(mountPoint)
+	if err != nil {
+		return core.MountStatus{}, err
+	}
+	res, err := Send(path, Request{Command: CommandStatus})
+	if err != nil {
+		return core.MountStatus{}, err
+	}
+	return *res.Status, nil
+}
+
+// Unmount asks the running mount serving the given mount point to drain its write cache and unmount.
+func Unmount(mountPoint string) error {
+	path, err := find(mountPoint)
+	if err != nil {
+		return err
+	}
+	_, err = Send(path, Request{Command: CommandUnmount})
+	return err
+}
+
+// find returns the path of the control socket of the mount serving the given mount point.
+func find(mountPoint string) (string, error) {
+	sockets, err := listSockets()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range sockets {
+		res, err := Send(path, Request{Command: CommandStatus})
+		if err != nil {
+			continue
+		}
+		if sameMountPoint(res.Status.MountPoint, mountPoint) {
+			return path, nil
+		}
+	}
+	return "", ErrMountNotFound
+}
+
+// listSockets returns the paths of all control sockets in the sockets directory.
+func listSockets() ([]string, error) {
+	dir, err := SocketDir()
+	if err != nil {
+		return nil, err
+	}
+	return filepath.Glob(filepath.Join(dir, "*.sock"))
+}
+
+// sameMountPoint reports whether the two mount points refer to the same location.
+// Trailing separators are ignored, so "Z:" and "Z:\" are the same mount point.
+func sameMountPoint(a string, b string) bool {
+	clean := func(p string) string {
+		return strings.TrimRight(filepath.Clean(p), `\/`)
+	}
+	return clean(a) == clean(b)
+}
@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	CommandStatus  = "status"  // CommandStatus requests the status of the mount.
+	CommandUnmount = "unmount" // CommandUnmount requests a graceful unmount of the mount.
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown control command")
+	ErrMountNotFound  = errors.New("no running mount found for the mount point")
+)
+
+// Request is a request sent to the control socket of a mount.
+type Request struct {
+	Command string `json:"command"`
+}
+
+// Response is the response of the control socket to a request.
+type Response struct {
+	Ok     bool              `json:"ok"`
+	Err    string            `json:"err,omitempty"`
+	Status *core.MountStatus `json:"status,omitempty"`
+}
+
+// Handler handles the requests received on the control socket of a mount.
+type Handler interface {
+	// Status returns the status of the mount.
+	Status() core.MountStatus
+	// Unmount drains the write cache and unmounts the file system.
+	Unmount() error
+}
+
+// Server serves the control socket of a mount.
+type Server struct {
+	listener net.Listener
+	handler  Handler
+	path     string
+}
+
+// Listen creates the control socket of the current process and starts serving it in a separate goroutine.
+// The socket is created in the sockets directory and named after the process id.
+func Listen(handler Handler) (*Server, error) {
+	dir, err := SocketDir()
+	if err != nil {
+		return nil, err
+	}
+	path := socketPath(dir, os.Getpid())
+	// Remove a stale socket left by a previous process with the same pid
+	_ = os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating control socket: %v", err)
+	}
+	s := &Server{
+		listener: listener,
+		handler:  handler,
+		path:     path,
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops serving the control socket and removes the socket file.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	_ = os.Remove(s.path)
+	return err
+}
+
+// serve accepts connections on the control socket until the listener is closed.
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle reads a single request from the connection, dispatches it to the handler and writes the response.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Error("Error reading control request: ", err)
+		return
+	}
+	res := Response{Ok: true}
+	switch req.Command {
+	case CommandStatus:
+		status := s.handler.Status()
+		res.Status = &status
+	case CommandUnmount:
+		if err := s.handler.Unmount(); err != nil {
+			res = Response{Ok: false, Err: err.Error()}
+		}
+	default:
+		res = Response{Ok: false, Err: ErrUnknownCommand.Error()}
+	}
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		log.Error("Error writing control response: ", err)
+	}
+}
+
+// SocketDir returns the directory holding the control sockets of the running mounts.
+// The directory is created if it does not exist and is only accessible by the current user.
+func SocketDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".cognitechbridge", "mounts")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// socketPath returns the path of the control socket of the process with the given pid.
+func socketPath(dir string, pid int) string {
+	return filepath.Join(dir, fmt.Sprintf("%d.sock", pid))
+}
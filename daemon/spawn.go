@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var (
+	ErrDaemonExited  = errors.New("background mount exited before the file system was mounted")
+	ErrDaemonTimeout = errors.New("timeout waiting for the background mount")
+)
+
+// startTimeout is the maximum time to wait for a background mount to report that it is mounted.
+const startTimeout = 30 * time.Second
+
+// Spawn starts the current executable with the given arguments as a background process detached from the terminal.
+// It waits until the background process reports through its control socket that the file system is mounted,
+// and returns the status of the new mount.
+// The secrets of the background process, such as its private key, are written to its standard input
+// rather than given in its arguments, which are visible to the other users of the system.
+func Spawn(args []string, stdin io.Reader) (*Response, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := SocketDir()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = stdin
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	path := socketPath(dir, cmd.Process.Pid)
+	deadline := time.After(startTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return nil, fmt.Errorf("%w: %v", ErrDaemonExited, err)
+		case <-deadline:
+			return nil, ErrDaemonTimeout
+		case <-ticker.C:
+			res, err := Send(path, Request{Command: CommandStatus})
+			if err == nil && res.Status.Mounted {
+				return &res, nil
+			}
+		}
+	}
+}
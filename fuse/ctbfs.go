@@ -2,15 +2,22 @@ package fuse
 
 import (
 	"ctb-cli/core"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
+	"sort"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/winfsp/cgofuse/fuse"
 )
 
+var (
+	ErrUnmountFailed = errors.New("error unmounting the file system")
+)
+
 type CtbFs struct {
 	mountPoint string
 	fuse.FileSystemBase
@@ -19,14 +26,32 @@ type CtbFs struct {
 
 	fs core.FileSystemService
 
+	// readOnly indicates that the file system is mounted in read-only mode.
+	// In read-only mode every mutating operation returns EROFS.
+	readOnly bool
+
 	root    *Node
-	openMap map[uint64]*Node
+	handles map[uint64]*Handle
+	fh      uint64
 
 	ino Ino
 	uid uint32
 	gid uint32
+
+	// host is the fuse host serving the file system, it is set when the file system is mounted
+	host *fuse.FileSystemHost
+	// mounted indicates that the file system is mounted and receiving operations
+	mounted bool
+	// closing indicates that the file system is shutting down.
+	// While closing, operations that open or modify files return EBUSY.
+	closing bool
+	// errors holds the most recent errors of the file system, reported by the mount status
+	errors []string
 }
 
+// maxRecordedErrors is the maximum number of recent errors kept for the mount status.
+const maxRecordedErrors = 20
+
 type Node struct {
 	stat     fuse.Stat_t
 	xatr     map[string][]byte
@@ -41,10 +66,13 @@ type Ino struct {
 	counter uint64
 }
 
-func New(fs core.FileSystemService) *CtbFs {
+// New creates a new CtbFs on top of the given file system service.
+// If readOnly is true, the file system is mounted read-only and never modifies the repository.
+func New(fs core.FileSystemService, readOnly bool) *CtbFs {
 	c := CtbFs{
-		openMap: make(map[uint64]*Node),
-		fs:      fs,
+		handles:  make(map[uint64]*Handle),
+		fs:       fs,
+		readOnly: readOnly,
 	}
 	defer c.synchronize()()
 	modePerm := fs.GetUserFileAccess("/", true)
@@ -69,14 +97,115 @@ func (c *CtbFs) FindMountPoint(mount string) string {
 func (c *CtbFs) Mount() {
 	host := fuse.NewFileSystemHost(c)
 	host.SetCapReaddirPlus(true)
+	c.host = host
 	opts := make([]string, 0)
 	mount := c.mountPoint
 	if runtime.GOOS == "windows" {
 		opts = append(opts, "-o", "volname=CTB-Secure-Drive")
+	} else if c.readOnly {
+		opts = append(opts, "-o", "ro")
 	}
 	host.Mount(mount, opts)
 }
 
+// Init is called by the fuse host when the file system is mounted.
+func (c *CtbFs) Init() {
+	defer c.synchronize()()
+	c.mounted = true
+}
+
+// Destroy is called by the fuse host when the file system is unmounted.
+// The fuse host unmounts the file system on its own when the process is interrupted,
+// so the files still open for writing are committed or journaled here as well.
+func (c *CtbFs) Destroy() {
+	defer c.synchronize()()
+	c.closing = true
+	c.mounted = false
+	_ = c.drain()
+}
+
+// Unmount gracefully shuts the file system down.
+// It stops accepting operations that open or modify files, commits every file that is open for writing,
+// journals the files that could not be committed, and then unmounts the file system.
+// It returns an error if any of the files could not be committed; the file system is unmounted anyway.
+func (c *CtbFs) Unmount() error {
+	err := c.shutdown()
+	if c.host == nil || !c.host.Unmount() {
+		return errors.Join(err, ErrUnmountFailed)
+	}
+	return err
+}
+
+// shutdown stops accepting operations that open or modify files and drains the write cache.
+func (c *CtbFs) shutdown() error {
+	defer c.synchronize()()
+	c.closing = true
+	return c.drain()
+}
+
+// drain commits every file that is open for writing.
+// The files that could not be committed are journaled, so they are committed on the next mount.
+func (c *CtbFs) drain() error {
+	if c.readOnly {
+		return nil
+	}
+	var errs []error
+	for _, path := range c.pendingCommits() {
+		if err := c.fs.Sync(path); err != nil {
+			c.recordError(fmt.Errorf("error committing %s: %v", path, err))
+			c.journal(path)
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// journal records the file at the specified path as not committed, so it is committed on the next mount.
+func (c *CtbFs) journal(path string) {
+	if err := c.fs.Journal(path); err != nil {
+		log.Error("Error journaling node: ", path, ". error: ", err)
+		c.recordError(fmt.Errorf("error journaling %s: %v", path, err))
+	}
+}
+
+// Status returns the status of the file system.
+func (c *CtbFs) Status() core.MountStatus {
+	defer c.synchronize()()
+	return core.MountStatus{
+		MountPoint:     c.mountPoint,
+		Mounted:        c.mounted,
+		ReadOnly:       c.readOnly,
+		PendingCommits: c.pendingCommits(),
+		Errors:         append([]string{}, c.errors...),
+	}
+}
+
+// pendingCommits returns the paths of the files that are open for writing and not committed yet.
+func (c *CtbFs) pendingCommits() []string {
+	paths := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, handle := range c.handles {
+		if !handle.write {
+			continue
+		}
+		if _, ok := seen[handle.path]; ok {
+			continue
+		}
+		seen[handle.path] = struct{}{}
+		paths = append(paths, handle.path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// recordError records an error of the file system, keeping only the most recent errors.
+func (c *CtbFs) recordError(err error) {
+	c.errors = append(c.errors, fmt.Sprintf("%s: %v", time.Now().Format(time.RFC3339), err))
+	if len(c.errors) > maxRecordedErrors {
+		c.errors = c.errors[len(c.errors)-maxRecordedErrors:]
+	}
+}
+
 // FindUnusedDrive finds the first unused drive letter in the system.
 // It iterates through drive letters from 'Z' to 'A' and checks if each drive is accessible.
 // If an unused drive is found, it prints the drive letter and exits the loop.
@@ -122,43 +251,10 @@ func (c *CtbFs) getNode(path string, fh uint64) *Node {
 	if ^uint64(0) == fh {
 		_, _, node := c.lookupNode(path, nil)
 		return node
-	} else {
-		return c.openMap[fh]
-	}
-}
-
-func (c *CtbFs) openNode(path string, dir bool) (errc int, fh uint64) {
-	_, _, node := c.lookupNode(path, nil)
-	if node == nil {
-		log.Error("Error opening node: ", path, " does not exist.")
-		return -fuse.ENOENT, ^uint64(0)
-	}
-	if !dir && fuse.S_IFDIR == node.stat.Mode&fuse.S_IFMT {
-		log.Error("Error opening node: ", path, " is a directory and requested as a file.")
-		return -fuse.EISDIR, ^uint64(0)
-	}
-	if dir && fuse.S_IFDIR != node.stat.Mode&fuse.S_IFMT {
-		log.Error("Error opening node: ", path, " is not a directory and requested as a directory.")
-		return -fuse.ENOTDIR, ^uint64(0)
-	}
-	node.opencnt++
-	if node.opencnt == 1 {
-		c.openMap[node.stat.Ino] = node
-	}
-	return 0, node.stat.Ino
-}
-
-func (c *CtbFs) closeNode(fh uint64) int {
-	node := c.openMap[fh]
-	node.opencnt--
-	if node.opencnt == 0 {
-		err := c.commit(node)
-		if err != nil {
-			return errno(err)
-		}
-		delete(c.openMap, node.stat.Ino)
+	} else if handle := c.getHandle(fh); handle != nil {
+		return handle.node
 	}
-	return 0
+	return nil
 }
 
 func (c *CtbFs) exploreDir(path string) (err error) {
@@ -182,6 +278,12 @@ func (c *CtbFs) exploreDir(path string) (err error) {
 func (c *CtbFs) Mknod(path string, mode uint32, dev uint64) (errc int) {
 	defer trace(path, mode, dev)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	prnt, name, node := c.lookupNode(path, nil)
 	if prnt == nil {
 		log.Error("Error creating node: ", path, ". Parent does not exist.")
@@ -200,6 +302,12 @@ func (c *CtbFs) Mknod(path string, mode uint32, dev uint64) (errc int) {
 func (c *CtbFs) Mkdir(path string, mode uint32) (errc int) {
 	defer trace(path, mode)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	prnt, name, node := c.lookupNode(path, nil)
 	if prnt == nil {
 		log.Error("Error creating directory: ", path, ". Parent does not exist.")
@@ -218,6 +326,12 @@ func (c *CtbFs) Mkdir(path string, mode uint32) (errc int) {
 func (c *CtbFs) Rmdir(path string) (errc int) {
 	defer trace(path)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	if err := c.removeNode(path, true); err != 0 {
 		log.Error("Error removing node while removing directory: ", path, ". error: ", err)
 		return err
@@ -255,12 +369,29 @@ func (c *CtbFs) removeNode(path string, dir bool) int {
 func (c *CtbFs) Write(path string, buff []byte, ofst int64, fh uint64) (n int) {
 	defer trace(path, buff, ofst, fh)(&n)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	node := c.getNode(path, fh)
 	if node == nil {
 		log.Error("Error writing to node: ", path, ". Node does not exist.")
 		return -fuse.ENOENT
 	}
-	n, _ = c.fs.Write(path, buff, ofst)
+	handle := c.getHandle(fh)
+	if handle != nil && !handle.write {
+		log.Error("Error writing to node: ", path, ". Handle is not opened for writing.")
+		return -fuse.EBADF
+	}
+	// Files opened with O_APPEND are always written at the end of the file
+	if handle != nil && handle.append {
+		ofst = node.stat.Size
+		n, _ = c.fs.Append(path, buff)
+	} else {
+		n, _ = c.fs.Write(path, buff, ofst)
+	}
 	if int64(n)+ofst > node.stat.Size {
 		node.stat.Size = int64(n) + ofst
 	}
@@ -287,6 +418,9 @@ func (c *CtbFs) newNode(dev uint64, isDir bool, path string, modePerm uint32, si
 	}
 	tmsp := fuse.Now()
 	ino := c.getIno()
+	if c.readOnly {
+		modePerm &^= 0222
+	}
 	mode := c.getMode(isDir, modePerm)
 	self := Node{
 		stat: fuse.Stat_t{
@@ -342,11 +476,21 @@ func (c *CtbFs) getIno() uint64 {
 func (c *CtbFs) Truncate(path string, size int64, fh uint64) (errc int) {
 	defer trace(path, size, fh)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	node := c.getNode(path, fh)
 	if node == nil {
 		log.Error("Error truncating node: ", path, ". Node does not exist.")
 		return -fuse.ENOENT
 	}
+	if handle := c.getHandle(fh); handle != nil && !handle.write {
+		log.Error("Error truncating node: ", path, ". Handle is not opened for writing.")
+		return -fuse.EBADF
+	}
 	if err := c.fs.Resize(path, size); err != nil {
 		log.Error("Error resizing file while truncating node: ", path, ". error: ", err)
 		return errno(err)
@@ -358,6 +502,12 @@ func (c *CtbFs) Truncate(path string, size int64, fh uint64) (errc int) {
 func (c *CtbFs) Rename(oldPath string, newPath string) (errc int) {
 	defer trace(oldPath, newPath)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	oldPrnt, oldName, oldNode := c.lookupNode(oldPath, nil)
 	if oldNode == nil {
 		log.Error("Error renaming node: ", oldPath, ". Node does not exist.")
@@ -388,12 +538,39 @@ func (c *CtbFs) Rename(oldPath string, newPath string) (errc int) {
 	}
 	delete(oldPrnt.chld, oldName)
 	newPrnt.chld[newName] = oldNode
+	c.movePaths(oldNode, newPath)
 	return 0
 }
 
+// movePaths sets the path of the node to path and the paths of its descendants below it,
+// keeping the open handles of the subtree pointing to the new paths.
+func (c *CtbFs) movePaths(node *Node, path string) {
+	moved := make(map[*Node]string)
+	var walk func(node *Node, path string)
+	walk = func(node *Node, path string) {
+		node.path = path
+		moved[node] = path
+		for name, child := range node.chld {
+			walk(child, join(path, name))
+		}
+	}
+	walk(node, path)
+	for _, handle := range c.handles {
+		if path, ok := moved[handle.node]; ok {
+			handle.path = path
+		}
+	}
+}
+
 func (c *CtbFs) Unlink(path string) (errc int) {
 	defer trace(path)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	err := c.fs.RemovePath(path)
 	if err != nil {
 		log.Error("Error removing (unlink) node: ", path, ". error: ", err)
@@ -426,12 +603,18 @@ func (c *CtbFs) Statfs(_ string, stat *fuse.Statfs_t) (errc int) {
 	}
 	stat.Bavail = stat.Bfree
 	stat.Namemax = uint64(10 * 1024 * 1024)
+	if c.readOnly {
+		stat.Flag |= stRdonly
+	}
 	return 0
 }
 
 func (c *CtbFs) Chmod(path string, mode uint32) (errc int) {
 	defer trace(path, mode)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error changing mode of node: ", path, ". Node does not exist.")
@@ -445,6 +628,9 @@ func (c *CtbFs) Chmod(path string, mode uint32) (errc int) {
 func (c *CtbFs) Chown(path string, uid uint32, gid uint32) (errc int) {
 	defer trace(path, uid, gid)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error changing ownership of node: ", path, ". Node does not exist.")
@@ -463,6 +649,9 @@ func (c *CtbFs) Chown(path string, uid uint32, gid uint32) (errc int) {
 func (c *CtbFs) Utimens(path string, tmsp []fuse.Timespec) (errc int) {
 	defer trace(path, tmsp)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error setting time of node: ", path, ". Node does not exist.")
@@ -479,10 +668,80 @@ func (c *CtbFs) Utimens(path string, tmsp []fuse.Timespec) (errc int) {
 	return 0
 }
 
+// Open opens the file at the specified path.
+// It checks the user's access against the access mode requested in flags before opening the node.
+// If O_TRUNC is requested the file is truncated to zero size, and if O_APPEND is requested
+// all writes to the file are done at the end of the file.
 func (c *CtbFs) Open(path string, flags int) (errc int, fh uint64) {
 	defer trace(path, flags)(&errc, &fh)
 	defer c.synchronize()()
-	return c.openNode(path, false)
+	if c.readOnly && (flags&fuse.O_ACCMODE != fuse.O_RDONLY || flags&(fuse.O_TRUNC|fuse.O_APPEND) != 0) {
+		return -fuse.EROFS, ^uint64(0)
+	}
+	if c.closing {
+		return -fuse.EBUSY, ^uint64(0)
+	}
+	if errc := c.checkAccess(path, flags); errc != 0 {
+		return errc, ^uint64(0)
+	}
+	errc, fh = c.openNode(path, false, flags)
+	if errc != 0 {
+		return errc, fh
+	}
+	node := c.handles[fh].node
+	if flags&fuse.O_TRUNC != 0 && node.stat.Size != 0 {
+		if err := c.fs.Resize(path, 0); err != nil {
+			log.Error("Error truncating node while opening: ", path, ". error: ", err)
+			c.closeNode(fh)
+			return -fuse.EIO, ^uint64(0)
+		}
+		node.stat.Size = 0
+	}
+	return 0, fh
+}
+
+// checkAccess checks if the user has access to the file at the specified path with the access mode requested in flags.
+// Read only opens require read access, while write, read-write and truncating opens require write access.
+// It returns -fuse.EACCES if the user does not have the required access.
+func (c *CtbFs) checkAccess(path string, flags int) int {
+	perm := c.fs.GetUserFileAccess(path, false)
+	if flags&fuse.O_ACCMODE == fuse.O_RDONLY && flags&fuse.O_TRUNC == 0 {
+		if perm&0444 == 0 {
+			log.Error("Error opening node: ", path, ". User does not have read access.")
+			return -fuse.EACCES
+		}
+		return 0
+	}
+	if perm&0222 == 0 {
+		log.Error("Error opening node: ", path, ". User does not have write access.")
+		return -fuse.EACCES
+	}
+	return 0
+}
+
+// Flush is called on each close of a file descriptor.
+// It commits the changes made to the file so they are encrypted and persisted in the repository.
+func (c *CtbFs) Flush(path string, fh uint64) (errc int) {
+	defer trace(path, fh)(&errc)
+	defer c.synchronize()()
+	node := c.getNode(path, fh)
+	if node == nil {
+		log.Error("Error flushing node: ", path, ". Node does not exist.")
+		return -fuse.ENOENT
+	}
+	return c.sync(path)
+}
+
+// Fsync forces an encrypted commit of the file, so the changes are persisted in the repository.
+func (c *CtbFs) Fsync(path string, datasync bool, fh uint64) (errc int) {
+	defer trace(path, datasync, fh)(&errc)
+	defer c.synchronize()()
+	node := c.getNode(path, fh)
+	if node == nil {
+		log.Error("Error syncing node: ", path, ". Node does not exist.")
+		return -fuse.ENOENT
+	}
+	return c.sync(path)
 }
 
 func (c *CtbFs) Getattr(path string, stat *fuse.Stat_t, fh uint64) (errc int) {
@@ -506,6 +765,9 @@ func (c *CtbFs) Release(path string, fh uint64) (errc int) {
 func (c *CtbFs) Opendir(path string) (errc int, fh uint64) {
 	defer trace(path)(&errc, &fh)
 	defer c.synchronize()()
+	if c.closing {
+		return -fuse.EBUSY, ^uint64(0)
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if !node.explored {
 		err := c.exploreDir(path)
@@ -514,7 +776,7 @@ func (c *CtbFs) Opendir(path string) (errc int, fh uint64) {
 			return errno(err), ^uint64(0)
 		}
 	}
-	return c.openNode(path, true)
+	return c.openNode(path, true, fuse.O_RDONLY)
 }
 
 func (c *CtbFs) Readdir(path string,
@@ -524,7 +786,11 @@ func (c *CtbFs) Readdir(path string,
 
 	defer trace(path, fill, ofst, fh)(&errc)
 	defer c.synchronize()()
-	node := c.openMap[fh]
+	node := c.getNode(path, fh)
+	if node == nil {
+		log.Error("Error reading directory: ", path, ". Node does not exist.")
+		return -fuse.ENOENT
+	}
 	fill(".", &node.stat, 0)
 	fill("..", nil, 0)
 	for name, chld := range node.chld {
@@ -544,6 +810,12 @@ func (c *CtbFs) Releasedir(path string, fh uint64) (errc int) {
 func (c *CtbFs) Setxattr(path string, name string, value []byte, flags int) (errc int) {
 	defer trace(path, name, value, flags)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
+	if c.closing {
+		return -fuse.EBUSY
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		return -fuse.ENOENT
@@ -594,6 +866,9 @@ func (c *CtbFs) Getxattr(path string, name string) (errc int, xatr []byte) {
 func (c *CtbFs) Removexattr(path string, name string) (errc int) {
 	defer trace(path, name)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error removing extended attribute: ", path, ". Node does not exist.")
@@ -631,6 +906,9 @@ func (c *CtbFs) Listxattr(path string, fill func(name string) bool) (errc int) {
 func (c *CtbFs) Chflags(path string, flags uint32) (errc int) {
 	defer trace(path, flags)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error changing flags of node: ", path, ". Node does not exist.")
@@ -644,6 +922,9 @@ func (c *CtbFs) Chflags(path string, flags uint32) (errc int) {
 func (c *CtbFs) Setcrtime(path string, tmsp fuse.Timespec) (errc int) {
 	defer trace(path, tmsp)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error setting creation time of node: ", path, ". Node does not exist.")
@@ -657,6 +938,9 @@ func (c *CtbFs) Setcrtime(path string, tmsp fuse.Timespec) (errc int) {
 func (c *CtbFs) Setchgtime(path string, tmsp fuse.Timespec) (errc int) {
 	defer trace(path, tmsp)(&errc)
 	defer c.synchronize()()
+	if c.readOnly {
+		return -fuse.EROFS
+	}
 	_, _, node := c.lookupNode(path, nil)
 	if node == nil {
 		log.Error("Error setting change time of node: ", path, ". Node does not exist.")
@@ -673,6 +957,19 @@ func (c *CtbFs) synchronize() func() {
 	}
 }
 
-func (c *CtbFs) commit(node *Node) error {
-	return c.fs.Commit(node.path)
+func (c *CtbFs) commit(path string) error {
+	return c.fs.Commit(path)
+}
+
+// sync commits the pending changes of the file at the specified path, if any, and returns the fuse error code.
+func (c *CtbFs) sync(path string) int {
+	if c.readOnly {
+		return 0
+	}
+	if err := c.fs.Sync(path); err != nil {
+		log.Error("Error syncing node: ", path, ". error: ", err)
+		c.recordError(fmt.Errorf("error syncing %s: %v", path, err))
+		return -fuse.EIO
+	}
+	return 0
 }
@@ -0,0 +1,83 @@
+package fuse
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/winfsp/cgofuse/fuse"
+)
+
+// Handle represents a single open of a node.
+// Every call to Open or Opendir creates a new handle, so two processes opening the same file
+// do not share the per-open state.
+type Handle struct {
+	node   *Node
+	path   string // path of the node at the time it was opened
+	dir    bool   // the handle is opened as a directory
+	write  bool   // the handle is opened with write intent
+	append bool   // all writes using the handle are done at the end of the file
+}
+
+// newHandle creates a new handle for the node opened with the given flags.
+func newHandle(node *Node, path string, flags int, dir bool) *Handle {
+	return &Handle{
+		node:   node,
+		path:   path,
+		dir:    dir,
+		write:  flags&fuse.O_ACCMODE != fuse.O_RDONLY,
+		append: flags&fuse.O_APPEND != 0,
+	}
+}
+
+// openNode opens the node at the specified path and registers a new handle for it in the handle table.
+// It returns the fuse error code and the file handle.
+func (c *CtbFs) openNode(path string, dir bool, flags int) (errc int, fh uint64) {
+	_, _, node := c.lookupNode(path, nil)
+	if node == nil {
+		log.Error("Error opening node: ", path, " does not exist.")
+		return -fuse.ENOENT, ^uint64(0)
+	}
+	if !dir && fuse.S_IFDIR == node.stat.Mode&fuse.S_IFMT {
+		log.Error("Error opening node: ", path, " is a directory and requested as a file.")
+		return -fuse.EISDIR, ^uint64(0)
+	}
+	if dir && fuse.S_IFDIR != node.stat.Mode&fuse.S_IFMT {
+		log.Error("Error opening node: ", path, " is not a directory and requested as a directory.")
+		return -fuse.ENOTDIR, ^uint64(0)
+	}
+	node.opencnt++
+	c.fh++
+	c.handles[c.fh] = newHandle(node, path, flags, dir)
+	return 0, c.fh
+}
+
+// closeNode releases the handle and removes it from the handle table.
+// When the last handle of the node is released, the changes made to the node are committed.
+func (c *CtbFs) closeNode(fh uint64) int {
+	handle := c.handles[fh]
+	if handle == nil {
+		log.Error("Error closing handle: ", fh, ". Handle does not exist.")
+		return -fuse.EBADF
+	}
+	delete(c.handles, fh)
+	node := handle.node
+	node.opencnt--
+	if node.opencnt == 0 && !handle.dir {
+		if err := c.commit(handle.path); err != nil {
+			log.Error("Error committing node: ", handle.path, ". error: ", err)
+			c.recordError(fmt.Errorf("error committing %s: %v", handle.path, err))
+			// Keep the changes so they are committed on the next mount
+			c.journal(handle.path)
+			return -fuse.EIO
+		}
+	}
+	return 0
+}
+
+// getHandle returns the handle registered for fh, or nil if fh is not a valid handle.
+func (c *CtbFs) getHandle(fh uint64) *Handle {
+	if ^uint64(0) == fh {
+		return nil
+	}
+	return c.handles[fh]
+}
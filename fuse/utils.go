@@ -8,6 +8,9 @@ import (
 	"syscall"
 )
 
+// stRdonly is the ST_RDONLY flag of statvfs, reported for read-only mounts.
+const stRdonly = 1
+
 func split(path string) []string {
 	return strings.Split(path, "/")
 }
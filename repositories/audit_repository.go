@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"bufio"
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrAuditHeadNotFound = errors.New("audit head not found")
+)
+
+// AuditRepository stores the audit log of the repository in the .meta/.audit folder of the root.
+// The entries are appended to the log file one JSON document per line,
+// and the head file references the last entry.
+type AuditRepository struct {
+	rootPath string
+}
+
+func NewAuditRepository(rootPath string) *AuditRepository {
+	return &AuditRepository{
+		rootPath: rootPath,
+	}
+}
+
+// Append appends the entry to the log file and replaces the head.
+func (a *AuditRepository) Append(entry core.AuditEntry, head core.AuditHead) error {
+	err := os.MkdirAll(a.getAuditPath(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(a.getLogPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(js, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	js, err = json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	// Write the head to a temporary file first, so that it is never left half written
+	tmp := a.getHeadPath() + ".tmp"
+	if err := os.WriteFile(tmp, js, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.getHeadPath())
+}
+
+// List returns the entries of the log file in order.
+// An empty list is returned if the log file does not exist.
+func (a *AuditRepository) List() ([]core.AuditEntry, error) {
+	entries := make([]core.AuditEntry, 0)
+	file, err := os.Open(a.getLogPath())
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry core.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error unmarshaling audit log line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// GetHead returns the head of the log. It returns ErrAuditHeadNotFound if the head file does not exist.
+func (a *AuditRepository) GetHead() (core.AuditHead, error) {
+	js, err := os.ReadFile(a.getHeadPath())
+	if os.IsNotExist(err) {
+		return core.AuditHead{}, ErrAuditHeadNotFound
+	}
+	if err != nil {
+		return core.AuditHead{}, err
+	}
+	var head core.AuditHead
+	if err := json.Unmarshal(js, &head); err != nil {
+		return core.AuditHead{}, fmt.Errorf("error unmarshaling audit head: %v", err)
+	}
+	return head, nil
+}
+
+func (a *AuditRepository) getAuditPath() string {
+	return filepath.Join(a.rootPath, ".meta", ".audit")
+}
+
+func (a *AuditRepository) getLogPath() string {
+	return filepath.Join(a.getAuditPath(), "log")
+}
+
+func (a *AuditRepository) getHeadPath() string {
+	return filepath.Join(a.getAuditPath(), "head")
+}
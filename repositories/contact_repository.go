@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+)
+
+// ContactRepository stores the address book of the repository in the .meta/.contacts folder of the root.
+// Every contact is stored in a file named after its public key.
+type ContactRepository struct {
+	rootPath string
+}
+
+func NewContactRepository(rootPath string) *ContactRepository {
+	return &ContactRepository{
+		rootPath: rootPath,
+	}
+}
+
+// Save writes the contact to the address book, replacing the contact with the same public key.
+func (c *ContactRepository) Save(contact core.Contact) error {
+	if err := checkPublicKeyName(contact.PublicKey); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.getContactsPath(), os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.Marshal(contact)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.getContactsPath(), contact.PublicKey), js, 0666)
+}
+
+// Get returns the contact with the specified public key.
+func (c *ContactRepository) Get(publicKey string) (core.Contact, error) {
+	if err := checkPublicKeyName(publicKey); err != nil {
+		return core.Contact{}, ErrContactNotFound
+	}
+	js, err := os.ReadFile(filepath.Join(c.getContactsPath(), publicKey))
+	if os.IsNotExist(err) {
+		return core.Contact{}, ErrContactNotFound
+	}
+	if err != nil {
+		return core.Contact{}, err
+	}
+	var contact core.Contact
+	if err := json.Unmarshal(js, &contact); err != nil {
+		return core.Contact{}, fmt.Errorf("error unmarshaling contact %s: %v", publicKey, err)
+	}
+	return contact, nil
+}
+
+// List returns the contacts of the address book, sorted by public key.
+func (c *ContactRepository) List() ([]core.Contact, error) {
+	contacts := make([]core.Contact, 0)
+	entries, err := os.ReadDir(c.getContactsPath())
+	if os.IsNotExist(err) {
+		return contacts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		contact, err := c.Get(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+// Remove removes the contact with the specified public key.
+func (c *ContactRepository) Remove(publicKey string) error {
+	if err := checkPublicKeyName(publicKey); err != nil {
+		return ErrContactNotFound
+	}
+	err := os.Remove(filepath.Join(c.getContactsPath(), publicKey))
+	if os.IsNotExist(err) {
+		return ErrContactNotFound
+	}
+	return err
+}
+
+func (c *ContactRepository) getContactsPath() string {
+	return filepath.Join(c.rootPath, ".meta", ".contacts")
+}
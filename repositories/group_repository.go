@@ -0,0 +1,217 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrGroupKeyNotFound = errors.New("group key not found")
+)
+
+// groupInfoName is the name of the file holding the group in its folder.
+const groupInfoName = ".group"
+
+// GroupRepository is an interface for persisting groups and the group private keys sealed to their members
+type GroupRepository interface {
+	SaveGroup(group core.Group) error
+	GetGroup(groupId string) (core.Group, error)
+	ListGroups() ([]core.Group, error)
+	SaveMemberKey(groupId string, userId string, sealed string) error
+	GetMemberKey(groupId string, userId string) (string, error)
+	MemberKeyExist(groupId string, userId string) bool
+	DeleteMemberKey(groupId string, userId string) error
+	ListMembers(groupId string) ([]string, error)
+	ListUserGroups(userId string) ([]string, error)
+	RemoveGroup(groupId string) error
+}
+
+// GroupRepositoryFile stores the groups in the .meta/.groups folder of the root.
+// Every group has a folder named after its public key (the group id), holding the group
+// and the group private key sealed to each member in a file named after the public key of the member.
+type GroupRepositoryFile struct {
+	rootPath string
+}
+
+var _ GroupRepository = &GroupRepositoryFile{}
+
+func NewGroupRepositoryFile(rootPath string) *GroupRepositoryFile {
+	return &GroupRepositoryFile{
+		rootPath: rootPath,
+	}
+}
+
+// SaveGroup writes the group, replacing the group with the same public key.
+func (g *GroupRepositoryFile) SaveGroup(group core.Group) error {
+	if err := checkPublicKeyName(group.PublicKey); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(g.getGroupPath(group.PublicKey), os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.Marshal(group)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.getGroupPath(group.PublicKey), groupInfoName), js, 0666)
+}
+
+// GetGroup returns the group with the specified id.
+func (g *GroupRepositoryFile) GetGroup(groupId string) (core.Group, error) {
+	if err := checkPublicKeyName(groupId); err != nil {
+		return core.Group{}, ErrGroupNotFound
+	}
+	js, err := os.ReadFile(filepath.Join(g.getGroupPath(groupId), groupInfoName))
+	if os.IsNotExist(err) {
+		return core.Group{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return core.Group{}, err
+	}
+	var group core.Group
+	if err := json.Unmarshal(js, &group); err != nil {
+		return core.Group{}, fmt.Errorf("error unmarshaling group %s: %v", groupId, err)
+	}
+	return group, nil
+}
+
+// ListGroups returns the groups, sorted by id. Folders without a group are skipped.
+func (g *GroupRepositoryFile) ListGroups() ([]core.Group, error) {
+	groups := make([]core.Group, 0)
+	ids, err := g.listGroupIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		group, err := g.GetGroup(id)
+		if errors.Is(err, ErrGroupNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// SaveMemberKey writes the group private key sealed to the user.
+func (g *GroupRepositoryFile) SaveMemberKey(groupId string, userId string, sealed string) error {
+	if err := errors.Join(checkPublicKeyName(groupId), checkPublicKeyName(userId)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(g.getGroupPath(groupId), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(g.getGroupPath(groupId), userId), []byte(sealed), 0666)
+}
+
+// GetMemberKey returns the group private key sealed to the user.
+func (g *GroupRepositoryFile) GetMemberKey(groupId string, userId string) (string, error) {
+	if err := errors.Join(checkPublicKeyName(groupId), checkPublicKeyName(userId)); err != nil {
+		return "", ErrGroupKeyNotFound
+	}
+	content, err := os.ReadFile(filepath.Join(g.getGroupPath(groupId), userId))
+	if os.IsNotExist(err) {
+		return "", ErrGroupKeyNotFound
+	}
+	return string(content), err
+}
+
+// MemberKeyExist returns true if the group private key is sealed to the user.
+func (g *GroupRepositoryFile) MemberKeyExist(groupId string, userId string) bool {
+	if err := errors.Join(checkPublicKeyName(groupId), checkPublicKeyName(userId)); err != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(g.getGroupPath(groupId), userId))
+	return err == nil
+}
+
+// DeleteMemberKey removes the group private key sealed to the user.
+func (g *GroupRepositoryFile) DeleteMemberKey(groupId string, userId string) error {
+	if err := errors.Join(checkPublicKeyName(groupId), checkPublicKeyName(userId)); err != nil {
+		return ErrGroupKeyNotFound
+	}
+	err := os.Remove(filepath.Join(g.getGroupPath(groupId), userId))
+	if os.IsNotExist(err) {
+		return ErrGroupKeyNotFound
+	}
+	return err
+}
+
+// ListMembers returns the users the group private key is sealed to, sorted.
+func (g *GroupRepositoryFile) ListMembers(groupId string) ([]string, error) {
+	if err := checkPublicKeyName(groupId); err != nil {
+		return nil, ErrGroupNotFound
+	}
+	entries, err := os.ReadDir(g.getGroupPath(groupId))
+	if os.IsNotExist(err) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && entry.Name() != groupInfoName {
+			members = append(members, entry.Name())
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// ListUserGroups returns the ids of the groups the user is a member of, sorted.
+func (g *GroupRepositoryFile) ListUserGroups(userId string) ([]string, error) {
+	ids, err := g.listGroupIds()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0)
+	for _, id := range ids {
+		if g.MemberKeyExist(id, userId) {
+			groups = append(groups, id)
+		}
+	}
+	return groups, nil
+}
+
+// RemoveGroup removes the group and the group private keys sealed to its members.
+func (g *GroupRepositoryFile) RemoveGroup(groupId string) error {
+	if err := checkPublicKeyName(groupId); err != nil {
+		return ErrGroupNotFound
+	}
+	return os.RemoveAll(g.getGroupPath(groupId))
+}
+
+func (g *GroupRepositoryFile) listGroupIds() ([]string, error) {
+	entries, err := os.ReadDir(g.getGroupsPath())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (g *GroupRepositoryFile) getGroupsPath() string {
+	return filepath.Join(g.rootPath, ".meta", ".groups")
+}
+
+func (g *GroupRepositoryFile) getGroupPath(groupId string) string {
+	return filepath.Join(g.getGroupsPath(), groupId)
+}
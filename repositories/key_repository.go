@@ -2,13 +2,22 @@ package repositories
 
 import (
 	"ctb-cli/core"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// DataKeyExpirySuffix is the suffix of the file recording the expiry of a data key, next to the data key.
+const DataKeyExpirySuffix = ".expires"
+
+// PasswordRecipientSuffix is the suffix of the file recording a password recipient,
+// next to its key share folder in the key share area of the root.
+const PasswordRecipientSuffix = ".password"
+
 var (
 	ErrKeyNotFound   = errors.New("key not found")
 	ErrUserNotJoined = errors.New("user not joined")
@@ -17,15 +26,23 @@ var (
 // KeyRepository KeyStorePersist is an interface for persisting keys
 type KeyRepository interface {
 	SaveDataKey(keyId, key, recipient string, path string) error
+	SaveDataKeyAtStoredPath(keyId, key, recipient string, storedPath string) error
 	GetDataKey(keyID string, userId string, path string) (string, error)
 	DataKeyExist(keyId string, userId string, path string) bool
-	IsUserJoined(userId string) bool
 	ListUsers() ([]string, error)
 	DeleteDataKey(keyID string, userId string, path string) error
+	ListDataKeys(path string) (map[string][]string, error)
+	ListRecipientDataKeys(recipient string) (map[string][]string, error)
+	SetDataKeyExpiry(expiry core.DataKeyExpiry) error
+	GetDataKeyExpiry(keyId string, recipient string, path string) (core.DataKeyExpiry, bool)
+	ListDataKeyExpiries() ([]core.DataKeyExpiry, error)
+	SavePasswordRecipient(recipient core.PasswordRecipient) error
+	ListPasswordRecipients() ([]core.PasswordRecipient, error)
 }
 
 type KeyRepositoryFile struct {
-	rootPath string
+	rootPath     string
+	pathResolver PathResolver // nil if the names of the directories are stored in plaintext
 }
 
 var _ KeyRepository = &KeyRepositoryFile{}
@@ -36,8 +53,27 @@ func NewKeyRepositoryFile(rootPath string) *KeyRepositoryFile {
 	}
 }
 
+// SetPathResolver sets the resolver of the paths stored on disk, used when the names of the directories are encrypted.
+func (k *KeyRepositoryFile) SetPathResolver(pathResolver PathResolver) {
+	k.pathResolver = pathResolver
+}
+
 func (k *KeyRepositoryFile) SaveDataKey(keyId, key, recipient string, path string) error {
-	datapath := k.getDataPath(recipient, path)
+	datapath, err := k.getDataPath(recipient, path)
+	if err != nil {
+		return err
+	}
+	return saveDataKey(datapath, keyId, key)
+}
+
+// SaveDataKeyAtStoredPath saves the data key shared with the recipient in the directory at the path stored on disk,
+// which is not resolved, for the users who cannot encrypt the names of the parent directories.
+func (k *KeyRepositoryFile) SaveDataKeyAtStoredPath(keyId, key, recipient string, storedPath string) error {
+	return saveDataKey(filepath.Join(k.rootPath, storedPath, ".meta", ".key-share", recipient), keyId, key)
+}
+
+// saveDataKey writes the data key in the folder of the data keys of a recipient.
+func saveDataKey(datapath string, keyId string, key string) error {
 	err := os.MkdirAll(datapath, os.ModePerm)
 	if err != nil {
 		return err
@@ -56,7 +92,10 @@ func (k *KeyRepositoryFile) SaveDataKey(keyId, key, recipient string, path strin
 }
 
 func (k *KeyRepositoryFile) GetDataKey(keyID string, userId string, path string) (string, error) {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return "", err
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return "", err
 	}
@@ -79,7 +118,10 @@ func (k *KeyRepositoryFile) GetDataKey(keyID string, userId string, path string)
 // DataKeyExist checks if a data key with the given key ID exists for the specified user.
 // It returns true if the data key exists, and false otherwise.
 func (k *KeyRepositoryFile) DataKeyExist(keyId string, userId string, path string) bool {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return false
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return false
 	}
@@ -91,27 +133,22 @@ func (k *KeyRepositoryFile) DataKeyExist(keyId string, userId string, path strin
 	}
 }
 
-func (k *KeyRepositoryFile) IsUserJoined(userId string) bool {
-	users, err := k.GetJoinedUsers()
-	if err != nil {
-		return false
-	}
-	for _, user := range users {
-		if user.Recipient == userId {
-			return true
-		}
-	}
-	return false
-}
-
-// ListUsers returns a list of users stored in the key repository.
+// ListUsers returns the users keys are shared with anywhere in the repository.
+// Every user is listed once, even if keys are shared with the user in several directories.
+// The users keys are only shared with in directories whose names cannot be decrypted are not listed.
 func (k *KeyRepositoryFile) ListUsers() ([]string, error) {
 	joinedUser, err := k.GetJoinedUsers()
-	if err != nil {
+	var unresolved *UnresolvedPathsError
+	if err != nil && !errors.As(err, &unresolved) {
 		return nil, err
 	}
 	users := make([]string, 0)
+	seen := make(map[string]struct{})
 	for _, user := range joinedUser {
+		if _, ok := seen[user.Recipient]; ok {
+			continue
+		}
+		seen[user.Recipient] = struct{}{}
 		users = append(users, user.Recipient)
 	}
 	return users, nil
@@ -121,26 +158,223 @@ func (k *KeyRepositoryFile) ListUsers() ([]string, error) {
 // It removes the file corresponding to the keyID from the user's data path.
 // If an error occurs during the deletion process, it is returned.
 func (k *KeyRepositoryFile) DeleteDataKey(keyID string, userId string, path string) error {
-	datapath := k.getDataPath(userId, path)
+	datapath, err := k.getDataPath(userId, path)
+	if err != nil {
+		return err
+	}
 	if _, err := os.Stat(datapath); err != nil {
 		return err
 	}
 	p := filepath.Join(datapath, keyID)
-	err := os.Remove(p)
+	err = os.Remove(p)
 	if err != nil {
 		return err
 	}
+	// Remove the expiry of the data key, if any
+	if err := os.Remove(p + DataKeyExpirySuffix); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
+// SetDataKeyExpiry records the signed expiry of the data key shared with the recipient at the path of the expiry.
+// A zero time removes the expiry, so the data key does not expire.
+func (k *KeyRepositoryFile) SetDataKeyExpiry(expiry core.DataKeyExpiry) error {
+	datapath, err := k.getDataPath(expiry.Recipient, expiry.Path)
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(datapath, expiry.KeyId+DataKeyExpirySuffix)
+	if expiry.ExpiresAt.IsZero() {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	// The path is given by the location of the file, it is not stored so the names of the directories do not leak
+	expiry.Path = ""
+	js, err := json.Marshal(expiry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, js, 0644)
+}
+
+// GetDataKeyExpiry returns the expiry of the data key shared with the recipient at the specified path.
+// It returns false if the data key does not expire. An unreadable expiry is returned without time nor signature.
+func (k *KeyRepositoryFile) GetDataKeyExpiry(keyId string, recipient string, path string) (core.DataKeyExpiry, bool) {
+	expiry := core.DataKeyExpiry{KeyId: keyId, Recipient: recipient, Path: path}
+	datapath, err := k.getDataPath(recipient, path)
+	if err != nil {
+		return expiry, false
+	}
+	b, err := os.ReadFile(filepath.Join(datapath, keyId+DataKeyExpirySuffix))
+	if err != nil {
+		return expiry, false
+	}
+	var stored core.DataKeyExpiry
+	if err := json.Unmarshal(b, &stored); err != nil || stored.KeyId != keyId || stored.Recipient != recipient {
+		return expiry, true
+	}
+	stored.Path = path
+	return stored, true
+}
+
+// ListDataKeyExpiries returns the expiries of the data keys shared anywhere in the repository.
+// The paths are relative to the root, which is the empty path. If the names of some directories cannot be decrypted,
+// the expiries of the other directories are returned with an UnresolvedPathsError.
+func (k *KeyRepositoryFile) ListDataKeyExpiries() ([]core.DataKeyExpiry, error) {
+	joinedUsers, walkErr := k.GetJoinedUsers()
+	var unresolved *UnresolvedPathsError
+	if walkErr != nil && !errors.As(walkErr, &unresolved) {
+		return nil, walkErr
+	}
+	res := make([]core.DataKeyExpiry, 0)
+	for _, user := range joinedUsers {
+		datapath, err := k.getDataPath(user.Recipient, user.Path)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(datapath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			keyId, ok := strings.CutSuffix(entry.Name(), DataKeyExpirySuffix)
+			if entry.IsDir() || !ok {
+				continue
+			}
+			expiry, _ := k.GetDataKeyExpiry(keyId, user.Recipient, user.Path)
+			res = append(res, expiry)
+		}
+	}
+	return res, walkErr
+}
+
+// SavePasswordRecipient writes the password recipient, replacing the recipient with the same public key.
+func (k *KeyRepositoryFile) SavePasswordRecipient(recipient core.PasswordRecipient) error {
+	keysPath, err := k.getKeysPath("")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(keysPath, os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.Marshal(recipient)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(keysPath, recipient.PublicKey+PasswordRecipientSuffix), js, 0644)
+}
+
+// ListPasswordRecipients returns the password recipients of the repository, sorted by public key.
+func (k *KeyRepositoryFile) ListPasswordRecipients() ([]core.PasswordRecipient, error) {
+	res := make([]core.PasswordRecipient, 0)
+	keysPath, err := k.getKeysPath("")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(keysPath)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), PasswordRecipientSuffix) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(keysPath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var recipient core.PasswordRecipient
+		if err := json.Unmarshal(b, &recipient); err != nil {
+			return nil, fmt.Errorf("invalid password recipient %s: %w", entry.Name(), err)
+		}
+		res = append(res, recipient)
+	}
+	return res, nil
+}
+
+// ListDataKeys returns the IDs of the data keys shared at the specified path, by recipient.
+// Recipients without any data key at the path are listed with an empty list.
+func (k *KeyRepositoryFile) ListDataKeys(path string) (map[string][]string, error) {
+	res := make(map[string][]string)
+	keysPath, err := k.getKeysPath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(keysPath)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		keys, err := os.ReadDir(filepath.Join(keysPath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		res[entry.Name()] = make([]string, 0, len(keys))
+		for _, key := range keys {
+			if !key.IsDir() && !strings.HasSuffix(key.Name(), DataKeyExpirySuffix) {
+				res[entry.Name()] = append(res[entry.Name()], key.Name())
+			}
+		}
+	}
+	return res, nil
+}
+
+// ListRecipientDataKeys returns the IDs of the data keys shared with the recipient anywhere in the repository, by path.
+// The paths are relative to the root, which is the empty path. If the names of some directories cannot be decrypted,
+// the data keys shared in the other directories are returned with an UnresolvedPathsError.
+func (k *KeyRepositoryFile) ListRecipientDataKeys(recipient string) (map[string][]string, error) {
+	res := make(map[string][]string)
+	joinedUsers, walkErr := k.GetJoinedUsers()
+	var unresolved *UnresolvedPathsError
+	if walkErr != nil && !errors.As(walkErr, &unresolved) {
+		return nil, walkErr
+	}
+	for _, user := range joinedUsers {
+		if user.Recipient != recipient {
+			continue
+		}
+		keys, err := k.ListDataKeys(user.Path)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys[recipient]) > 0 {
+			res[user.Path] = keys[recipient]
+		}
+	}
+	return res, walkErr
+}
+
+// GetJoinedUsers returns the users keys are shared with, by directory. If the names of some directories cannot be decrypted,
+// the users of the other directories are returned with an UnresolvedPathsError.
 func (k *KeyRepositoryFile) GetJoinedUsers() ([]core.JoinedUser, error) {
-	return k.getJoinedUsersInPath("")
+	unresolved := make([]string, 0)
+	list, err := k.getJoinedUsersInPath("", &unresolved)
+	if err == nil && len(unresolved) > 0 {
+		err = &UnresolvedPathsError{Paths: unresolved}
+	}
+	return list, err
 }
 
-func (k *KeyRepositoryFile) getJoinedUsersInPath(path string) ([]core.JoinedUser, error) {
+func (k *KeyRepositoryFile) getJoinedUsersInPath(path string, unresolved *[]string) ([]core.JoinedUser, error) {
 	list := make([]core.JoinedUser, 0)
 
-	entries, err := os.ReadDir(k.getKeysPath(path))
+	keysPath, err := k.getKeysPath(path)
+	if err != nil {
+		return list, err
+	}
+	entries, err := os.ReadDir(keysPath)
 	if err != nil {
 		fmt.Println("Error reading directory:", err)
 		return list, err
@@ -155,12 +389,12 @@ func (k *KeyRepositoryFile) getJoinedUsersInPath(path string) ([]core.JoinedUser
 		}
 	}
 
-	subs, err := k.getSubFolders(path)
+	subs, err := k.getSubFolders(path, unresolved)
 	if err != nil {
 		return list, err
 	}
 	for _, sub := range subs {
-		users, err := k.getJoinedUsersInPath(sub)
+		users, err := k.getJoinedUsersInPath(sub, unresolved)
 		if err != nil {
 			return list, err
 		}
@@ -170,10 +404,16 @@ func (k *KeyRepositoryFile) getJoinedUsersInPath(path string) ([]core.JoinedUser
 	return list, nil
 }
 
-func (k *KeyRepositoryFile) getSubFolders(path string) ([]string, error) {
+// getSubFolders returns the paths of the sub directories of the directory at the path,
+// and adds to unresolved the paths of the sub directories whose names cannot be decrypted.
+func (k *KeyRepositoryFile) getSubFolders(path string, unresolved *[]string) ([]string, error) {
 	list := make([]string, 0)
 
-	entries, err := os.ReadDir(filepath.Join(k.rootPath, path))
+	dir, err := resolvePath(k.pathResolver, k.rootPath, path)
+	if err != nil {
+		return list, err
+	}
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		fmt.Println("Error reading directory:", err)
 		return list, err
@@ -182,7 +422,16 @@ func (k *KeyRepositoryFile) getSubFolders(path string) ([]string, error) {
 	for _, entry := range entries {
 		if entry.IsDir() {
 			if entry.Name() != ".meta" {
-				list = append(list, filepath.Join(path, entry.Name()))
+				name := entry.Name()
+				// Skip the directories whose names cannot be decrypted
+				if k.pathResolver != nil {
+					var ok bool
+					if name, ok = k.pathResolver.ResolveName(path, name); !ok {
+						*unresolved = append(*unresolved, filepath.Join(string(filepath.Separator), path, entry.Name()))
+						continue
+					}
+				}
+				list = append(list, filepath.Join(path, name))
 			}
 		}
 	}
@@ -190,12 +439,10 @@ func (k *KeyRepositoryFile) getSubFolders(path string) ([]string, error) {
 	return list, nil
 }
 
-func (k *KeyRepositoryFile) getDataPath(recipient string, path string) string {
-	p := filepath.Join(k.getKeysPath(path), recipient)
-	return p
+func (k *KeyRepositoryFile) getDataPath(recipient string, path string) (string, error) {
+	return resolvePath(k.pathResolver, k.rootPath, path, ".meta", ".key-share", recipient)
 }
 
-func (k *KeyRepositoryFile) getKeysPath(path string) string {
-	dir := filepath.Join(k.rootPath, path, ".meta", ".key-share")
-	return dir
+func (k *KeyRepositoryFile) getKeysPath(path string) (string, error) {
+	return resolvePath(k.pathResolver, k.rootPath, path, ".meta", ".key-share")
 }
@@ -17,7 +17,8 @@ var (
 )
 
 type LinkRepository struct {
-	rootPath string
+	rootPath     string
+	pathResolver PathResolver // nil if the names of the files and directories are stored in plaintext
 }
 
 func NewLinkRepository(rootPath string) *LinkRepository {
@@ -26,13 +27,21 @@ func NewLinkRepository(rootPath string) *LinkRepository {
 	}
 }
 
+// SetPathResolver sets the resolver of the paths stored on disk, used when the names of the files and directories are encrypted.
+func (c *LinkRepository) SetPathResolver(pathResolver PathResolver) {
+	c.pathResolver = pathResolver
+}
+
 // Create creates a new file at the specified path and writes the JSON representation of the given link to it.
 // If the file or any necessary directories do not exist, they will be created.
 // The path parameter specifies the relative path to the file, and the link parameter contains the data to be written.
 // Returns an error if any error occurs during the creation or writing process.
 func (c *LinkRepository) Create(link core.Link) error {
-	absPath := filepath.Join(c.rootPath, link.Path)
-	err := os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
+	absPath, err := c.AbsPath(link.Path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -49,7 +58,10 @@ func (c *LinkRepository) Create(link core.Link) error {
 // Update updates the link file at the specified path with the provided link data.
 // It returns an error if there was a problem updating the file.
 func (c *LinkRepository) Update(link core.Link) error {
-	absPath := filepath.Join(c.rootPath, link.Path)
+	absPath, err := c.AbsPath(link.Path)
+	if err != nil {
+		return fmt.Errorf("error updating link file: %v", err)
+	}
 	file, err := os.OpenFile(absPath, os.O_RDWR|os.O_TRUNC, 0666)
 	if err != nil {
 		return fmt.Errorf("error updating link file: %v", err)
@@ -63,7 +75,10 @@ func (c *LinkRepository) Update(link core.Link) error {
 // GetByPath retrieves a link from the repository based on the given path.
 // It returns the retrieved link and an error, if any.
 func (c *LinkRepository) GetByPath(path string) (core.Link, error) {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.AbsPath(path)
+	if err != nil {
+		return core.Link{}, ErrVaultLinkNotFount
+	}
 	js, err := os.ReadFile(p)
 	if os.IsNotExist(err) {
 		return core.Link{}, ErrVaultLinkNotFount
@@ -85,8 +100,11 @@ func (c *LinkRepository) GetByPath(path string) (core.Link, error) {
 // Remove deletes the file at the specified path.
 // It takes the relative path of the file as input and returns an error if any.
 func (c *LinkRepository) Remove(path string) error {
-	absPath := filepath.Join(c.rootPath, path)
-	err := os.Remove(absPath)
+	absPath, err := c.AbsPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(absPath)
 	if err != nil {
 		return err
 	}
@@ -97,7 +115,10 @@ func (c *LinkRepository) Remove(path string) error {
 // It takes the path of the directory to be removed as a parameter.
 // Returns an error if the directory removal fails.
 func (c *LinkRepository) RemoveDir(path string) error {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.AbsPath(path)
+	if err != nil {
+		return err
+	}
 	systemFolderNames := core.GetRepoSystemFolderNames()
 	for _, folder := range systemFolderNames {
 		err := os.RemoveAll(filepath.Join(p, ".meta", folder))
@@ -105,7 +126,7 @@ func (c *LinkRepository) RemoveDir(path string) error {
 			return err
 		}
 	}
-	err := os.Remove(filepath.Join(p, ".meta", "config.yaml"))
+	err = os.Remove(filepath.Join(p, ".meta", "config.yaml"))
 	if err != nil {
 		return err
 	}
@@ -120,9 +141,15 @@ func (c *LinkRepository) RemoveDir(path string) error {
 // Rename renames a file or directory from the old path to the new path.
 // It takes the old path and the new path as parameters and returns an error if any.
 func (c *LinkRepository) Rename(oldPath string, newPath string) error {
-	o := filepath.Join(c.rootPath, oldPath)
-	n := filepath.Join(c.rootPath, newPath)
-	err := os.Rename(o, n)
+	o, err := c.AbsPath(oldPath)
+	if err != nil {
+		return err
+	}
+	n, err := c.AbsPath(newPath)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(o, n)
 	if err != nil {
 		return err
 	}
@@ -134,7 +161,10 @@ func (c *LinkRepository) Rename(oldPath string, newPath string) error {
 // It returns an error if there was a problem creating the directory.
 func (c *LinkRepository) CreateDir(path string) (err error) {
 	// Create the directory
-	absPath := filepath.Join(c.rootPath, path)
+	absPath, err := c.AbsPath(path)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(absPath, os.ModePerm)
 	if err != nil {
 		return err
@@ -151,27 +181,55 @@ func (c *LinkRepository) CreateDir(path string) (err error) {
 
 // GetSubFiles returns a list of sub-files in the specified directory path.
 // It takes a path string as input and returns a slice of os.FileInfo and an error.
+// If the names are encrypted, the sub-files are listed with their decrypted names,
+// and the sub-files whose names cannot be decrypted are skipped, see ListSubFiles.
 func (c *LinkRepository) GetSubFiles(path string) ([]os.FileInfo, error) {
+	subFiles, _, err := c.ListSubFiles(path)
+	return subFiles, err
+}
+
+// ListSubFiles returns the sub-files of the directory at the specified path, with their decrypted names if the names
+// are encrypted, and the sub-files whose names cannot be decrypted, with their names stored on disk.
+func (c *LinkRepository) ListSubFiles(path string) ([]os.FileInfo, []os.FileInfo, error) {
 	// Make sure the path is a directory
 	if !c.IsDir(path) {
-		return nil, ErrPathIsNotDir
+		return nil, nil, ErrPathIsNotDir
 	}
 	// Read the sub-files
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.AbsPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
 	file, err := os.Open(p)
 	if err != nil {
-		return nil, fmt.Errorf("error opening dir to Read sub files: %v", err)
+		return nil, nil, fmt.Errorf("error opening dir to Read sub files: %v", err)
 	}
 	defer file.Close()
 	subFiles, _ := file.Readdir(0)
-	return subFiles, nil
+	if c.pathResolver == nil {
+		return subFiles, nil, nil
+	}
+	res := make([]os.FileInfo, 0, len(subFiles))
+	unresolved := make([]os.FileInfo, 0)
+	for _, subFile := range subFiles {
+		name, ok := c.pathResolver.ResolveName(path, subFile.Name())
+		if !ok {
+			unresolved = append(unresolved, subFile)
+			continue
+		}
+		res = append(res, namedFileInfo{FileInfo: subFile, name: name})
+	}
+	return res, unresolved, nil
 }
 
 // IsDir checks if the given path is a valid directory.
 // It returns true if the path is a directory.
 // It returns false if the path is not a directory or if there was an issue accessing the file system.
 func (c *LinkRepository) IsDir(path string) bool {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.AbsPath(path)
+	if err != nil {
+		return false
+	}
 	fi, err := os.Stat(p)
 	if err != nil {
 		return false
@@ -183,7 +241,10 @@ func (c *LinkRepository) IsDir(path string) bool {
 // It returns true if the path is a file.
 // It returns false if the path is not a file or if there was an issue accessing the file system.
 func (c *LinkRepository) IsFile(path string) bool {
-	p := filepath.Join(c.rootPath, path)
+	p, err := c.AbsPath(path)
+	if err != nil {
+		return false
+	}
 	fi, err := os.Stat(p)
 	if err != nil {
 		return false
@@ -193,8 +254,11 @@ func (c *LinkRepository) IsFile(path string) bool {
 
 // IsValidPath checks if the given path is a valid path.
 func (c *LinkRepository) IsValidPath(path string) bool {
-	absPath := filepath.Join(c.rootPath, path)
-	_, err := os.Stat(absPath)
+	absPath, err := c.AbsPath(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(absPath)
 	return err == nil
 }
 
@@ -202,3 +266,27 @@ func (c *LinkRepository) IsValidPath(path string) bool {
 func (c *LinkRepository) GetRootPath() string {
 	return c.rootPath
 }
+
+// ResolvePath returns the path stored on disk for the path, relative to the root path.
+// It is the path itself unless the names of the files and directories are encrypted.
+func (c *LinkRepository) ResolvePath(path string) (string, error) {
+	if c.pathResolver == nil {
+		return path, nil
+	}
+	return c.pathResolver.ResolvePath(path)
+}
+
+// AbsPath returns the absolute path stored on disk for the path.
+func (c *LinkRepository) AbsPath(path string) (string, error) {
+	return resolvePath(c.pathResolver, c.rootPath, path)
+}
+
+// namedFileInfo is the file info of a sub-file listed with its decrypted name.
+type namedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i namedFileInfo) Name() string {
+	return i.name
+}
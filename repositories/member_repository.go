@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"ctb-cli/core"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+	ErrInviteNotFound      = errors.New("invitation not found")
+)
+
+// MemberRepository stores the member registry of the repository in the .meta/.members folder of the root.
+// Every member is stored in a file named after its public key,
+// the pending join requests in the .requests sub folder and the revocations in the .revoked sub folder.
+// The invitations are stored in the .invites sub folder, in files named after their id.
+type MemberRepository struct {
+	rootPath string
+}
+
+func NewMemberRepository(rootPath string) *MemberRepository {
+	return &MemberRepository{
+		rootPath: rootPath,
+	}
+}
+
+// IsInitialized returns true if the member registry holds at least one member.
+func (m *MemberRepository) IsInitialized() bool {
+	ids, err := m.list(m.getMembersPath())
+	return err == nil && len(ids) > 0
+}
+
+// SaveMember writes the member to the registry, replacing the member with the same public key.
+func (m *MemberRepository) SaveMember(member core.Member) error {
+	return m.save(m.getMembersPath(), member.PublicKey, member)
+}
+
+// GetMember returns the member with the specified public key.
+func (m *MemberRepository) GetMember(publicKey string) (core.Member, error) {
+	var member core.Member
+	err := m.get(m.getMembersPath(), publicKey, &member, ErrMemberNotFound)
+	return member, err
+}
+
+// ListMembers returns the members of the registry, sorted by public key.
+func (m *MemberRepository) ListMembers() ([]core.Member, error) {
+	members := make([]core.Member, 0)
+	ids, err := m.list(m.getMembersPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		member, err := m.GetMember(id)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// SaveJoinRequest writes the join request, replacing the request of the same public key.
+func (m *MemberRepository) SaveJoinRequest(request core.JoinRequest) error {
+	return m.save(m.getRequestsPath(), request.PublicKey, request)
+}
+
+// GetJoinRequest returns the join request of the specified public key.
+func (m *MemberRepository) GetJoinRequest(publicKey string) (core.JoinRequest, error) {
+	var request core.JoinRequest
+	err := m.get(m.getRequestsPath(), publicKey, &request, ErrJoinRequestNotFound)
+	return request, err
+}
+
+// ListJoinRequests returns the pending join requests, sorted by public key.
+func (m *MemberRepository) ListJoinRequests() ([]core.JoinRequest, error) {
+	requests := make([]core.JoinRequest, 0)
+	ids, err := m.list(m.getRequestsPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		request, err := m.GetJoinRequest(id)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+// RemoveJoinRequest removes the join request of the specified public key.
+func (m *MemberRepository) RemoveJoinRequest(publicKey string) error {
+	err := os.Remove(filepath.Join(m.getRequestsPath(), publicKey))
+	if os.IsNotExist(err) {
+		return ErrJoinRequestNotFound
+	}
+	return err
+}
+
+// SaveRevocation writes the revocation, replacing the revocation of the same public key.
+func (m *MemberRepository) SaveRevocation(revocation core.Revocation) error {
+	return m.save(m.getRevokedPath(), revocation.PublicKey, revocation)
+}
+
+// ListRevocations returns the revocations, sorted by public key.
+func (m *MemberRepository) ListRevocations() ([]core.Revocation, error) {
+	revocations := make([]core.Revocation, 0)
+	ids, err := m.list(m.getRevokedPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		var revocation core.Revocation
+		if err := m.get(m.getRevokedPath(), id, &revocation, ErrMemberNotFound); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, revocation)
+	}
+	return revocations, nil
+}
+
+// SaveInvite writes the invitation, replacing the invitation with the same id.
+func (m *MemberRepository) SaveInvite(invite core.Invite) error {
+	if err := checkInviteId(invite.Id); err != nil {
+		return err
+	}
+	return m.write(m.getInvitesPath(), invite.Id, invite)
+}
+
+// GetInvite returns the invitation with the specified id.
+func (m *MemberRepository) GetInvite(id string) (core.Invite, error) {
+	var invite core.Invite
+	if err := checkInviteId(id); err != nil {
+		return invite, ErrInviteNotFound
+	}
+	err := m.read(m.getInvitesPath(), id, &invite, ErrInviteNotFound)
+	return invite, err
+}
+
+// ListInvites returns the invitations, sorted by id.
+func (m *MemberRepository) ListInvites() ([]core.Invite, error) {
+	invites := make([]core.Invite, 0)
+	ids, err := m.list(m.getInvitesPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		invite, err := m.GetInvite(id)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, nil
+}
+
+func (m *MemberRepository) save(dir string, publicKey string, v any) error {
+	if err := checkPublicKeyName(publicKey); err != nil {
+		return err
+	}
+	return m.write(dir, publicKey, v)
+}
+
+func (m *MemberRepository) write(dir string, name string, v any) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), js, 0666)
+}
+
+func (m *MemberRepository) get(dir string, publicKey string, v any, errNotFound error) error {
+	if err := checkPublicKeyName(publicKey); err != nil {
+		return errNotFound
+	}
+	return m.read(dir, publicKey, v, errNotFound)
+}
+
+func (m *MemberRepository) read(dir string, name string, v any, errNotFound error) error {
+	js, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return errNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(js, v); err != nil {
+		return fmt.Errorf("error unmarshaling %s: %v", name, err)
+	}
+	return nil
+}
+
+func (m *MemberRepository) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// checkPublicKeyName makes sure the public key can be used as a file name.
+func checkPublicKeyName(publicKey string) error {
+	if _, err := core.NewPublicKeyFromEncoded(publicKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkInviteId makes sure the invitation id can be used as a file name.
+func checkInviteId(id string) error {
+	if id == "" || strings.HasPrefix(id, ".") || filepath.Base(id) != id {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (m *MemberRepository) getMembersPath() string {
+	return filepath.Join(m.rootPath, ".meta", ".members")
+}
+
+func (m *MemberRepository) getRequestsPath() string {
+	return filepath.Join(m.getMembersPath(), ".requests")
+}
+
+func (m *MemberRepository) getRevokedPath() string {
+	return filepath.Join(m.getMembersPath(), ".revoked")
+}
+
+func (m *MemberRepository) getInvitesPath() string {
+	return filepath.Join(m.getMembersPath(), ".invites")
+}
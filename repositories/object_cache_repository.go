@@ -1,10 +1,13 @@
 package repositories
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 var (
@@ -16,7 +19,19 @@ type ObjectCacheRepository struct {
 	resolver       func(id string, writer io.Writer) (err error)
 	readPath       string
 	writePath      string
+	journalPath    string
 	committingList map[string]struct{}
+	// touched holds the ids of the objects cached by this repository, so they can be wiped on shutdown
+	touched map[string]struct{}
+}
+
+// JournalEntry represents an object that was open for writing and could not be committed.
+// The object is kept in the write cache so it can be committed when the repository is mounted again.
+type JournalEntry struct {
+	Repo     string    `json:"repo"`
+	Path     string    `json:"path"`
+	ObjectId string    `json:"objectId"`
+	Time     time.Time `json:"time"`
 }
 
 func NewObjectCacheRepository(path string) ObjectCacheRepository {
@@ -28,7 +43,9 @@ func NewObjectCacheRepository(path string) ObjectCacheRepository {
 	return ObjectCacheRepository{
 		readPath:       path,
 		writePath:      writePath,
+		journalPath:    filepath.Join(path, "journal.json"),
 		committingList: make(map[string]struct{}),
+		touched:        make(map[string]struct{}),
 	}
 }
 
@@ -42,6 +59,7 @@ func (o *ObjectCacheRepository) MoveToWrite(oldId string, newId string) (err err
 	if err != nil {
 		return
 	}
+	o.touched[newId] = struct{}{}
 	//Create link
 	err = o.createWriteLink(newId)
 	if err != nil {
@@ -53,6 +71,7 @@ func (o *ObjectCacheRepository) MoveToWrite(oldId string, newId string) (err err
 func (o *ObjectCacheRepository) CacheObjectWriter(id string) (io.WriteCloser, error) {
 	p := filepath.Join(o.readPath, id)
 	file, err := os.Create(p)
+	o.touched[id] = struct{}{}
 	return file, err
 }
 
@@ -87,10 +106,11 @@ func (o *ObjectCacheRepository) Truncate(id string, size int64) (err error) {
 func (o *ObjectCacheRepository) Create(id string) (err error) {
 	objWritePath := filepath.Join(o.writePath, id)
 	objFile, err := os.Create(objWritePath)
-	objFile.Close()
 	if err != nil {
 		return
 	}
+	objFile.Close()
+	o.touched[id] = struct{}{}
 	err = o.createWriteLink(id)
 	if err != nil {
 		return
@@ -192,3 +212,97 @@ func (o *ObjectCacheRepository) IsOpenForWrite(id string) bool {
 func (o *ObjectCacheRepository) AdToCommitting(id string) {
 	o.committingList[id] = struct{}{}
 }
+
+// RemoveFromCommitting unmarks the object as committed, so it is open for writing again.
+func (o *ObjectCacheRepository) RemoveFromCommitting(id string) {
+	delete(o.committingList, id)
+}
+
+// AddToJournal records an object that could not be committed in the journal.
+// The object is kept in the write cache until it is removed from the journal.
+// An existing entry of the same object is replaced.
+func (o *ObjectCacheRepository) AddToJournal(entry JournalEntry) error {
+	entries, err := o.GetJournal()
+	if err != nil {
+		return err
+	}
+	res := make([]JournalEntry, 0, len(entries)+1)
+	for _, e := range entries {
+		if e.ObjectId != entry.ObjectId {
+			res = append(res, e)
+		}
+	}
+	res = append(res, entry)
+	return o.saveJournal(res)
+}
+
+// GetJournal returns the entries of the journal.
+// If the journal does not exist, it returns an empty list.
+func (o *ObjectCacheRepository) GetJournal() ([]JournalEntry, error) {
+	entries := make([]JournalEntry, 0)
+	js, err := os.ReadFile(o.journalPath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(js, &entries); err != nil {
+		return nil, fmt.Errorf("error unmarshaling journal: %v", err)
+	}
+	return entries, nil
+}
+
+// RemoveFromJournal removes the entries of the object with the specified ID from the journal.
+func (o *ObjectCacheRepository) RemoveFromJournal(id string) error {
+	entries, err := o.GetJournal()
+	if err != nil {
+		return err
+	}
+	res := make([]JournalEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ObjectId != id {
+			res = append(res, entry)
+		}
+	}
+	return o.saveJournal(res)
+}
+
+// saveJournal writes the entries to the journal file, removing the file if there are no entries.
+func (o *ObjectCacheRepository) saveJournal(entries []JournalEntry) error {
+	if len(entries) == 0 {
+		err := os.Remove(o.journalPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	js, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(o.journalPath, js, 0600)
+}
+
+// Wipe removes every object cached by this repository from the read and write caches,
+// except the objects with the IDs in keep.
+// Objects cached by other processes sharing the cache folder are not touched.
+func (o *ObjectCacheRepository) Wipe(keep []string) error {
+	kept := make(map[string]struct{}, len(keep))
+	for _, id := range keep {
+		kept[id] = struct{}{}
+	}
+	var errs []error
+	for id := range o.touched {
+		if _, ok := kept[id]; ok {
+			continue
+		}
+		for _, p := range []string{filepath.Join(o.readPath, id), filepath.Join(o.writePath, id)} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+		delete(o.touched, id)
+	}
+	return errors.Join(errs...)
+}
@@ -8,7 +8,8 @@ import (
 )
 
 type ObjectRepository struct {
-	rootPath string
+	rootPath     string
+	pathResolver PathResolver // nil if the names of the directories are stored in plaintext
 }
 
 func NewObjectRepository(rootPath string) ObjectRepository {
@@ -17,8 +18,16 @@ func NewObjectRepository(rootPath string) ObjectRepository {
 	}
 }
 
+// SetPathResolver sets the resolver of the paths stored on disk, used when the names of the directories are encrypted.
+func (o *ObjectRepository) SetPathResolver(pathResolver PathResolver) {
+	o.pathResolver = pathResolver
+}
+
 func (o *ObjectRepository) IsInRepo(link core.Link) (is bool) {
-	p := o.GetPath(link.Id(), link.Path)
+	p, err := o.GetPath(link.Id(), link.Path)
+	if err != nil {
+		return false
+	}
 	if _, err := os.Stat(p); os.IsNotExist(err) {
 		return false
 	}
@@ -26,28 +35,74 @@ func (o *ObjectRepository) IsInRepo(link core.Link) (is bool) {
 }
 
 func (o *ObjectRepository) CreateFile(link core.Link) (*os.File, error) {
-	objectPath := o.GetPath(link.Id(), link.Path)
+	objectPath, err := o.GetPath(link.Id(), link.Path)
+	if err != nil {
+		return nil, err
+	}
 	file, _ := os.Create(objectPath)
 	return file, nil
 }
 
 func (o *ObjectRepository) OpenObject(link core.Link) (io.ReadCloser, error) {
-	objectPath := o.GetPath(link.Id(), link.Path)
+	objectPath, err := o.GetPath(link.Id(), link.Path)
+	if err != nil {
+		return nil, err
+	}
 	file, _ := os.Open(objectPath)
 	return file, nil
 }
 
+// GetObjectSize returns the size of the encrypted object of the link.
+func (o *ObjectRepository) GetObjectSize(link core.Link) (int64, error) {
+	objectPath, err := o.GetPath(link.Id(), link.Path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
 func (o *ObjectRepository) ChangePath(link core.Link, newPath string) error {
-	if o.GetPath(link.Id(), link.Path) != o.GetPath(link.Id(), newPath) {
-		oldObjectPath := o.GetPath(link.Id(), link.Path)
-		newObjectPath := o.GetPath(link.Id(), newPath)
+	oldObjectPath, err := o.GetPath(link.Id(), link.Path)
+	if err != nil {
+		return err
+	}
+	newObjectPath, err := o.GetPath(link.Id(), newPath)
+	if err != nil {
+		return err
+	}
+	if oldObjectPath != newObjectPath {
 		return os.Rename(oldObjectPath, newObjectPath)
 	}
 	return nil
 }
 
-func (o *ObjectRepository) GetPath(id string, path string) string {
+// ListObjects returns the IDs of the objects stored for the files of the directory at the specified path.
+func (o *ObjectRepository) ListObjects(dir string) ([]string, error) {
+	objectFolder, err := resolvePath(o.pathResolver, o.rootPath, dir, ".meta", ".object")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(objectFolder)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+func (o *ObjectRepository) GetPath(id string, path string) (string, error) {
 	dir := filepath.Dir(path)
-	res := filepath.Join(o.rootPath, dir, ".meta", ".object", id)
-	return res
+	return resolvePath(o.pathResolver, o.rootPath, dir, ".meta", ".object", id)
 }
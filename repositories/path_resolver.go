@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// PathResolver maps the paths of the repository to the paths of the folders and link files stored on disk,
+// which differ when the names of the files and directories are encrypted.
+type PathResolver interface {
+	// ResolvePath returns the path stored on disk for the path, relative to the root of the repository.
+	ResolvePath(path string) (string, error)
+	// ResolveName returns the name of the entry stored on disk as name in the directory,
+	// or false if the name cannot be decrypted.
+	ResolveName(dir string, name string) (string, bool)
+}
+
+// resolvePath returns the absolute path stored on disk for the path joined with the elements,
+// the elements are not resolved. A nil resolver stores the paths as they are.
+func resolvePath(resolver PathResolver, rootPath string, path string, elem ...string) (string, error) {
+	if resolver != nil {
+		var err error
+		path, err = resolver.ResolvePath(path)
+		if err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(append([]string{rootPath, path}, elem...)...), nil
+}
+
+// UnresolvedPathsError is returned with the partial result of a walk of the repository when the names of
+// some directories cannot be decrypted, because the user has no access to the name key of their parent directory.
+// These directories and their content are not walked.
+type UnresolvedPathsError struct {
+	Paths []string // paths of the directories, the path of their parent joined with their name stored on disk
+}
+
+func (e *UnresolvedPathsError) Error() string {
+	return fmt.Sprintf("the names of %d directories cannot be decrypted, their content is skipped: %s", len(e.Paths), strings.Join(e.Paths, ", "))
+}
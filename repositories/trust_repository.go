@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// TrustRepository stores the public key of the first owner of the repository in the trust.json file of the state folder
+// of the user, outside of the repository, so a user who can write to the repository cannot replace it.
+// The file holds the first owners of all the repositories the user opened, by absolute path of the repository.
+type TrustRepository struct {
+	statePath string
+	repoPath  string
+}
+
+func NewTrustRepository(statePath string, repoPath string) *TrustRepository {
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		repoPath = abs
+	}
+	return &TrustRepository{
+		statePath: statePath,
+		repoPath:  repoPath,
+	}
+}
+
+// GetFirstOwner returns the pinned public key of the first owner of the repository, empty if it is not pinned.
+func (t *TrustRepository) GetFirstOwner() string {
+	return t.read()[t.repoPath]
+}
+
+// SetFirstOwner pins the public key of the first owner of the repository, replacing the key pinned before.
+func (t *TrustRepository) SetFirstOwner(publicKey string) error {
+	if err := checkPublicKeyName(publicKey); err != nil {
+		return err
+	}
+	pins := t.read()
+	pins[t.repoPath] = publicKey
+	if err := os.MkdirAll(t.statePath, os.ModePerm); err != nil {
+		return err
+	}
+	js, err := json.Marshal(pins)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.getTrustPath(), js, 0600)
+}
+
+// read returns the pinned first owners by path of the repository, empty if none is pinned.
+func (t *TrustRepository) read() map[string]string {
+	pins := make(map[string]string)
+	js, err := os.ReadFile(t.getTrustPath())
+	if err != nil {
+		return pins
+	}
+	_ = json.Unmarshal(js, &pins)
+	return pins
+}
+
+func (t *TrustRepository) getTrustPath() string {
+	return filepath.Join(t.statePath, "trust.json")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // VaultRepository KeyStorePersist is an interface for persisting keys
@@ -15,15 +16,19 @@ type VaultRepository interface {
 	InsertVault(vault core.Vault, vaultPath string) error
 	AddKeyToVault(vault *core.Vault, vaultPath string, keyId string, serialized string) error
 	GetKey(keyId string, vaultId string, vaultPath string) (string, bool)
+	ListKeys(vaultId string, vaultPath string) ([]string, error)
 	RemoveKey(keyId string, vaultId string, vaultPath string) error
 	GetVaultParent(vaultPath string) (string, core.Vault, error)
 	GetVaultByPath(path string) (core.Vault, error)
 	RemoveVault(path string) error
 	GetFileVault(path string) (core.Vault, string, error)
+	ListVaults(vaultPath string) ([]string, error)
+	RestoreVaultLink(vaultPath string, vaultId string) error
 }
 
 type VaultRepositoryFile struct {
-	rootPath string
+	rootPath     string
+	pathResolver PathResolver // nil if the names of the directories are stored in plaintext
 }
 
 type vaultLink struct {
@@ -44,8 +49,16 @@ func NewVaultRepositoryFile(rootPath string) *VaultRepositoryFile {
 	}
 }
 
+// SetPathResolver sets the resolver of the paths stored on disk, used when the names of the directories are encrypted.
+func (k *VaultRepositoryFile) SetPathResolver(pathResolver PathResolver) {
+	k.pathResolver = pathResolver
+}
+
 func (k *VaultRepositoryFile) GetVault(vaultId string, vaultPath string) (core.Vault, error) {
-	p := k.vaultFile(vaultId, vaultPath)
+	p, err := k.vaultFile(vaultId, vaultPath)
+	if err != nil {
+		return core.Vault{}, err
+	}
 	content, err := os.ReadFile(p)
 	if err != nil {
 		return core.Vault{}, err
@@ -58,7 +71,10 @@ func (k *VaultRepositoryFile) InsertVault(vault core.Vault, vaultPath string) er
 	if err != nil {
 		return err
 	}
-	insidePath := k.vaultKeyFolder(vault.Id, vaultPath)
+	insidePath, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(insidePath, os.ModePerm)
 	if err != nil {
 		return err
@@ -75,7 +91,10 @@ func (k *VaultRepositoryFile) InsertVault(vault core.Vault, vaultPath string) er
 }
 
 func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err error) {
-	p := k.vaultFile(vault.Id, vaultPath)
+	p, err := k.vaultFile(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	file, err := os.Create(p)
 	if err != nil {
 		return err
@@ -89,7 +108,10 @@ func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err
 	if err != nil {
 		return err
 	}
-	insidePath := k.vaultKeyFolder(vault.Id, vaultPath)
+	insidePath, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
 	err = os.MkdirAll(insidePath, os.ModePerm)
 	if err != nil {
 		return err
@@ -98,17 +120,42 @@ func (k *VaultRepositoryFile) SaveVault(vault core.Vault, vaultPath string) (err
 }
 
 func (k *VaultRepositoryFile) GetKey(keyId string, vaultId string, vaultPath string) (string, bool) {
-	path := filepath.Join(k.vaultKeyFolder(vaultId, vaultPath), keyId)
-	b, err := os.ReadFile(path)
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return "", false
+	}
+	b, err := os.ReadFile(filepath.Join(folder, keyId))
 	if err != nil {
 		return "", false
 	}
 	return string(b), true
 }
 
+// ListKeys returns the IDs of the keys sealed in the vault with the specified ID.
+func (k *VaultRepositoryFile) ListKeys(vaultId string, vaultPath string) ([]string, error) {
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
 func (k *VaultRepositoryFile) AddKeyToVault(vault *core.Vault, vaultPath string, keyId string, serialized string) error {
-	path := filepath.Join(k.vaultKeyFolder(vault.Id, vaultPath), keyId)
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	folder, err := k.vaultKeyFolder(vault.Id, vaultPath)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(folder, keyId), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
@@ -121,8 +168,11 @@ func (k *VaultRepositoryFile) AddKeyToVault(vault *core.Vault, vaultPath string,
 }
 
 func (k *VaultRepositoryFile) RemoveKey(keyId string, vaultId string, vaultPath string) error {
-	path := filepath.Join(k.vaultKeyFolder(vaultId, vaultPath), keyId)
-	return os.Remove(path)
+	folder, err := k.vaultKeyFolder(vaultId, vaultPath)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(folder, keyId))
 }
 
 func (k *VaultRepositoryFile) GetVaultParent(vaultPath string) (string, core.Vault, error) {
@@ -159,7 +209,10 @@ func (k *VaultRepositoryFile) GetVaultByPath(path string) (core.Vault, error) {
 // If an error occurs during file reading or unmarshaling, it returns an empty VaultLink object and the error.
 func (k *VaultRepositoryFile) getVaultLinkByPath(path string) (vaultLink, error) {
 	// Read the vault link file
-	p := k.getVaultLinkPath(path)
+	p, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return vaultLink{}, fmt.Errorf("error reading vault link file: %v", err)
+	}
 	js, err := os.ReadFile(p)
 	if err != nil {
 		return vaultLink{}, fmt.Errorf("error reading vault link file: %v", err)
@@ -175,8 +228,11 @@ func (k *VaultRepositoryFile) getVaultLinkByPath(path string) (vaultLink, error)
 // RemoveVaultLink removes the vault link file for the specified path.
 // It takes the path of the link file as input and returns an error if any.
 func (k *VaultRepositoryFile) removeVaultLink(path string) error {
-	absPath := k.getVaultLinkPath(path)
-	err := os.Remove(absPath)
+	absPath, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(absPath)
 	if err != nil {
 		return ErrRemovingVaultLinkFile
 	}
@@ -205,12 +261,19 @@ func (k *VaultRepositoryFile) RemoveVault(path string) error {
 		return err
 	}
 	//Remove vault file
-	err = os.Remove(k.vaultFile(vault.Id, path))
+	vaultFile, err := k.vaultFile(vault.Id, path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(vaultFile)
 	if err != nil {
 		return err
 	}
 	// Remove vault folder
-	folderPath := k.vaultKeyFolder(vault.Id, path)
+	folderPath, err := k.vaultKeyFolder(vault.Id, path)
+	if err != nil {
+		return err
+	}
 	err = os.RemoveAll(folderPath)
 	if err != nil {
 		return err
@@ -218,13 +281,44 @@ func (k *VaultRepositoryFile) RemoveVault(path string) error {
 	return nil
 }
 
+// ListVaults returns the IDs of the vault files stored at the specified path.
+func (k *VaultRepositoryFile) ListVaults(vaultPath string) ([]string, error) {
+	folder, err := k.vaultFolder(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
+// RestoreVaultLink writes the vault link of the specified path to the vault with the specified ID.
+// It returns an error if the vault cannot be read.
+func (k *VaultRepositoryFile) RestoreVaultLink(vaultPath string, vaultId string) error {
+	if _, err := k.GetVault(vaultId, vaultPath); err != nil {
+		return err
+	}
+	return k.insertVaultLink(vaultPath, vaultLink{VaultId: vaultId})
+}
+
 // InsertVaultLink inserts a VaultLink into the specified path.
 // It creates the necessary directories and writes the link data to a file.
 // The link data is serialized as JSON before writing to the file.
 // If any error occurs during the process, it is returned.
 func (k *VaultRepositoryFile) insertVaultLink(path string, link vaultLink) error {
-	absPath := k.getVaultLinkPath(path)
-	err := os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
+	absPath, err := k.getVaultLinkPath(path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(absPath), os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -239,21 +333,30 @@ func (k *VaultRepositoryFile) insertVaultLink(path string, link vaultLink) error
 }
 
 // vaultFolder returns the path to the vault folder for the specified path.
-func (k *VaultRepositoryFile) vaultFolder(vaultPath string) string {
-	return filepath.Join(k.rootPath, vaultPath, ".meta", ".vault")
+func (k *VaultRepositoryFile) vaultFolder(vaultPath string) (string, error) {
+	return resolvePath(k.pathResolver, k.rootPath, vaultPath, ".meta", ".vault")
 }
 
 // getVaultLinkPath returns the path to the vault link file for the specified path.
-func (k *VaultRepositoryFile) getVaultLinkPath(path string) string {
-	return filepath.Join(k.vaultFolder(path), ".link")
+func (k *VaultRepositoryFile) getVaultLinkPath(path string) (string, error) {
+	return k.inVaultFolder(path, ".link")
 }
 
 // vaultKeyFolder returns the path to the key folder for the specified vault ID and path.
-func (k *VaultRepositoryFile) vaultKeyFolder(vaultId string, vaultPath string) string {
-	return filepath.Join(k.vaultFolder(vaultPath), "."+vaultId)
+func (k *VaultRepositoryFile) vaultKeyFolder(vaultId string, vaultPath string) (string, error) {
+	return k.inVaultFolder(vaultPath, "."+vaultId)
 }
 
 // vaultFile returns the path to the vault file for the specified vault ID and path.
-func (k *VaultRepositoryFile) vaultFile(vaultId string, vaultPath string) string {
-	return filepath.Join(k.vaultFolder(vaultPath), vaultId)
+func (k *VaultRepositoryFile) vaultFile(vaultId string, vaultPath string) (string, error) {
+	return k.inVaultFolder(vaultPath, vaultId)
+}
+
+// inVaultFolder returns the path to the entry with the specified name in the vault folder of the path.
+func (k *VaultRepositoryFile) inVaultFolder(vaultPath string, name string) (string, error) {
+	folder, err := k.vaultFolder(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, name), nil
 }
@@ -0,0 +1,323 @@
+package audit_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service writes and verifies the audit log of the repository.
+type Service struct {
+	keyService    core.KeyService
+	auditRepo     *repositories.AuditRepository
+	memberLister  core.MemberLister
+	memberChecker core.MemberChecker // nil if the actors of the logged expiries are not checked to be members
+	pathEncoder   core.PathEncoder   // nil if the paths are logged as they are
+
+	mu       sync.Mutex
+	expiries []core.DataKeyExpiry // the logged expiries, read up to the entry with the hash expHead
+	expHead  string
+}
+
+// Ensure Service implements AuditLogger and ExpiryLog
+var (
+	_ core.AuditLogger = &Service{}
+	_ core.ExpiryLog   = &Service{}
+)
+
+// NewService creates a new instance of the audit service.
+func NewService(keyService core.KeyService, auditRepo *repositories.AuditRepository) *Service {
+	return &Service{
+		keyService: keyService,
+		auditRepo:  auditRepo,
+	}
+}
+
+// SetMemberLister sets the member lister used to check that the actors of the log are verified members.
+func (s *Service) SetMemberLister(lister core.MemberLister) {
+	s.memberLister = lister
+}
+
+// SetMemberChecker sets the checker of the members who log the expiries of the shares.
+func (s *Service) SetMemberChecker(checker core.MemberChecker) {
+	s.memberChecker = checker
+}
+
+// SetPathEncoder sets the encoder of the paths of the files and directories, which are logged as they are stored on disk
+// so that the log does not hold the names of a repository encrypting them.
+func (s *Service) SetPathEncoder(pathEncoder core.PathEncoder) {
+	s.pathEncoder = pathEncoder
+}
+
+// Log appends an entry to the audit log, chained to the last entry and signed with the private key of the user,
+// and replaces the head of the log. The last entry is taken from the head, the log is not read.
+// The paths are logged as they are stored on disk, a path the user cannot encode is not logged.
+func (s *Service) Log(action core.AuditAction, path string, target string, keyId string) error {
+	return s.append(core.AuditEntry{Action: action, Path: path, Target: target, KeyId: keyId})
+}
+
+// LogExpiry appends an entry setting the expiry of the data key shared with the recipient to the audit log,
+// which removes the expiry if its time is zero.
+func (s *Service) LogExpiry(expiry core.DataKeyExpiry) error {
+	entry := core.AuditEntry{Action: core.AuditSetExpiry, Path: expiry.Path, Target: expiry.Recipient, KeyId: expiry.KeyId}
+	if !expiry.ExpiresAt.IsZero() {
+		expiresAt := expiry.ExpiresAt.UTC()
+		entry.ExpiresAt = &expiresAt
+	}
+	return s.append(entry)
+}
+
+// ListLoggedExpiries returns the last expiry logged for each data key shared with a recipient, signed by their actor.
+// The expiries are removed when the share is removed or expired. The logged paths are not returned,
+// as the directories may have moved since. Only the entries whose hash and signature are valid are read,
+// and whose actor is a verified member other than the recipient, so the recipient cannot extend the share.
+// An expiry removed from the log breaks its chain, which is reported by Verify.
+// The expiries are read again when the head of the log changes.
+func (s *Service) ListLoggedExpiries() ([]core.DataKeyExpiry, error) {
+	head, err := s.auditRepo.GetHead()
+	if errors.Is(err, repositories.ErrAuditHeadNotFound) {
+		head, err = core.AuditHead{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	cached, cachedHead := s.expiries, s.expHead
+	s.mu.Unlock()
+	if cached != nil && cachedHead == head.Hash {
+		return cached, nil
+	}
+	entries, err := s.auditRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool)
+	isMember := func(userId string) bool {
+		if s.memberChecker == nil {
+			return true
+		}
+		if _, ok := members[userId]; !ok {
+			members[userId] = s.memberChecker.IsMember(userId)
+		}
+		return members[userId]
+	}
+	type share struct{ keyId, recipient string }
+	logged := make(map[share]core.DataKeyExpiry)
+	order := make([]share, 0)
+	for _, e := range entries {
+		if e.Action != core.AuditSetExpiry && e.Action != core.AuditUnshare && e.Action != core.AuditExpire {
+			continue
+		}
+		if e.Actor == e.Target || e.ComputeHash() != e.Hash || !signature.VerifyEncoded(e.Actor, e.SignedMessage(), e.Signature) || !isMember(e.Actor) {
+			continue
+		}
+		key := share{e.KeyId, e.Target}
+		if _, ok := logged[key]; !ok {
+			order = append(order, key)
+		}
+		expiry := core.DataKeyExpiry{KeyId: e.KeyId, Recipient: e.Target, SignedBy: e.Actor}
+		if e.Action == core.AuditSetExpiry && e.ExpiresAt != nil {
+			expiry.ExpiresAt = *e.ExpiresAt
+		}
+		logged[key] = expiry
+	}
+	expiries := make([]core.DataKeyExpiry, 0, len(logged))
+	for _, key := range order {
+		if expiry := logged[key]; !expiry.ExpiresAt.IsZero() {
+			expiries = append(expiries, expiry)
+		}
+	}
+	s.mu.Lock()
+	s.expiries, s.expHead = expiries, head.Hash
+	s.mu.Unlock()
+	return expiries, nil
+}
+
+// append chains the entry to the last entry of the audit log, signs it with the private key of the user and appends it,
+// replacing the head of the log.
+func (s *Service) append(entry core.AuditEntry) error {
+	entry.Path = s.encodePath(entry.Path)
+	if entry.Action == core.AuditMoveVault {
+		entry.Target = s.encodePath(entry.Target)
+	}
+	publicKey, err := s.keyService.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	last, err := s.auditRepo.GetHead()
+	if err != nil && !errors.Is(err, repositories.ErrAuditHeadNotFound) {
+		return err
+	}
+	entry.Seq = 1
+	entry.Time = time.Now().UTC()
+	entry.Actor = publicKey.String()
+	if err == nil {
+		entry.Seq = last.Seq + 1
+		entry.Prev = last.Hash
+	}
+	entry.Hash = entry.ComputeHash()
+	if entry.Signature, err = s.sign(entry.SignedMessage()); err != nil {
+		return err
+	}
+	head := core.AuditHead{
+		Seq:   entry.Seq,
+		Hash:  entry.Hash,
+		Actor: entry.Actor,
+	}
+	if head.Signature, err = s.sign(head.SignedMessage()); err != nil {
+		return err
+	}
+	return s.auditRepo.Append(entry, head)
+}
+
+// List returns the entries of the audit log in order, with the paths the user can decode in plaintext.
+// The paths logged before the name key of one of their directories was replaced are listed as stored.
+// The hashes and signatures of the entries are computed on the paths stored in the log, verify them with Verify.
+func (s *Service) List() (core.AuditLog, error) {
+	entries, err := s.auditRepo.List()
+	if err != nil || s.pathEncoder == nil {
+		return entries, err
+	}
+	for i, e := range entries {
+		entries[i].Path = s.decodePath(e.Path)
+		if e.Action == core.AuditMoveVault {
+			entries[i].Target = s.decodePath(e.Target)
+		}
+	}
+	return entries, nil
+}
+
+// Verify checks the audit log and returns the problems found.
+// Every entry must follow the previous one, hold its hash, match its own hash and be signed by its actor.
+// The actor must be a verified member of the repository, and not revoked by an earlier entry of the log.
+// The signed head must reference the last entry, otherwise entries were removed from or appended to the log
+// without the private key of a user.
+func (s *Service) Verify() (core.AuditReport, error) {
+	report := core.AuditReport{Valid: true, Problems: make([]core.AuditProblem, 0)}
+	entries, err := s.auditRepo.List()
+	if err != nil {
+		return report, err
+	}
+	members, err := s.verifiedMembers()
+	if err != nil {
+		return report, err
+	}
+	report.Entries = len(entries)
+	revoked := make(map[string]struct{})
+	prev := ""
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			report.Add(e.Seq, "entry %d found at position %d", e.Seq, i+1)
+		}
+		if e.Prev != prev {
+			report.Add(e.Seq, "previous hash does not match the previous entry")
+		}
+		if e.ComputeHash() != e.Hash {
+			report.Add(e.Seq, "entry was modified, its hash does not match")
+		} else if !signature.VerifyEncoded(e.Actor, e.SignedMessage(), e.Signature) {
+			report.Add(e.Seq, "signature of %s is invalid", e.Actor)
+		} else if !isMember(members, e.Actor) {
+			report.Add(e.Seq, "%s is not a verified member", e.Actor)
+		} else if _, ok := revoked[e.Actor]; ok {
+			report.Add(e.Seq, "%s was revoked by an earlier entry", e.Actor)
+		} else if e.Action == core.AuditRevokeMember {
+			revoked[e.Target] = struct{}{}
+		}
+		prev = e.Hash
+	}
+	head, err := s.auditRepo.GetHead()
+	if errors.Is(err, repositories.ErrAuditHeadNotFound) {
+		if len(entries) > 0 {
+			report.Add(0, "head is missing")
+		}
+		return report, nil
+	}
+	if err != nil {
+		report.Add(0, "head cannot be read: %v", err)
+		return report, nil
+	}
+	if !signature.VerifyEncoded(head.Actor, head.SignedMessage(), head.Signature) {
+		report.Add(0, "signature of %s is invalid", head.Actor)
+	} else if !isMember(members, head.Actor) {
+		report.Add(0, "head is signed by %s, who is not a verified member", head.Actor)
+	}
+	var last core.AuditEntry
+	if len(entries) > 0 {
+		last = entries[len(entries)-1]
+	}
+	switch {
+	case head.Seq > last.Seq:
+		report.Add(0, "log is truncated, the head references entry %d but the log ends at entry %d", head.Seq, last.Seq)
+	case head.Seq < last.Seq:
+		report.Add(0, "head references entry %d but the log ends at entry %d", head.Seq, last.Seq)
+	case head.Hash != last.Hash:
+		report.Add(0, "head does not match the last entry")
+	}
+	return report, nil
+}
+
+// encodePath returns the path of the file or directory as stored on disk, empty if it cannot be encoded.
+// The paths of the groups are not paths of the file system and are kept.
+func (s *Service) encodePath(path string) string {
+	if s.pathEncoder == nil || path == "" || strings.HasPrefix(path, core.GroupPrefix) {
+		return path
+	}
+	encoded, err := s.pathEncoder.EncodePath(path)
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// decodePath returns the plaintext path of the path stored in the log, or the stored path if it cannot be decoded.
+func (s *Service) decodePath(stored string) string {
+	if stored == "" || strings.HasPrefix(stored, core.GroupPrefix) {
+		return stored
+	}
+	path, err := s.pathEncoder.DecodePath(stored)
+	if err != nil {
+		return stored
+	}
+	return path
+}
+
+// verifiedMembers returns the public keys of the verified members of the repository, including the revoked ones,
+// whose revocation is checked against the log. It returns nil if there is no member lister.
+func (s *Service) verifiedMembers() (map[string]struct{}, error) {
+	if s.memberLister == nil {
+		return nil, nil
+	}
+	list, err := s.memberLister.ListMembers()
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]struct{}, len(list))
+	for _, m := range list {
+		if m.Verified {
+			members[m.PublicKey] = struct{}{}
+		}
+	}
+	return members, nil
+}
+
+// isMember returns true if the user is one of the members, or if the members are not known.
+func isMember(members map[string]struct{}, userId string) bool {
+	if members == nil {
+		return true
+	}
+	_, ok := members[userId]
+	return ok
+}
+
+// sign signs the message with the private key of the user and returns the encoded signature.
+func (s *Service) sign(message []byte) (string, error) {
+	sig, err := s.keyService.Sign(message)
+	if err != nil {
+		return "", err
+	}
+	return signature.Encode(sig), nil
+}
@@ -0,0 +1,156 @@
+package capsule_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/capsule"
+	"ctb-cli/repositories"
+	"ctb-cli/services/object_service"
+	"ctb-cli/services/share_service"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrNotCommitted = errors.New("the file is not committed yet")
+)
+
+// Service exports files of the repository to capsules.
+//
+// A capsule holds the encrypted objects of the files as stored in the repository, with their keys sealed to a recipient,
+// so that the files can be delivered out of band to a user who has no access to the repository.
+type Service struct {
+	keyService    core.KeyService
+	linkRepo      *repositories.LinkRepository
+	objectService *object_service.Service
+	shareService  *share_service.Service
+	auditLogger   core.AuditLogger
+}
+
+// NewService creates a new instance of the capsule service.
+func NewService(
+	keyService core.KeyService,
+	linkRepo *repositories.LinkRepository,
+	objectService *object_service.Service,
+	shareService *share_service.Service,
+	auditLogger core.AuditLogger,
+) *Service {
+	return &Service{
+		keyService:    keyService,
+		linkRepo:      linkRepo,
+		objectService: objectService,
+		shareService:  shareService,
+		auditLogger:   auditLogger,
+	}
+}
+
+// Export writes a capsule holding the file, or the files of the directory and its sub directories,
+// at the specified path to dst, sealed to the recipient. The current user must have access to every file.
+// Capsules are sealed with X25519 only, so they cannot be exported from a repository requiring hybrid keys.
+func (s *Service) Export(p string, recipient string, dst io.Writer) (core.CapsuleReport, error) {
+	if err := s.keyService.CheckX25519Recipient(); err != nil {
+		return core.CapsuleReport{}, err
+	}
+	p = filepath.Join(string(filepath.Separator), p)
+	if !s.linkRepo.IsValidPath(p) {
+		return core.CapsuleReport{}, fmt.Errorf("%w: %s", core.ErrInvalidPath, p)
+	}
+	publicKey, err := core.NewPublicKeyFromEncoded(recipient)
+	if err != nil {
+		return core.CapsuleReport{}, err
+	}
+	report := core.CapsuleReport{Path: p, Recipient: recipient, Files: make([]string, 0)}
+	entries := make([]capsule.Entry, 0)
+	if s.linkRepo.IsDir(p) {
+		err = s.walk(p, "", func(filePath string, name string) error {
+			entry, err := s.entry(filePath, name)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	} else {
+		var entry capsule.Entry
+		entry, err = s.entry(p, filepath.Base(p))
+		entries = append(entries, entry)
+	}
+	if err != nil {
+		return core.CapsuleReport{}, err
+	}
+	if err := capsule.Write(dst, publicKey, entries); err != nil {
+		return core.CapsuleReport{}, err
+	}
+	for _, entry := range entries {
+		report.Files = append(report.Files, entry.Path)
+	}
+	keyId, _, _, err := s.shareService.GetKeyIdByPath(p)
+	if err != nil {
+		return core.CapsuleReport{}, err
+	}
+	return report, s.auditLogger.Log(core.AuditExportCapsule, p, recipient, keyId)
+}
+
+// entry returns the capsule entry of the file at the path of the repository, named by the path in the capsule.
+func (s *Service) entry(filePath string, name string) (capsule.Entry, error) {
+	link, err := s.linkRepo.GetByPath(filePath)
+	if err != nil {
+		return capsule.Entry{}, err
+	}
+	if s.objectService.IsOpenForWrite(link) {
+		return capsule.Entry{}, fmt.Errorf("%w: %s", ErrNotCommitted, filePath)
+	}
+	// Get the object first, the key id is read from its header
+	object, objectSize, err := s.objectService.OpenObject(link)
+	if err != nil {
+		return capsule.Entry{}, err
+	}
+	object.Close()
+	keyId, startVaultId, startVaultPath, err := s.shareService.GetKeyIdByPath(filePath)
+	if err != nil {
+		return capsule.Entry{}, err
+	}
+	key, err := s.keyService.Get(keyId, startVaultId, startVaultPath)
+	if err != nil {
+		return capsule.Entry{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return capsule.Entry{
+		Path:       name,
+		Key:        key,
+		Size:       link.Data.Size,
+		ObjectSize: objectSize,
+		Open: func() (io.ReadCloser, error) {
+			object, _, err := s.objectService.OpenObject(link)
+			return object, err
+		},
+	}, nil
+}
+
+// walk calls fn for every file in the directory at the specified path of the repository and its sub directories,
+// with the path of the file relative to the exported directory, in a stable order.
+func (s *Service) walk(dir string, rel string, fn func(filePath string, name string) error) error {
+	subFiles, err := s.linkRepo.GetSubFiles(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(subFiles, func(i, j int) bool { return subFiles[i].Name() < subFiles[j].Name() })
+	for _, sub := range subFiles {
+		if sub.Name() == ".meta" {
+			continue
+		}
+		filePath := filepath.Join(dir, sub.Name())
+		name := path.Join(rel, sub.Name())
+		if sub.IsDir() {
+			err = s.walk(filePath, name, fn)
+		} else {
+			err = fn(filePath, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
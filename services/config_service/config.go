@@ -1,14 +1,20 @@
 package config_service
 
 import (
+	"ctb-cli/repositories"
 	"path/filepath"
 
 	"github.com/spf13/viper"
 )
 
+// CompressionNone is the compression of a directory whose new files are not compressed,
+// overriding the compression of its parent directories.
+const CompressionNone = "none"
+
 // Config represents the configuration of the application
 type ConfigService struct {
-	rootPath string
+	rootPath     string
+	pathResolver repositories.PathResolver // nil if the names of the directories are stored in plaintext
 }
 
 // New returns a new Config
@@ -18,14 +24,22 @@ func New(rootPath string) *ConfigService {
 	}
 }
 
+// SetPathResolver sets the resolver of the paths stored on disk, used when the names of the directories are encrypted.
+func (c *ConfigService) SetPathResolver(pathResolver repositories.PathResolver) {
+	c.pathResolver = pathResolver
+}
+
 // InitConfig generates the configuration file for the repository.
 func (c *ConfigService) InitConfig(path string) error {
-	configPath := c.getConfigPath(path)
+	configPath, err := c.getConfigPath(path)
+	if err != nil {
+		return err
+	}
 	cfg := viper.New()
 	// Set the default values for the configuration
 	cfg.SetConfigFile(filepath.Join(configPath, "config.yaml"))
 	cfg.Set("version", 1)
-	err := cfg.WriteConfig()
+	err = cfg.WriteConfig()
 	if err != nil {
 		return err
 	}
@@ -43,19 +57,83 @@ func (c *ConfigService) GetRepoVersion(path string) string {
 	return c.getConfig(path).GetString("version")
 }
 
+// GetAlgorithm returns the AEAD algorithm the new objects of the repository are encrypted with, empty for the default algorithm.
+func (c *ConfigService) GetAlgorithm() string {
+	return c.getConfig("").GetString("algorithm")
+}
+
+// SetAlgorithm sets the AEAD algorithm the new objects of the repository are encrypted with.
+// The objects already encrypted keep their algorithm, which is stored in their header.
+func (c *ConfigService) SetAlgorithm(alg string) error {
+	cfg := c.getConfig("")
+	cfg.Set("algorithm", alg)
+	return cfg.WriteConfig()
+}
+
+// GetCompression returns the compression the new files of the directory are compressed with, empty if they are not compressed.
+// The compression is set in the configuration of the directory, or inherited from the closest parent directory setting it.
+func (c *ConfigService) GetCompression(dir string) string {
+	for {
+		if compression := c.GetDirCompression(dir); compression != "" {
+			if compression == CompressionNone {
+				return ""
+			}
+			return compression
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// GetDirCompression returns the compression set in the configuration of the directory, empty if it is inherited.
+func (c *ConfigService) GetDirCompression(dir string) string {
+	return c.getConfig(dir).GetString("compression")
+}
+
+// SetDirCompression sets the compression of the new files of the directory and its sub directories that do not set theirs.
+// CompressionNone disables the compression, and an empty compression inherits the compression of the parent directory.
+// The files already encrypted keep their compression, which is stored in their header.
+func (c *ConfigService) SetDirCompression(dir string, compression string) error {
+	cfg := c.getConfig(dir)
+	cfg.Set("compression", compression)
+	return c.writeConfig(cfg, dir)
+}
+
 // GetRepoConfig returns the configuration of the path.
+// If the path cannot be resolved, the configuration is empty and cannot be read.
 func (c *ConfigService) getConfig(path string) *viper.Viper {
-	configPath := c.getConfigPath(path)
 	cfg := viper.New()
 	cfg.SetConfigName("config")
 	cfg.SetConfigType("yaml")
-	cfg.AddConfigPath(configPath)
+	if configPath, err := c.getConfigPath(path); err == nil {
+		cfg.AddConfigPath(configPath)
+	}
 
 	_ = cfg.ReadInConfig()
 
 	return cfg
 }
 
-func (c *ConfigService) getConfigPath(path string) string {
-	return filepath.Join(c.rootPath, path, ".meta")
+// writeConfig writes the configuration of the path, creating its configuration file if the path has none yet,
+// as the sub directories have no configuration until a setting is set on them.
+func (c *ConfigService) writeConfig(cfg *viper.Viper, path string) error {
+	configPath, err := c.getConfigPath(path)
+	if err != nil {
+		return err
+	}
+	return cfg.WriteConfigAs(filepath.Join(configPath, "config.yaml"))
+}
+
+func (c *ConfigService) getConfigPath(path string) (string, error) {
+	if c.pathResolver == nil {
+		return filepath.Join(c.rootPath, path, ".meta"), nil
+	}
+	resolved, err := c.pathResolver.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.rootPath, resolved, ".meta"), nil
 }
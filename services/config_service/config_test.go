@@ -0,0 +1,51 @@
+package config_service_test
+
+import (
+	"ctb-cli/crypto/file_crypto"
+	"ctb-cli/services/config_service"
+	"ctb-cli/test/testrepo"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetDirCompression(t *testing.T) {
+	repo := testrepo.New(t, "a", "a/b", "a/b/c")
+	repoPath := repo.Path
+	// The configuration file of a directory can be missing, it is created when the compression is set
+	for _, name := range []string{"a", "a/b/c"} {
+		if err := os.Remove(filepath.Join(repoPath, name, ".meta", "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configService := config_service.New(repoPath)
+
+	if err := configService.SetDirCompression("/a", file_crypto.CompressionZstd); err != nil {
+		t.Fatal(err)
+	}
+	if err := configService.SetDirCompression("/a/b/c", config_service.CompressionNone); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dir         string
+		compression string
+		inherited   bool
+	}{
+		{"/", "", true},
+		{"/a", file_crypto.CompressionZstd, false},
+		{"/a/b", file_crypto.CompressionZstd, true},
+		{"/a/b/c", "", false},
+	}
+	for _, test := range tests {
+		if got := configService.GetCompression(test.dir); got != test.compression {
+			t.Errorf("got compression %q for %s, want %q", got, test.dir, test.compression)
+		}
+		if inherited := configService.GetDirCompression(test.dir) == ""; inherited != test.inherited {
+			t.Errorf("got inherited %v for %s, want %v", inherited, test.dir, test.inherited)
+		}
+	}
+	// The setting of the root configuration is kept
+	if configService.GetRepoVersion("") == "" {
+		t.Error("the version of the repository is lost")
+	}
+}
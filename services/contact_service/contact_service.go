@@ -0,0 +1,222 @@
+package contact_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidName       = errors.New("the contact name must not be empty or a public key")
+	ErrInvalidEmail      = errors.New("invalid email")
+	ErrAliasConflict     = errors.New("the name or email is already used by another contact")
+	ErrContactExists     = errors.New("the public key is already in the address book")
+	ErrUnknownRecipient  = errors.New("the recipient is neither a public key nor a contact of the address book")
+	ErrUnverifiedContact = errors.New("the contact is not signed by a member of the repository")
+	ErrAmbiguousContact  = errors.New("the name or email matches several verified contacts")
+)
+
+// Service manages the address book of the repository, which maps names and emails to public keys.
+//
+// Every contact is signed by the member who added it, and only the contacts signed by a verified member
+// are used to resolve recipients, so that a name cannot be pointed to another key by editing the address book.
+type Service struct {
+	keyService    core.KeyService
+	contactRepo   *repositories.ContactRepository
+	memberChecker core.MemberChecker
+}
+
+// NewService creates a new instance of the contact service.
+func NewService(keyService core.KeyService, contactRepo *repositories.ContactRepository, memberChecker core.MemberChecker) *Service {
+	return &Service{
+		keyService:    keyService,
+		contactRepo:   contactRepo,
+		memberChecker: memberChecker,
+	}
+}
+
+// Add adds a contact with the given name and optional email for the public key, signed by the current user.
+// The name and the email must not be used by a contact of another public key.
+// If the public key is already in the address book, its contact is replaced only if replace is true.
+func (s *Service) Add(name string, email string, publicKey string, replace bool) (core.ContactEntry, error) {
+	name = strings.TrimSpace(name)
+	email = strings.TrimSpace(email)
+	if name == "" || isPublicKey(name) {
+		return core.ContactEntry{}, ErrInvalidName
+	}
+	if email != "" && (!strings.Contains(email, "@") || isPublicKey(email)) {
+		return core.ContactEntry{}, ErrInvalidEmail
+	}
+	key, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return core.ContactEntry{}, err
+	}
+	contacts, err := s.contactRepo.List()
+	if err != nil {
+		return core.ContactEntry{}, err
+	}
+	contact := core.Contact{
+		PublicKey: publicKey,
+		Name:      name,
+		Email:     email,
+	}
+	for _, other := range contacts {
+		if other.PublicKey == publicKey {
+			if !replace {
+				return core.ContactEntry{}, ErrContactExists
+			}
+			continue
+		}
+		for _, alias := range contact.Aliases() {
+			if other.Matches(alias) {
+				return core.ContactEntry{}, fmt.Errorf("%w: %q is used by %s", ErrAliasConflict, alias, other.PublicKey)
+			}
+		}
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.ContactEntry{}, err
+	}
+	contact.AddedBy = userId
+	contact.AddedAt = time.Now().UTC()
+	sig, err := s.keyService.Sign(contact.SignedMessage())
+	if err != nil {
+		return core.ContactEntry{}, err
+	}
+	contact.Signature = signature.Encode(sig)
+	if err := s.contactRepo.Save(contact); err != nil {
+		return core.ContactEntry{}, err
+	}
+	return core.ContactEntry{Contact: contact, Fingerprint: key.Fingerprint(), Verified: true}, nil
+}
+
+// Remove removes the contact with the given name, email or public key.
+func (s *Service) Remove(alias string) error {
+	if isPublicKey(alias) {
+		return s.contactRepo.Remove(alias)
+	}
+	contacts, err := s.contactRepo.List()
+	if err != nil {
+		return err
+	}
+	for _, contact := range contacts {
+		if contact.Matches(alias) {
+			return s.contactRepo.Remove(contact.PublicKey)
+		}
+	}
+	return repositories.ErrContactNotFound
+}
+
+// List returns the contacts of the address book with their fingerprint and verification, sorted by name.
+func (s *Service) List() (core.ContactList, error) {
+	contacts, err := s.contactRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	list := make(core.ContactList, 0, len(contacts))
+	for _, contact := range contacts {
+		fingerprint := ""
+		if key, err := core.NewPublicKeyFromEncoded(contact.PublicKey); err == nil {
+			fingerprint = key.Fingerprint()
+		}
+		list = append(list, core.ContactEntry{
+			Contact:     contact,
+			Fingerprint: fingerprint,
+			Verified:    s.isVerified(contact),
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+	return list, nil
+}
+
+// Resolve returns the public key of the recipient, which is either a public key or the name or email of a contact.
+// Names and emails are matched case-insensitively, and only verified contacts are used.
+// A name or email matching several verified contacts is rejected rather than resolved to one of them.
+func (s *Service) Resolve(recipient string) (string, error) {
+	recipient = strings.TrimSpace(recipient)
+	if isPublicKey(recipient) {
+		return recipient, nil
+	}
+	contacts, err := s.contactRepo.List()
+	if err != nil {
+		return "", err
+	}
+	found := false
+	var verified []string
+	for _, contact := range contacts {
+		if !contact.Matches(recipient) {
+			continue
+		}
+		if s.isVerified(contact) {
+			verified = append(verified, contact.PublicKey)
+		}
+		found = true
+	}
+	switch {
+	case len(verified) == 1:
+		return verified[0], nil
+	case len(verified) > 1:
+		return "", fmt.Errorf("%w: %q is used by %s", ErrAmbiguousContact, recipient, strings.Join(verified, ", "))
+	case found:
+		return "", ErrUnverifiedContact
+	}
+	return "", ErrUnknownRecipient
+}
+
+// NameAccessList sets the name of the verified contacts in the access list.
+func (s *Service) NameAccessList(list core.KeyAccessList) error {
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].Name = names[list[i].PublicKey]
+	}
+	return nil
+}
+
+// NameAccessReport sets the name of the verified contacts in the access lists of the report.
+func (s *Service) NameAccessReport(report core.AccessReport) error {
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+	for _, p := range report {
+		for i := range p.Access {
+			p.Access[i].Name = names[p.Access[i].PublicKey]
+		}
+	}
+	return nil
+}
+
+// names returns the names of the verified contacts by public key.
+func (s *Service) names() (map[string]string, error) {
+	contacts, err := s.contactRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(contacts))
+	for _, contact := range contacts {
+		if s.isVerified(contact) {
+			names[contact.PublicKey] = contact.Name
+		}
+	}
+	return names, nil
+}
+
+// isVerified returns true if the contact is signed by a verified member of the repository.
+func (s *Service) isVerified(contact core.Contact) bool {
+	return signature.VerifyEncoded(contact.AddedBy, contact.SignedMessage(), contact.Signature) &&
+		s.memberChecker.IsMember(contact.AddedBy)
+}
+
+// isPublicKey returns true if the string is an encoded public key.
+func isPublicKey(s string) bool {
+	_, err := core.NewPublicKeyFromEncoded(s)
+	return err == nil
+}
@@ -0,0 +1,44 @@
+package contact_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"ctb-cli/services/contact_service"
+	"ctb-cli/test/testrepo"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolveAmbiguousContact(t *testing.T) {
+	repo := testrepo.New(t)
+	owner := repo.Owner(t)
+	first := repo.Open(t, testrepo.NewUserKey(t)).Id
+	second := repo.Open(t, testrepo.NewUserKey(t)).Id
+
+	if _, err := owner.Contact.Add("alice", "alice@example.com", first, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := owner.Contact.Resolve("Alice"); err != nil || got != first {
+		t.Fatalf("got %s, %v, want %s", got, err, first)
+	}
+
+	// A second verified contact with the same email, as added by another member before the address books were merged
+	contact := core.Contact{PublicKey: second, Name: "alice smith", Email: "alice@example.com", AddedBy: owner.Id, AddedAt: time.Now().UTC()}
+	sig, err := owner.KeyStore.Sign(contact.SignedMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	contact.Signature = signature.Encode(sig)
+	if err := repositories.NewContactRepository(repo.Path).Save(contact); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := owner.Contact.Resolve("alice@example.com"); !errors.Is(err, contact_service.ErrAmbiguousContact) {
+		t.Fatalf("got %v, want ErrAmbiguousContact", err)
+	}
+	if got, err := owner.Contact.Resolve("alice"); err != nil || got != first {
+		t.Fatalf("got %s, %v, want %s", got, err, first)
+	}
+}
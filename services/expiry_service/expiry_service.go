@@ -0,0 +1,284 @@
+package expiry_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Service removes the expired data key shares of the repository.
+//
+// An expired data key does not give access anymore, but the recipient may have kept the keys it gave access to.
+// So the key of every vault the recipient could reach through the share is replaced when the share is removed:
+// the recipient can still open the objects written before, but not the objects written afterwards.
+// The expiries are recorded next to the data keys and logged in the audit log, the shares are found from both,
+// so a share whose recorded expiry is removed still expires.
+type Service struct {
+	keyService  core.KeyService
+	keyRepo     repositories.KeyRepository
+	vaultRepo   repositories.VaultRepository
+	linkRepo    *repositories.LinkRepository
+	auditLogger core.AuditLogger
+	expiryLog   core.ExpiryLog
+}
+
+// NewService creates a new instance of the expiry service.
+func NewService(
+	keyService core.KeyService,
+	keyRepo repositories.KeyRepository,
+	vaultRepo repositories.VaultRepository,
+	linkRepo *repositories.LinkRepository,
+	auditLogger core.AuditLogger,
+	expiryLog core.ExpiryLog,
+) *Service {
+	return &Service{
+		keyService:  keyService,
+		keyRepo:     keyRepo,
+		vaultRepo:   vaultRepo,
+		linkRepo:    linkRepo,
+		auditLogger: auditLogger,
+		expiryLog:   expiryLog,
+	}
+}
+
+// Expire removes the expired data key shares and replaces the keys of the vaults their recipients could reach.
+// The shares reaching a vault the current user has no access to are kept, as the key of the vault cannot be replaced,
+// and are reported as skipped, as are the shares whose reachable vaults cannot be listed because the names of some
+// directories cannot be decrypted. These directories are reported as well, as the shares below them are not checked.
+// If dryRun is true, the changes are only reported.
+func (s *Service) Expire(dryRun bool) (core.ExpiryReport, error) {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.ExpiryReport{}, err
+	}
+	report := core.ExpiryReport{DryRun: dryRun, Shares: make([]core.ExpiredShare, 0)}
+	expiries, unresolved, err := s.listExpiringShares()
+	if err != nil {
+		return core.ExpiryReport{}, err
+	}
+	report.Unresolved = unresolved
+	now := time.Now()
+	for _, e := range expiries {
+		// An expiry which is not validly signed is expired, at the zero time
+		expiresAt := s.keyService.GetShareExpiry(e.KeyId, e.Recipient, e.Path)
+		if expiresAt == nil || expiresAt.After(now) {
+			continue
+		}
+		share := core.ExpiredShare{
+			KeyId:         e.KeyId,
+			Recipient:     e.Recipient,
+			Path:          repoPath(e.Path),
+			ExpiresAt:     *expiresAt,
+			RotatedVaults: make([]string, 0),
+		}
+		unresolvedVaults := make([]string, 0)
+		if err := s.listReachableVaults(&share.RotatedVaults, &unresolvedVaults, e.KeyId, share.Path); err != nil {
+			return core.ExpiryReport{}, err
+		}
+		if len(unresolvedVaults) > 0 {
+			share.Skipped = fmt.Sprintf("the names of %s cannot be decrypted to find the vaults the share reaches", strings.Join(unresolvedVaults, ", "))
+		}
+		for _, path := range share.RotatedVaults {
+			if share.Skipped == "" && !s.hasAccessToVault(path, userId) {
+				share.Skipped = fmt.Sprintf("no access to the vault %s to replace its key", path)
+			}
+		}
+		report.Shares = append(report.Shares, share)
+	}
+	sort.SliceStable(report.Shares, func(i, j int) bool { return report.Shares[i].Path < report.Shares[j].Path })
+	if dryRun {
+		return report, nil
+	}
+	// Remove the shares first, so the new keys of the vaults are not shared with their recipients
+	rotations := make([]string, 0)
+	for _, share := range report.Shares {
+		if share.Skipped != "" {
+			continue
+		}
+		if err := s.keyService.Unshare(share.KeyId, share.Recipient, share.Path); err != nil {
+			return core.ExpiryReport{}, err
+		}
+		if err := s.auditLogger.Log(core.AuditExpire, share.Path, share.Recipient, share.KeyId); err != nil {
+			return core.ExpiryReport{}, err
+		}
+		for _, path := range share.RotatedVaults {
+			if !contains(rotations, path) {
+				rotations = append(rotations, path)
+			}
+		}
+	}
+	sort.Strings(rotations)
+	for _, path := range rotations {
+		if _, err := s.keyService.RotateVaultKey(path); err != nil {
+			return core.ExpiryReport{}, fmt.Errorf("the expired shares are removed but the key of the vault %s cannot be replaced: %v", path, err)
+		}
+	}
+	return report, nil
+}
+
+// listExpiringShares returns the data key shares with an expiry recorded next to the data key or logged in the audit log,
+// with the directories whose names cannot be decrypted, whose shares are not listed.
+// The logged expiries have no path, the shares of their data keys are found in the directories of their recipients.
+func (s *Service) listExpiringShares() ([]core.DataKeyExpiry, []string, error) {
+	expiries, err := s.keyRepo.ListDataKeyExpiries()
+	unresolved, err := unresolvedPaths(err)
+	if err != nil {
+		return nil, nil, err
+	}
+	logged, err := s.expiryLog.ListLoggedExpiries()
+	if err != nil {
+		return nil, nil, err
+	}
+	recorded := make(map[core.DataKeyExpiry]struct{}, len(expiries))
+	for _, e := range expiries {
+		recorded[core.DataKeyExpiry{KeyId: e.KeyId, Recipient: e.Recipient, Path: e.Path}] = struct{}{}
+	}
+	recipientShares := make(map[string]map[string][]string)
+	for _, l := range logged {
+		shares, ok := recipientShares[l.Recipient]
+		if !ok {
+			var paths []string
+			shares, err = s.keyRepo.ListRecipientDataKeys(l.Recipient)
+			if paths, err = unresolvedPaths(err); err != nil {
+				return nil, nil, err
+			}
+			for _, path := range paths {
+				if !contains(unresolved, path) {
+					unresolved = append(unresolved, path)
+				}
+			}
+			recipientShares[l.Recipient] = shares
+		}
+		for path, keyIds := range shares {
+			share := core.DataKeyExpiry{KeyId: l.KeyId, Recipient: l.Recipient, Path: path}
+			if _, ok := recorded[share]; ok || !contains(keyIds, l.KeyId) {
+				continue
+			}
+			recorded[share] = struct{}{}
+			expiries = append(expiries, share)
+		}
+	}
+	sort.Strings(unresolved)
+	return expiries, unresolved, nil
+}
+
+// unresolvedPaths returns the paths of an UnresolvedPathsError, or the error if it is another error.
+func unresolvedPaths(err error) ([]string, error) {
+	var unresolved *repositories.UnresolvedPathsError
+	if errors.As(err, &unresolved) {
+		return unresolved.Paths, nil
+	}
+	return nil, err
+}
+
+// listReachableVaults adds to the list the vault whose key is shared at the path, if it is a vault key,
+// and the vaults below it whose keys are sealed in their parent vault, parents first.
+// The directories whose names cannot be decrypted, which may hold reachable vaults, are added to unresolved.
+func (s *Service) listReachableVaults(list *[]string, unresolved *[]string, keyId string, path string) error {
+	// The key of the root vault is shared at the root
+	if path == string(filepath.Separator) {
+		root, err := s.vaultRepo.GetVaultByPath(path)
+		if err != nil {
+			return err
+		}
+		if root.KeyId == keyId {
+			*list = append(*list, path)
+			return s.listInheritingVaults(list, unresolved, path, root)
+		}
+	}
+	// The keys of the other vaults are shared at their parent
+	subFiles, unresolvedSubFiles, err := s.linkRepo.ListSubFiles(path)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subFiles {
+		if !sub.IsDir() || sub.Name() == ".meta" {
+			continue
+		}
+		subPath := filepath.Join(path, sub.Name())
+		vault, err := s.vaultRepo.GetVaultByPath(subPath)
+		if err != nil {
+			continue
+		}
+		if vault.KeyId == keyId {
+			*list = append(*list, subPath)
+			return s.listInheritingVaults(list, unresolved, subPath, vault)
+		}
+	}
+	// The key is a file key, or the key of a vault whose name cannot be decrypted
+	addUnresolvedDirs(unresolved, path, unresolvedSubFiles)
+	return nil
+}
+
+// listInheritingVaults adds to the list the vaults below the vault at the path whose keys are sealed in their parent vault,
+// and to unresolved the directories whose names cannot be decrypted.
+func (s *Service) listInheritingVaults(list *[]string, unresolved *[]string, path string, vault core.Vault) error {
+	subFiles, unresolvedSubFiles, err := s.linkRepo.ListSubFiles(path)
+	if err != nil {
+		return err
+	}
+	addUnresolvedDirs(unresolved, path, unresolvedSubFiles)
+	sort.Slice(subFiles, func(i, j int) bool { return subFiles[i].Name() < subFiles[j].Name() })
+	for _, sub := range subFiles {
+		if !sub.IsDir() || sub.Name() == ".meta" {
+			continue
+		}
+		subPath := filepath.Join(path, sub.Name())
+		subVault, err := s.vaultRepo.GetVaultByPath(subPath)
+		if err != nil {
+			continue
+		}
+		if _, found := s.vaultRepo.GetKey(subVault.KeyId, vault.Id, path); !found {
+			continue
+		}
+		*list = append(*list, subPath)
+		if err := s.listInheritingVaults(list, unresolved, subPath, subVault); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addUnresolvedDirs adds to unresolved the paths of the sub directories of the directory at the path.
+func addUnresolvedDirs(unresolved *[]string, path string, subFiles []os.FileInfo) {
+	for _, sub := range subFiles {
+		if sub.IsDir() {
+			*unresolved = append(*unresolved, filepath.Join(path, sub.Name()))
+		}
+	}
+}
+
+// hasAccessToVault returns true if the user has access to the key of the vault at the path.
+func (s *Service) hasAccessToVault(path string, userId string) bool {
+	vault, err := s.vaultRepo.GetVaultByPath(path)
+	if err != nil {
+		return false
+	}
+	parentPath, parentVault, err := s.vaultRepo.GetVaultParent(path)
+	if err != nil {
+		return false
+	}
+	hasAccess, _ := s.keyService.GetHasAccessToKey(vault.KeyId, parentVault.Id, parentPath, userId)
+	return hasAccess
+}
+
+// repoPath returns the path relative to the root of the repository as a rooted path.
+func repoPath(path string) string {
+	return filepath.Join(string(filepath.Separator), path)
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package expiry_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/test/testrepo"
+	"testing"
+	"time"
+)
+
+func TestExpireUnsignedExpiries(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner := repo.Owner(t)
+	keyStore := owner.KeyStore
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := make([]string, 0, 2)
+	for range 2 {
+		recipientKey, err := core.NewPrivateKeyFromEncoded(testrepo.NewUserKey(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient, err := recipientKey.ToPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keyStore.Share(vault.KeyId, parent.Id, parentPath, recipient, recipient.String()); err != nil {
+			t.Fatal(err)
+		}
+		if err := keyStore.SetShareExpiry(vault.KeyId, recipient.String(), parentPath, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		recipients = append(recipients, recipient.String())
+	}
+	// The expiry of the second share is extended without being signed again
+	tampered, _ := keyRepository.GetDataKeyExpiry(vault.KeyId, recipients[1], parentPath)
+	tampered.ExpiresAt = tampered.ExpiresAt.AddDate(1, 0, 0)
+	if err := keyRepository.SetDataKeyExpiry(tampered); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := owner.Expiry.Expire(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Shares) != 1 || report.Shares[0].Recipient != recipients[1] {
+		t.Fatalf("got expired shares %v, want the share of %s only", report.Shares, recipients[1])
+	}
+}
+
+func TestExpireRemovedExpiries(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner := repo.Owner(t)
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := repo.Open(t, testrepo.NewUserKey(t))
+	recipientKey, err := recipient.KeyStore.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.KeyStore.Share(vault.KeyId, parent.Id, parentPath, recipientKey, recipient.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.KeyStore.SetShareExpiry(vault.KeyId, recipient.Id, parentPath, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// The expiry recorded next to the data key is removed, the expiry logged in the audit log is kept
+	if err := keyRepository.SetDataKeyExpiry(core.DataKeyExpiry{KeyId: vault.KeyId, Recipient: recipient.Id, Path: parentPath}); err != nil {
+		t.Fatal(err)
+	}
+	if hasAccess, _ := owner.KeyStore.GetHasAccessToKey(vault.KeyId, parent.Id, parentPath, recipient.Id); hasAccess {
+		t.Error("the share whose expiry is removed gives access")
+	}
+
+	report, err := owner.Expiry.Expire(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Shares) != 1 || report.Shares[0].Recipient != recipient.Id || report.Shares[0].Skipped != "" {
+		t.Fatalf("got expired shares %v, want the share of %s", report.Shares, recipient.Id)
+	}
+	if keyRepository.DataKeyExist(vault.KeyId, recipient.Id, parentPath) {
+		t.Error("the expired share is not removed")
+	}
+	if report, err := owner.Expiry.Expire(true); err != nil || len(report.Shares) != 0 {
+		t.Errorf("got expired shares %v (%v) after the sweep, want none", report.Shares, err)
+	}
+}
@@ -23,6 +23,8 @@ type FileSystem struct {
 var (
 	ErrFindingKey  = errors.New("Cannot find key")
 	ErrFindingLink = errors.New("Cannot find link")
+
+	ErrJournalEntryStale = errors.New("journaled file has changed since it was journaled")
 )
 
 // Make sure FileSystem implements the FileSystemService interface
@@ -148,6 +150,31 @@ func (f *FileSystem) GetSubFiles(path string) (res []fs.FileInfo, err error) {
 	return infos, nil
 }
 
+// Stat returns the file info of the file or directory at the specified path.
+// It returns an error wrapping fs.ErrNotExist if the path does not exist.
+func (f *FileSystem) Stat(path string) (fs.FileInfo, error) {
+	if !f.linkRepo.IsValidPath(path) {
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, path)
+	}
+	if f.linkRepo.IsDir(path) {
+		return FileInfo{
+			isDir: true,
+			name:  filepath.Base(path),
+			mode:  f.GetUserFileAccess(path, true),
+		}, nil
+	}
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file size: %v", err)
+	}
+	return FileInfo{
+		isDir: false,
+		name:  filepath.Base(path),
+		size:  link.Data.Size,
+		mode:  f.GetUserFileAccess(path, false),
+	}, nil
+}
+
 // RemoveDir removes a directory at the specified path.
 // It first removes the vault link associated with the directory,
 // and then removes the directory itself from the link repository.
@@ -217,6 +244,18 @@ func (f *FileSystem) Write(path string, buff []byte, ofst int64) (n int, err err
 	return
 }
 
+// Append writes the given byte slice at the end of the file at the specified path.
+// The current end of the file is taken from the size stored in the file link.
+// It returns the number of bytes written and any error encountered.
+func (f *FileSystem) Append(path string, buff []byte) (n int, err error) {
+	//Get file link to find the end of the file
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return 0, err
+	}
+	return f.Write(path, buff, link.Data.Size)
+}
+
 // changeFileId changes the ID of a file identified by the given path.
 // It retrieves the file link from the link repository, updates the ID in the link repository,
 // and moves the file in the object service to the new ID.
@@ -252,6 +291,10 @@ func (f *FileSystem) Read(path string, buff []byte, ofst int64) (n int, err erro
 	if err != nil {
 		return 0, err
 	}
+	//A file open for writing is only in the cache, it has no encrypted object to get the key from yet
+	if f.objectService.IsOpenForWrite(link) {
+		return f.objectService.Read(link, buff, ofst, nil)
+	}
 	//Get file key
 	key, err := f.getKeyByPath(path)
 	if err != nil {
@@ -384,6 +427,88 @@ func (f *FileSystem) Commit(path string) error {
 	return nil
 }
 
+// Discard drops the changes of the file at the specified path that were not committed.
+// The link of the file is restored to previous, the link of the file before it was opened for writing,
+// or the file is removed if previous is nil because it did not exist before.
+func (f *FileSystem) Discard(path string, previous *core.Link) error {
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return err
+	}
+	if err := f.objectService.Discard(link); err != nil {
+		return err
+	}
+	if previous == nil {
+		return f.linkRepo.Remove(path)
+	}
+	return f.linkRepo.Update(*previous)
+}
+
+// GetLink returns the link of the file at the specified path.
+func (f *FileSystem) GetLink(path string) (core.Link, error) {
+	return f.linkRepo.GetByPath(path)
+}
+
+// Sync forces an encrypted commit of the file at the specified path.
+// If the file is not open for writing there is nothing to persist and it returns nil.
+// The next write to the file opens it for writing again.
+func (f *FileSystem) Sync(path string) error {
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return err
+	}
+	if !f.objectService.IsOpenForWrite(link) {
+		return nil
+	}
+	return f.Commit(path)
+}
+
+// Journal records the file at the specified path as not committed.
+// The changes are kept in the write cache and committed by ReplayJournal on the next mount.
+func (f *FileSystem) Journal(path string) error {
+	link, err := f.linkRepo.GetByPath(path)
+	if err != nil {
+		return err
+	}
+	return f.objectService.AddToJournal(f.linkRepo.GetRootPath(), link)
+}
+
+// ReplayJournal commits the files of the repository that were journaled as not committed.
+// It returns the paths of the committed files.
+// Entries whose file has been changed or removed since they were journaled are left in the journal.
+func (f *FileSystem) ReplayJournal() (replayed []string, err error) {
+	entries, err := f.objectService.GetJournal()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if entry.Repo != f.linkRepo.GetRootPath() {
+			continue
+		}
+		link, err := f.linkRepo.GetByPath(entry.Path)
+		if err != nil || link.Id() != entry.ObjectId {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrJournalEntryStale, entry.Path))
+			continue
+		}
+		if err := f.Commit(entry.Path); err != nil {
+			errs = append(errs, fmt.Errorf("error committing %s: %v", entry.Path, err))
+			continue
+		}
+		if err := f.objectService.RemoveFromJournal(entry.ObjectId); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		replayed = append(replayed, entry.Path)
+	}
+	return replayed, errors.Join(errs...)
+}
+
+// WipeCache removes the plaintext files cached by the file system, except the journaled ones.
+func (f *FileSystem) WipeCache() error {
+	return f.objectService.WipeCache()
+}
+
 // ValidatePath validates the path and returns an error if the path is not valid.
 // If the path is valid, it returns nil. If the path is not valid, it returns the error.
 func (f *FileSystem) validatePath(path string) error {
@@ -0,0 +1,275 @@
+package fsck_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/services/config_service"
+	"ctb-cli/services/object_service"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Service checks the consistency of a repository.
+type Service struct {
+	keyService    core.KeyService
+	keyRepo       repositories.KeyRepository
+	vaultRepo     repositories.VaultRepository
+	linkRepo      *repositories.LinkRepository
+	objectRepo    *repositories.ObjectRepository
+	objectService *object_service.Service
+	configService *config_service.ConfigService
+}
+
+// NewService creates a new instance of the consistency check service.
+func NewService(
+	keyService core.KeyService,
+	keyRepo repositories.KeyRepository,
+	vaultRepo repositories.VaultRepository,
+	linkRepo *repositories.LinkRepository,
+	objectRepo *repositories.ObjectRepository,
+	objectService *object_service.Service,
+	configService *config_service.ConfigService,
+) *Service {
+	return &Service{
+		keyService:    keyService,
+		keyRepo:       keyRepo,
+		vaultRepo:     vaultRepo,
+		linkRepo:      linkRepo,
+		objectRepo:    objectRepo,
+		objectService: objectService,
+		configService: configService,
+	}
+}
+
+// dirCheck holds the state of the check of a single directory.
+type dirCheck struct {
+	path   string
+	vault  *core.Vault // nil if the vault of the directory cannot be read
+	shares map[string][]string
+	// used holds the key and object ids used by the files and sub directories of the directory
+	used map[string]struct{}
+	// complete is false if the ids used by some files or sub directories are unknown,
+	// in which case the unused keys and objects of the directory cannot be told apart
+	complete bool
+}
+
+// Check walks the repository tree and returns the problems found.
+// Every directory is checked for its system folders, vault and key shares, and every file for its link,
+// object and header. If full is true, the objects the user has access to are decrypted completely,
+// which requires the private key of the user to be set in the key service.
+func (s *Service) Check(full bool) (core.FsckReport, error) {
+	report := core.FsckReport{Findings: make([]core.FsckFinding, 0)}
+	err := s.checkDir(&report, string(filepath.Separator), nil, full)
+	return report, err
+}
+
+// checkDir checks the directory at the specified path and its sub directories.
+// parent is the check of the parent directory, nil for the root.
+func (s *Service) checkDir(report *core.FsckReport, path string, parent *dirCheck, full bool) error {
+	report.Dirs++
+	dc := &dirCheck{
+		path:     path,
+		used:     make(map[string]struct{}),
+		complete: true,
+	}
+	// Check the system folders and the configuration
+	for _, folder := range core.GetRepoSystemFolderNames() {
+		absFolder, err := s.abs(filepath.Join(path, ".meta", folder))
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(absFolder); err != nil || !info.IsDir() {
+			report.Add(core.FsckSeverityError, core.FsckMissingSystemFolder, path, folder, "system folder %s is missing", folder)
+		}
+	}
+	if !s.configService.IsRepositoryConfigExists(path) {
+		report.Add(core.FsckSeverityWarning, core.FsckMissingConfig, path, "", "configuration file is missing")
+	}
+	// Check the key shares
+	shares, err := s.keyRepo.ListDataKeys(path)
+	if err != nil {
+		report.Add(core.FsckSeverityError, core.FsckUnreadableKeyShares, path, "", "cannot read key shares: %v", err)
+		shares = map[string][]string{}
+	}
+	dc.shares = shares
+	for _, recipient := range sortedKeys(shares) {
+		if _, err := core.NewPublicKeyFromEncoded(recipient); err != nil {
+			report.Add(core.FsckSeverityWarning, core.FsckInvalidRecipient, path, recipient, "key share recipient %s is not a valid public key", recipient)
+		}
+	}
+	// Check the vault
+	s.checkVault(report, dc, parent)
+	// Check the files and sub directories
+	subFiles, unresolved, err := s.linkRepo.ListSubFiles(path)
+	if err != nil {
+		return err
+	}
+	// The entries whose names cannot be decrypted are not checked, and the ids they use are unknown
+	for _, sub := range unresolved {
+		dc.complete = false
+		report.Add(core.FsckSeverityInfo, core.FsckUnresolvedName, path, sub.Name(), "entry %s not checked, its name cannot be decrypted", sub.Name())
+	}
+	for _, sub := range subFiles {
+		if sub.Name() == ".meta" {
+			continue
+		}
+		p := filepath.Join(path, sub.Name())
+		if sub.IsDir() {
+			if err := s.checkDir(report, p, dc, full); err != nil {
+				return err
+			}
+			continue
+		}
+		s.checkFile(report, dc, p, full)
+	}
+	if dc.complete {
+		s.checkOrphans(report, dc)
+	}
+	return nil
+}
+
+// checkVault checks that the vault of the directory exists and its key can be resolved
+// through the parent vault or the key shares of the parent directory.
+func (s *Service) checkVault(report *core.FsckReport, dc *dirCheck, parent *dirCheck) {
+	vault, err := s.vaultRepo.GetVaultByPath(dc.path)
+	if err != nil {
+		if parent != nil {
+			parent.complete = false
+		}
+		if linkPath, lerr := s.abs(filepath.Join(dc.path, ".meta", ".vault", ".link")); lerr != nil {
+			report.Add(core.FsckSeverityError, core.FsckMissingVault, dc.path, "", "vault cannot be read: %v", err)
+		} else if _, lerr := os.Stat(linkPath); lerr != nil {
+			report.Add(core.FsckSeverityError, core.FsckMissingVaultLink, dc.path, "", "vault link is missing")
+		} else {
+			report.Add(core.FsckSeverityError, core.FsckMissingVault, dc.path, "", "vault cannot be read: %v", err)
+		}
+		return
+	}
+	dc.vault = &vault
+	if vault.NameKeyId != "" {
+		// The name key is used by the names of the files and sub directories
+		dc.used[vault.NameKeyId] = struct{}{}
+	}
+	if parent == nil {
+		// The key of the root vault is only shared with the users
+		dc.used[vault.KeyId] = struct{}{}
+		if !hasShare(dc.shares, vault.KeyId) {
+			report.Add(core.FsckSeverityError, core.FsckUnresolvableVaultKey, dc.path, vault.KeyId, "vault key %s is not shared with any user", vault.KeyId)
+		}
+		return
+	}
+	parent.used[vault.KeyId] = struct{}{}
+	if parent.vault != nil {
+		if _, found := s.vaultRepo.GetKey(vault.KeyId, parent.vault.Id, parent.path); found {
+			return
+		}
+	}
+	if !hasShare(parent.shares, vault.KeyId) {
+		report.Add(core.FsckSeverityError, core.FsckUnresolvableVaultKey, dc.path, vault.KeyId, "vault key %s is neither in the parent vault nor shared with any user", vault.KeyId)
+	}
+}
+
+// checkFile checks the link, object and header of the file at the specified path.
+// If full is true, the object is decrypted completely if the user has access to it.
+func (s *Service) checkFile(report *core.FsckReport, dc *dirCheck, path string, full bool) {
+	report.Files++
+	link, err := s.linkRepo.GetByPath(path)
+	if err != nil || link.Id() == "" {
+		dc.complete = false
+		report.Add(core.FsckSeverityError, core.FsckInvalidLink, path, "", "link file cannot be read: %v", err)
+		return
+	}
+	dc.used[link.Id()] = struct{}{}
+	if !s.objectService.IsInRepo(link) {
+		dc.complete = false
+		report.Add(core.FsckSeverityError, core.FsckMissingObject, path, link.Id(), "object %s is missing", link.Id())
+		return
+	}
+	keyId, err := s.objectService.GetKeyIdByObjectId(link)
+	if err != nil {
+		dc.complete = false
+		report.Add(core.FsckSeverityError, core.FsckCorruptHeader, path, link.Id(), "object header cannot be parsed: %v", err)
+		return
+	}
+	dc.used[keyId] = struct{}{}
+	inVault := false
+	if dc.vault != nil {
+		_, inVault = s.vaultRepo.GetKey(keyId, dc.vault.Id, dc.path)
+	}
+	if !inVault && !hasShare(dc.shares, keyId) {
+		report.Add(core.FsckSeverityError, core.FsckUnknownKey, path, keyId, "key %s of the object is neither in the vault nor shared with any user", keyId)
+		return
+	}
+	if !full || dc.vault == nil {
+		return
+	}
+	key, err := s.keyService.Get(keyId, dc.vault.Id, dc.path)
+	if err != nil {
+		report.Add(core.FsckSeverityInfo, core.FsckNoAccess, path, link.Id(), "object not decrypted, the key cannot be resolved: %v", err)
+		return
+	}
+	size, err := s.objectService.VerifyObject(link, key)
+	if err != nil {
+		report.Add(core.FsckSeverityError, core.FsckCorruptObject, path, link.Id(), "object cannot be decrypted: %v", err)
+		return
+	}
+	if size != link.Data.Size {
+		report.Add(core.FsckSeverityWarning, core.FsckSizeMismatch, path, link.Id(), "link size %d does not match the object size %d", link.Data.Size, size)
+	}
+}
+
+// checkOrphans reports the key shares, vault keys and objects of the directory
+// that are not used by any of its files or sub directories.
+// It must only be called if the ids used by all the files and sub directories are known.
+func (s *Service) checkOrphans(report *core.FsckReport, dc *dirCheck) {
+	for _, recipient := range sortedKeys(dc.shares) {
+		for _, keyId := range dc.shares[recipient] {
+			if _, ok := dc.used[keyId]; !ok {
+				report.Add(core.FsckSeverityWarning, core.FsckOrphanedKeyShare, dc.path, recipient+"/"+keyId, "key %s shared with %s is not used", keyId, recipient)
+			}
+		}
+	}
+	if dc.vault != nil {
+		keys, err := s.vaultRepo.ListKeys(dc.vault.Id, dc.path)
+		if err != nil {
+			report.Add(core.FsckSeverityError, core.FsckMissingVault, dc.path, "", "vault keys cannot be read: %v", err)
+		}
+		for _, keyId := range keys {
+			if _, ok := dc.used[keyId]; !ok {
+				report.Add(core.FsckSeverityWarning, core.FsckOrphanedVaultKey, dc.path, keyId, "vault key %s is not used", keyId)
+			}
+		}
+	}
+	objects, err := s.objectRepo.ListObjects(dc.path)
+	if err != nil {
+		report.Add(core.FsckSeverityError, core.FsckUnreadableObjects, dc.path, "", "objects cannot be listed: %v", err)
+	}
+	for _, id := range objects {
+		if _, ok := dc.used[id]; !ok {
+			report.Add(core.FsckSeverityWarning, core.FsckOrphanedObject, dc.path, id, "object %s is not used by any file", id)
+		}
+	}
+}
+
+// hasShare returns true if the key is shared with at least one user.
+func hasShare(shares map[string][]string, keyId string) bool {
+	for _, keys := range shares {
+		for _, k := range keys {
+			if k == keyId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the recipients of the key shares in a stable order.
+func sortedKeys(shares map[string][]string) []string {
+	keys := make([]string, 0, len(shares))
+	for k := range shares {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,256 @@
+package fsck_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/test/testrepo"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixture is a repository with a directory a holding a file, and the ids stored on disk for them.
+type fixture struct {
+	testrepo.Repo
+	rootVaultId string // id of the vault of the root
+	vaultId     string // id of the vault of a
+	vaultKeyId  string // id of the key of the vault of a, sealed in the vault of the root
+	fileKeyId   string // id of the key of the file, sealed in the vault of a
+	objectId    string // id of the object of the file
+}
+
+// newFixture creates the fixture repository.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	f := fixture{Repo: testrepo.New(t, "a")}
+	f.WriteFile(t, "a/file", []byte("content"))
+	f.rootVaultId = readJSON(t, f.abs(".meta/.vault/.link"), "vaultId")
+	f.vaultId = readJSON(t, f.abs("a/.meta/.vault/.link"), "vaultId")
+	f.vaultKeyId = onlyEntry(t, f.abs(".meta/.vault/."+f.rootVaultId))
+	f.fileKeyId = onlyEntry(t, f.abs("a/.meta/.vault/."+f.vaultId))
+	f.objectId = readJSON(t, f.abs("a/file"), "objectId")
+	return f
+}
+
+// abs returns the path on disk of the path relative to the root of the repository.
+func (f fixture) abs(path string) string {
+	return filepath.Join(f.Path, filepath.FromSlash(path))
+}
+
+// readJSON returns the string field of the JSON file.
+func readJSON(t *testing.T, name string, field string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	value, _ := m[field].(string)
+	return value
+}
+
+// onlyEntry returns the name of the only entry of the folder.
+func onlyEntry(t *testing.T, dir string) string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries in %s, want 1", len(entries), dir)
+	}
+	return entries[0].Name()
+}
+
+// must fails the test if err is not nil.
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// copyFile copies the file at src to dst.
+func copyFile(t *testing.T, src string, dst string) {
+	t.Helper()
+	b, err := os.ReadFile(src)
+	must(t, err)
+	must(t, os.WriteFile(dst, b, 0644))
+}
+
+// hasFinding returns true if the report holds a finding with the code at the path.
+func hasFinding(report core.FsckReport, code string, path string) bool {
+	for _, f := range report.Findings {
+		if f.Code == code && f.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckClean(t *testing.T) {
+	f := newFixture(t)
+	report, err := f.Owner(t).Fsck.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 0 || report.Dirs != 2 || report.Files != 1 {
+		t.Fatalf("got %d dirs, %d files and findings:\n%s", report.Dirs, report.Files, report)
+	}
+}
+
+func TestCheckFindings(t *testing.T) {
+	tests := []struct {
+		code   string
+		path   string
+		full   bool
+		user   func(t *testing.T, f fixture) testrepo.User // the owner if nil
+		damage func(t *testing.T, f fixture)               // damages the fixture, nil to keep it intact
+	}{
+		{
+			code: core.FsckMissingSystemFolder, path: "/a",
+			damage: func(t *testing.T, f fixture) { must(t, os.Remove(f.abs("a/.meta/.key-share"))) },
+		},
+		{
+			code: core.FsckMissingConfig, path: "/a",
+			damage: func(t *testing.T, f fixture) { must(t, os.Remove(f.abs("a/.meta/config.yaml"))) },
+		},
+		{
+			code: core.FsckMissingVaultLink, path: "/a",
+			damage: func(t *testing.T, f fixture) { must(t, os.Remove(f.abs("a/.meta/.vault/.link"))) },
+		},
+		{
+			code: core.FsckMissingVault, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				must(t, os.WriteFile(f.abs("a/.meta/.vault/.link"), []byte("not a link"), 0644))
+			},
+		},
+		{
+			code: core.FsckUnresolvableVaultKey, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				must(t, os.Remove(f.abs(".meta/.vault/."+f.rootVaultId+"/"+f.vaultKeyId)))
+			},
+		},
+		{
+			code: core.FsckInvalidLink, path: "/a/file",
+			damage: func(t *testing.T, f fixture) { must(t, os.WriteFile(f.abs("a/file"), []byte("not a link"), 0644)) },
+		},
+		{
+			code: core.FsckMissingObject, path: "/a/file",
+			damage: func(t *testing.T, f fixture) { must(t, os.Remove(f.abs("a/.meta/.object/"+f.objectId))) },
+		},
+		{
+			code: core.FsckCorruptHeader, path: "/a/file",
+			damage: func(t *testing.T, f fixture) {
+				must(t, os.WriteFile(f.abs("a/.meta/.object/"+f.objectId), []byte("not an object"), 0644))
+			},
+		},
+		{
+			code: core.FsckUnknownKey, path: "/a/file",
+			damage: func(t *testing.T, f fixture) {
+				must(t, os.Remove(f.abs("a/.meta/.vault/."+f.vaultId+"/"+f.fileKeyId)))
+			},
+		},
+		{
+			code: core.FsckCorruptObject, path: "/a/file", full: true,
+			damage: func(t *testing.T, f fixture) {
+				name := f.abs("a/.meta/.object/" + f.objectId)
+				b, err := os.ReadFile(name)
+				must(t, err)
+				b[len(b)-1] ^= 0xff
+				must(t, os.WriteFile(name, b, 0644))
+			},
+		},
+		{
+			code: core.FsckSizeMismatch, path: "/a/file", full: true,
+			damage: func(t *testing.T, f fixture) {
+				link := []byte(`{"objectId":"` + f.objectId + `","size":99}`)
+				must(t, os.WriteFile(f.abs("a/file"), link, 0644))
+			},
+		},
+		{
+			code: core.FsckOrphanedKeyShare, path: "/",
+			damage: func(t *testing.T, f fixture) {
+				shares := f.abs(".meta/.key-share/" + f.Owner(t).Id)
+				copyFile(t, filepath.Join(shares, onlyEntry(t, shares)), filepath.Join(shares, "unused"))
+			},
+		},
+		{
+			code: core.FsckOrphanedVaultKey, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				keys := f.abs("a/.meta/.vault/." + f.vaultId)
+				copyFile(t, filepath.Join(keys, f.fileKeyId), filepath.Join(keys, "unused"))
+			},
+		},
+		{
+			code: core.FsckOrphanedObject, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				copyFile(t, f.abs("a/.meta/.object/"+f.objectId), f.abs("a/.meta/.object/unused"))
+			},
+		},
+		{
+			code: core.FsckInvalidRecipient, path: "/",
+			damage: func(t *testing.T, f fixture) { must(t, os.Mkdir(f.abs(".meta/.key-share/not-a-key"), 0755)) },
+		},
+		{
+			code: core.FsckNoAccess, path: "/a/file", full: true,
+			user: func(t *testing.T, f fixture) testrepo.User { return f.Open(t, testrepo.NewUserKey(t)) },
+		},
+		{
+			code: core.FsckUnreadableKeyShares, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				must(t, os.Remove(f.abs("a/.meta/.key-share")))
+				must(t, os.WriteFile(f.abs("a/.meta/.key-share"), nil, 0644))
+			},
+		},
+		{
+			code: core.FsckUnreadableObjects, path: "/a",
+			damage: func(t *testing.T, f fixture) {
+				// The objects are only listed if the directory holds no unreadable file
+				must(t, os.Remove(f.abs("a/file")))
+				must(t, os.RemoveAll(f.abs("a/.meta/.object")))
+				must(t, os.WriteFile(f.abs("a/.meta/.object"), nil, 0644))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.damage != nil {
+				tt.damage(t, f)
+			}
+			user := f.Owner(t)
+			if tt.user != nil {
+				user = tt.user(t, f)
+			}
+			report, err := user.Fsck.Check(tt.full)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !hasFinding(report, tt.code, tt.path) {
+				t.Fatalf("no %s finding at %s:\n%s", tt.code, tt.path, report)
+			}
+		})
+	}
+}
+
+func TestCheckUnresolvedName(t *testing.T) {
+	r := testrepo.NewWithEncryptedNames(t, "a")
+	// An entry the repository did not store, whose name is not encrypted with the name key of the root
+	must(t, os.Mkdir(filepath.Join(r.Path, "stray"), 0755))
+	report, err := r.Owner(t).Fsck.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasFinding(report, core.FsckUnresolvedName, "/") {
+		t.Fatalf("no %s finding at /:\n%s", core.FsckUnresolvedName, report)
+	}
+	for _, f := range report.Findings {
+		if f.Path == "/" && (f.Code == core.FsckOrphanedKeyShare || f.Code == core.FsckOrphanedVaultKey || f.Code == core.FsckOrphanedObject) {
+			t.Errorf("got finding %s %s at the root, which holds an unresolved entry", f.Code, f.Target)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package fsck_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBackupNotFound = errors.New("repair backup not found")
+)
+
+// repairLogName is the name of the file logging the actions of a repair in its backup folder.
+const repairLogName = "repair.json"
+
+// repair holds the state of a single repair run.
+type repair struct {
+	s        *Service
+	dryRun   bool
+	backupId string // empty until the first file is backed up or created
+	actions  []core.RepairAction
+}
+
+// Repair fixes the problems found by Check that can be fixed without losing data:
+// missing system folders and configuration files, missing vault links, wrong file sizes,
+// unreadable objects, which are quarantined, and unused key shares and vault keys.
+// Every file changed or removed is saved to a backup folder first, so that the repair
+// can be undone with Restore. If full is true, the objects are decrypted to find
+// wrong sizes and unreadable objects, which requires the private key of the user.
+// If dryRun is true, the fixes are only reported.
+func (s *Service) Repair(full bool, dryRun bool) (core.RepairReport, error) {
+	r := &repair{
+		s:       s,
+		dryRun:  dryRun,
+		actions: make([]core.RepairAction, 0),
+	}
+	err := r.run(full)
+	if r.backupId != "" {
+		if logErr := r.saveLog(); err == nil {
+			err = logErr
+		}
+	}
+	if err != nil {
+		return core.RepairReport{}, err
+	}
+	remaining, err := s.Check(full)
+	if err != nil {
+		return core.RepairReport{}, err
+	}
+	return core.RepairReport{
+		BackupId:  r.backupId,
+		DryRun:    dryRun,
+		Actions:   r.actions,
+		Remaining: remaining,
+	}, nil
+}
+
+// Restore undoes the repair with the specified backup id.
+// The files and folders created by the repair are removed and the files saved are copied back,
+// in the reverse order of the fixes.
+func (s *Service) Restore(backupId string) error {
+	if backupId == "" || strings.ContainsAny(backupId, `/\`) {
+		return ErrBackupNotFound
+	}
+	dir := s.backupDir(backupId)
+	js, err := os.ReadFile(filepath.Join(dir, repairLogName))
+	if os.IsNotExist(err) {
+		return ErrBackupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var actions []core.RepairAction
+	if err := json.Unmarshal(js, &actions); err != nil {
+		return fmt.Errorf("error unmarshaling repair log: %v", err)
+	}
+	root := s.linkRepo.GetRootPath()
+	for i := len(actions) - 1; i >= 0; i-- {
+		action := actions[i]
+		for j := len(action.Created) - 1; j >= 0; j-- {
+			if err := os.Remove(filepath.Join(root, action.Created[j])); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		for _, p := range action.Backup {
+			if err := copyFile(filepath.Join(dir, "files", p), filepath.Join(root, p)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run checks the repository and fixes the problems found.
+// The structure of the directories is fixed first, then the repository is checked again
+// to find the problems hidden by the broken structure, and those are fixed.
+func (r *repair) run(full bool) error {
+	report, err := r.s.Check(full)
+	if err != nil {
+		return err
+	}
+	fixed := len(r.actions)
+	for _, f := range report.Findings {
+		var err error
+		switch f.Code {
+		case core.FsckMissingSystemFolder:
+			err = r.fixSystemFolder(f)
+		case core.FsckMissingConfig:
+			err = r.fixConfig(f)
+		case core.FsckMissingVaultLink:
+			err = r.fixVaultLink(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !r.dryRun && len(r.actions) > fixed {
+		if report, err = r.s.Check(full); err != nil {
+			return err
+		}
+	}
+	for _, f := range report.Findings {
+		var err error
+		switch f.Code {
+		case core.FsckSizeMismatch:
+			err = r.fixSize(f)
+		case core.FsckCorruptHeader, core.FsckCorruptObject:
+			err = r.quarantine(f)
+		case core.FsckOrphanedKeyShare:
+			err = r.removeKeyShare(f)
+		case core.FsckOrphanedVaultKey:
+			err = r.removeVaultKey(f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixSystemFolder creates the missing system folder of a directory.
+func (r *repair) fixSystemFolder(f core.FsckFinding) error {
+	if f.Target == "" {
+		return nil
+	}
+	action := r.newAction(f, "create system folder %s", f.Target)
+	if r.dryRun {
+		return r.add(action)
+	}
+	meta := filepath.Join(f.Path, ".meta")
+	folder := filepath.Join(meta, f.Target)
+	absFolder, err := r.s.abs(folder)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(absFolder); err == nil && !info.IsDir() {
+		// a file is in the way of the folder
+		if err := r.backup(&action, folder); err != nil {
+			return err
+		}
+		if err := os.Remove(absFolder); err != nil {
+			return err
+		}
+	}
+	for _, p := range []string{meta, folder} {
+		absPath, err := r.s.abs(p)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(absPath); os.IsNotExist(err) {
+			if err := os.Mkdir(absPath, os.ModePerm); err != nil {
+				return err
+			}
+			if err := r.created(&action, p); err != nil {
+				return err
+			}
+		}
+	}
+	return r.add(action)
+}
+
+// fixConfig creates the missing configuration file of a directory.
+func (r *repair) fixConfig(f core.FsckFinding) error {
+	action := r.newAction(f, "create configuration file")
+	if r.dryRun {
+		return r.add(action)
+	}
+	if err := r.s.configService.InitConfig(f.Path); err != nil {
+		return err
+	}
+	if err := r.created(&action, filepath.Join(f.Path, ".meta", "config.yaml")); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// fixVaultLink rebuilds the missing vault link of a directory from its vault file.
+// The link is only rebuilt if the directory holds exactly one vault.
+func (r *repair) fixVaultLink(f core.FsckFinding) error {
+	vaults, err := r.s.vaultRepo.ListVaults(f.Path)
+	if err != nil || len(vaults) != 1 {
+		return nil
+	}
+	action := r.newAction(f, "link vault %s", vaults[0])
+	action.Target = vaults[0]
+	if r.dryRun {
+		return r.add(action)
+	}
+	if err := r.s.vaultRepo.RestoreVaultLink(f.Path, vaults[0]); err != nil {
+		// the vault file is unreadable, the finding remains
+		return nil
+	}
+	if err := r.created(&action, filepath.Join(f.Path, ".meta", ".vault", ".link")); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// fixSize sets the size of the link of a file to the size of its decrypted object.
+func (r *repair) fixSize(f core.FsckFinding) error {
+	link, err := r.s.linkRepo.GetByPath(f.Path)
+	if err != nil {
+		return nil
+	}
+	size, err := r.s.objectSize(link)
+	if err != nil {
+		return nil
+	}
+	action := r.newAction(f, "set size from %d to %d", link.Data.Size, size)
+	if r.dryRun {
+		return r.add(action)
+	}
+	if err := r.backup(&action, f.Path); err != nil {
+		return err
+	}
+	link.Data.Size = size
+	if err := r.s.linkRepo.Update(link); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// quarantine moves the unreadable object of a file and its link to the backup folder,
+// removing the file from the repository.
+func (r *repair) quarantine(f core.FsckFinding) error {
+	action := r.newAction(f, "quarantine object %s", f.Target)
+	if r.dryRun {
+		return r.add(action)
+	}
+	object := filepath.Join(filepath.Dir(f.Path), ".meta", ".object", f.Target)
+	absObject, err := r.s.abs(object)
+	if err != nil {
+		return err
+	}
+	if err := r.backup(&action, object, f.Path); err != nil {
+		return err
+	}
+	if err := os.Remove(absObject); err != nil {
+		return err
+	}
+	if err := r.s.linkRepo.Remove(f.Path); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// removeKeyShare removes a key share that is not used by any file or sub directory.
+func (r *repair) removeKeyShare(f core.FsckFinding) error {
+	i := strings.LastIndex(f.Target, "/")
+	if i < 0 {
+		return nil
+	}
+	recipient, keyId := f.Target[:i], f.Target[i+1:]
+	action := r.newAction(f, "remove key %s shared with %s", keyId, recipient)
+	if r.dryRun {
+		return r.add(action)
+	}
+	paths := []string{filepath.Join(f.Path, ".meta", ".key-share", recipient, keyId)}
+	if _, ok := r.s.keyRepo.GetDataKeyExpiry(keyId, recipient, f.Path); ok {
+		paths = append(paths, paths[0]+repositories.DataKeyExpirySuffix)
+	}
+	if err := r.backup(&action, paths...); err != nil {
+		return err
+	}
+	if err := r.s.keyRepo.DeleteDataKey(keyId, recipient, f.Path); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// removeVaultKey removes a vault key that is not used by any file or sub directory.
+func (r *repair) removeVaultKey(f core.FsckFinding) error {
+	vault, err := r.s.vaultRepo.GetVaultByPath(f.Path)
+	if err != nil {
+		return nil
+	}
+	action := r.newAction(f, "remove vault key %s", f.Target)
+	if r.dryRun {
+		return r.add(action)
+	}
+	if err := r.backup(&action, filepath.Join(f.Path, ".meta", ".vault", "."+vault.Id, f.Target)); err != nil {
+		return err
+	}
+	if err := r.s.vaultRepo.RemoveKey(f.Target, vault.Id, f.Path); err != nil {
+		return err
+	}
+	return r.add(action)
+}
+
+// newAction creates the action fixing the finding.
+func (r *repair) newAction(f core.FsckFinding, format string, a ...any) core.RepairAction {
+	return core.RepairAction{
+		Code:        f.Code,
+		Path:        f.Path,
+		Target:      f.Target,
+		Description: fmt.Sprintf(format, a...),
+	}
+}
+
+// add adds the action to the log of the repair. The log is saved after every fix,
+// so that a repair stopped by an error can still be undone.
+func (r *repair) add(action core.RepairAction) error {
+	r.actions = append(r.actions, action)
+	if r.dryRun || r.backupId == "" {
+		return nil
+	}
+	return r.saveLog()
+}
+
+// backup copies the files at the specified paths to the backup folder and records them in the action.
+func (r *repair) backup(action *core.RepairAction, paths ...string) error {
+	if err := r.initBackup(); err != nil {
+		return err
+	}
+	dir := r.s.backupDir(r.backupId)
+	for _, p := range paths {
+		// The path stored on disk is recorded, so the file is restored there even if the names are encrypted
+		stored, err := r.s.linkRepo.ResolvePath(p)
+		if err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(r.s.linkRepo.GetRootPath(), stored), filepath.Join(dir, "files", stored)); err != nil {
+			return err
+		}
+		action.Backup = append(action.Backup, stored)
+	}
+	return nil
+}
+
+// created records the file or folder created at the specified path in the action.
+func (r *repair) created(action *core.RepairAction, path string) error {
+	if err := r.initBackup(); err != nil {
+		return err
+	}
+	stored, err := r.s.linkRepo.ResolvePath(path)
+	if err != nil {
+		return err
+	}
+	action.Created = append(action.Created, stored)
+	return nil
+}
+
+// initBackup creates the backup folder of the repair, named after the current time.
+func (r *repair) initBackup() error {
+	if r.backupId != "" {
+		return nil
+	}
+	id := time.Now().UTC().Format("20060102T150405Z")
+	for i := 2; ; i++ {
+		err := os.MkdirAll(filepath.Dir(r.s.backupDir(id)), os.ModePerm)
+		if err != nil {
+			return err
+		}
+		err = os.Mkdir(r.s.backupDir(id), os.ModePerm)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+		id = fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405Z"), i)
+	}
+	r.backupId = id
+	return nil
+}
+
+// saveLog writes the actions of the repair to the backup folder.
+func (r *repair) saveLog() error {
+	js, err := json.MarshalIndent(r.actions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.s.backupDir(r.backupId), repairLogName), js, 0666)
+}
+
+// objectSize returns the size of the decrypted object of the link.
+func (s *Service) objectSize(link core.Link) (int64, error) {
+	vault, dir, err := s.vaultRepo.GetFileVault(link.Path)
+	if err != nil {
+		return 0, err
+	}
+	keyId, err := s.objectService.GetKeyIdByObjectId(link)
+	if err != nil {
+		return 0, err
+	}
+	key, err := s.keyService.Get(keyId, vault.Id, dir)
+	if err != nil {
+		return 0, err
+	}
+	return s.objectService.VerifyObject(link, key)
+}
+
+// backupDir returns the absolute path of the backup folder with the specified id.
+func (s *Service) backupDir(backupId string) string {
+	return filepath.Join(s.linkRepo.GetRootPath(), ".meta", ".backup", backupId)
+}
+
+// abs returns the absolute path stored on disk for the repository path.
+func (s *Service) abs(path string) (string, error) {
+	return s.linkRepo.AbsPath(path)
+}
+
+// copyFile copies the file at src to dst, creating the parent folders of dst.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
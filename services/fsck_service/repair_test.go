@@ -0,0 +1,107 @@
+package fsck_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/services/fsck_service"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// findingKeys returns the code, path and target of the findings of the report, sorted.
+func findingKeys(report core.FsckReport) []string {
+	keys := make([]string, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		keys = append(keys, f.Code+" "+f.Path+" "+f.Target)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestRepairAndRestore(t *testing.T) {
+	f := newFixture(t)
+	f.WriteFile(t, "a/other", []byte("other"))
+	otherObjectId := readJSON(t, f.abs("a/other"), "objectId")
+	owner := f.Owner(t)
+
+	// Damage the repository with one problem of every kind the repair fixes
+	must(t, os.Remove(f.abs("a/.meta/config.yaml")))
+	must(t, os.Remove(f.abs("a/.meta/.vault/.link")))
+	must(t, os.WriteFile(f.abs("a/file"), []byte(`{"objectId":"`+f.objectId+`","size":99}`), 0644))
+	object := f.abs("a/.meta/.object/" + otherObjectId)
+	b, err := os.ReadFile(object)
+	must(t, err)
+	b[len(b)-1] ^= 0xff
+	must(t, os.WriteFile(object, b, 0644))
+	shares := f.abs(".meta/.key-share/" + owner.Id)
+	copyFile(t, filepath.Join(shares, onlyEntry(t, shares)), filepath.Join(shares, "unused"))
+	keys := f.abs("a/.meta/.vault/." + f.vaultId)
+	copyFile(t, filepath.Join(keys, f.fileKeyId), filepath.Join(keys, "unused"))
+
+	// The structural problems hide the others until they are fixed
+	damaged, err := owner.Fsck.Check(true)
+	must(t, err)
+	// The target is checked for the orphans only, the key of the quarantined object is orphaned by the repair
+	fixes := []struct{ code, path, target string }{
+		{core.FsckMissingConfig, "/a", ""},
+		{core.FsckMissingVaultLink, "/a", ""},
+		{core.FsckSizeMismatch, "/a/file", ""},
+		{core.FsckCorruptObject, "/a/other", ""},
+		{core.FsckOrphanedKeyShare, "/", owner.Id + "/unused"},
+		{core.FsckOrphanedVaultKey, "/a", "unused"},
+	}
+
+	// The dry run reports the fixes without changing anything
+	plan, err := owner.Fsck.Repair(true, true)
+	must(t, err)
+	if plan.BackupId != "" {
+		t.Errorf("the dry run created the backup %s", plan.BackupId)
+	}
+	if after, err := owner.Fsck.Check(true); err != nil || !slices.Equal(findingKeys(after), findingKeys(damaged)) {
+		t.Fatalf("the dry run changed the repository, got findings:\n%s", after)
+	}
+
+	// The repair fixes every problem and backs up what it changes
+	report, err := owner.Fsck.Repair(true, false)
+	must(t, err)
+	if report.BackupId == "" {
+		t.Fatal("the repair has no backup")
+	}
+	for _, fix := range fixes {
+		matches := func(code, path, target string) bool {
+			return code == fix.code && path == fix.path && (fix.target == "" || target == fix.target)
+		}
+		if !slices.ContainsFunc(report.Actions, func(a core.RepairAction) bool { return matches(a.Code, a.Path, a.Target) }) {
+			t.Errorf("no %s fix at %s:\n%s", fix.code, fix.path, report)
+		}
+		if slices.ContainsFunc(report.Remaining.Findings, func(f core.FsckFinding) bool { return matches(f.Code, f.Path, f.Target) }) {
+			t.Errorf("%s at %s remains after the repair", fix.code, fix.path)
+		}
+	}
+	fsys := f.OpenFS(t)
+	if got, err := fs.ReadFile(fsys, "a/file"); err != nil || string(got) != "content" {
+		t.Errorf("got %q, %v after the repair, want \"content\"", got, err)
+	}
+	if _, err := fs.Stat(fsys, "a/other"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the file of the unreadable object is not quarantined: %v", err)
+	}
+	must(t, fsys.Close())
+
+	// Restoring the backup brings the repository back to its damaged state
+	must(t, owner.Fsck.Restore(report.BackupId))
+	restored, err := owner.Fsck.Check(true)
+	must(t, err)
+	if !slices.Equal(findingKeys(restored), findingKeys(damaged)) {
+		t.Fatalf("got findings after the restore:\n%s\nwant:\n%s", restored, damaged)
+	}
+	if got, err := os.ReadFile(object); err != nil || !slices.Equal(got, b) {
+		t.Errorf("the quarantined object is not restored: %v", err)
+	}
+
+	if err := owner.Fsck.Restore("unknown"); !errors.Is(err, fsck_service.ErrBackupNotFound) {
+		t.Errorf("got %v for an unknown backup, want ErrBackupNotFound", err)
+	}
+}
@@ -0,0 +1,291 @@
+package group_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"ctb-cli/services/key_service"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidGroupName    = errors.New("the group name must only contain letters, digits, '.', '_' and '-'")
+	ErrGroupExists         = errors.New("a group with the same name already exists")
+	ErrUnknownGroup        = errors.New("unknown group")
+	ErrUnverifiedGroup     = errors.New("the group is not signed by a member of the repository")
+	ErrAlreadyGroupMember  = errors.New("the user is already a member of the group")
+	ErrRemoveLastGroupUser = errors.New("cannot remove the last member of the group")
+)
+
+var groupNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Service manages the groups of the repository.
+//
+// Every group is signed by the member who created it, and only the groups signed by a verified member
+// are used to resolve recipients. Removing a member from a group replaces the key pair of the group, but does not change
+// the data keys the member may have kept a copy of, rotate the keys shared with the group to revoke them.
+type Service struct {
+	keyService    core.KeyService
+	groupRepo     repositories.GroupRepository
+	memberChecker core.MemberChecker
+	auditLogger   core.AuditLogger
+}
+
+// NewService creates a new instance of the group service.
+func NewService(
+	keyService core.KeyService,
+	groupRepo repositories.GroupRepository,
+	memberChecker core.MemberChecker,
+	auditLogger core.AuditLogger,
+) *Service {
+	return &Service{
+		keyService:    keyService,
+		groupRepo:     groupRepo,
+		memberChecker: memberChecker,
+		auditLogger:   auditLogger,
+	}
+}
+
+// Create creates a group with the given name, with the current user as its only member.
+func (s *Service) Create(name string) (core.GroupEntry, error) {
+	name = strings.TrimPrefix(name, core.GroupPrefix)
+	if !groupNameRegexp.MatchString(name) {
+		return core.GroupEntry{}, ErrInvalidGroupName
+	}
+	if _, err := s.find(name); err == nil {
+		return core.GroupEntry{}, ErrGroupExists
+	} else if !errors.Is(err, ErrUnknownGroup) {
+		return core.GroupEntry{}, err
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.GroupEntry{}, err
+	}
+	publicKey, err := s.keyService.CreateGroupKey()
+	if err != nil {
+		return core.GroupEntry{}, err
+	}
+	group := core.Group{
+		Name:      name,
+		PublicKey: publicKey.String(),
+		CreatedBy: userId,
+		CreatedAt: time.Now().UTC(),
+	}
+	sig, err := s.keyService.Sign(group.SignedMessage())
+	if err != nil {
+		return core.GroupEntry{}, err
+	}
+	group.Signature = signature.Encode(sig)
+	if err := s.groupRepo.SaveGroup(group); err != nil {
+		return core.GroupEntry{}, err
+	}
+	if err := s.auditLogger.Log(core.AuditCreateGroup, core.GroupPrefix+name, group.PublicKey, ""); err != nil {
+		return core.GroupEntry{}, err
+	}
+	return core.GroupEntry{Group: group, Members: []string{userId}, Verified: true}, nil
+}
+
+// AddMember adds the user with the public key to the group. The current user must be a member of the group.
+func (s *Service) AddMember(name string, publicKey string) error {
+	group, err := s.find(name)
+	if err != nil {
+		return err
+	}
+	recipient, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return err
+	}
+	if s.groupRepo.MemberKeyExist(group.PublicKey, publicKey) {
+		return ErrAlreadyGroupMember
+	}
+	if err := s.keyService.ShareGroupKey(group.PublicKey, recipient, publicKey); err != nil {
+		return err
+	}
+	return s.auditLogger.Log(core.AuditAddGroupMember, core.GroupPrefix+group.Name, publicKey, "")
+}
+
+// RemoveMember removes the user with the public key from the group and replaces the key pair of the group,
+// so that the user cannot read the keys shared with the group afterwards. The current user must be a member of the group,
+// and the last member of a group cannot be removed. It returns the new id of the group.
+func (s *Service) RemoveMember(name string, publicKey string) (string, error) {
+	group, err := s.find(name)
+	if err != nil {
+		return "", err
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return "", err
+	}
+	if !s.groupRepo.MemberKeyExist(group.PublicKey, userId) {
+		return "", key_service.ErrNotGroupMember
+	}
+	if !s.groupRepo.MemberKeyExist(group.PublicKey, publicKey) {
+		return "", key_service.ErrNotGroupMember
+	}
+	members, err := s.groupRepo.ListMembers(group.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 1 {
+		return "", ErrRemoveLastGroupUser
+	}
+	return s.RemoveAndRotate(group.PublicKey, publicKey)
+}
+
+// RemoveAndRotate removes the user from the group with the specified id and replaces the key pair of the group,
+// so that the keys shared with the group afterwards are not readable by the user. The data keys shared with the group
+// are shared with the new key pair, the current user must have access to them. The group is signed again by the current user.
+// If the user was the last member, the group and the data keys shared with it are removed.
+// It returns the new id of the group, empty if the group was removed.
+func (s *Service) RemoveAndRotate(groupId string, publicKey string) (string, error) {
+	group, err := s.groupRepo.GetGroup(groupId)
+	if err != nil {
+		return "", err
+	}
+	members, err := s.groupRepo.ListMembers(groupId)
+	if err != nil {
+		return "", err
+	}
+	remaining := make([]string, 0, len(members))
+	for _, member := range members {
+		if member != publicKey {
+			remaining = append(remaining, member)
+		}
+	}
+	if len(remaining) == len(members) {
+		return "", key_service.ErrNotGroupMember
+	}
+	newPublicKey, err := s.keyService.RotateGroupKey(groupId, remaining)
+	if err != nil {
+		return "", err
+	}
+	newGroupId := ""
+	if len(remaining) > 0 {
+		userId, err := s.keyService.GetUserId()
+		if err != nil {
+			return "", err
+		}
+		rotated := core.Group{
+			Name:      group.Name,
+			PublicKey: newPublicKey.String(),
+			CreatedBy: userId,
+			CreatedAt: time.Now().UTC(),
+		}
+		sig, err := s.keyService.Sign(rotated.SignedMessage())
+		if err != nil {
+			return "", err
+		}
+		rotated.Signature = signature.Encode(sig)
+		if err := s.groupRepo.SaveGroup(rotated); err != nil {
+			return "", err
+		}
+		newGroupId = rotated.PublicKey
+	}
+	if err := s.groupRepo.RemoveGroup(groupId); err != nil {
+		return "", err
+	}
+	if err := s.auditLogger.Log(core.AuditRemoveGroupMember, core.GroupPrefix+group.Name, publicKey, ""); err != nil {
+		return "", err
+	}
+	return newGroupId, s.auditLogger.Log(core.AuditRotateGroupKey, core.GroupPrefix+group.Name, newGroupId, "")
+}
+
+// List returns the groups with their members and verification, sorted by name.
+func (s *Service) List() (core.GroupList, error) {
+	groups, err := s.groupRepo.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+	list := make(core.GroupList, 0, len(groups))
+	for _, group := range groups {
+		members, err := s.groupRepo.ListMembers(group.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, core.GroupEntry{Group: group, Members: members, Verified: s.isVerified(group)})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Resolve returns the public key of the group with the given name, with or without the group prefix.
+func (s *Service) Resolve(name string) (string, error) {
+	group, err := s.find(name)
+	if err != nil {
+		return "", err
+	}
+	return group.PublicKey, nil
+}
+
+// NameAccessList sets the name of the verified groups in the access list.
+func (s *Service) NameAccessList(list core.KeyAccessList) error {
+	names, err := s.names()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if name, ok := names[list[i].PublicKey]; ok {
+			list[i].Name = name
+		}
+	}
+	return nil
+}
+
+// NameAccessReport sets the name of the verified groups in the access lists of the report.
+func (s *Service) NameAccessReport(report core.AccessReport) error {
+	for _, p := range report {
+		if err := s.NameAccessList(p.Access); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find returns the verified group with the given name, with or without the group prefix.
+// Groups with the same name not signed by a member are ignored.
+func (s *Service) find(name string) (core.Group, error) {
+	name = strings.TrimPrefix(name, core.GroupPrefix)
+	groups, err := s.groupRepo.ListGroups()
+	if err != nil {
+		return core.Group{}, err
+	}
+	found := false
+	for _, group := range groups {
+		if group.Name != name {
+			continue
+		}
+		if s.isVerified(group) {
+			return group, nil
+		}
+		found = true
+	}
+	if found {
+		return core.Group{}, ErrUnverifiedGroup
+	}
+	return core.Group{}, ErrUnknownGroup
+}
+
+// names returns the prefixed names of the verified groups by public key.
+func (s *Service) names() (map[string]string, error) {
+	groups, err := s.groupRepo.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(groups))
+	for _, group := range groups {
+		if s.isVerified(group) {
+			names[group.PublicKey] = core.GroupPrefix + group.Name
+		}
+	}
+	return names, nil
+}
+
+// isVerified returns true if the group is signed by a verified member of the repository.
+func (s *Service) isVerified(group core.Group) bool {
+	return signature.VerifyEncoded(group.CreatedBy, group.SignedMessage(), group.Signature) &&
+		s.memberChecker.IsMember(group.CreatedBy)
+}
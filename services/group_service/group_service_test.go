@@ -0,0 +1,40 @@
+package group_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/test/testrepo"
+	"slices"
+	"testing"
+)
+
+func TestRemoveMemberRotatesGroupKey(t *testing.T) {
+	repo := testrepo.New(t)
+	owner := repo.Owner(t)
+	alice := repo.Join(t, owner, core.MemberRoleMember, "")
+	group, err := owner.Group.Create("team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.Group.AddMember("team", alice.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	groupId, err := owner.Group.RemoveMember("team", alice.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupId == "" || groupId == group.PublicKey {
+		t.Fatalf("got group id %q, want a new key pair replacing %s", groupId, group.PublicKey)
+	}
+	groups, err := owner.Group.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].PublicKey != groupId || !groups[0].Verified || !slices.Equal(groups[0].Members, []string{owner.Id}) {
+		t.Fatalf("got groups %v, want the rotated group with the owner only", groups)
+	}
+	if userGroups, err := repositories.NewGroupRepositoryFile(repo.Path).ListUserGroups(alice.Id); err != nil || len(userGroups) != 0 {
+		t.Errorf("the user still holds the key of groups %v: %v", userGroups, err)
+	}
+}
@@ -0,0 +1,252 @@
+package invite_service
+
+import (
+	"crypto/rand"
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"ctb-cli/services/member_service"
+	"ctb-cli/services/share_service"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCode     = errors.New("the invitation code or passphrase is invalid")
+	ErrInviteRedeemed  = errors.New("the invitation is already redeemed")
+	ErrInviteExpired   = errors.New("the invitation is expired")
+	ErrInvalidInvite   = errors.New("the invitation is not signed by a member of the repository")
+	ErrMissingPassword = errors.New("the passphrase is required to redeem an invitation without code")
+)
+
+// codeSeparator separates the id of the invitation from its secret in the code given to the invitee.
+const codeSeparator = "-"
+
+// Service manages the invitations of the repository.
+//
+// An invitation gives access to a file or directory to a user who has no key yet. The key of the file or directory
+// is sealed to a key pair derived from a secret, which the invitee gives back with their new public key
+// to get the key shared with them and to join the repository as a guest. An invitation can be redeemed once.
+type Service struct {
+	keyService    core.KeyService
+	memberRepo    *repositories.MemberRepository
+	memberService *member_service.Service
+	shareService  *share_service.Service
+	auditLogger   core.AuditLogger
+	pathEncoder   core.PathEncoder // nil if the paths are stored as they are
+}
+
+// NewService creates a new instance of the invite service.
+func NewService(
+	keyService core.KeyService,
+	memberRepo *repositories.MemberRepository,
+	memberService *member_service.Service,
+	shareService *share_service.Service,
+	auditLogger core.AuditLogger,
+) *Service {
+	return &Service{
+		keyService:    keyService,
+		memberRepo:    memberRepo,
+		memberService: memberService,
+		shareService:  shareService,
+		auditLogger:   auditLogger,
+	}
+}
+
+// SetPathEncoder sets the encoder of the paths of the files and directories, which are stored in the invitations
+// as they are stored on disk so that the invitations do not hold the names of a repository encrypting them.
+func (s *Service) SetPathEncoder(pathEncoder core.PathEncoder) {
+	s.pathEncoder = pathEncoder
+}
+
+// Create creates an invitation to the file or directory at the specified path, expiring at the given time.
+// If passphrase is empty, the invitation is protected by a random secret and the returned code holds the id and the secret,
+// otherwise the invitee needs the id of the invitation and the passphrase.
+// The current user must be a verified member of the repository, and not a guest.
+// The key pair of an invitation is an X25519 key pair, so invitations cannot be created in a repository requiring hybrid keys.
+func (s *Service) Create(path string, passphrase string, expiresAt time.Time) (core.InviteCode, error) {
+	if err := s.keyService.CheckX25519Recipient(); err != nil {
+		return core.InviteCode{}, err
+	}
+	if err := s.memberService.CheckInviter(); err != nil {
+		return core.InviteCode{}, err
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	path = filepath.Join(string(filepath.Separator), path)
+	keyId, startVaultId, startVaultPath, err := s.shareService.GetKeyIdByPath(path)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	key, err := s.keyService.Get(keyId, startVaultId, startVaultPath)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	id, err := randomString(12)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	secret := passphrase
+	if secret == "" {
+		if secret, err = randomString(16); err != nil {
+			return core.InviteCode{}, err
+		}
+	}
+	// Seal the key to the key pair derived from the secret
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return core.InviteCode{}, err
+	}
+	inviteKey, err := key_crypto.DerivePrivateKey(secret, salt)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	invitePublicKey, err := inviteKey.ToPublicKey()
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	sealedKey, err := key_crypto.SealDataKey(key.Key, invitePublicKey)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	storedPath, err := s.encodePath(path)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	storedSharePath, err := s.encodePath(startVaultPath)
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	invite := core.Invite{
+		Id:        id,
+		Path:      storedPath,
+		KeyId:     keyId,
+		SharePath: storedSharePath,
+		PublicKey: invitePublicKey.String(),
+		Salt:      base64.RawStdEncoding.EncodeToString(salt),
+		SealedKey: sealedKey,
+		CreatedBy: userId,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+	sig, err := s.keyService.Sign(invite.SignedMessage())
+	if err != nil {
+		return core.InviteCode{}, err
+	}
+	invite.Signature = signature.Encode(sig)
+	if err := s.memberRepo.SaveInvite(invite); err != nil {
+		return core.InviteCode{}, err
+	}
+	if err := s.auditLogger.Log(core.AuditInvite, path, id, keyId); err != nil {
+		return core.InviteCode{}, err
+	}
+	code := core.InviteCode{Id: id, Path: path, ExpiresAt: invite.ExpiresAt}
+	if passphrase == "" {
+		code.Code = id + codeSeparator + secret
+	}
+	return code, nil
+}
+
+// Redeem redeems the invitation given by its code, or by its id and passphrase: the key of the file or directory
+// is shared with the current user, who joins the repository as a guest with the given name, unless already a member.
+// The sealed key is removed from the invitation, so that it cannot be redeemed again.
+// The paths of the returned invitation are the paths stored on disk.
+func (s *Service) Redeem(code string, passphrase string, name string) (core.Invite, error) {
+	id, secret := code, passphrase
+	if secret == "" {
+		var ok bool
+		if id, secret, ok = strings.Cut(code, codeSeparator); !ok {
+			return core.Invite{}, ErrMissingPassword
+		}
+	}
+	invite, err := s.memberRepo.GetInvite(id)
+	if err != nil {
+		return core.Invite{}, err
+	}
+	if invite.RedeemedBy != "" || invite.SealedKey == "" {
+		return core.Invite{}, ErrInviteRedeemed
+	}
+	if !invite.ExpiresAt.After(time.Now()) {
+		return core.Invite{}, ErrInviteExpired
+	}
+	if !signature.VerifyEncoded(invite.CreatedBy, invite.SignedMessage(), invite.Signature) || !s.memberService.IsMember(invite.CreatedBy) {
+		return core.Invite{}, ErrInvalidInvite
+	}
+	// Open the key with the key pair derived from the secret
+	salt, err := base64.RawStdEncoding.DecodeString(invite.Salt)
+	if err != nil {
+		return core.Invite{}, ErrInvalidInvite
+	}
+	inviteKey, err := key_crypto.DerivePrivateKey(secret, salt)
+	if err != nil {
+		return core.Invite{}, ErrInvalidCode
+	}
+	if invitePublicKey, err := inviteKey.ToPublicKey(); err != nil || invitePublicKey.String() != invite.PublicKey {
+		return core.Invite{}, ErrInvalidCode
+	}
+	key, err := key_crypto.OpenDataKey(invite.SealedKey, inviteKey)
+	if err != nil {
+		return core.Invite{}, ErrInvalidCode
+	}
+	// Share the key with the user and record the redemption
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.Invite{}, err
+	}
+	// The key is saved at the stored path, the invitee cannot decrypt the names of the parent directories
+	keyInfo := core.NewKeyInfo(invite.KeyId, *key)
+	if err := s.keyService.InsertAtStoredPath(&keyInfo, invite.SharePath); err != nil {
+		return core.Invite{}, err
+	}
+	invite.SealedKey = ""
+	invite.RedeemedBy = userId
+	invite.RedeemedAt = time.Now().UTC()
+	if err := s.memberRepo.SaveInvite(invite); err != nil {
+		return core.Invite{}, err
+	}
+	if !s.memberService.IsMember(userId) {
+		if err := s.memberService.AddGuest(invite, inviteKey, name); err != nil {
+			return core.Invite{}, err
+		}
+	}
+	// The path is not logged if the user cannot decode it, without access to the parent directory of the file or directory
+	path, err := s.decodePath(invite.Path)
+	if err != nil {
+		path = ""
+	}
+	if err := s.auditLogger.Log(core.AuditRedeemInvite, path, userId, invite.KeyId); err != nil {
+		return core.Invite{}, err
+	}
+	return invite, nil
+}
+
+// encodePath returns the path stored on disk for the path of the file or directory.
+func (s *Service) encodePath(path string) (string, error) {
+	if s.pathEncoder == nil {
+		return path, nil
+	}
+	return s.pathEncoder.EncodePath(path)
+}
+
+// decodePath returns the path of the file or directory stored on disk as stored.
+func (s *Service) decodePath(stored string) (string, error) {
+	if s.pathEncoder == nil {
+		return stored, nil
+	}
+	return s.pathEncoder.DecodePath(stored)
+}
+
+// randomString returns a random string of the given number of bytes, encoded in base58.
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return core.EncodeUid(b)
+}
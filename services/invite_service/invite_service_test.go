@@ -0,0 +1,111 @@
+package invite_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/services/invite_service"
+	"ctb-cli/test/testrepo"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedeemWithEncryptedNames(t *testing.T) {
+	repo := testrepo.NewWithEncryptedNames(t, "a", "a/b")
+	repo.WriteFile(t, "a/b/file", []byte("content"))
+	owner := repo.Owner(t)
+	code, err := owner.Invite.Create("/a/b", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The invitee has no access to the name keys of the parent directories
+	invitee := repo.Open(t, testrepo.NewUserKey(t))
+	invite, err := invitee.Invite.Redeem(code.Code, "", "guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invite.RedeemedBy != invitee.Id {
+		t.Fatalf("got invitation redeemed by %q, want %s", invite.RedeemedBy, invitee.Id)
+	}
+
+	// The key is shared with the invitee at the path of the directory the key was shared at
+	sharePath, err := owner.Names.DecodePath(invite.SharePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sharePath == invite.SharePath {
+		t.Fatalf("the path %s is stored in plaintext", sharePath)
+	}
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	keyRepository.SetPathResolver(owner.Names)
+	if !keyRepository.DataKeyExist(invite.KeyId, invitee.Id, sharePath) {
+		t.Errorf("the key is not shared with the invitee at %s", sharePath)
+	}
+	if !owner.Member.IsMember(invitee.Id) {
+		t.Error("the invitee did not join the repository")
+	}
+}
+
+func TestRedeemRejectsInvalidInvitations(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner := repo.Owner(t)
+	newCode := func(expiresAt time.Time) core.InviteCode {
+		t.Helper()
+		code, err := owner.Invite.Create("/a", "", expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	redeem := func(code string) error {
+		t.Helper()
+		_, err := repo.Open(t, testrepo.NewUserKey(t)).Invite.Redeem(code, "", "guest")
+		return err
+	}
+
+	t.Run("wrong code", func(t *testing.T) {
+		code := newCode(time.Now().Add(time.Hour))
+		if err := redeem(code.Id + "-wrong"); !errors.Is(err, invite_service.ErrInvalidCode) {
+			t.Fatalf("got %v, want ErrInvalidCode", err)
+		}
+		// The invitation can still be redeemed with its code
+		if err := redeem(code.Code); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("second redemption", func(t *testing.T) {
+		code := newCode(time.Now().Add(time.Hour))
+		if err := redeem(code.Code); err != nil {
+			t.Fatal(err)
+		}
+		if err := redeem(code.Code); !errors.Is(err, invite_service.ErrInviteRedeemed) {
+			t.Fatalf("got %v, want ErrInviteRedeemed", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		code := newCode(time.Now().Add(-time.Minute))
+		if err := redeem(code.Code); !errors.Is(err, invite_service.ErrInviteExpired) {
+			t.Fatalf("got %v, want ErrInviteExpired", err)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		code := newCode(time.Now().Add(time.Hour))
+		// The expiry is extended without the invitation being signed again
+		memberRepository := repositories.NewMemberRepository(repo.Path)
+		invite, err := memberRepository.GetInvite(code.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		invite.ExpiresAt = invite.ExpiresAt.AddDate(1, 0, 0)
+		if err := memberRepository.SaveInvite(invite); err != nil {
+			t.Fatal(err)
+		}
+		if err := redeem(code.Code); !errors.Is(err, invite_service.ErrInvalidInvite) {
+			t.Fatalf("got %v, want ErrInvalidInvite", err)
+		}
+	})
+}
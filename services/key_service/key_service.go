@@ -3,9 +3,12 @@ package key_service
 import (
 	"ctb-cli/core"
 	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/signature"
 	"ctb-cli/repositories"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"golang.org/x/crypto/curve25519"
 )
@@ -13,9 +16,14 @@ import (
 var (
 	ErrInvalidPrivateKeyOrUserNotJoined = errors.New("invalid private key or user not joined")
 	ErrDataKeyNotFound                  = errors.New("data key not found")
-	ErrUserAlreadyJoined                = errors.New("user already joined")
 	ErrGeneratingVaultId                = errors.New("error generating vault id")
 	ErrGeneratingKey                    = errors.New("error generating key")
+	ErrNotGroupMember                   = errors.New("the user is not a member of the group")
+	ErrRootVault                        = errors.New("the root vault has no parent vault")
+	ErrHybridRecipientRequired          = errors.New("the repository requires hybrid keys and the recipient has no ML-KEM-768 public key")
+	ErrGroupsNotHybrid                  = errors.New("group keys are sealed with X25519 only, the repository requires hybrid keys")
+	ErrX25519Recipient                  = errors.New("the recipient is sealed with X25519 only, the repository requires hybrid keys")
+	ErrNameRotatorNotSet                = errors.New("the names of the vault are encrypted and no name rotator is set to rename its entries")
 )
 
 // KeyStoreDefault represents a key store
@@ -23,16 +31,25 @@ type KeyStoreDefault struct {
 	privateKey      core.PrivateKey
 	keyRepository   repositories.KeyRepository
 	vaultRepository repositories.VaultRepository
+	groupRepository repositories.GroupRepository // nil if keys are not shared with groups
+	auditLogger     core.AuditLogger             // nil if the changes are not audited
+	kemKeyResolver  core.KemKeyResolver          // nil if the data keys are sealed to other users with X25519 only
+	namePolicy      core.NamePolicy              // nil if the names of the files and directories are not encrypted
+	memberChecker   core.MemberChecker           // nil if the signers of the share expiries are not checked to be members
+	hybridPolicy    core.HybridPolicy            // nil if the data keys may be sealed with X25519 only
+	nameRotator     core.NameRotator             // nil if the entries are not renamed when a name key is replaced
+	expiryLog       core.ExpiryLog               // nil if the expiries of the shares are only recorded next to the data keys
 }
 
 // Ensure KeyStoreDefault implements KeyService
 var _ core.KeyService = &KeyStoreDefault{}
 
 // NewKeyStore creates a new instance of KeyStoreDefault
-func NewKeyStore(keyRepository repositories.KeyRepository, vaultRepository repositories.VaultRepository) *KeyStoreDefault {
+func NewKeyStore(keyRepository repositories.KeyRepository, vaultRepository repositories.VaultRepository, groupRepository repositories.GroupRepository) *KeyStoreDefault {
 	return &KeyStoreDefault{
 		keyRepository:   keyRepository,
 		vaultRepository: vaultRepository,
+		groupRepository: groupRepository,
 	}
 }
 
@@ -41,6 +58,48 @@ func (ks *KeyStoreDefault) SetPrivateKey(privateKey core.PrivateKey) {
 	ks.privateKey = privateKey
 }
 
+// SetAuditLogger sets the logger recording the vaults created and moved by the key store.
+func (ks *KeyStoreDefault) SetAuditLogger(auditLogger core.AuditLogger) {
+	ks.auditLogger = auditLogger
+}
+
+// SetKemKeyResolver sets the resolver of the ML-KEM-768 public keys of the members,
+// the data keys shared with a member with a hybrid key are sealed with both X25519 and ML-KEM-768.
+func (ks *KeyStoreDefault) SetKemKeyResolver(kemKeyResolver core.KemKeyResolver) {
+	ks.kemKeyResolver = kemKeyResolver
+}
+
+// SetMemberChecker sets the checker of the members who sign the expiries of the shares.
+func (ks *KeyStoreDefault) SetMemberChecker(memberChecker core.MemberChecker) {
+	ks.memberChecker = memberChecker
+}
+
+// SetHybridPolicy sets the policy telling whether the data keys must be sealed with both X25519 and ML-KEM-768.
+func (ks *KeyStoreDefault) SetHybridPolicy(hybridPolicy core.HybridPolicy) {
+	ks.hybridPolicy = hybridPolicy
+}
+
+// SetNamePolicy sets the policy telling whether the names of the files and directories are encrypted,
+// the vaults created when they are get a name key.
+func (ks *KeyStoreDefault) SetNamePolicy(namePolicy core.NamePolicy) {
+	ks.namePolicy = namePolicy
+}
+
+// SetNameRotator sets the rotator renaming the entries of a vault whose name key is replaced with its vault key.
+func (ks *KeyStoreDefault) SetNameRotator(nameRotator core.NameRotator) {
+	ks.nameRotator = nameRotator
+}
+
+// SetExpiryLog sets the log recording the expiries of the shares, where an expiry removed from the repository is still found.
+func (ks *KeyStoreDefault) SetExpiryLog(expiryLog core.ExpiryLog) {
+	ks.expiryLog = expiryLog
+}
+
+// Sign signs the message with the private key of the user.
+func (ks *KeyStoreDefault) Sign(message []byte) ([]byte, error) {
+	return signature.Sign(ks.privateKey, message)
+}
+
 // GetUserId returns the user ID associated with the key store.
 // It retrieves the user's public key and encodes it to obtain the user ID.
 func (ks *KeyStoreDefault) GetUserId() (string, error) {
@@ -56,33 +115,53 @@ func (ks *KeyStoreDefault) GetUserId() (string, error) {
 
 // Insert inserts a new key into the key store.
 // It first retrieves the user's public key using the GetPublicKey method.
-// Then, it seals the key with the user's public key using the SealDataKey method.
+// Then, it seals the key with the user's public key.
 // Next, it retrieves the user's ID using the GetUserId method.
 // Finally, it saves the key in the user's data keys using the SaveDataKey method.
 // If any error occurs during the process, it is returned.
 func (ks *KeyStoreDefault) Insert(key *core.KeyInfo, path string) error {
+	keyHashed, userId, err := ks.sealForUser(key)
+	if err != nil {
+		return err
+	}
+	// Save key in user's data keys
+	return ks.keyRepository.SaveDataKey(key.Id, keyHashed, userId, path)
+}
+
+// InsertAtStoredPath inserts the key in the data keys of the user in the directory at the path stored on disk,
+// for a user who cannot resolve the path, without access to the name keys of the parent directories.
+func (ks *KeyStoreDefault) InsertAtStoredPath(key *core.KeyInfo, storedPath string) error {
+	keyHashed, userId, err := ks.sealForUser(key)
+	if err != nil {
+		return err
+	}
+	return ks.keyRepository.SaveDataKeyAtStoredPath(key.Id, keyHashed, userId, storedPath)
+}
+
+// sealForUser seals the key with the public key of the user, and returns it with the id of the user.
+func (ks *KeyStoreDefault) sealForUser(key *core.KeyInfo) (string, string, error) {
 	// Get user public key
 	pk, err := ks.GetPublicKey()
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	// Seal key with user public key
-	keyHashed, err := key_crypto.SealDataKey(key.Key, pk)
+	keyHashed, err := ks.newSealer().seal(key.Key, pk)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	// Get user id
 	userId, err := ks.GetUserId()
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	// Save key in user's data keys
-	return ks.keyRepository.SaveDataKey(key.Id, keyHashed, userId, path)
+	return keyHashed, userId, nil
 }
 
 // Get retrieves a key from the KeyStoreDefault.
 // It takes a keyId and a startVaultId as parameters.
 // If the key exists in the user's data keys, it returns the key in KeyInfo format.
+// If the key exists in the data keys of a group the user is a member of, it is unsealed with the group private key.
 // If the key does not exist in the user's data keys, it checks if it exists in the provided vault.
 // If the key is found in the vault, it recursively calls the Get method to retrieve the vault key.
 // It then retrieves the encrypted data key from the vault and unseals it using the vault key.
@@ -94,7 +173,7 @@ func (ks *KeyStoreDefault) Get(keyId string, startVaultId string, startVaultPath
 		return nil, err
 	}
 	// Check if key directly exists in user's data keys
-	if ks.keyRepository.DataKeyExist(keyId, userId, startVaultPath) {
+	if ks.hasActiveDataKey(keyId, userId, startVaultPath) {
 		// Get key from user's data keys
 		sk, err := ks.keyRepository.GetDataKey(keyId, userId, startVaultPath)
 		if err != nil {
@@ -109,6 +188,20 @@ func (ks *KeyStoreDefault) Get(keyId string, startVaultId string, startVaultPath
 		keyInfo := core.NewKeyInfo(keyId, *key)
 		return &keyInfo, nil
 	}
+	// Check if key is shared with a group of the user, trying the next group if the key cannot be opened with one
+	if groups := ks.findGroupsWithKey(keyId, userId, startVaultPath); len(groups) > 0 {
+		var errs []error
+		for _, groupId := range groups {
+			key, err := ks.getGroupDataKey(keyId, groupId, userId, startVaultPath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			keyInfo := core.NewKeyInfo(keyId, *key)
+			return &keyInfo, nil
+		}
+		return nil, errors.Join(errs...)
+	}
 	// If key does not exist in user's data keys, check if it exists in a vault
 	// If startVaultId is not provided, return key not found
 	if startVaultId == "" {
@@ -148,36 +241,300 @@ func (ks *KeyStoreDefault) Get(keyId string, startVaultId string, startVaultPath
 // It also checks if the access is inherited from a vault or directly from the user's data keys.
 // It first checks if the key directly exists in the user's data keys.
 // If the key exists, it returns true and false for `hasAccess` and `inherited` respectively.
+// If the key is shared with a group the user is a member of, the access is reported as inherited.
 // If the key does not exist in the user's data keys, it checks if it exists in a vault.
 // If the key exists in a vault, it recursively calls `GetHasAccessToKey` to check if the user has access to the vault key.
 // It returns the result of the recursive call and true for `inherited`.
+// Expired data keys do not give access.
 func (ks *KeyStoreDefault) GetHasAccessToKey(keyId string, startVaultId string, startVaultPath string, userId string) (hasAccess bool, inherited bool) {
+	hasAccess, inherited, _ = ks.getAccessToKey(keyId, startVaultId, startVaultPath, userId)
+	return hasAccess, inherited
+}
+
+// getAccessToKey checks if a user has access to a specific key as GetHasAccessToKey does,
+// and also returns the time the data key giving the access expires, or nil if it does not expire.
+func (ks *KeyStoreDefault) getAccessToKey(keyId string, startVaultId string, startVaultPath string, userId string) (hasAccess bool, inherited bool, expiresAt *time.Time) {
 	// Check if key directly exists in user's data keys
-	if ks.keyRepository.DataKeyExist(keyId, userId, startVaultPath) {
-		// Get key from user's data keys
-		exc := ks.keyRepository.DataKeyExist(keyId, userId, startVaultPath)
-		if exc == true {
-			return true, false
-		}
+	if ks.hasActiveDataKey(keyId, userId, startVaultPath) {
+		return true, false, ks.getExpiry(keyId, userId, startVaultPath)
+	}
+	// Check if key is shared with a group of the user
+	if groups := ks.findGroupsWithKey(keyId, userId, startVaultPath); len(groups) > 0 {
+		return true, true, ks.getExpiry(keyId, groups[0], startVaultPath)
 	}
 	// If key does not exist in user's data keys, check if it exists in a vault
 	// If startVaultId is not provided, return false
 	if startVaultId == "" {
-		return false, false
-
+		return false, false, nil
 	}
 	// Get start vault
 	vault, err := ks.vaultRepository.GetVault(startVaultId, startVaultPath)
 	if err != nil {
-		return false, false
+		return false, false, nil
 	}
-	// Get vault key using recursive call to GetHasAccessToKey
+	// The key is inherited only if it is sealed in the vault
+	if _, found := ks.vaultRepository.GetKey(keyId, vault.Id, startVaultPath); !found {
+		return false, false, nil
+	}
+	// Get vault key using recursive call to getAccessToKey
 	parentPath, parentLink, err := ks.vaultRepository.GetVaultParent(startVaultPath)
 	if err != nil {
-		return false, false
+		return false, false, nil
+	}
+	px, _, expiresAt := ks.getAccessToKey(vault.KeyId, parentLink.Id, parentPath, userId)
+	return px, true, expiresAt
+}
+
+// hasActiveDataKey returns true if the data key is shared with the recipient at the path and is not expired.
+// A data key with an expiry whose signature is missing or invalid is expired.
+func (ks *KeyStoreDefault) hasActiveDataKey(keyId string, recipient string, path string) bool {
+	if !ks.keyRepository.DataKeyExist(keyId, recipient, path) {
+		return false
 	}
-	px, _ := ks.GetHasAccessToKey(vault.KeyId, parentLink.Id, parentPath, userId)
-	return px, true
+	expiresAt := ks.getExpiry(keyId, recipient, path)
+	return expiresAt == nil || expiresAt.After(time.Now())
+}
+
+// GetShareExpiry returns the time the data key shared with the recipient at the path expires, or nil if it does not expire.
+// It is the earliest of the expiry recorded next to the data key and the expiry logged in the audit log,
+// so the share still expires when the recorded expiry is removed.
+// The zero time is returned if the recorded expiry is not validly signed, or if the logged expiries cannot be read.
+func (ks *KeyStoreDefault) GetShareExpiry(keyId string, recipientUserId string, path string) *time.Time {
+	return ks.getExpiry(keyId, recipientUserId, path)
+}
+
+// getExpiry returns the time the data key shared with the recipient at the path expires, as GetShareExpiry does.
+func (ks *KeyStoreDefault) getExpiry(keyId string, recipient string, path string) *time.Time {
+	var expiresAt *time.Time
+	if expiry, ok := ks.keyRepository.GetDataKeyExpiry(keyId, recipient, path); ok {
+		if !ks.VerifyShareExpiry(expiry) {
+			return &time.Time{}
+		}
+		expiresAt = &expiry.ExpiresAt
+	}
+	logged, err := ks.getLoggedExpiry(keyId, recipient)
+	if err != nil {
+		return &time.Time{}
+	}
+	if logged != nil && (expiresAt == nil || logged.Before(*expiresAt)) {
+		return logged
+	}
+	return expiresAt
+}
+
+// getLoggedExpiry returns the time the data key shared with the recipient expires according to the audit log,
+// or nil if no expiry is logged.
+func (ks *KeyStoreDefault) getLoggedExpiry(keyId string, recipient string) (*time.Time, error) {
+	if ks.expiryLog == nil {
+		return nil, nil
+	}
+	logged, err := ks.expiryLog.ListLoggedExpiries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range logged {
+		if e.KeyId == keyId && e.Recipient == recipient {
+			return &e.ExpiresAt, nil
+		}
+	}
+	return nil, nil
+}
+
+// copyExpiry gives the data key shared with the new recipient at the new path the expiry of the old data key, if any.
+// The expiry is signed and logged again by the user if the key or the recipient changes, an expiry which is not validly
+// signed is replaced by an expiry at the current time.
+func (ks *KeyStoreDefault) copyExpiry(keyId string, recipient string, path string, newKeyId string, newRecipient string, newPath string) error {
+	expiresAt := ks.getExpiry(keyId, recipient, path)
+	if expiresAt == nil {
+		return nil
+	}
+	if newKeyId == keyId && newRecipient == recipient {
+		// The logged expiry has no path and stays valid
+		expiry, ok := ks.keyRepository.GetDataKeyExpiry(keyId, recipient, path)
+		if !ok {
+			return nil
+		}
+		expiry.Path = newPath
+		return ks.keyRepository.SetDataKeyExpiry(expiry)
+	}
+	if expiresAt.IsZero() {
+		now := time.Now()
+		expiresAt = &now
+	}
+	return ks.SetShareExpiry(newKeyId, newRecipient, newPath, *expiresAt)
+}
+
+// SetShareExpiry sets the time the data key shared with the recipient at the path expires, signed by the user,
+// and logs it in the audit log. Once expired, the data key does not give access anymore, and it is removed by the expiry sweep.
+// A zero time removes the expiry, it is only logged if an expiry was logged before.
+func (ks *KeyStoreDefault) SetShareExpiry(keyId string, recipientUserId string, path string, expiresAt time.Time) error {
+	expiry := core.DataKeyExpiry{
+		KeyId:     keyId,
+		Recipient: recipientUserId,
+		Path:      path,
+	}
+	if !expiresAt.IsZero() {
+		userId, err := ks.GetUserId()
+		if err != nil {
+			return err
+		}
+		expiry.ExpiresAt = expiresAt.UTC()
+		expiry.SignedBy = userId
+		sig, err := ks.Sign(expiry.SignedMessage())
+		if err != nil {
+			return err
+		}
+		expiry.Signature = signature.Encode(sig)
+	}
+	if err := ks.keyRepository.SetDataKeyExpiry(expiry); err != nil {
+		return err
+	}
+	if expiry.ExpiresAt.IsZero() {
+		logged, err := ks.getLoggedExpiry(keyId, recipientUserId)
+		if err != nil || logged == nil {
+			return err
+		}
+	}
+	if ks.expiryLog == nil {
+		return nil
+	}
+	return ks.expiryLog.LogExpiry(expiry)
+}
+
+// VerifyShareExpiry returns true if the expiry is signed by a verified member other than the recipient,
+// so the recipient cannot extend the share.
+func (ks *KeyStoreDefault) VerifyShareExpiry(expiry core.DataKeyExpiry) bool {
+	if expiry.SignedBy == "" || expiry.SignedBy == expiry.Recipient {
+		return false
+	}
+	if !signature.VerifyEncoded(expiry.SignedBy, expiry.SignedMessage(), expiry.Signature) {
+		return false
+	}
+	return ks.memberChecker == nil || ks.memberChecker.IsMember(expiry.SignedBy)
+}
+
+// findGroupsWithKey returns the ids of the groups the user is a member of and the key is shared with at the path.
+func (ks *KeyStoreDefault) findGroupsWithKey(keyId string, userId string, path string) []string {
+	if ks.groupRepository == nil {
+		return nil
+	}
+	groups, err := ks.groupRepository.ListUserGroups(userId)
+	if err != nil {
+		return nil
+	}
+	res := make([]string, 0)
+	for _, groupId := range groups {
+		if ks.hasActiveDataKey(keyId, groupId, path) {
+			res = append(res, groupId)
+		}
+	}
+	return res
+}
+
+// getGroupDataKey opens the data key shared with the group at the path, with the private key of the group sealed to the user.
+func (ks *KeyStoreDefault) getGroupDataKey(keyId string, groupId string, userId string, path string) (*core.Key, error) {
+	sk, err := ks.keyRepository.GetDataKey(keyId, groupId, path)
+	if err != nil {
+		return nil, err
+	}
+	groupKey, err := ks.getGroupPrivateKey(groupId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return key_crypto.OpenDataKey(sk, groupKey)
+}
+
+// getGroupPrivateKey unseals the private key of the group sealed to the user.
+func (ks *KeyStoreDefault) getGroupPrivateKey(groupId string, userId string) (core.PrivateKey, error) {
+	if ks.groupRepository == nil || !ks.groupRepository.MemberKeyExist(groupId, userId) {
+		return core.EmptyPrivateKey(), ErrNotGroupMember
+	}
+	sealed, err := ks.groupRepository.GetMemberKey(groupId, userId)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	key, err := key_crypto.OpenDataKey(sealed, ks.privateKey)
+	if err != nil {
+		return core.EmptyPrivateKey(), err
+	}
+	return core.NewPrivateKeyFromBytes(key.Bytes()), nil
+}
+
+// CreateGroupKey generates the key pair of a new group and seals the group private key to the user.
+// It returns the public key of the group, which is also the group id.
+// The group key pair is an X25519 key pair, so groups cannot be created in a repository requiring hybrid keys.
+func (ks *KeyStoreDefault) CreateGroupKey() (core.PublicKey, error) {
+	if ks.requireHybrid() {
+		return core.EmptyPublicKey(), ErrGroupsNotHybrid
+	}
+	publicKey, err := ks.GetPublicKey()
+	if err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	groupKey, groupPublicKey, err := newGroupKey()
+	if err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	if err := ks.sealGroupKey(ks.newSealer(), groupPublicKey.String(), groupKey, publicKey, publicKey.String()); err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	return groupPublicKey, nil
+}
+
+// newGroupKey generates the key pair of a group. The group id is the encoded public key, which must be read back
+// as a recipient, so the key pairs whose public key encodes shorter than 44 characters are skipped.
+func newGroupKey() (core.PrivateKey, core.PublicKey, error) {
+	for {
+		groupKey, err := core.NewPrivateKeyFromRand()
+		if err != nil {
+			return core.EmptyPrivateKey(), core.EmptyPublicKey(), err
+		}
+		groupPublicKey, err := groupKey.ToPublicKey()
+		if err != nil {
+			return core.EmptyPrivateKey(), core.EmptyPublicKey(), err
+		}
+		if len(groupPublicKey.String()) == 44 {
+			return groupKey, groupPublicKey, nil
+		}
+	}
+}
+
+// ShareGroupKey seals the private key of the group to the recipient, making the recipient a member of the group.
+// The user must be a member of the group.
+func (ks *KeyStoreDefault) ShareGroupKey(groupId string, recipient core.PublicKey, recipientUserId string) error {
+	userId, err := ks.GetUserId()
+	if err != nil {
+		return err
+	}
+	groupKey, err := ks.getGroupPrivateKey(groupId, userId)
+	if err != nil {
+		return err
+	}
+	return ks.sealGroupKey(ks.newSealer(), groupId, groupKey, recipient, recipientUserId)
+}
+
+// UnshareGroupKey removes the private key of the group sealed to the user.
+func (ks *KeyStoreDefault) UnshareGroupKey(groupId string, userId string) error {
+	if ks.groupRepository == nil {
+		return ErrNotGroupMember
+	}
+	return ks.groupRepository.DeleteMemberKey(groupId, userId)
+}
+
+// sealGroupKey seals the private key of the group to the recipient and saves it in the group.
+func (ks *KeyStoreDefault) sealGroupKey(seal *sealer, groupId string, groupKey core.PrivateKey, recipient core.PublicKey, recipientUserId string) error {
+	if ks.groupRepository == nil {
+		return ErrNotGroupMember
+	}
+	key, err := core.KeyFromBytes(groupKey.Bytes())
+	if err != nil {
+		return err
+	}
+	sealed, err := seal.seal(key, recipient)
+	if err != nil {
+		return err
+	}
+	return ks.groupRepository.SaveMemberKey(groupId, recipientUserId, sealed)
 }
 
 func (ks *KeyStoreDefault) Share(keyId string, startVaultId string, startVaultPath string, recipient core.PublicKey, recipientUserId string) error {
@@ -186,7 +543,7 @@ func (ks *KeyStoreDefault) Share(keyId string, startVaultId string, startVaultPa
 		return fmt.Errorf("cannot load key: %v", err)
 	}
 
-	keyHashed, err := key_crypto.SealDataKey(key.Key, recipient)
+	keyHashed, err := ks.newSealer().seal(key.Key, recipient)
 	if err != nil {
 		return err
 	}
@@ -206,6 +563,67 @@ func (ks *KeyStoreDefault) GetPublicKey() (core.PublicKey, error) {
 	return core.NewPublicKeyFromBytes(res), nil
 }
 
+// GetKemPublicKey returns the ML-KEM-768 public key of the user, nil if the private key of the user is not hybrid.
+func (ks *KeyStoreDefault) GetKemPublicKey() ([]byte, error) {
+	if !ks.privateKey.IsHybrid() {
+		return nil, nil
+	}
+	return key_crypto.KemPublicKey(ks.privateKey)
+}
+
+// sealer seals data keys to recipients during an operation of the key store.
+// The ML-KEM-768 public keys of the members are resolved once, by the first seal to another user.
+type sealer struct {
+	ks      *KeyStoreDefault
+	kemKeys map[string][]byte
+	loaded  bool
+}
+
+// newSealer returns a sealer for an operation of the key store.
+func (ks *KeyStoreDefault) newSealer() *sealer {
+	return &sealer{ks: ks}
+}
+
+// seal seals the key to the recipient, with both X25519 and ML-KEM-768 if the recipient is the user or a member
+// with a hybrid key, and with X25519 only otherwise. If the repository requires hybrid keys,
+// it returns ErrHybridRecipientRequired for a recipient without ML-KEM-768 public key.
+func (s *sealer) seal(key core.Key, recipient core.PublicKey) (string, error) {
+	var kemPublicKey []byte
+	if publicKey, err := s.ks.GetPublicKey(); err == nil && publicKey.Equals(recipient) {
+		if kemPublicKey, err = s.ks.GetKemPublicKey(); err != nil {
+			return "", err
+		}
+	} else if s.ks.kemKeyResolver != nil {
+		if !s.loaded {
+			s.kemKeys = s.ks.kemKeyResolver.ListKemPublicKeys()
+			s.loaded = true
+		}
+		kemPublicKey = s.kemKeys[recipient.String()]
+	}
+	if kemPublicKey == nil {
+		if s.ks.requireHybrid() {
+			return "", fmt.Errorf("%w: %s", ErrHybridRecipientRequired, recipient)
+		}
+		return key_crypto.SealDataKey(key, recipient)
+	}
+	return key_crypto.SealHybridDataKey(key, recipient, kemPublicKey)
+}
+
+// requireHybrid returns true if the repository requires the data keys to be sealed with both X25519 and ML-KEM-768.
+func (ks *KeyStoreDefault) requireHybrid() bool {
+	return ks.hybridPolicy != nil && ks.hybridPolicy.RequireHybrid()
+}
+
+// CheckX25519Recipient returns ErrX25519Recipient if the repository requires hybrid keys.
+// It is checked before sealing keys to the recipients which have no ML-KEM-768 public key by design:
+// password recipients, invitations and capsules.
+func (ks *KeyStoreDefault) CheckX25519Recipient() error {
+	if ks.requireHybrid() {
+		return ErrX25519Recipient
+	}
+	return nil
+}
+
 // GetPublicKeyByPrivateKey returns the public key as a string.
 // It uses the X25519 function from the curve25519 package to perform the scalar multiplication
 // of the private key with the base point, resulting in the public key.
@@ -220,6 +638,7 @@ func (ks *KeyStoreDefault) GetPublicKeyByPrivateKey(privateKey core.PrivateKey)
 // CreateVault generates a new vault and inserts it into the vault repository.
 // If parentId is provided, it generates a key in the parent vault and associates it with the new vault.
 // If parentId is not provided, it generates a key without a parent and inserts it into the keystore.
+// If the names are encrypted, it also generates the name key of the vault and adds it to the vault.
 // The generated vault and associated key are returned on success.
 // If any error occurs during the process, an error is returned.
 func (ks *KeyStoreDefault) CreateVault(parentId string, path string) (*core.Vault, error) {
@@ -261,6 +680,23 @@ func (ks *KeyStoreDefault) CreateVault(parentId string, path string) (*core.Vaul
 	if err != nil {
 		return nil, err
 	}
+	// Generate the key the names of the sub files and directories are encrypted with
+	if ks.namePolicy != nil && ks.namePolicy.EncryptNames() {
+		nameKey, err := core.GenerateKey()
+		if err != nil {
+			return nil, ErrGeneratingKey
+		}
+		if err := ks.AddKeyToVault(&vault, path, *nameKey); err != nil {
+			return nil, err
+		}
+		vault.NameKeyId = nameKey.Id
+		if err := ks.vaultRepository.SaveVault(vault, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := ks.audit(core.AuditCreateVault, path, vault.Id, key.Id); err != nil {
+		return nil, err
+	}
 	return &vault, nil
 }
 
@@ -304,14 +740,18 @@ func (ks *KeyStoreDefault) MoveVault(vaultId string, oldVaultPath string, newVau
 		if err != nil {
 			return err
 		}
-		// Move vault key to new parent
-		err = ks.MoveKey(vault.KeyId, oldParentVaultId, oldParentVaultPath, newParentVaultId, newParentVaultPath)
+		// Move vault key to new parent, or only its shares if the vault does not inherit from the parent
+		if _, found := ks.vaultRepository.GetKey(vault.KeyId, oldParentVaultId, oldParentVaultPath); found {
+			err = ks.MoveKey(vault.KeyId, oldParentVaultId, oldParentVaultPath, newParentVaultId, newParentVaultPath)
+		} else {
+			err = ks.moveKeyShares(vault.KeyId, oldParentVaultPath, newParentVaultPath)
+		}
 		if err != nil {
 			return err
 		}
 	}
-	// If old and new parent vault paths are the same, nothing needs to be done and the function returns nil
-	return nil
+	// Record the move, even if the vault key stays in the same parent vault
+	return ks.audit(core.AuditMoveVault, oldVaultPath, newVaultPath, "")
 }
 
 // MoveKey moves a key from one vault to another.
@@ -346,6 +786,33 @@ func (ks *KeyStoreDefault) MoveKey(keyId string, oldVaultId string, oldVaultPath
 	return nil
 }
 
+// moveKeyShares moves the data keys of the key shared with every recipient from the old path to the new path.
+func (ks *KeyStoreDefault) moveKeyShares(keyId string, oldPath string, newPath string) error {
+	shares, err := ks.keyRepository.ListDataKeys(oldPath)
+	if err != nil {
+		return err
+	}
+	for recipient, ids := range shares {
+		if !contains(ids, keyId) {
+			continue
+		}
+		sealed, err := ks.keyRepository.GetDataKey(keyId, recipient, oldPath)
+		if err != nil {
+			return err
+		}
+		if err := ks.keyRepository.SaveDataKey(keyId, sealed, recipient, newPath); err != nil {
+			return err
+		}
+		if err := ks.copyExpiry(keyId, recipient, oldPath, keyId, recipient, newPath); err != nil {
+			return err
+		}
+		if err := ks.keyRepository.DeleteDataKey(keyId, recipient, oldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GenerateKeyInVault generates a new key and adds it to the specified vault.
 // It returns the generated key information or an error if the operation fails.
 func (ks *KeyStoreDefault) GenerateKeyInVault(vaultId string, vaultPath string) (*core.KeyInfo, error) {
@@ -374,15 +841,13 @@ func (ks *KeyStoreDefault) GenerateUserKey() (*core.PrivateKey, error) {
 	return &key, nil
 }
 
-// IsUserJoined checks if the user with the specified user ID has joined the key store.
-// It returns a boolean indicating whether the user has joined or not.
-// If any error occurs during the process, it returns false.
-func (ks *KeyStoreDefault) IsUserJoined() bool {
-	userId, err := ks.GetUserId()
+// GenerateHybridUserKey generates a new hybrid user key, with an ML-KEM-768 key pair in addition to the X25519 key pair.
+func (ks *KeyStoreDefault) GenerateHybridUserKey() (*core.PrivateKey, error) {
+	key, err := key_crypto.NewHybridPrivateKey()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return ks.keyRepository.IsUserJoined(userId)
+	return &key, nil
 }
 
 // GetKeyAccessList retrieves the key access list for a given key ID and starting vault ID.
@@ -390,25 +855,392 @@ func (ks *KeyStoreDefault) IsUserJoined() bool {
 // along with a boolean value indicating whether the access is inherited from a parent vault.
 // If an error occurs during the retrieval process, it is returned as the second value.
 func (ks *KeyStoreDefault) GetKeyAccessList(keyId string, startVaultId string, startVaultPath string) (core.KeyAccessList, error) {
-	usersList, err := ks.keyRepository.ListUsers()
+	usersList, err := ks.listUsersAndGroupMembers()
 	if err != nil {
 		return nil, err
 	}
 	accessList := make(core.KeyAccessList, 0)
 	for _, user := range usersList {
-		if hasAccess, inherited := ks.GetHasAccessToKey(keyId, startVaultId, startVaultPath, user); hasAccess {
+		if hasAccess, inherited, expiresAt := ks.getAccessToKey(keyId, startVaultId, startVaultPath, user); hasAccess {
 			accessList = append(accessList, core.KeyAccess{
 				PublicKey: user,
 				Inherited: inherited,
+				ExpiresAt: expiresAt,
 			})
 		}
 	}
 	return accessList, nil
 }
 
+// RotateVaultKey replaces the key of the vault at the specified path with a new key.
+// The keys sealed in the vault are sealed again with the new key, and the new key is stored wherever the old one was:
+// in the parent vault and in the data keys of the recipients the old key was shared with.
+// The old key still opens the existing objects, but the keys generated in the vault afterwards are not readable with it.
+// If the names are encrypted, the name key of the vault is replaced as well and the entries of the directory are renamed.
+// It returns the id of the new key.
+func (ks *KeyStoreDefault) RotateVaultKey(vaultPath string) (string, error) {
+	vault, err := ks.vaultRepository.GetVaultByPath(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	parentPath, parentVault, err := ks.vaultRepository.GetVaultParent(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	oldKey, err := ks.Get(vault.KeyId, parentVault.Id, parentPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot load key of vault %s: %v", vaultPath, err)
+	}
+	newKey, err := core.GenerateKey()
+	if err != nil {
+		return "", ErrGeneratingKey
+	}
+	// Seal the keys of the vault with the new key, the name key is replaced
+	sealedKeys, err := ks.sealVaultKeys(vault, vaultPath, oldKey, newKey)
+	if err != nil {
+		return "", err
+	}
+	oldNameKey, newNameKey, err := ks.replaceNameKey(&vault, vaultPath, oldKey, newKey, sealedKeys)
+	if err != nil {
+		return "", err
+	}
+	// Store the new key in the parent vault, unless the vault does not inherit from it, and share it with the recipients of the old key
+	_, inherits := ks.vaultRepository.GetKey(vault.KeyId, parentVault.Id, parentPath)
+	if inherits {
+		if err := ks.AddKeyToVault(&parentVault, parentPath, *newKey); err != nil {
+			return "", err
+		}
+	}
+	shares, err := ks.keyRepository.ListDataKeys(parentPath)
+	if err != nil {
+		return "", err
+	}
+	recipients := make([]string, 0)
+	seal := ks.newSealer()
+	for recipient, ids := range shares {
+		if !contains(ids, vault.KeyId) {
+			continue
+		}
+		publicKey, err := core.NewPublicKeyFromEncoded(recipient)
+		if err != nil {
+			return "", err
+		}
+		sealed, err := seal.seal(newKey.Key, publicKey)
+		if err != nil {
+			return "", err
+		}
+		if err := ks.keyRepository.SaveDataKey(newKey.Id, sealed, recipient, parentPath); err != nil {
+			return "", err
+		}
+		if err := ks.copyExpiry(vault.KeyId, recipient, parentPath, newKey.Id, recipient, parentPath); err != nil {
+			return "", err
+		}
+		recipients = append(recipients, recipient)
+	}
+	// Replace the keys of the vault and switch the vault to the new key
+	oldKeyId := vault.KeyId
+	if err := ks.replaceVaultKeys(&vault, vaultPath, sealedKeys, newKey.Id); err != nil {
+		return "", err
+	}
+	if err := ks.renameEntries(vault, vaultPath, oldNameKey, newNameKey); err != nil {
+		return "", err
+	}
+	// Remove the old key
+	if inherits {
+		if err := ks.vaultRepository.RemoveKey(oldKeyId, parentVault.Id, parentPath); err != nil {
+			return "", err
+		}
+	}
+	for _, recipient := range recipients {
+		if err := ks.keyRepository.DeleteDataKey(oldKeyId, recipient, parentPath); err != nil {
+			return "", err
+		}
+	}
+	if err := ks.audit(core.AuditRotateKey, vaultPath, newKey.Id, oldKeyId); err != nil {
+		return "", err
+	}
+	return newKey.Id, nil
+}
+
+// BreakInheritance replaces the key of the vault at the specified path with a new key which is not stored in the parent vault,
+// so the users who reach the parent vault do not reach the vault anymore.
+// The new key is shared only with the given recipients, user or group public keys, and the shares of the old key are removed.
+// The old key still opens the keys sealed in the vault before, and the name key is replaced, as for RotateVaultKey.
+// It returns the id of the new key.
+func (ks *KeyStoreDefault) BreakInheritance(vaultPath string, recipients []string) (string, error) {
+	parentPath, parentVault, err := ks.vaultRepository.GetVaultParent(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	if parentVault.Id == "" {
+		return "", ErrRootVault
+	}
+	vault, err := ks.vaultRepository.GetVaultByPath(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	oldKey, err := ks.Get(vault.KeyId, parentVault.Id, parentPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot load key of vault %s: %v", vaultPath, err)
+	}
+	newKey, err := core.GenerateKey()
+	if err != nil {
+		return "", ErrGeneratingKey
+	}
+	sealedKeys, err := ks.sealVaultKeys(vault, vaultPath, oldKey, newKey)
+	if err != nil {
+		return "", err
+	}
+	oldNameKey, newNameKey, err := ks.replaceNameKey(&vault, vaultPath, oldKey, newKey, sealedKeys)
+	if err != nil {
+		return "", err
+	}
+	// Share the new key with the recipients only
+	seal := ks.newSealer()
+	for _, recipient := range recipients {
+		publicKey, err := core.NewPublicKeyFromEncoded(recipient)
+		if err != nil {
+			return "", err
+		}
+		sealed, err := seal.seal(newKey.Key, publicKey)
+		if err != nil {
+			return "", err
+		}
+		if err := ks.keyRepository.SaveDataKey(newKey.Id, sealed, recipient, parentPath); err != nil {
+			return "", err
+		}
+	}
+	oldKeyId := vault.KeyId
+	if err := ks.replaceVaultKeys(&vault, vaultPath, sealedKeys, newKey.Id); err != nil {
+		return "", err
+	}
+	if err := ks.renameEntries(vault, vaultPath, oldNameKey, newNameKey); err != nil {
+		return "", err
+	}
+	// Remove the old key from the parent vault, if the inheritance was not already broken, and from the recipients
+	if _, found := ks.vaultRepository.GetKey(oldKeyId, parentVault.Id, parentPath); found {
+		if err := ks.vaultRepository.RemoveKey(oldKeyId, parentVault.Id, parentPath); err != nil {
+			return "", err
+		}
+	}
+	shares, err := ks.keyRepository.ListDataKeys(parentPath)
+	if err != nil {
+		return "", err
+	}
+	for recipient, ids := range shares {
+		if !contains(ids, oldKeyId) {
+			continue
+		}
+		if err := ks.keyRepository.DeleteDataKey(oldKeyId, recipient, parentPath); err != nil {
+			return "", err
+		}
+	}
+	if err := ks.audit(core.AuditBreakInheritance, vaultPath, newKey.Id, oldKeyId); err != nil {
+		return "", err
+	}
+	return newKey.Id, nil
+}
+
+// sealVaultKeys opens the keys of the vault with its old key and seals them with the new key.
+// It returns the sealed keys by key id.
+func (ks *KeyStoreDefault) sealVaultKeys(vault core.Vault, vaultPath string, oldKey *core.KeyInfo, newKey *core.KeyInfo) (map[string]string, error) {
+	keyIds, err := ks.vaultRepository.ListKeys(vault.Id, vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	sealedKeys := make(map[string]string, len(keyIds))
+	for _, keyId := range keyIds {
+		encKey, found := ks.vaultRepository.GetKey(keyId, vault.Id, vaultPath)
+		if !found {
+			return nil, ErrDataKeyNotFound
+		}
+		key, err := key_crypto.OpenVaultDataKey(encKey, oldKey.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open key %s of vault %s: %v", keyId, vaultPath, err)
+		}
+		if sealedKeys[keyId], err = key_crypto.SealVaultDataKey(*key, newKey.Key); err != nil {
+			return nil, err
+		}
+	}
+	return sealedKeys, nil
+}
+
+// replaceNameKey replaces the name key of the vault, if the names of its entries are encrypted: the new name key is sealed
+// with the new vault key in the sealed keys in place of the old one, so the old vault key does not decrypt the names
+// stored afterwards. It returns the old and the new name keys, nil if the names are not encrypted.
+func (ks *KeyStoreDefault) replaceNameKey(vault *core.Vault, vaultPath string, oldKey *core.KeyInfo, newKey *core.KeyInfo, sealedKeys map[string]string) (*core.KeyInfo, *core.KeyInfo, error) {
+	if vault.NameKeyId == "" {
+		return nil, nil, nil
+	}
+	if ks.nameRotator == nil {
+		return nil, nil, ErrNameRotatorNotSet
+	}
+	encKey, found := ks.vaultRepository.GetKey(vault.NameKeyId, vault.Id, vaultPath)
+	if !found {
+		return nil, nil, ErrDataKeyNotFound
+	}
+	key, err := key_crypto.OpenVaultDataKey(encKey, oldKey.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open name key of vault %s: %v", vaultPath, err)
+	}
+	oldNameKey := core.NewKeyInfo(vault.NameKeyId, *key)
+	newNameKey, err := core.GenerateKey()
+	if err != nil {
+		return nil, nil, ErrGeneratingKey
+	}
+	if sealedKeys[newNameKey.Id], err = key_crypto.SealVaultDataKey(newNameKey.Key, newKey.Key); err != nil {
+		return nil, nil, err
+	}
+	delete(sealedKeys, oldNameKey.Id)
+	vault.NameKeyId = newNameKey.Id
+	return &oldNameKey, newNameKey, nil
+}
+
+// renameEntries renames the entries of the vault stored under names encrypted with the old name key
+// to their names encrypted with the new one, then removes the old name key from the vault.
+// It does nothing if the names of the vault are not encrypted.
+func (ks *KeyStoreDefault) renameEntries(vault core.Vault, vaultPath string, oldNameKey *core.KeyInfo, newNameKey *core.KeyInfo) error {
+	if oldNameKey == nil {
+		return nil
+	}
+	if err := ks.nameRotator.RenameEntries(vaultPath, oldNameKey.Key, newNameKey.Key); err != nil {
+		return err
+	}
+	return ks.vaultRepository.RemoveKey(oldNameKey.Id, vault.Id, vaultPath)
+}
+
+// replaceVaultKeys replaces the keys of the vault with the sealed keys and switches the vault to the new key.
+func (ks *KeyStoreDefault) replaceVaultKeys(vault *core.Vault, vaultPath string, sealedKeys map[string]string, newKeyId string) error {
+	for keyId, sealed := range sealedKeys {
+		if err := ks.vaultRepository.AddKeyToVault(vault, vaultPath, keyId, sealed); err != nil {
+			return err
+		}
+	}
+	vault.KeyId = newKeyId
+	return ks.vaultRepository.SaveVault(*vault, vaultPath)
+}
+
+// RotateGroupKey replaces the key pair of the group with a new one, sealed to the given members only.
+// The data keys shared with the group are shared with the new public key of the group instead,
+// so the user must have access to all of them. If there is no member left, the data keys shared with the group are removed
+// and an empty public key is returned. The old group private key sealed to the members is not removed.
+func (ks *KeyStoreDefault) RotateGroupKey(groupId string, members []string) (core.PublicKey, error) {
+	if ks.groupRepository == nil {
+		return core.EmptyPublicKey(), ErrNotGroupMember
+	}
+	shares, err := ks.keyRepository.ListRecipientDataKeys(groupId)
+	if err != nil {
+		return core.EmptyPublicKey(), err
+	}
+	newPublicKey := core.EmptyPublicKey()
+	if len(members) > 0 {
+		seal := ks.newSealer()
+		groupKey, groupPublicKey, err := newGroupKey()
+		if err != nil {
+			return core.EmptyPublicKey(), err
+		}
+		newPublicKey = groupPublicKey
+		for _, member := range members {
+			memberKey, err := core.NewPublicKeyFromEncoded(member)
+			if err != nil {
+				return core.EmptyPublicKey(), err
+			}
+			if err := ks.sealGroupKey(seal, newPublicKey.String(), groupKey, memberKey, member); err != nil {
+				return core.EmptyPublicKey(), err
+			}
+		}
+		// Share the data keys of the group with the new public key
+		for path, keyIds := range shares {
+			for _, keyId := range keyIds {
+				key, err := ks.getSharedKey(keyId, path)
+				if err != nil {
+					return core.EmptyPublicKey(), fmt.Errorf("cannot load key %s shared with the group: %v", keyId, err)
+				}
+				sealed, err := seal.seal(key.Key, newPublicKey)
+				if err != nil {
+					return core.EmptyPublicKey(), err
+				}
+				if err := ks.keyRepository.SaveDataKey(keyId, sealed, newPublicKey.String(), path); err != nil {
+					return core.EmptyPublicKey(), err
+				}
+				if err := ks.copyExpiry(keyId, groupId, path, keyId, newPublicKey.String(), path); err != nil {
+					return core.EmptyPublicKey(), err
+				}
+			}
+		}
+	}
+	// Remove the data keys shared with the old public key
+	for path, keyIds := range shares {
+		for _, keyId := range keyIds {
+			if err := ks.keyRepository.DeleteDataKey(keyId, groupId, path); err != nil {
+				return core.EmptyPublicKey(), err
+			}
+		}
+	}
+	return newPublicKey, nil
+}
+
+// getSharedKey loads a data key shared at the specified path: a file key or the key of a sub vault sealed
+// in the vault of the path, or the key of the root vault which the user has in their own data keys.
+func (ks *KeyStoreDefault) getSharedKey(keyId string, path string) (*core.KeyInfo, error) {
+	vaultPath := filepath.Join(string(filepath.Separator), path)
+	vault, err := ks.vaultRepository.GetVaultByPath(vaultPath)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Get(keyId, vault.Id, vaultPath)
+}
+
+// listUsersAndGroupMembers returns the users keys are shared with and the members of the groups, without duplicates.
+func (ks *KeyStoreDefault) listUsersAndGroupMembers() ([]string, error) {
+	users, err := ks.keyRepository.ListUsers()
+	if err != nil || ks.groupRepository == nil {
+		return users, err
+	}
+	groups, err := ks.groupRepository.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(users))
+	for _, user := range users {
+		seen[user] = struct{}{}
+	}
+	for _, group := range groups {
+		members, err := ks.groupRepository.ListMembers(group.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if _, ok := seen[member]; !ok {
+				seen[member] = struct{}{}
+				users = append(users, member)
+			}
+		}
+	}
+	return users, nil
+}
+
 // Unshare removes the sharing of a data key with a recipient user.
 // It takes the key ID and the recipient user ID as parameters.
 // Returns an error if there was a problem deleting the data key.
 func (ks *KeyStoreDefault) Unshare(keyId string, recipientUserId string, path string) error {
 	return ks.keyRepository.DeleteDataKey(keyId, recipientUserId, path)
 }
+
+// audit records the change in the audit log if an audit logger is set.
+func (ks *KeyStoreDefault) audit(action core.AuditAction, path string, target string, keyId string) error {
+	if ks.auditLogger == nil {
+		return nil
+	}
+	return ks.auditLogger.Log(action, path, target, keyId)
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package key_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/key_service"
+	"ctb-cli/test/testrepo"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openKeyStore opens the key store of the repository with the encoded private key and returns it with the user id.
+func openKeyStore(t *testing.T, repoPath string, encodedKey string) (*key_service.KeyStoreDefault, string) {
+	privateKey, err := core.NewPrivateKeyFromEncoded(encodedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyStore := key_service.NewKeyStore(repositories.NewKeyRepositoryFile(repoPath), repositories.NewVaultRepositoryFile(repoPath),
+		repositories.NewGroupRepositoryFile(repoPath))
+	keyStore.SetPrivateKey(privateKey)
+	userId, err := keyStore.GetUserId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyStore, userId
+}
+
+func TestRotateVaultKey(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner, _ := openKeyStore(t, repo.Path, repo.OwnerKey)
+	bob, bobId := openKeyStore(t, repo.Path, testrepo.NewUserKey(t))
+	bobPublicKey, err := bob.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.Share(vault.KeyId, parent.Id, parentPath, bobPublicKey, bobId); err != nil {
+		t.Fatal(err)
+	}
+
+	newKeyId, err := owner.RotateVaultKey("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newKeyId == vault.KeyId {
+		t.Fatal("the key id is not replaced")
+	}
+	rotated, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.KeyId != newKeyId {
+		t.Errorf("the vault uses key %s, want %s", rotated.KeyId, newKeyId)
+	}
+	// The new key is shared with the recipients of the old key, and the old shares are removed
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	if !keyRepository.DataKeyExist(newKeyId, bobId, parentPath) {
+		t.Error("the new key is not shared with the recipient")
+	}
+	if keyRepository.DataKeyExist(vault.KeyId, bobId, parentPath) {
+		t.Error("the old key is still shared with the recipient")
+	}
+	for name, keyStore := range map[string]*key_service.KeyStoreDefault{"owner": owner, "recipient": bob} {
+		if _, err := keyStore.Get(newKeyId, parent.Id, parentPath); err != nil {
+			t.Errorf("the %s cannot load the new key: %v", name, err)
+		}
+	}
+}
+
+func TestRotateGroupKey(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner, ownerId := openKeyStore(t, repo.Path, repo.OwnerKey)
+	bob, bobId := openKeyStore(t, repo.Path, testrepo.NewUserKey(t))
+	bobPublicKey, err := bob.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupKey, err := owner.CreateGroupKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupId := groupKey.String()
+	if err := owner.ShareGroupKey(groupId, bobPublicKey, bobId); err != nil {
+		t.Fatal(err)
+	}
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.Share(vault.KeyId, parent.Id, parentPath, groupKey, groupId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bob.Get(vault.KeyId, parent.Id, parentPath); err != nil {
+		t.Fatalf("the group member cannot load the key shared with the group: %v", err)
+	}
+
+	// Bob is removed from the group
+	newGroupKey, err := owner.RotateGroupKey(groupId, []string{ownerId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newGroupKey.String() == groupId {
+		t.Fatal("the group key pair is not replaced")
+	}
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	if keyRepository.DataKeyExist(vault.KeyId, groupId, parentPath) {
+		t.Error("the key is still shared with the old group key")
+	}
+	if !keyRepository.DataKeyExist(vault.KeyId, newGroupKey.String(), parentPath) {
+		t.Error("the key is not shared with the new group key")
+	}
+	// The removed member cannot open the new group key, the owner still reaches the key through the group
+	groupRepository := repositories.NewGroupRepositoryFile(repo.Path)
+	if groupRepository.MemberKeyExist(newGroupKey.String(), bobId) {
+		t.Error("the new group key is sealed to the removed member")
+	}
+	if !groupRepository.MemberKeyExist(newGroupKey.String(), ownerId) {
+		t.Error("the new group key is not sealed to the remaining member")
+	}
+	if _, err := bob.Get(vault.KeyId, parent.Id, parentPath); err == nil {
+		t.Error("the removed member can load the key shared with the group")
+	}
+
+	// Without members left, the keys shared with the group are removed
+	emptyKey, err := owner.RotateGroupKey(newGroupKey.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emptyKey.String() != "" {
+		t.Errorf("got public key %s for a group without members", emptyKey)
+	}
+	if keyRepository.DataKeyExist(vault.KeyId, newGroupKey.String(), parentPath) {
+		t.Error("the key is still shared with the group without members")
+	}
+}
+
+func TestShareExpirySignature(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner, _ := openKeyStore(t, repo.Path, repo.OwnerKey)
+	bob, bobId := openKeyStore(t, repo.Path, testrepo.NewUserKey(t))
+	bobPublicKey, err := bob.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.Share(vault.KeyId, parent.Id, parentPath, bobPublicKey, bobId); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SetShareExpiry(vault.KeyId, bobId, parentPath, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if hasAccess, _ := bob.GetHasAccessToKey(vault.KeyId, parent.Id, parentPath, bobId); !hasAccess {
+		t.Fatal("the share is expired before its expiry")
+	}
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	expiry, ok := keyRepository.GetDataKeyExpiry(vault.KeyId, bobId, parentPath)
+	if !ok || !owner.VerifyShareExpiry(expiry) {
+		t.Fatal("the expiry set by the owner is not valid")
+	}
+
+	// The recipient extends the share by editing the expiry, or by signing it
+	expiry.ExpiresAt = expiry.ExpiresAt.AddDate(1, 0, 0)
+	if err := keyRepository.SetDataKeyExpiry(expiry); err != nil {
+		t.Fatal(err)
+	}
+	if hasAccess, _ := bob.GetHasAccessToKey(vault.KeyId, parent.Id, parentPath, bobId); hasAccess {
+		t.Error("the share gives access with an edited expiry")
+	}
+	if err := bob.SetShareExpiry(vault.KeyId, bobId, parentPath, time.Now().AddDate(1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if hasAccess, _ := bob.GetHasAccessToKey(vault.KeyId, parent.Id, parentPath, bobId); hasAccess {
+		t.Error("the share gives access with an expiry signed by its recipient")
+	}
+
+	// An expiry without signature, as written by earlier versions, is expired
+	expiryPath := filepath.Join(repo.Path, ".meta", ".key-share", bobId, vault.KeyId+repositories.DataKeyExpirySuffix)
+	if _, err := os.Stat(expiryPath); err != nil {
+		t.Fatal(err)
+	}
+	legacy := time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339)
+	if err := os.WriteFile(expiryPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hasAccess, _ := bob.GetHasAccessToKey(vault.KeyId, parent.Id, parentPath, bobId); hasAccess {
+		t.Error("the share gives access with an unsigned expiry")
+	}
+}
+
+// newHybridKey generates a hybrid key pair whose public key is encoded in 44 characters.
+func newHybridKey(t *testing.T) (core.PrivateKey, core.PublicKey) {
+	for {
+		key, err := key_crypto.NewHybridPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := key.ToPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(publicKey.String()) == 44 {
+			return key, publicKey
+		}
+	}
+}
+
+// hybridPolicy is a hybrid policy requiring hybrid keys if true.
+type hybridPolicy bool
+
+func (p hybridPolicy) RequireHybrid() bool {
+	return bool(p)
+}
+
+// kemKeyResolver resolves the ML-KEM-768 public keys from a map and counts the lookups.
+type kemKeyResolver struct {
+	kemKeys map[string][]byte
+	lookups int
+}
+
+func (r *kemKeyResolver) ListKemPublicKeys() map[string][]byte {
+	r.lookups++
+	return r.kemKeys
+}
+
+func TestRequireHybrid(t *testing.T) {
+	repo := testrepo.New(t, "a")
+	owner, _ := openKeyStore(t, repo.Path, repo.OwnerKey)
+	_, bobId := openKeyStore(t, repo.Path, testrepo.NewUserKey(t))
+	carolKey, carolPublicKey := newHybridKey(t)
+	carolKemKey, err := key_crypto.KemPublicKey(carolKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := &kemKeyResolver{kemKeys: map[string][]byte{carolPublicKey.String(): carolKemKey}}
+	owner.SetKemKeyResolver(resolver)
+	vaultRepository := repositories.NewVaultRepositoryFile(repo.Path)
+	vault, err := vaultRepository.GetVaultByPath("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentPath, parent, err := vaultRepository.GetVaultParent("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPublicKey, err := core.NewPublicKeyFromEncoded(bobId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recipient := range []core.PublicKey{bobPublicKey, carolPublicKey} {
+		if err := owner.Share(vault.KeyId, parent.Id, parentPath, recipient, recipient.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The members are resolved once for the rotation of the key, however many recipients it has
+	resolver.lookups = 0
+	newKeyId, err := owner.RotateVaultKey("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("the ML-KEM-768 public keys are resolved %d times, want 1", resolver.lookups)
+	}
+	keyRepository := repositories.NewKeyRepositoryFile(repo.Path)
+	sealed, err := keyRepository.GetDataKey(newKeyId, carolPublicKey.String(), parentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key_crypto.OpenDataKey(sealed, core.NewPrivateKeyFromBytes(carolKey.Bytes()[:core.PrivateKeySize])); !errors.Is(err, key_crypto.ErrHybridKeyRequired) {
+		t.Errorf("the key shared with a hybrid member is not sealed with ML-KEM-768: %v", err)
+	}
+
+	// Once hybrid keys are required, the keys are sealed to hybrid recipients only
+	owner.SetHybridPolicy(hybridPolicy(true))
+	if err := owner.Share(newKeyId, parent.Id, parentPath, carolPublicKey, carolPublicKey.String()); err != nil {
+		t.Errorf("the key cannot be shared with a hybrid member: %v", err)
+	}
+	if err := owner.Share(newKeyId, parent.Id, parentPath, bobPublicKey, bobId); !errors.Is(err, key_service.ErrHybridRecipientRequired) {
+		t.Errorf("got %v, want ErrHybridRecipientRequired", err)
+	}
+	if _, err := owner.RotateVaultKey("/a"); !errors.Is(err, key_service.ErrHybridRecipientRequired) {
+		t.Errorf("got %v, want ErrHybridRecipientRequired for the rotation of a key shared with a classic recipient", err)
+	}
+	if _, err := owner.CreateGroupKey(); !errors.Is(err, key_service.ErrGroupsNotHybrid) {
+		t.Errorf("got %v, want ErrGroupsNotHybrid", err)
+	}
+	if err := owner.CheckX25519Recipient(); !errors.Is(err, key_service.ErrX25519Recipient) {
+		t.Errorf("got %v, want ErrX25519Recipient", err)
+	}
+}
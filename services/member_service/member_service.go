@@ -0,0 +1,783 @@
+package member_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrAlreadyMember       = errors.New("the user is already a member of the repository")
+	ErrNotMember           = errors.New("the user is not a member of the repository")
+	ErrNotOwner            = errors.New("only owners can approve owners")
+	ErrInvalidRole         = errors.New("invalid role")
+	ErrInvalidJoinRequest  = errors.New("the signature of the join request is invalid")
+	ErrRegistryInitialized = errors.New("the member registry is already initialized")
+	ErrRevokeSelf          = errors.New("cannot revoke yourself")
+	ErrOwnerRequired       = errors.New("only owners can revoke members")
+	ErrGuest               = errors.New("guests cannot approve or invite users")
+	ErrInvalidInvite       = errors.New("the invitation is invalid")
+	ErrRevokeEarlierMember = errors.New("owners can only revoke the members verified after them")
+	ErrRegistryMissing     = errors.New("the member registry is missing but the first owner of the repository is pinned")
+	ErrPolicyNotVerified   = errors.New("the policy of the repository is not signed by its first owner")
+)
+
+// Service manages the member registry of the repository.
+//
+// Every member of the registry is signed by the member who approved it, and the first owner signs their own entry.
+// The public key of the first owner is pinned on the device of the user, outside the repository, when the user initializes
+// the registry or reads it for the first time, so a user who can write to the repository cannot replace it afterwards.
+// A member is verified if the chain of signatures leads to the first owner, and only owners can approve owners.
+// A guest who redeemed an invitation is verified if the member who created the invitation is verified,
+// and guests cannot approve anyone.
+// A verified member stops being a member when an owner verified before it revokes it, the members it approved stay members.
+// The members are ordered by the length of their chain, then by the time their approver added them,
+// so a member cannot revoke the owners it was approved by, and the first owner cannot be revoked.
+// Members are approved to the root of the repository, or to a directory only.
+// Repositories created before the registry have no member, their members are the users a key is shared with,
+// and the registry is initialized with the first user the root vault key is shared with to approve a user.
+// Once the first owner is pinned, a repository without registry has no member: the registry was removed.
+// The policy of the repository is signed by the first owner in their entry, and is the strictest if it cannot be verified.
+type Service struct {
+	keyService  core.KeyService
+	keyRepo     repositories.KeyRepository
+	vaultRepo   repositories.VaultRepository
+	memberRepo  *repositories.MemberRepository
+	auditLogger core.AuditLogger
+	trustRoot   core.TrustRoot
+}
+
+// NewService creates a new instance of the member service.
+func NewService(
+	keyService core.KeyService,
+	keyRepo repositories.KeyRepository,
+	vaultRepo repositories.VaultRepository,
+	memberRepo *repositories.MemberRepository,
+	auditLogger core.AuditLogger,
+) *Service {
+	return &Service{
+		keyService:  keyService,
+		keyRepo:     keyRepo,
+		vaultRepo:   vaultRepo,
+		memberRepo:  memberRepo,
+		auditLogger: auditLogger,
+	}
+}
+
+// SetTrustRoot sets the store of the pinned public key of the first owner.
+func (s *Service) SetTrustRoot(trustRoot core.TrustRoot) {
+	s.trustRoot = trustRoot
+}
+
+// InitRegistry initializes the member registry with the current user as the first owner, who signs the policy of the repository.
+func (s *Service) InitRegistry(name string, policy core.RepoPolicy) error {
+	if s.memberRepo.IsInitialized() {
+		return ErrRegistryInitialized
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	return s.initRegistry(userId, name, policy)
+}
+
+// IsMember returns true if the user is a verified member of the repository.
+// In a repository created before the registry, it returns true if a key is shared with the user.
+func (s *Service) IsMember(userId string) bool {
+	legacy, err := s.isLegacy()
+	if err != nil {
+		return false
+	}
+	if legacy {
+		return s.hasKeyShare(userId)
+	}
+	verified, err := s.verifiedMembers()
+	if err != nil {
+		return false
+	}
+	_, ok := verified[userId]
+	return ok
+}
+
+// RequireHybrid returns true if the policy of the repository requires the data keys to be sealed with both X25519 and ML-KEM-768,
+// or if the policy cannot be verified.
+func (s *Service) RequireHybrid() bool {
+	policy, err := s.policy()
+	return err != nil || policy.RequireHybrid
+}
+
+// EncryptNames returns true if the policy of the repository encrypts the names of the files and directories,
+// or if the policy cannot be verified, so the names are not stored in plaintext.
+func (s *Service) EncryptNames() bool {
+	policy, err := s.policy()
+	return err != nil || policy.EncryptNames
+}
+
+// ListKemPublicKeys returns the ML-KEM-768 public keys of the verified members with a hybrid key, by public key.
+// It returns nil if the member registry is not initialized.
+func (s *Service) ListKemPublicKeys() map[string][]byte {
+	if !s.memberRepo.IsInitialized() {
+		return nil
+	}
+	verified, err := s.verifiedMembers()
+	if err != nil {
+		return nil
+	}
+	kemKeys := make(map[string][]byte)
+	for publicKey, member := range verified {
+		if member.KemKey == "" {
+			continue
+		}
+		kemKey, err := base64.RawStdEncoding.DecodeString(member.KemKey)
+		if err != nil {
+			continue
+		}
+		kemKeys[publicKey] = kemKey
+	}
+	return kemKeys
+}
+
+// RequestJoin writes a join request of the current user, signed with the private key of the user.
+// The request of a user with a hybrid key holds the ML-KEM-768 public key of the user.
+// The user becomes a member when an existing member approves the request.
+func (s *Service) RequestJoin(name string) error {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	if s.IsMember(userId) {
+		return ErrAlreadyMember
+	}
+	kemKey, err := s.ownKemKey()
+	if err != nil {
+		return err
+	}
+	request := core.JoinRequest{
+		PublicKey:   userId,
+		Name:        name,
+		RequestedAt: time.Now().UTC(),
+		KemKey:      kemKey,
+	}
+	sig, err := s.keyService.Sign(request.SignedMessage())
+	if err != nil {
+		return err
+	}
+	request.Signature = signature.Encode(sig)
+	return s.memberRepo.SaveJoinRequest(request)
+}
+
+// Approve approves the join request of the user with the specified public key.
+// The user is added to the registry with the given role, signed by the current user,
+// and the vault key of the directory at path is shared with the user, the root of the repository if path is empty.
+// The current user must be a verified member, and an owner to approve an owner.
+func (s *Service) Approve(publicKey string, role core.MemberRole, path string) error {
+	if role != core.MemberRoleOwner && role != core.MemberRoleMember {
+		return ErrInvalidRole
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	if err := s.initLegacyRegistry(userId); err != nil {
+		return err
+	}
+	verified, err := s.verifiedMembers()
+	if err != nil {
+		return err
+	}
+	approver, ok := verified[userId]
+	if !ok {
+		return ErrNotMember
+	}
+	if approver.Role == core.MemberRoleGuest {
+		return ErrGuest
+	}
+	if role == core.MemberRoleOwner && approver.Role != core.MemberRoleOwner {
+		return ErrNotOwner
+	}
+	if _, ok := verified[publicKey]; ok {
+		return ErrAlreadyMember
+	}
+	request, err := s.memberRepo.GetJoinRequest(publicKey)
+	if err != nil {
+		return err
+	}
+	if !signature.VerifyEncoded(request.PublicKey, request.SignedMessage(), request.Signature) {
+		return ErrInvalidJoinRequest
+	}
+	if request.KemKey != "" {
+		kemKey, err := base64.RawStdEncoding.DecodeString(request.KemKey)
+		if err != nil || key_crypto.CheckKemPublicKey(kemKey) != nil {
+			return ErrInvalidJoinRequest
+		}
+	}
+	recipient, err := core.NewPublicKeyFromEncoded(publicKey)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = "/"
+	}
+	keyId, startVaultId, startVaultPath, err := s.vaultKey(path)
+	if err != nil {
+		return err
+	}
+	// Add the member before granting access to the vault, so that the key is sealed to the hybrid key of the member
+	if err := s.addMember(publicKey, request.Name, role, request.KemKey, nil); err != nil {
+		return err
+	}
+	if err := s.keyService.Share(keyId, startVaultId, startVaultPath, recipient, publicKey); err != nil {
+		return err
+	}
+	if err := s.memberRepo.RemoveJoinRequest(publicKey); err != nil {
+		return err
+	}
+	return s.auditLogger.Log(core.AuditAddMember, path, publicKey, keyId)
+}
+
+// ListMembers returns the members of the registry with the result of their verification, sorted by name.
+// The members of a repository created before the registry are the users a key is shared with,
+// owners if the root vault key is shared with them.
+func (s *Service) ListMembers() (core.MemberList, error) {
+	list := make(core.MemberList, 0)
+	legacy, err := s.isLegacy()
+	if err != nil {
+		return nil, err
+	}
+	if legacy {
+		users, err := s.keyRepo.ListUsers()
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			role := core.MemberRoleMember
+			if s.hasRootAccess(user) {
+				role = core.MemberRoleOwner
+			}
+			list = append(list, core.MemberEntry{Member: core.Member{PublicKey: user, Role: role}, Verified: true})
+		}
+		return list, nil
+	}
+	members, err := s.memberRepo.ListMembers()
+	if err != nil {
+		return nil, err
+	}
+	invites, err := s.invites()
+	if err != nil {
+		return nil, err
+	}
+	firstOwner, err := s.pinFirstOwner(members)
+	if err != nil {
+		return nil, err
+	}
+	chain := chainMembers(members, invites, firstOwner)
+	revoked, err := s.revokedMembers(chain)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		_, ok := chain[member.PublicKey]
+		_, isRevoked := revoked[member.PublicKey]
+		list = append(list, core.MemberEntry{Member: member, Verified: ok, Revoked: isRevoked})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// CheckRevoke returns an error if the current user cannot revoke the member with the specified public key.
+// The current user must be a verified owner, verified before the member, and cannot revoke themselves.
+func (s *Service) CheckRevoke(publicKey string) error {
+	if _, err := core.NewPublicKeyFromEncoded(publicKey); err != nil {
+		return err
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	if userId == publicKey {
+		return ErrRevokeSelf
+	}
+	legacy, err := s.isLegacy()
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if !s.hasRootAccess(userId) {
+			return ErrNotMember
+		}
+		return nil
+	}
+	verified, err := s.verifiedMembers()
+	if err != nil {
+		return err
+	}
+	revoker, ok := verified[userId]
+	if !ok {
+		return ErrNotMember
+	}
+	if revoker.Role != core.MemberRoleOwner {
+		return ErrOwnerRequired
+	}
+	chain, err := s.chain()
+	if err != nil {
+		return err
+	}
+	ranks := chainRanks(chain)
+	if rank, ok := ranks[publicKey]; ok && rank < ranks[userId] {
+		return ErrRevokeEarlierMember
+	}
+	return nil
+}
+
+// Revoke revokes the membership of the user with the specified public key, and removes the join request of the user.
+// The revocation is signed by the current user, who must be a verified owner.
+// It does not remove the keys shared with the user.
+func (s *Service) Revoke(publicKey string) error {
+	if err := s.CheckRevoke(publicKey); err != nil {
+		return err
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	if err := s.initLegacyRegistry(userId); err != nil {
+		return err
+	}
+	revocation := core.Revocation{
+		PublicKey: publicKey,
+		RevokedBy: userId,
+		RevokedAt: time.Now().UTC(),
+	}
+	sig, err := s.keyService.Sign(revocation.SignedMessage())
+	if err != nil {
+		return err
+	}
+	revocation.Signature = signature.Encode(sig)
+	if err := s.memberRepo.SaveRevocation(revocation); err != nil {
+		return err
+	}
+	if err := s.memberRepo.RemoveJoinRequest(publicKey); err != nil && !errors.Is(err, repositories.ErrJoinRequestNotFound) {
+		return err
+	}
+	return s.auditLogger.Log(core.AuditRevokeMember, "/", publicKey, "")
+}
+
+// CheckInviter returns an error if the current user cannot create invitations: the user must be a verified member,
+// and not a guest. In a repository created before the registry, the registry is initialized with the user, as for Approve.
+func (s *Service) CheckInviter() error {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	legacy, err := s.isLegacy()
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if !s.hasRootAccess(userId) {
+			return ErrNotMember
+		}
+		return s.initLegacyRegistry(userId)
+	}
+	verified, err := s.verifiedMembers()
+	if err != nil {
+		return err
+	}
+	inviter, ok := verified[userId]
+	if !ok {
+		return ErrNotMember
+	}
+	if inviter.Role == core.MemberRoleGuest {
+		return ErrGuest
+	}
+	return nil
+}
+
+// AddGuest adds the current user to the registry as a guest, added by the member who created the redeemed invitation.
+// The entry is signed with the private key of the invitation, derived from its secret.
+func (s *Service) AddGuest(invite core.Invite, inviteKey core.PrivateKey, name string) error {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	if invite.RedeemedBy != userId {
+		return ErrInvalidInvite
+	}
+	kemKey, err := s.ownKemKey()
+	if err != nil {
+		return err
+	}
+	member := core.Member{
+		PublicKey: userId,
+		Name:      name,
+		Role:      core.MemberRoleGuest,
+		AddedBy:   invite.CreatedBy,
+		AddedAt:   time.Now().UTC(),
+		Invite:    invite.Id,
+		KemKey:    kemKey,
+	}
+	sig, err := signature.Sign(inviteKey, member.SignedMessage())
+	if err != nil {
+		return err
+	}
+	member.Signature = signature.Encode(sig)
+	return s.memberRepo.SaveMember(member)
+}
+
+// ListJoinRequests returns the pending join requests.
+func (s *Service) ListJoinRequests() (core.JoinRequestList, error) {
+	return s.memberRepo.ListJoinRequests()
+}
+
+// addMember adds the user to the registry with the given role and ML-KEM-768 public key, empty for classic keys,
+// signed by the current user. The policy of the repository is only set in the entry of the first owner.
+func (s *Service) addMember(publicKey string, name string, role core.MemberRole, kemKey string, policy *core.RepoPolicy) error {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return err
+	}
+	member := core.Member{
+		PublicKey: publicKey,
+		Name:      name,
+		Role:      role,
+		AddedBy:   userId,
+		AddedAt:   time.Now().UTC(),
+		KemKey:    kemKey,
+		Policy:    policy,
+	}
+	sig, err := s.keyService.Sign(member.SignedMessage())
+	if err != nil {
+		return err
+	}
+	member.Signature = signature.Encode(sig)
+	return s.memberRepo.SaveMember(member)
+}
+
+// initLegacyRegistry initializes the registry of a repository created before it, with the user as first owner,
+// if the user has access to the root vault.
+func (s *Service) initLegacyRegistry(userId string) error {
+	legacy, err := s.isLegacy()
+	if err != nil || !legacy || !s.hasRootAccess(userId) {
+		return err
+	}
+	return s.initRegistry(userId, "", core.RepoPolicy{})
+}
+
+// initRegistry pins the user as the first owner and adds the user to the registry as an owner, with the policy of the repository.
+func (s *Service) initRegistry(userId string, name string, policy core.RepoPolicy) error {
+	if s.trustRoot != nil {
+		if err := s.trustRoot.SetFirstOwner(userId); err != nil {
+			return err
+		}
+	}
+	kemKey, err := s.ownKemKey()
+	if err != nil {
+		return err
+	}
+	return s.addMember(userId, name, core.MemberRoleOwner, kemKey, &policy)
+}
+
+// firstOwner returns the pinned public key of the first owner, empty if it is not pinned.
+func (s *Service) firstOwner() string {
+	if s.trustRoot == nil {
+		return ""
+	}
+	return s.trustRoot.GetFirstOwner()
+}
+
+// pinFirstOwner returns the pinned public key of the first owner. If it is not pinned yet, the only owner of the members
+// who signed their own entry is pinned, trusted on first use, and it is empty if no such owner can be found.
+func (s *Service) pinFirstOwner(members []core.Member) (string, error) {
+	firstOwner := s.firstOwner()
+	if firstOwner != "" || s.trustRoot == nil {
+		return firstOwner, nil
+	}
+	for publicKey, m := range chainMembers(members, nil, "") {
+		if m.AddedBy == publicKey {
+			return publicKey, s.trustRoot.SetFirstOwner(publicKey)
+		}
+	}
+	return "", nil
+}
+
+// policy returns the policy of the repository signed by the first owner in their entry.
+// The repositories created before the registry have no policy.
+func (s *Service) policy() (core.RepoPolicy, error) {
+	legacy, err := s.isLegacy()
+	if err != nil || legacy {
+		return core.RepoPolicy{}, err
+	}
+	chain, err := s.chain()
+	if err != nil {
+		return core.RepoPolicy{}, err
+	}
+	for publicKey, m := range chain {
+		if m.AddedBy != publicKey {
+			continue
+		}
+		// The registries initialized before the policies have no policy
+		if m.Policy == nil {
+			return core.RepoPolicy{}, nil
+		}
+		return *m.Policy, nil
+	}
+	return core.RepoPolicy{}, ErrPolicyNotVerified
+}
+
+// isLegacy returns true if the repository was created before the registry, whose members are the users a key is shared with.
+// It returns ErrRegistryMissing if the registry is missing but the first owner is pinned, the registry was removed.
+func (s *Service) isLegacy() (bool, error) {
+	if s.memberRepo.IsInitialized() {
+		return false, nil
+	}
+	if s.firstOwner() != "" {
+		return false, ErrRegistryMissing
+	}
+	return true, nil
+}
+
+// ownKemKey returns the ML-KEM-768 public key of the current user encoded to raw base64, empty if the key is not hybrid.
+func (s *Service) ownKemKey() (string, error) {
+	kemKey, err := s.keyService.GetKemPublicKey()
+	if err != nil || kemKey == nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(kemKey), nil
+}
+
+// verifiedMembers returns the members whose chain of signatures leads to the first owner and who are not revoked,
+// by public key.
+func (s *Service) verifiedMembers() (map[string]core.Member, error) {
+	verified, err := s.chain()
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := s.revokedMembers(verified)
+	if err != nil {
+		return nil, err
+	}
+	for publicKey := range revoked {
+		delete(verified, publicKey)
+	}
+	return verified, nil
+}
+
+// chain returns the members whose chain of signatures leads to the first owner, revoked or not, by public key.
+// It returns ErrRegistryMissing if the registry is missing but the first owner is pinned.
+func (s *Service) chain() (map[string]core.Member, error) {
+	if _, err := s.isLegacy(); err != nil {
+		return nil, err
+	}
+	members, err := s.memberRepo.ListMembers()
+	if err != nil {
+		return nil, err
+	}
+	invites, err := s.invites()
+	if err != nil {
+		return nil, err
+	}
+	firstOwner, err := s.pinFirstOwner(members)
+	if err != nil {
+		return nil, err
+	}
+	return chainMembers(members, invites, firstOwner), nil
+}
+
+// revokedMembers returns the public keys of the members revoked by an owner of the chain verified before them.
+// The revocations are applied in the order of their revokers in the chain, and the revocations signed by a revoked owner
+// are ignored: an owner is only revoked by owners verified before it, whose revocations are applied first.
+// The time of the revocations is chosen by their signer and is not used.
+func (s *Service) revokedMembers(chain map[string]core.Member) (map[string]struct{}, error) {
+	revocations, err := s.memberRepo.ListRevocations()
+	if err != nil {
+		return nil, err
+	}
+	ranks := chainRanks(chain)
+	valid := make([]core.Revocation, 0, len(revocations))
+	for _, r := range revocations {
+		revoker, ok := chain[r.RevokedBy]
+		if !ok || revoker.Role != core.MemberRoleOwner || r.RevokedBy == r.PublicKey {
+			continue
+		}
+		if rank, ok := ranks[r.PublicKey]; ok && rank < ranks[r.RevokedBy] {
+			continue
+		}
+		valid = append(valid, r)
+	}
+	sort.SliceStable(valid, func(i, j int) bool { return ranks[valid[i].RevokedBy] < ranks[valid[j].RevokedBy] })
+	revoked := make(map[string]struct{})
+	for _, r := range valid {
+		if _, ok := revoked[r.RevokedBy]; ok {
+			continue
+		}
+		if !signature.VerifyEncoded(r.RevokedBy, r.SignedMessage(), r.Signature) {
+			continue
+		}
+		revoked[r.PublicKey] = struct{}{}
+	}
+	return revoked, nil
+}
+
+// invites returns the invitations of the registry, by id.
+func (s *Service) invites() (map[string]core.Invite, error) {
+	list, err := s.memberRepo.ListInvites()
+	if err != nil {
+		return nil, err
+	}
+	invites := make(map[string]core.Invite, len(list))
+	for _, invite := range list {
+		invites[invite.Id] = invite
+	}
+	return invites, nil
+}
+
+// chainMembers returns the members whose chain of signatures leads to the first owner, by public key.
+// The first owner is the owner with the pinned public key firstOwner who signed their own entry.
+// If the first owner is not pinned, it is the only owner who signed their own entry,
+// and no member is verified if several owners signed their own entry.
+func chainMembers(members []core.Member, invites map[string]core.Invite, firstOwner string) map[string]core.Member {
+	verified := make(map[string]core.Member)
+	var first *core.Member
+	for i, m := range members {
+		if m.AddedBy != m.PublicKey || m.Role != core.MemberRoleOwner || !isSigned(m) {
+			continue
+		}
+		if firstOwner != "" && m.PublicKey != firstOwner {
+			continue
+		}
+		if first != nil {
+			return verified
+		}
+		first = &members[i]
+	}
+	if first == nil {
+		return verified
+	}
+	verified[first.PublicKey] = *first
+	// Verify the members approved by verified members until no more member can be verified
+	for changed := true; changed; {
+		changed = false
+		for _, m := range members {
+			if _, ok := verified[m.PublicKey]; ok {
+				continue
+			}
+			approver, ok := verified[m.AddedBy]
+			if !ok || m.AddedBy == m.PublicKey || approver.Role == core.MemberRoleGuest {
+				continue
+			}
+			if m.Invite != "" {
+				if m.Role != core.MemberRoleGuest || !isInvited(m, invites[m.Invite]) {
+					continue
+				}
+			} else if !isSigned(m) {
+				continue
+			}
+			if m.Role == core.MemberRoleOwner && approver.Role != core.MemberRoleOwner {
+				continue
+			}
+			verified[m.PublicKey] = m
+			changed = true
+		}
+	}
+	return verified
+}
+
+// vaultKey returns the id of the key of the vault of the directory at path,
+// with the id and path of the vault the key is stored in, empty for the root vault.
+func (s *Service) vaultKey(path string) (keyId string, startVaultId string, startVaultPath string, err error) {
+	vault, err := s.vaultRepo.GetVaultByPath(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	if path == "/" {
+		return vault.KeyId, "", "", nil
+	}
+	parentPath, parent, err := s.vaultRepo.GetVaultParent(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	return vault.KeyId, parent.Id, parentPath, nil
+}
+
+// hasKeyShare returns true if a key is shared with the user, in any directory of the repository.
+func (s *Service) hasKeyShare(userId string) bool {
+	users, err := s.keyRepo.ListUsers()
+	if err != nil {
+		return false
+	}
+	for _, user := range users {
+		if user == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// chainRanks returns the position of the members of the chain, by public key.
+// The members are ordered by the length of their chain to the first owner, then by the time they were added,
+// which is signed by the member who added them, then by public key.
+func chainRanks(chain map[string]core.Member) map[string]int {
+	depths := make(map[string]int, len(chain))
+	var depth func(m core.Member) int
+	depth = func(m core.Member) int {
+		if d, ok := depths[m.PublicKey]; ok {
+			return d
+		}
+		d := 0
+		if approver, ok := chain[m.AddedBy]; ok && m.AddedBy != m.PublicKey {
+			depths[m.PublicKey] = len(chain) // guard against cycles, the chain has none
+			d = depth(approver) + 1
+		}
+		depths[m.PublicKey] = d
+		return d
+	}
+	keys := make([]string, 0, len(chain))
+	for publicKey, m := range chain {
+		depth(m)
+		keys = append(keys, publicKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := chain[keys[i]], chain[keys[j]]
+		if depths[a.PublicKey] != depths[b.PublicKey] {
+			return depths[a.PublicKey] < depths[b.PublicKey]
+		}
+		if !a.AddedAt.Equal(b.AddedAt) {
+			return a.AddedAt.Before(b.AddedAt)
+		}
+		return a.PublicKey < b.PublicKey
+	})
+	ranks := make(map[string]int, len(keys))
+	for i, publicKey := range keys {
+		ranks[publicKey] = i
+	}
+	return ranks
+}
+
+// hasRootAccess returns true if the root vault key is shared with the user.
+func (s *Service) hasRootAccess(userId string) bool {
+	rootVault, err := s.vaultRepo.GetVaultByPath("/")
+	if err != nil {
+		return false
+	}
+	return s.keyRepo.DataKeyExist(rootVault.KeyId, userId, "")
+}
+
+// isSigned returns true if the member is signed by the member who added it.
+func isSigned(m core.Member) bool {
+	return signature.VerifyEncoded(m.AddedBy, m.SignedMessage(), m.Signature)
+}
+
+// isInvited returns true if the guest redeemed the invitation, created and signed by the member who added the guest,
+// and the entry of the guest is signed with the key pair of the invitation.
+func isInvited(m core.Member, invite core.Invite) bool {
+	return invite.Id == m.Invite &&
+		invite.CreatedBy == m.AddedBy &&
+		invite.RedeemedBy == m.PublicKey &&
+		signature.VerifyEncoded(invite.CreatedBy, invite.SignedMessage(), invite.Signature) &&
+		signature.VerifyEncoded(invite.PublicKey, m.SignedMessage(), m.Signature)
+}
@@ -0,0 +1,209 @@
+package member_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/signature"
+	"ctb-cli/repositories"
+	"ctb-cli/services/member_service"
+	"ctb-cli/test/testrepo"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forgeRevocation writes a revocation of the member signed by the revoker, bypassing the checks of Revoke.
+func forgeRevocation(t *testing.T, repoPath string, revoker testrepo.User, publicKey string, revokedAt time.Time) {
+	revocation := core.Revocation{
+		PublicKey: publicKey,
+		RevokedBy: revoker.Id,
+		RevokedAt: revokedAt,
+	}
+	sig, err := revoker.KeyStore.Sign(revocation.SignedMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocation.Signature = signature.Encode(sig)
+	if err := repositories.NewMemberRepository(repoPath).SaveRevocation(revocation); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRevokedOwnerCannotRevokeEarlierOwner(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	alice := repo.Join(t, first, core.MemberRoleOwner, "")
+	bob := repo.Join(t, first, core.MemberRoleOwner, "")
+
+	// Bob was verified after Alice and cannot revoke her
+	if err := bob.Member.CheckRevoke(alice.Id); !errors.Is(err, member_service.ErrRevokeEarlierMember) {
+		t.Fatalf("got %v, want ErrRevokeEarlierMember", err)
+	}
+	if err := alice.Member.Revoke(bob.Id); err != nil {
+		t.Fatal(err)
+	}
+	// A back-dated revocation of Alice signed by Bob is ignored
+	forgeRevocation(t, repo.Path, bob, alice.Id, time.Unix(0, 0))
+	if !first.Member.IsMember(alice.Id) {
+		t.Error("Alice is revoked by a revocation signed by a revoked owner")
+	}
+	if first.Member.IsMember(bob.Id) {
+		t.Error("Bob is not revoked")
+	}
+}
+
+func TestRevocationsOfRevokedOwnerAreIgnored(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	alice := repo.Join(t, first, core.MemberRoleOwner, "")
+	carol := repo.Join(t, alice, core.MemberRoleMember, "")
+
+	// Alice revokes Carol, then the first owner revokes Alice
+	if err := alice.Member.Revoke(carol.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Member.Revoke(alice.Id); err != nil {
+		t.Fatal(err)
+	}
+	if first.Member.IsMember(alice.Id) {
+		t.Error("Alice is not revoked")
+	}
+	if !first.Member.IsMember(carol.Id) {
+		t.Error("Carol is revoked by a revoked owner")
+	}
+}
+
+func TestFirstOwnerCannotBeRevoked(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	alice := repo.Join(t, first, core.MemberRoleOwner, "")
+
+	if err := alice.Member.CheckRevoke(first.Id); !errors.Is(err, member_service.ErrRevokeEarlierMember) {
+		t.Fatalf("got %v, want ErrRevokeEarlierMember", err)
+	}
+	forgeRevocation(t, repo.Path, alice, first.Id, time.Now().UTC())
+	if !alice.Member.IsMember(first.Id) {
+		t.Error("the first owner is revoked")
+	}
+}
+
+// forgeFirstOwner writes an owner entry of the user signed by the user, as the first owner signs their own entry.
+func forgeFirstOwner(t *testing.T, repoPath string, user testrepo.User) {
+	member := core.Member{
+		PublicKey: user.Id,
+		Role:      core.MemberRoleOwner,
+		AddedBy:   user.Id,
+		AddedAt:   time.Unix(0, 0),
+	}
+	sig, err := user.KeyStore.Sign(member.SignedMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.Signature = signature.Encode(sig)
+	if err := repositories.NewMemberRepository(repoPath).SaveMember(member); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForgedFirstOwnerIsNotVerified(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	mallory := repo.Open(t, testrepo.NewUserKey(t))
+
+	// Mallory adds herself as an owner signing her own entry, earlier than the first owner
+	forgeFirstOwner(t, repo.Path, mallory)
+	if first.Member.IsMember(mallory.Id) {
+		t.Error("a self-signed owner is verified")
+	}
+	if !first.Member.IsMember(first.Id) {
+		t.Error("the first owner is not verified")
+	}
+}
+
+func TestReplacedFirstOwnerIsNotVerified(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	mallory := repo.Open(t, testrepo.NewUserKey(t))
+
+	// Mallory replaces the registry with her own entry and pins herself in the configuration of the repository
+	if err := os.RemoveAll(filepath.Join(repo.Path, ".meta", ".members")); err != nil {
+		t.Fatal(err)
+	}
+	forgeFirstOwner(t, repo.Path, mallory)
+	config := fmt.Sprintf("version: 1\nfirstOwner: %s\n", mallory.Id)
+	if err := os.WriteFile(filepath.Join(repo.Path, ".meta", "config.yaml"), []byte(config), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// The first owner pinned on the device of the user is kept
+	if first.Member.IsMember(mallory.Id) {
+		t.Error("the replaced first owner is verified")
+	}
+	if first.Member.IsMember(first.Id) {
+		t.Error("the first owner is verified without entry")
+	}
+	// A user opening the repository for the first time on another device trusts the registry found
+	device := testrepo.Repo{Path: repo.Path, CachePath: t.TempDir()}
+	if !device.Open(t, testrepo.NewUserKey(t)).Member.IsMember(mallory.Id) {
+		t.Error("the first owner is not pinned on first use")
+	}
+}
+
+func TestMissingRegistryHasNoMember(t *testing.T) {
+	repo := testrepo.New(t)
+	first := repo.Owner(t)
+	alice := repo.Join(t, first, core.MemberRoleMember, "")
+
+	// The users a key is shared with are not members once the registry of a pinned first owner is removed
+	if err := os.RemoveAll(filepath.Join(repo.Path, ".meta", ".members")); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{first.Id, alice.Id} {
+		if first.Member.IsMember(id) {
+			t.Errorf("%s is a member without registry", id)
+		}
+	}
+	if _, err := first.Member.ListMembers(); !errors.Is(err, member_service.ErrRegistryMissing) {
+		t.Errorf("got %v, want ErrRegistryMissing", err)
+	}
+	if err := first.Member.CheckInviter(); !errors.Is(err, member_service.ErrRegistryMissing) {
+		t.Errorf("got %v, want ErrRegistryMissing", err)
+	}
+}
+
+func TestPolicyCannotBeWeakened(t *testing.T) {
+	dir := t.TempDir()
+	repo := testrepo.Repo{Path: filepath.Join(dir, "repo"), CachePath: filepath.Join(dir, "cache")}
+	first := repo.Open(t, testrepo.NewUserKey(t))
+	if err := first.Member.InitRegistry("", core.RepoPolicy{RequireHybrid: true, EncryptNames: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !first.Member.RequireHybrid() || !first.Member.EncryptNames() {
+		t.Fatal("the policy of the first owner is not applied")
+	}
+
+	// The policy removed from the entry of the first owner invalidates the entry, and the strictest policy applies
+	memberRepo := repositories.NewMemberRepository(repo.Path)
+	member, err := memberRepo.GetMember(first.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member.Policy = nil
+	if err := memberRepo.SaveMember(member); err != nil {
+		t.Fatal(err)
+	}
+	if !first.Member.RequireHybrid() || !first.Member.EncryptNames() {
+		t.Error("the policy is weakened by editing the entry of the first owner")
+	}
+	if first.Member.IsMember(first.Id) {
+		t.Error("the edited entry of the first owner is verified")
+	}
+	// So does the removal of the registry
+	if err := os.RemoveAll(filepath.Join(repo.Path, ".meta", ".members")); err != nil {
+		t.Fatal(err)
+	}
+	if !first.Member.RequireHybrid() || !first.Member.EncryptNames() {
+		t.Error("the policy is weakened by removing the registry")
+	}
+}
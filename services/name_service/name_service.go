@@ -0,0 +1,215 @@
+package name_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/name_crypto"
+	"ctb-cli/repositories"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNameKeyNotFound      = errors.New("the directory has no name key, its names are not encrypted")
+	ErrNameNotDecrypted     = errors.New("the name cannot be decrypted")
+	ErrLinkRepositoryNotSet = errors.New("the link repository is not set, the entries cannot be renamed")
+)
+
+// Service resolves the paths of the repository to the paths stored on disk.
+//
+// When the repository encrypts names, every file and directory is stored under its name encrypted with the name key
+// of the vault of its parent directory, so the path stored on disk of a path is the path stored on disk of its parent
+// joined with the encrypted name. The encryption is deterministic, so a path is resolved without listing directories,
+// but resolving it requires access to the vaults of all its parent directories.
+// The root and the system folders (".meta") are stored in plaintext.
+// The name key of a vault is replaced with its vault key, the entries of the directory are then renamed.
+type Service struct {
+	keyService core.KeyService
+	vaultRepo  repositories.VaultRepository
+	namePolicy core.NamePolicy
+	linkRepo   *repositories.LinkRepository // nil if the entries are not renamed
+
+	mu       sync.Mutex
+	enabled  *bool               // the policy, read the first time a path other than the root is resolved
+	nameKeys map[string]core.Key // the name keys by key id
+}
+
+// Make sure Service implements the PathResolver, PathEncoder and NameRotator interfaces
+var (
+	_ repositories.PathResolver = &Service{}
+	_ core.PathEncoder          = &Service{}
+	_ core.NameRotator          = &Service{}
+)
+
+// NewService creates a new instance of the name service.
+func NewService(keyService core.KeyService, vaultRepo repositories.VaultRepository, namePolicy core.NamePolicy) *Service {
+	return &Service{
+		keyService: keyService,
+		vaultRepo:  vaultRepo,
+		namePolicy: namePolicy,
+		nameKeys:   make(map[string]core.Key),
+	}
+}
+
+// SetLinkRepository sets the link repository giving the paths of the directories whose entries are renamed.
+func (s *Service) SetLinkRepository(linkRepo *repositories.LinkRepository) {
+	s.linkRepo = linkRepo
+}
+
+// ResolvePath returns the path stored on disk for the path, relative to the root of the repository.
+// It returns an error if the name key of one of the parent directories cannot be read.
+func (s *Service) ResolvePath(path string) (string, error) {
+	parts := splitPath(path)
+	if len(parts) == 0 || !s.isEnabled() {
+		return filepath.Join(parts...), nil
+	}
+	resolved := make([]string, 0, len(parts))
+	dir := string(filepath.Separator)
+	for i, part := range parts {
+		// The system folders and their content are not encrypted
+		if part == ".meta" {
+			resolved = append(resolved, parts[i:]...)
+			break
+		}
+		key, err := s.nameKey(dir)
+		if err != nil {
+			return "", err
+		}
+		encrypted, err := name_crypto.EncryptName(key, part)
+		if err != nil {
+			return "", err
+		}
+		resolved = append(resolved, encrypted)
+		dir = filepath.Join(dir, part)
+	}
+	return filepath.Join(resolved...), nil
+}
+
+// ResolveName returns the name of the entry stored on disk as name in the directory.
+// It returns false if the name cannot be decrypted, because the user has no access to the directory
+// or the entry was not stored by the repository. The system folders keep their names.
+func (s *Service) ResolveName(dir string, name string) (string, bool) {
+	if strings.HasPrefix(name, ".") || !s.isEnabled() {
+		return name, true
+	}
+	key, err := s.nameKey(filepath.Join(string(filepath.Separator), dir))
+	if err != nil {
+		return "", false
+	}
+	decrypted, err := name_crypto.DecryptName(key, name)
+	if err != nil {
+		return "", false
+	}
+	return decrypted, true
+}
+
+// EncodePath returns the rooted path stored on disk for the path.
+func (s *Service) EncodePath(path string) (string, error) {
+	resolved, err := s.ResolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(string(filepath.Separator), resolved), nil
+}
+
+// DecodePath returns the rooted path of the path stored on disk, which is relative to the root of the repository,
+// rooted or not. It returns an error if the user has no access to the name key of one of the parent directories.
+func (s *Service) DecodePath(stored string) (string, error) {
+	parts := splitPath(stored)
+	path := string(filepath.Separator)
+	for i, part := range parts {
+		if part == ".meta" {
+			return filepath.Join(append([]string{path}, parts[i:]...)...), nil
+		}
+		name, ok := s.ResolveName(path, part)
+		if !ok {
+			return "", ErrNameNotDecrypted
+		}
+		path = filepath.Join(path, name)
+	}
+	return path, nil
+}
+
+// RenameEntries renames the entries of the directory at the path, stored under their names encrypted with the old
+// name key of its vault, to their names encrypted with the new name key. The system folders keep their names,
+// and the entries whose name cannot be decrypted, which were not stored by the repository, are left as they are.
+func (s *Service) RenameEntries(dir string, oldKey core.Key, newKey core.Key) error {
+	if s.linkRepo == nil {
+		return ErrLinkRepositoryNotSet
+	}
+	absDir, err := s.linkRepo.AbsPath(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name, err := name_crypto.DecryptName(oldKey, entry.Name())
+		if err != nil {
+			continue
+		}
+		encrypted, err := name_crypto.EncryptName(newKey, name)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(absDir, entry.Name()), filepath.Join(absDir, encrypted)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEnabled returns true if the repository encrypts names.
+func (s *Service) isEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enabled == nil {
+		enabled := s.namePolicy.EncryptNames()
+		s.enabled = &enabled
+	}
+	return *s.enabled
+}
+
+// nameKey returns the name key of the vault of the directory at the rooted path.
+// The name keys are cached by key id, as the vault is read again to find its current name key.
+func (s *Service) nameKey(dir string) (core.Key, error) {
+	vault, err := s.vaultRepo.GetVaultByPath(dir)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	if vault.NameKeyId == "" {
+		return core.EmptyKey(), ErrNameKeyNotFound
+	}
+	s.mu.Lock()
+	key, ok := s.nameKeys[vault.NameKeyId]
+	s.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	keyInfo, err := s.keyService.Get(vault.NameKeyId, vault.Id, dir)
+	if err != nil {
+		return core.EmptyKey(), err
+	}
+	s.mu.Lock()
+	s.nameKeys[vault.NameKeyId] = keyInfo.Key
+	s.mu.Unlock()
+	return keyInfo.Key, nil
+}
+
+// splitPath returns the names of the path, which is relative to the root of the repository, rooted or not.
+func splitPath(path string) []string {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(filepath.Clean(filepath.Join(string(filepath.Separator), path)), string(filepath.Separator)) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -8,19 +8,27 @@ import (
 	"ctb-cli/repositories"
 	"errors"
 	"io"
+	"path/filepath"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 // Service represents the object service.
 type Service struct {
-	objectCacheRepo *repositories.ObjectCacheRepository
-	objectRepo      *repositories.ObjectRepository
-	downloader      core.CloudStorage
+	objectCacheRepo   *repositories.ObjectCacheRepository
+	objectRepo        *repositories.ObjectRepository
+	downloader        core.CloudStorage
+	encryptionPolicy  core.EncryptionPolicy  // nil if the objects are encrypted with the default algorithm
+	compressionPolicy core.CompressionPolicy // nil if the objects are not compressed
 
 	uploadChan chan uploadChanItem
 }
 
+var (
+	ErrObjectNotFound = errors.New("object not found in repository")
+)
+
 // Make sure Service implements the core.ObjectService interface
 var _ core.ObjectService = (*Service)(nil)
 
@@ -43,6 +51,16 @@ func NewService(cache *repositories.ObjectCacheRepository, objectRepo *repositor
 	return service
 }
 
+// SetEncryptionPolicy sets the policy selecting the AEAD algorithm the objects are encrypted with.
+func (o *Service) SetEncryptionPolicy(policy core.EncryptionPolicy) {
+	o.encryptionPolicy = policy
+}
+
+// SetCompressionPolicy sets the policy selecting the compression of the objects of the files of each directory.
+func (o *Service) SetCompressionPolicy(policy core.CompressionPolicy) {
+	o.compressionPolicy = policy
+}
+
 // Read reads the object with the specified ID from the object service.
 // It populates the provided buffer with the object data starting from the specified offset.
 // Returns the number of bytes read and any error encountered.
@@ -149,12 +167,24 @@ func (o *Service) downloadToObject(link core.Link) error {
 	return nil
 }
 
-// encryptWriter encrypts the data written to the provided writer using the specified key and file ID.
+// encryptWriter encrypts the data written to the provided writer using the specified key and the ID of the link,
+// with the algorithm of the encryption policy. The data is compressed first with the compression of the directory of the link,
+// unless the name of the file tells its content is already compressed.
 // It returns a new io.WriteCloser that wraps the original writer and performs encryption.
 // The returned writer should be closed after the writing process is done to flush the remaining data and finalize the encryption.
 // If any error occurs during the process, it returns an error.
-func (o *Service) encryptWriter(writer io.Writer, fileId string, key *core.KeyInfo) (write io.WriteCloser, err error) {
-	return file_crypto.NewWriter(writer, key, fileId)
+func (o *Service) encryptWriter(writer io.Writer, link core.Link, key *core.KeyInfo) (write io.WriteCloser, err error) {
+	alg := file_crypto.DefaultAlg
+	if o.encryptionPolicy != nil {
+		if policyAlg := o.encryptionPolicy.GetAlgorithm(); policyAlg != "" {
+			alg = policyAlg
+		}
+	}
+	compression := file_crypto.CompressionNone
+	if o.compressionPolicy != nil && !file_crypto.IsCompressedName(link.Path) {
+		compression = o.compressionPolicy.GetCompression(filepath.Dir(link.Path))
+	}
+	return file_crypto.NewCompressedWriter(writer, key, link.Id(), alg, compression)
 }
 
 // decryptReader decrypts the data from the given reader using the provided key.
@@ -191,10 +221,14 @@ func (o *Service) GetKeyIdByObjectId(link core.Link) (string, error) {
 
 // Commit adds the object to the encrypt channel queue.
 // It takes a link and a key as parameters and returns an error if any.
+// If the encryption fails, the object stays open for writing, so the commit can be retried.
 func (o *Service) Commit(link core.Link, key *core.KeyInfo) error {
 	o.objectCacheRepo.AdToCommitting(link.Id())
 	// Encrypt the object
-	o.encrypt(link, key)
+	if err := o.encrypt(link, key); err != nil {
+		o.objectCacheRepo.RemoveFromCommitting(link.Id())
+		return err
+	}
 	return nil
 }
 
@@ -205,11 +239,99 @@ func (o *Service) RemoveFromCache(id string) error {
 	return o.objectCacheRepo.FlushFromRead(id)
 }
 
+// Discard removes the object of the link from the write cache, dropping the changes that were not committed.
+// It returns nil if the object is not open for writing.
+func (o *Service) Discard(link core.Link) error {
+	if !o.IsOpenForWrite(link) {
+		return nil
+	}
+	if err := o.objectCacheRepo.FlushFromWrite(link.Id()); err != nil {
+		return err
+	}
+	return o.objectCacheRepo.FlushFromRead(link.Id())
+}
+
+// AddToJournal records the object of the link as not committed, so it is kept in the write cache.
+func (o *Service) AddToJournal(repo string, link core.Link) error {
+	return o.objectCacheRepo.AddToJournal(repositories.JournalEntry{
+		Repo:     repo,
+		Path:     link.Path,
+		ObjectId: link.Id(),
+		Time:     time.Now(),
+	})
+}
+
+// GetJournal returns the objects that were not committed.
+func (o *Service) GetJournal() ([]repositories.JournalEntry, error) {
+	return o.objectCacheRepo.GetJournal()
+}
+
+// RemoveFromJournal removes the object with the specified ID from the journal.
+func (o *Service) RemoveFromJournal(id string) error {
+	return o.objectCacheRepo.RemoveFromJournal(id)
+}
+
+// WipeCache removes the plaintext objects cached by this service,
+// except the journaled objects which are not committed yet.
+func (o *Service) WipeCache() error {
+	entries, err := o.objectCacheRepo.GetJournal()
+	if err != nil {
+		return err
+	}
+	keep := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keep = append(keep, entry.ObjectId)
+	}
+	return o.objectCacheRepo.Wipe(keep)
+}
+
 // IsOpenForWrite returns true if the object with the specified ID is open for writing.
 func (o *Service) IsOpenForWrite(link core.Link) bool {
 	return o.objectCacheRepo.IsOpenForWrite(link.Id())
 }
 
+// OpenObject opens the encrypted object of the link, downloading it first if it is not in the repository.
+// It returns the object and its size.
+func (o *Service) OpenObject(link core.Link) (io.ReadCloser, int64, error) {
+	if !o.objectRepo.IsInRepo(link) {
+		if err := o.downloadToObject(link); err != nil {
+			return nil, 0, err
+		}
+	}
+	size, err := o.objectRepo.GetObjectSize(link)
+	if err != nil {
+		return nil, 0, err
+	}
+	object, err := o.objectRepo.OpenObject(link)
+	if err != nil {
+		return nil, 0, err
+	}
+	return object, size, nil
+}
+
+// IsInRepo returns true if the encrypted object of the link exists in the repository.
+func (o *Service) IsInRepo(link core.Link) bool {
+	return o.objectRepo.IsInRepo(link)
+}
+
+// VerifyObject decrypts the whole encrypted object of the link with the key, discarding the plaintext.
+// It returns the size of the plaintext, or an error if the object cannot be decrypted or fails authentication.
+func (o *Service) VerifyObject(link core.Link, key *core.KeyInfo) (int64, error) {
+	if !o.objectRepo.IsInRepo(link) {
+		return 0, ErrObjectNotFound
+	}
+	openObject, err := o.objectRepo.OpenObject(link)
+	if err != nil {
+		return 0, err
+	}
+	defer openObject.Close()
+	decryptedReader, err := o.decryptReader(openObject, key)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(io.Discard, decryptedReader)
+}
+
 // ValidateObject validates the object with the specified ID.
 // It returns an error if the validation fails.
 func (o *Service) ValidateObject(link core.Link, key *core.KeyInfo) error {
@@ -29,7 +29,7 @@ func (o *Service) encrypt(link core.Link, key *core.KeyInfo) (err error) {
 	defer file.Close()
 
 	//Create encrypted writer
-	encryptedWriter, err := o.encryptWriter(file, link.Id(), key)
+	encryptedWriter, err := o.encryptWriter(file, link, key)
 	if err != nil {
 		return fmt.Errorf("failed to create encrypted writer: %w", err)
 	}
@@ -74,7 +74,6 @@ func (o *Service) encrypt(link core.Link, key *core.KeyInfo) (err error) {
 	}
 
 	log.Debugf("File Encrypted: %s", link.Id())
-	fmt.Printf("File Encrypted: %s \n", link.Id())
 
 	//Trigger upload
 	//o.uploadChan <- uploadChanItem{id: link.Id(), path: link.Path}
@@ -98,7 +97,10 @@ func (o *Service) StartUploadRoutine() {
 // upload uploads the file with the specified ID.
 func (o *Service) upload(id string, path string) error {
 	// Get the dir of the object using the object repository
-	objectPath := o.objectRepo.GetPath(id, path)
+	objectPath, err := o.objectRepo.GetPath(id, path)
+	if err != nil {
+		return err
+	}
 	// Open the file
 	file, err := os.Open(objectPath)
 	if err != nil {
@@ -0,0 +1,229 @@
+package offboard_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/services/contact_service"
+	"ctb-cli/services/group_service"
+	"ctb-cli/services/member_service"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrUnresolvedPaths = errors.New("the names of some directories cannot be decrypted, the user cannot be offboarded from them")
+)
+
+// Service offboards users from the repository.
+//
+// Offboarding removes every data key shared with the user, removes the user from the groups and replaces their key pairs,
+// replaces the key of every vault the user could reach, removes the contact of the user and revokes their membership.
+// The user can still open the objects written before with the keys they may have kept,
+// but not the objects written afterwards.
+type Service struct {
+	keyService     core.KeyService
+	keyRepo        repositories.KeyRepository
+	vaultRepo      repositories.VaultRepository
+	linkRepo       *repositories.LinkRepository
+	groupRepo      repositories.GroupRepository
+	groupService   *group_service.Service
+	memberService  *member_service.Service
+	contactService *contact_service.Service
+	auditLogger    core.AuditLogger
+}
+
+// NewService creates a new instance of the offboard service.
+func NewService(
+	keyService core.KeyService,
+	keyRepo repositories.KeyRepository,
+	vaultRepo repositories.VaultRepository,
+	linkRepo *repositories.LinkRepository,
+	groupRepo repositories.GroupRepository,
+	groupService *group_service.Service,
+	memberService *member_service.Service,
+	contactService *contact_service.Service,
+	auditLogger core.AuditLogger,
+) *Service {
+	return &Service{
+		keyService:     keyService,
+		keyRepo:        keyRepo,
+		vaultRepo:      vaultRepo,
+		linkRepo:       linkRepo,
+		groupRepo:      groupRepo,
+		groupService:   groupService,
+		memberService:  memberService,
+		contactService: contactService,
+		auditLogger:    auditLogger,
+	}
+}
+
+// Offboard offboards the user with the specified public key. The current user must be an owner of the repository
+// with access to the vaults the user could reach. If dryRun is true, the changes are only reported.
+// The directories whose names cannot be decrypted are reported, and no change is made if there are any.
+func (s *Service) Offboard(userId string, dryRun bool) (core.OffboardReport, error) {
+	if err := s.memberService.CheckRevoke(userId); err != nil {
+		return core.OffboardReport{}, err
+	}
+	report, err := s.plan(userId)
+	if err != nil {
+		return core.OffboardReport{}, err
+	}
+	report.DryRun = dryRun
+	if dryRun {
+		return report, nil
+	}
+	if len(report.Unresolved) > 0 {
+		return report, ErrUnresolvedPaths
+	}
+	for i, action := range report.Actions {
+		if err := s.apply(userId, action); err != nil {
+			return report, fmt.Errorf("%s %s failed after %d of %d changes, run offboard again to finish: %v", action.Kind, action.Path, i, len(report.Actions), err)
+		}
+	}
+	return report, nil
+}
+
+// plan returns the changes to offboard the user, in the order they are applied.
+func (s *Service) plan(userId string) (core.OffboardReport, error) {
+	report := core.OffboardReport{User: userId, Actions: make([]core.OffboardAction, 0)}
+	// Data keys shared with the user
+	shares, err := s.keyRepo.ListRecipientDataKeys(userId)
+	var unresolved *repositories.UnresolvedPathsError
+	if errors.As(err, &unresolved) {
+		report.Unresolved = append(report.Unresolved, unresolved.Paths...)
+	} else if err != nil {
+		return report, err
+	}
+	for _, path := range sortedPaths(shares) {
+		for _, keyId := range shares[path] {
+			report.Add(core.OffboardRemoveKeyShare, repoPath(path), keyId, "remove key %s shared with the user", keyId)
+		}
+	}
+	// Groups of the user
+	groups, err := s.groupRepo.ListUserGroups(userId)
+	if err != nil {
+		return report, err
+	}
+	for _, groupId := range groups {
+		name := groupId
+		if group, err := s.groupRepo.GetGroup(groupId); err == nil {
+			name = group.Name
+		}
+		members, err := s.groupRepo.ListMembers(groupId)
+		if err != nil {
+			return report, err
+		}
+		if len(members) == 1 {
+			report.Add(core.OffboardRemoveFromGroup, core.GroupPrefix+name, groupId, "remove the group and the keys shared with it, the user is its last member")
+		} else {
+			report.Add(core.OffboardRemoveFromGroup, core.GroupPrefix+name, groupId, "remove the user from the group and replace the group key pair")
+		}
+	}
+	// Vaults the user could reach, directly or through a group
+	holders := append([]string{userId}, groups...)
+	if err := s.planVaults(&report, "/", false, holders); err != nil {
+		return report, err
+	}
+	// Contact and membership
+	contacts, err := s.contactService.List()
+	if err != nil {
+		return report, err
+	}
+	for _, contact := range contacts {
+		if contact.PublicKey == userId {
+			report.Add(core.OffboardRemoveContact, "/", userId, "remove contact %s", contact.Name)
+		}
+	}
+	report.Add(core.OffboardRevokeMember, "/", userId, "revoke the membership of the user")
+	sort.Strings(report.Unresolved)
+	return report, nil
+}
+
+// planVaults adds the rotation of the vault at the path to the report if the holders could reach it,
+// and walks the sub directories. A vault is reachable if its key is shared with a holder, or if its parent is reachable.
+// The sub directories whose names cannot be decrypted are added to the unresolved directories of the report.
+func (s *Service) planVaults(report *core.OffboardReport, path string, parentReachable bool, holders []string) error {
+	reachable := parentReachable
+	if vault, err := s.vaultRepo.GetVaultByPath(path); err == nil {
+		parentPath, _, err := s.vaultRepo.GetVaultParent(path)
+		if err != nil {
+			return err
+		}
+		for _, holder := range holders {
+			if s.keyRepo.DataKeyExist(vault.KeyId, holder, parentPath) {
+				reachable = true
+			}
+		}
+		if reachable {
+			report.Add(core.OffboardRotateVaultKey, path, vault.KeyId, "replace the key of the vault")
+		}
+	}
+	subFiles, unresolved, err := s.linkRepo.ListSubFiles(path)
+	if err != nil {
+		return err
+	}
+	for _, sub := range unresolved {
+		if p := filepath.Join(path, sub.Name()); sub.IsDir() && !contains(report.Unresolved, p) {
+			report.Unresolved = append(report.Unresolved, p)
+		}
+	}
+	sort.Slice(subFiles, func(i, j int) bool { return subFiles[i].Name() < subFiles[j].Name() })
+	for _, sub := range subFiles {
+		if !sub.IsDir() || sub.Name() == ".meta" {
+			continue
+		}
+		if err := s.planVaults(report, filepath.Join(path, sub.Name()), reachable, holders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies a change of the plan.
+func (s *Service) apply(userId string, action core.OffboardAction) error {
+	switch action.Kind {
+	case core.OffboardRemoveKeyShare:
+		if err := s.keyService.Unshare(action.Target, userId, action.Path); err != nil {
+			return err
+		}
+		return s.auditLogger.Log(core.AuditUnshare, action.Path, userId, action.Target)
+	case core.OffboardRemoveFromGroup:
+		_, err := s.groupService.RemoveAndRotate(action.Target, userId)
+		return err
+	case core.OffboardRotateVaultKey:
+		_, err := s.keyService.RotateVaultKey(action.Path)
+		return err
+	case core.OffboardRemoveContact:
+		return s.contactService.Remove(userId)
+	case core.OffboardRevokeMember:
+		return s.memberService.Revoke(userId)
+	}
+	return nil
+}
+
+// sortedPaths returns the paths of the data keys in a stable order.
+func sortedPaths(shares map[string][]string) []string {
+	paths := make([]string, 0, len(shares))
+	for path := range shares {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// repoPath returns the path relative to the root of the repository as a rooted path.
+func repoPath(path string) string {
+	return filepath.Join(string(filepath.Separator), path)
+}
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
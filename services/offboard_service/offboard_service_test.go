@@ -0,0 +1,134 @@
+package offboard_service_test
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/repositories"
+	"ctb-cli/test/testrepo"
+	"io"
+	"testing"
+)
+
+// newTestRepo initializes a repository with the file a/b/file.
+func newTestRepo(t *testing.T) testrepo.Repo {
+	repo := testrepo.New(t, "a", "a/b")
+	repo.WriteFile(t, "a/b/file", []byte("content"))
+	return repo
+}
+
+// vaultKeyIds returns the key ids of the vaults at the paths.
+func vaultKeyIds(t *testing.T, repoPath string, paths ...string) []string {
+	keyIds := make([]string, 0, len(paths))
+	for _, path := range paths {
+		vault, err := repositories.NewVaultRepositoryFile(repoPath).GetVaultByPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyIds = append(keyIds, vault.KeyId)
+	}
+	return keyIds
+}
+
+func TestOffboard(t *testing.T) {
+	repo := newTestRepo(t)
+	first := repo.Owner(t)
+	alice := repo.Join(t, first, core.MemberRoleMember, "/a")
+	if _, err := first.Group.Create("team"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Group.AddMember("team", alice.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Contact.Add("alice", "", alice.Id, false); err != nil {
+		t.Fatal(err)
+	}
+	before := vaultKeyIds(t, repo.Path, "/", "/a", "/a/b")
+
+	// A dry run reports the changes without applying them
+	report, err := first.Offboard.Offboard(alice.Id, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind core.OffboardActionKind
+		path string
+	}{
+		{core.OffboardRemoveKeyShare, "/"},
+		{core.OffboardRemoveFromGroup, core.GroupPrefix + "team"},
+		{core.OffboardRotateVaultKey, "/a"},
+		{core.OffboardRotateVaultKey, "/a/b"},
+		{core.OffboardRemoveContact, "/"},
+		{core.OffboardRevokeMember, "/"},
+	}
+	if len(report.Actions) != len(want) {
+		t.Fatalf("got %d actions, want %d:\n%s", len(report.Actions), len(want), report)
+	}
+	for i, action := range report.Actions {
+		if action.Kind != want[i].kind || action.Path != want[i].path {
+			t.Errorf("action %d: got %s %s, want %s %s", i, action.Kind, action.Path, want[i].kind, want[i].path)
+		}
+	}
+	if !first.Member.IsMember(alice.Id) {
+		t.Fatal("the dry run revoked the user")
+	}
+	if after := vaultKeyIds(t, repo.Path, "/", "/a", "/a/b"); after[1] != before[1] || after[2] != before[2] {
+		t.Fatal("the dry run replaced the vault keys")
+	}
+
+	// The offboarding revokes the user and replaces the keys of the vaults the user could reach
+	if _, err := first.Offboard.Offboard(alice.Id, false); err != nil {
+		t.Fatal(err)
+	}
+	if first.Member.IsMember(alice.Id) {
+		t.Error("the user is still a member")
+	}
+	after := vaultKeyIds(t, repo.Path, "/", "/a", "/a/b")
+	if after[0] != before[0] {
+		t.Error("the key of the root vault is replaced, the user could not reach it")
+	}
+	if after[1] == before[1] || after[2] == before[2] {
+		t.Error("the keys of the vaults the user could reach are not replaced")
+	}
+	shares, err := repositories.NewKeyRepositoryFile(repo.Path).ListRecipientDataKeys(alice.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 0 {
+		t.Errorf("keys are still shared with the user: %v", shares)
+	}
+	if groups, err := repositories.NewGroupRepositoryFile(repo.Path).ListUserGroups(alice.Id); err != nil || len(groups) != 0 {
+		t.Errorf("the user is still in groups %v: %v", groups, err)
+	}
+	if contacts, err := first.Contact.List(); err != nil || len(contacts) != 0 {
+		t.Errorf("the contact of the user is not removed: %v, %v", contacts, err)
+	}
+	root, err := repositories.NewVaultRepositoryFile(repo.Path).GetVaultByPath("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.KeyStore.Get(after[1], root.Id, "/"); err == nil {
+		t.Error("the user can load the new key of the vault")
+	}
+
+	// The first owner still reads the files
+	fsys := repo.OpenFS(t)
+	file, err := fsys.Open("a/b/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "content" {
+		t.Errorf("got %q, want %q", content, "content")
+	}
+}
+
+func TestOffboardRejectsFirstOwner(t *testing.T) {
+	repo := newTestRepo(t)
+	first := repo.Owner(t)
+	if _, err := first.Offboard.Offboard(first.Id, true); err == nil {
+		t.Error("the first owner can be offboarded")
+	}
+}
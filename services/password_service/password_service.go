@@ -0,0 +1,154 @@
+package password_service
+
+import (
+	"ctb-cli/core"
+	"ctb-cli/crypto/key_crypto"
+	"ctb-cli/repositories"
+	"ctb-cli/services/share_service"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+var (
+	ErrMissingPassphrase = errors.New("the passphrase is empty")
+	ErrUnknownPassphrase = errors.New("no password recipient matches the passphrase")
+)
+
+// passwordName is the name of the password recipients without label in the access lists.
+const passwordName = "password"
+
+// Service manages the password recipients of the repository.
+//
+// A password recipient is a key pair derived from a passphrase and a salt, for users who do not manage a key pair.
+// Keys are shared with its public key like with any other recipient, so the shares follow the rotations of the vault keys,
+// and the user unlocks them by giving the passphrase instead of a private key.
+// The same passphrase always unlocks the same recipient, so that several paths can be shared with it.
+type Service struct {
+	keyService   core.KeyService
+	keyRepo      repositories.KeyRepository
+	shareService *share_service.Service
+}
+
+// NewService creates a new instance of the password service.
+func NewService(keyService core.KeyService, keyRepo repositories.KeyRepository, shareService *share_service.Service) *Service {
+	return &Service{
+		keyService:   keyService,
+		keyRepo:      keyRepo,
+		shareService: shareService,
+	}
+}
+
+// Share shares the file or directory at the specified path with the password recipient of the passphrase,
+// which is created with the given label if no recipient matches the passphrase yet.
+// If expiresAt is not zero, the share expires at that time.
+// Password recipients have an X25519 key pair only, so they cannot be used in a repository requiring hybrid keys.
+func (s *Service) Share(path string, passphrase string, label string, expiresAt time.Time) (core.PasswordRecipient, error) {
+	if err := s.keyService.CheckX25519Recipient(); err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	recipient, _, err := s.find(passphrase)
+	if errors.Is(err, ErrUnknownPassphrase) {
+		recipient, err = s.create(passphrase, label)
+	}
+	if err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	if err := s.shareService.ShareByPublicKey(path, recipient.PublicKey, expiresAt); err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	return recipient, nil
+}
+
+// Unlock sets the private key of the password recipient of the passphrase in the key service,
+// so that the keys shared with the recipient can be opened.
+func (s *Service) Unlock(passphrase string) (core.PasswordRecipient, error) {
+	recipient, privateKey, err := s.find(passphrase)
+	if err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	s.keyService.SetPrivateKey(privateKey)
+	return recipient, nil
+}
+
+// NameAccessList sets the label of the password recipients in the access list.
+func (s *Service) NameAccessList(list core.KeyAccessList) error {
+	recipients, err := s.keyRepo.ListPasswordRecipients()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(recipients))
+	for _, recipient := range recipients {
+		names[recipient.PublicKey] = passwordName
+		if recipient.Label != "" {
+			names[recipient.PublicKey] = passwordName + " " + recipient.Label
+		}
+	}
+	for i := range list {
+		if name, ok := names[list[i].PublicKey]; ok && list[i].Name == "" {
+			list[i].Name = name
+		}
+	}
+	return nil
+}
+
+// NameAccessReport sets the label of the password recipients in the access lists of the report.
+func (s *Service) NameAccessReport(report core.AccessReport) error {
+	for _, p := range report {
+		if err := s.NameAccessList(p.Access); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create creates a password recipient for the passphrase, with a new salt.
+func (s *Service) create(passphrase string, label string) (core.PasswordRecipient, error) {
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	recipient, err := key_crypto.NewPasswordRecipient(passphrase)
+	if err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	res := core.PasswordRecipient{
+		PublicKey: recipient.PublicKey.String(),
+		Label:     label,
+		Salt:      base64.RawStdEncoding.EncodeToString(recipient.Salt),
+		LogN:      recipient.LogN,
+		CreatedBy: userId,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.keyRepo.SavePasswordRecipient(res); err != nil {
+		return core.PasswordRecipient{}, err
+	}
+	return res, nil
+}
+
+// find returns the password recipient of the passphrase and its private key.
+// The private key is derived for every recipient until one matches, as the salts differ.
+func (s *Service) find(passphrase string) (core.PasswordRecipient, core.PrivateKey, error) {
+	if passphrase == "" {
+		return core.PasswordRecipient{}, core.EmptyPrivateKey(), ErrMissingPassphrase
+	}
+	recipients, err := s.keyRepo.ListPasswordRecipients()
+	if err != nil {
+		return core.PasswordRecipient{}, core.EmptyPrivateKey(), err
+	}
+	for _, recipient := range recipients {
+		publicKey, err := core.NewPublicKeyFromEncoded(recipient.PublicKey)
+		if err != nil {
+			continue
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(recipient.Salt)
+		if err != nil {
+			continue
+		}
+		privateKey, err := key_crypto.PasswordRecipient{PublicKey: publicKey, Salt: salt, LogN: recipient.LogN}.Unlock(passphrase)
+		if err == nil {
+			return recipient, privateKey, nil
+		}
+	}
+	return core.PasswordRecipient{}, core.EmptyPrivateKey(), ErrUnknownPassphrase
+}
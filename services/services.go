@@ -0,0 +1,106 @@
+// Package services wires the repositories and services of a repository together,
+// for the CLI and for the programs embedding the repository through bridgeguard.
+package services
+
+import (
+	"ctb-cli/objectstorage/cloud"
+	"ctb-cli/repositories"
+	"ctb-cli/services/audit_service"
+	"ctb-cli/services/capsule_service"
+	"ctb-cli/services/config_service"
+	"ctb-cli/services/contact_service"
+	"ctb-cli/services/expiry_service"
+	"ctb-cli/services/filesystem_service"
+	"ctb-cli/services/fsck_service"
+	"ctb-cli/services/group_service"
+	"ctb-cli/services/invite_service"
+	"ctb-cli/services/key_service"
+	"ctb-cli/services/member_service"
+	"ctb-cli/services/name_service"
+	"ctb-cli/services/object_service"
+	"ctb-cli/services/offboard_service"
+	"ctb-cli/services/password_service"
+	"ctb-cli/services/share_service"
+)
+
+// Services holds the services of a repository. The private key of the user is set in the key store.
+type Services struct {
+	KeyStore   *key_service.KeyStoreDefault
+	Config     *config_service.ConfigService
+	Names      *name_service.Service
+	FileSystem *filesystem_service.FileSystem
+	Share      *share_service.Service
+	Fsck       *fsck_service.Service
+	Audit      *audit_service.Service
+	Member     *member_service.Service
+	Contact    *contact_service.Service
+	Group      *group_service.Service
+	Offboard   *offboard_service.Service
+	Expiry     *expiry_service.Service
+	Invite     *invite_service.Service
+	Password   *password_service.Service
+	Capsule    *capsule_service.Service
+}
+
+// New creates the services of the repository at repoPath, caching the plaintext of the files in cachePath.
+// The state kept on the device of the user, such as the pinned first owner of the repository, is stored in statePath.
+func New(repoPath string, cachePath string, statePath string) *Services {
+	cloudClient := cloud.NewClient("http://localhost:1323", 10*1024*1024)
+	//cloudClient := objectstorage.NewDummyClient()
+
+	// Create the repositories
+	keyRepository := repositories.NewKeyRepositoryFile(repoPath)
+	objectCacheRepository := repositories.NewObjectCacheRepository(cachePath)
+	objectRepository := repositories.NewObjectRepository(repoPath)
+	linkRepository := repositories.NewLinkRepository(repoPath)
+	vaultRepository := repositories.NewVaultRepositoryFile(repoPath)
+	auditRepository := repositories.NewAuditRepository(repoPath)
+	memberRepository := repositories.NewMemberRepository(repoPath)
+	contactRepository := repositories.NewContactRepository(repoPath)
+	groupRepository := repositories.NewGroupRepositoryFile(repoPath)
+	trustRepository := repositories.NewTrustRepository(statePath, repoPath)
+
+	// Create the services
+	s := &Services{}
+	keyStore := key_service.NewKeyStore(keyRepository, vaultRepository, groupRepository)
+	s.KeyStore = keyStore
+	s.Audit = audit_service.NewService(keyStore, auditRepository)
+	keyStore.SetAuditLogger(s.Audit)
+	keyStore.SetExpiryLog(s.Audit)
+	s.Config = config_service.New(repoPath)
+	s.Member = member_service.NewService(keyStore, keyRepository, vaultRepository, memberRepository, s.Audit)
+	// Resolve the paths stored on disk through the name service, in case the policy signed in the registry encrypts the names
+	nameService := name_service.NewService(keyStore, vaultRepository, s.Member)
+	s.Names = nameService
+	keyStore.SetNamePolicy(s.Member)
+	s.Config.SetPathResolver(nameService)
+	keyRepository.SetPathResolver(nameService)
+	objectRepository.SetPathResolver(nameService)
+	linkRepository.SetPathResolver(nameService)
+	vaultRepository.SetPathResolver(nameService)
+	// Keep the names out of the records stored outside the directories, and rename the entries when a name key is replaced
+	s.Audit.SetPathEncoder(nameService)
+	nameService.SetLinkRepository(linkRepository)
+	keyStore.SetNameRotator(nameService)
+	objectService := object_service.NewService(&objectCacheRepository, &objectRepository, cloudClient)
+	objectService.SetEncryptionPolicy(s.Config)
+	objectService.SetCompressionPolicy(s.Config)
+	s.Share = share_service.NewService(keyStore, linkRepository, vaultRepository, groupRepository, &objectService, s.Audit)
+	s.FileSystem = filesystem_service.NewFileSystem(keyStore, objectService, linkRepository, vaultRepository, *s.Config)
+	keyStore.SetKemKeyResolver(s.Member)
+	keyStore.SetMemberChecker(s.Member)
+	keyStore.SetHybridPolicy(s.Member)
+	s.Member.SetTrustRoot(trustRepository)
+	s.Audit.SetMemberLister(s.Member)
+	s.Audit.SetMemberChecker(s.Member)
+	s.Contact = contact_service.NewService(keyStore, contactRepository, s.Member)
+	s.Group = group_service.NewService(keyStore, groupRepository, s.Member, s.Audit)
+	s.Offboard = offboard_service.NewService(keyStore, keyRepository, vaultRepository, linkRepository, groupRepository, s.Group, s.Member, s.Contact, s.Audit)
+	s.Invite = invite_service.NewService(keyStore, memberRepository, s.Member, s.Share, s.Audit)
+	s.Invite.SetPathEncoder(nameService)
+	s.Password = password_service.NewService(keyStore, keyRepository, s.Share)
+	s.Capsule = capsule_service.NewService(keyStore, linkRepository, &objectService, s.Share, s.Audit)
+	s.Expiry = expiry_service.NewService(keyStore, keyRepository, vaultRepository, linkRepository, s.Audit, s.Audit)
+	s.Fsck = fsck_service.NewService(keyStore, keyRepository, vaultRepository, linkRepository, &objectRepository, &objectService, s.Config)
+	return s
+}
@@ -3,33 +3,50 @@ package share_service
 import (
 	"ctb-cli/core"
 	"ctb-cli/repositories"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInheritedAccess = errors.New("the access is inherited from a parent directory or a group, break the inheritance of the directory to remove it")
+	ErrNotDirectory    = errors.New("the path is not a directory")
 )
 
 type Service struct {
 	linkRepository  *repositories.LinkRepository
 	vaultRepository repositories.VaultRepository
+	groupRepository repositories.GroupRepository
 	objectService   core.ObjectService
 	keyService      core.KeyService
+	auditLogger     core.AuditLogger
 }
 
 func NewService(
 	keyService core.KeyService,
 	linkRepository *repositories.LinkRepository,
 	vaultRepository repositories.VaultRepository,
+	groupRepository repositories.GroupRepository,
 	objectService core.ObjectService,
+	auditLogger core.AuditLogger,
 ) *Service {
 	return &Service{
 		objectService:   objectService,
 		keyService:      keyService,
 		linkRepository:  linkRepository,
 		vaultRepository: vaultRepository,
+		groupRepository: groupRepository,
+		auditLogger:     auditLogger,
 	}
 }
 
 // ShareByPublicKey shares a file or directory located at the specified path with the given public key.
 // It retrieves the key ID associated with the path, decodes the provided public key, and then calls the Share method of the key service.
+// If expiresAt is not zero, the share expires at that time, otherwise it does not expire.
 // If any error occurs during the process, it is returned.
-func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string) error {
+func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string, expiresAt time.Time) error {
 	keyId, startVaultId, startVaultPath, err := s.GetKeyIdByPath(path)
 	if err != nil {
 		return err
@@ -42,8 +59,12 @@ func (s *Service) ShareByPublicKey(path string, publicKeyEncoded string) error {
 	if err != nil {
 		return err
 	}
+	err = s.keyService.SetShareExpiry(keyId, publicKeyEncoded, startVaultPath, expiresAt)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return s.auditLogger.Log(core.AuditShare, path, publicKeyEncoded, keyId)
 }
 
 // GetKeyIdByPath retrieves the key ID associated with the given path.
@@ -102,17 +123,180 @@ func (s *Service) GetAccessList(path string) (core.KeyAccessList, error) {
 	return s.keyService.GetKeyAccessList(keyId, startVaultId, startVaultPath)
 }
 
+// GetAccessTree retrieves the access lists of the file or directory at the specified path
+// and of every file and directory below it, in walk order.
+// If userId is not empty, only the access of that user is reported,
+// and only the paths the user has access to are listed.
+func (s *Service) GetAccessTree(path string, userId string) (core.AccessReport, error) {
+	if !s.linkRepository.IsValidPath(path) {
+		return nil, core.ErrInvalidPath
+	}
+	report := make(core.AccessReport, 0)
+	err := s.walkAccess(&report, path, userId)
+	return report, err
+}
+
+// walkAccess adds the access list of the path to the report and walks its sub files if it is a directory.
+func (s *Service) walkAccess(report *core.AccessReport, path string, userId string) error {
+	isDir := s.linkRepository.IsDir(path)
+	list, err := s.GetAccessList(path)
+	if err != nil {
+		return fmt.Errorf("cannot get the access list of %s: %v", path, err)
+	}
+	if userId != "" {
+		filtered := make(core.KeyAccessList, 0, 1)
+		for _, access := range list {
+			if access.PublicKey == userId {
+				filtered = append(filtered, access)
+			}
+		}
+		list = filtered
+	}
+	if userId == "" || len(list) > 0 {
+		*report = append(*report, core.PathAccess{Path: path, IsDir: isDir, Access: list})
+	}
+	if !isDir {
+		return nil
+	}
+	subFiles, err := s.linkRepository.GetSubFiles(path)
+	if err != nil {
+		return err
+	}
+	sort.Slice(subFiles, func(i, j int) bool { return subFiles[i].Name() < subFiles[j].Name() })
+	for _, sub := range subFiles {
+		if sub.Name() == ".meta" {
+			continue
+		}
+		if err := s.walkAccess(report, filepath.Join(path, sub.Name()), userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Unshare removes the sharing of a file or directory specified by the given path
-// with the public key provided. It returns an error if the operation fails.
+// with the public key provided. It returns an error if the operation fails,
+// or ErrInheritedAccess if the key is not shared directly with the public key but reached through a parent vault or a group.
 func (s *Service) Unshare(path string, publicKeyEncoded string) error {
-	keyId, _, _, err := s.GetKeyIdByPath(path)
+	keyId, startVaultId, startVaultPath, err := s.GetKeyIdByPath(path)
 	if err != nil {
 		return err
 	}
-	err = s.keyService.Unshare(keyId, publicKeyEncoded, path)
+	if hasAccess, inherited := s.keyService.GetHasAccessToKey(keyId, startVaultId, startVaultPath, publicKeyEncoded); hasAccess && inherited {
+		return ErrInheritedAccess
+	}
+	err = s.keyService.Unshare(keyId, publicKeyEncoded, startVaultPath)
 	if err != nil {
 		return err
 	}
 
+	return s.auditLogger.Log(core.AuditUnshare, path, publicKeyEncoded, keyId)
+}
+
+// BreakInheritance makes the directory at the specified path reachable only by the given recipients, user or group public keys:
+// the key of its vault is replaced by a key which is not stored in the parent vault and shared only with the recipients,
+// and the keys of the vaults of its sub directories are replaced, as the users losing access may have kept the old keys.
+// The user is always kept as a recipient. The keys shared directly with files and sub directories are kept.
+// If dryRun is true, the changes are only reported.
+func (s *Service) BreakInheritance(path string, recipients []string, dryRun bool) (core.InheritanceReport, error) {
+	path = filepath.Join(string(filepath.Separator), path)
+	if !s.linkRepository.IsValidPath(path) {
+		return core.InheritanceReport{}, core.ErrInvalidPath
+	}
+	if !s.linkRepository.IsDir(path) {
+		return core.InheritanceReport{}, ErrNotDirectory
+	}
+	userId, err := s.keyService.GetUserId()
+	if err != nil {
+		return core.InheritanceReport{}, err
+	}
+	if !contains(recipients, userId) {
+		recipients = append([]string{userId}, recipients...)
+	}
+	report := core.InheritanceReport{
+		Path:          path,
+		DryRun:        dryRun,
+		Recipients:    make(core.KeyAccessList, 0, len(recipients)),
+		LostAccess:    make(core.KeyAccessList, 0),
+		RotatedVaults: make([]string, 0),
+	}
+	for _, recipient := range recipients {
+		report.Recipients = append(report.Recipients, core.KeyAccess{PublicKey: recipient})
+	}
+	// Find the users who do not keep access
+	accessList, err := s.GetAccessList(path)
+	if err != nil {
+		return core.InheritanceReport{}, err
+	}
+	for _, access := range accessList {
+		keeps, err := s.keepsAccess(access.PublicKey, recipients)
+		if err != nil {
+			return core.InheritanceReport{}, err
+		}
+		if !keeps {
+			report.LostAccess = append(report.LostAccess, access)
+		}
+	}
+	if err := s.listSubVaults(&report.RotatedVaults, path); err != nil {
+		return core.InheritanceReport{}, err
+	}
+	if dryRun {
+		return report, nil
+	}
+	if report.KeyId, err = s.keyService.BreakInheritance(path, recipients); err != nil {
+		return core.InheritanceReport{}, err
+	}
+	for _, subPath := range report.RotatedVaults {
+		if _, err := s.keyService.RotateVaultKey(subPath); err != nil {
+			return core.InheritanceReport{}, fmt.Errorf("the inheritance is broken but the key of the vault %s cannot be replaced: %v", subPath, err)
+		}
+	}
+	return report, nil
+}
+
+// keepsAccess returns true if the user is one of the recipients or a member of a recipient group.
+func (s *Service) keepsAccess(userId string, recipients []string) (bool, error) {
+	if contains(recipients, userId) {
+		return true, nil
+	}
+	groups, err := s.groupRepository.ListUserGroups(userId)
+	if err != nil {
+		return false, err
+	}
+	for _, groupId := range groups {
+		if contains(recipients, groupId) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listSubVaults adds the paths of the sub directories of the path to the list, parents first.
+func (s *Service) listSubVaults(list *[]string, path string) error {
+	subFiles, err := s.linkRepository.GetSubFiles(path)
+	if err != nil {
+		return err
+	}
+	sort.Slice(subFiles, func(i, j int) bool { return subFiles[i].Name() < subFiles[j].Name() })
+	for _, sub := range subFiles {
+		if !sub.IsDir() || sub.Name() == ".meta" {
+			continue
+		}
+		subPath := filepath.Join(path, sub.Name())
+		*list = append(*list, subPath)
+		if err := s.listSubVaults(list, subPath); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// contains returns true if the list contains the value.
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
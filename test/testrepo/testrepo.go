@@ -0,0 +1,144 @@
+// Package testrepo creates the repositories the tests of the services and of bridgeguard run against.
+package testrepo
+
+import (
+	"ctb-cli/bridgeguard"
+	"ctb-cli/core"
+	"ctb-cli/services"
+	"ctb-cli/services/key_service"
+	"path/filepath"
+	"testing"
+)
+
+// Repo is a repository created in a temporary folder for a test.
+type Repo struct {
+	Path      string // the folder of the repository
+	CachePath string // the folder the plaintext of the files is cached in
+	OwnerKey  string // the encoded private key of the first owner
+}
+
+// User is a user of the repository, with the services opened with the private key of the user.
+type User struct {
+	*services.Services
+	Id  string // the public key of the user
+	Key string // the encoded private key of the user
+}
+
+// NewUserKey generates a user key and returns its encoding.
+// Keys are encoded in 44 characters, the keys whose private or public encoding is shorter are skipped.
+func NewUserKey(t *testing.T) string {
+	t.Helper()
+	for {
+		key, err := key_service.NewKeyStore(nil, nil, nil).GenerateUserKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := key.ToPublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded := key.Unsafe().String(); len(encoded) == 44 && len(publicKey.String()) == 44 {
+			return encoded
+		}
+	}
+}
+
+// New initializes a repository owned by a new user, with the directories created in order.
+func New(t *testing.T, dirs ...string) Repo {
+	t.Helper()
+	return newRepo(t, bridgeguard.Init, dirs)
+}
+
+// NewWithEncryptedNames initializes a repository as New does, storing the names of its files and directories encrypted.
+func NewWithEncryptedNames(t *testing.T, dirs ...string) Repo {
+	t.Helper()
+	return newRepo(t, bridgeguard.InitWithEncryptedNames, dirs)
+}
+
+// newRepo initializes a repository with the init function of bridgeguard and creates the directories.
+func newRepo(t *testing.T, init func(string, string, string) (*bridgeguard.Repo, error), dirs []string) Repo {
+	t.Helper()
+	dir := t.TempDir()
+	r := Repo{
+		Path:      filepath.Join(dir, "repo"),
+		CachePath: filepath.Join(dir, "cache"),
+		OwnerKey:  NewUserKey(t),
+	}
+	repo, err := init(r.Path, r.CachePath, r.OwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	for _, name := range dirs {
+		if err := repo.Mkdir(name, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// OpenFS opens the repository through bridgeguard as its first owner, it is closed at the end of the test.
+func (r Repo) OpenFS(t *testing.T) *bridgeguard.Repo {
+	t.Helper()
+	repo, err := bridgeguard.Open(r.Path, r.CachePath, r.OwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	return repo
+}
+
+// WriteFile writes the content of the named file of the repository as its first owner.
+func (r Repo) WriteFile(t *testing.T, name string, content []byte) {
+	t.Helper()
+	repo, err := bridgeguard.Open(r.Path, r.CachePath, r.OwnerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	file, err := repo.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Open opens the services of the repository with the encoded private key, the user does not need to be a member.
+func (r Repo) Open(t *testing.T, encodedKey string) User {
+	t.Helper()
+	privateKey, err := core.NewPrivateKeyFromEncoded(encodedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := services.New(r.Path, r.CachePath, r.CachePath)
+	s.KeyStore.SetPrivateKey(privateKey)
+	id, err := s.KeyStore.GetUserId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return User{Services: s, Id: id, Key: encodedKey}
+}
+
+// Owner opens the services of the repository with the private key of the first owner.
+func (r Repo) Owner(t *testing.T) User {
+	t.Helper()
+	return r.Open(t, r.OwnerKey)
+}
+
+// Join requests to join the repository with a new user, approved by the approver with the role as a member of the directory.
+func (r Repo) Join(t *testing.T, approver User, role core.MemberRole, path string) User {
+	t.Helper()
+	user := r.Open(t, NewUserKey(t))
+	if err := user.Member.RequestJoin(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := approver.Member.Approve(user.Id, role, path); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}